@@ -2,13 +2,21 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"strings"
+	"time"
 
 	"flag"
 
+	"github.com/modeneis/coind/src/providers/sky"
 	"github.com/modeneis/coind/src/server/api"
+	"github.com/modeneis/coind/src/server/loadgen"
+	"github.com/modeneis/coind/src/server/model_server"
 	"github.com/modeneis/coind/src/server/rpc"
+	"github.com/modeneis/coind/src/server/scenario"
 	"github.com/modeneis/coind/src/server/utils"
 )
 
@@ -24,9 +32,98 @@ func run() error {
 	certFile := flag.String("cert", "rpc.cert", "btcd rpc cert")
 	address := flag.String("address", "127.0.0.1:8334", "btcd listening address")
 	httpAPIAddress := flag.String("api", "127.0.0.1:4122", "http api listening address")
+	scenarioFile := flag.String("scenario", "", "yaml scenario to run once the servers are started")
+	loadFile := flag.String("loadgen", "", "yaml deposit load to generate once the servers are started")
+	mine := flag.Bool("mine", false, "mine blocks automatically, otherwise miners start paused")
+	blockTimes := flag.String("block-time", "", "block time overrides, e.g. SKY=10s,WAVES=60s")
+	blockJitter := flag.Float64("block-jitter", model_server.DefaultBlockJitter, "fraction of the block time a block interval may vary")
+	miningScale := flag.Float64("mining-scale", 1, "speeds up every block time by this factor")
+	clockMode := flag.String("clock", "", "clock of the block timestamps and the miners: real, fixed or scaled, defaults to real, or fixed with -seed")
+	clockStart := flag.String("clock-start", "", "RFC3339 time the clock starts from, defaults to now, or 2018-01-01 with -seed")
+	seed := flag.Int64("seed", 0, "generate the same hashes, ids and timestamps on every run, out of built-in block templates")
+	clockScale := flag.Float64("clock-scale", 1, "how many times faster than the wall clock a scaled clock runs")
+	ledgerFile := flag.String("ledger", "", "file the deposit ledger is appended to and reloaded from, kept in memory only if empty")
+	idempotencyWindow := flag.Duration("idempotency-window", model_server.DefaultIdempotencyWindow, "how long the receipts of deposits submitted with an idempotency key or a client id are replayed, 0 disables replays")
+	walletDir := flag.String("wallet-dir", "", "directory the wallets of the emulated skycoin wallet API are saved to and loaded from, kept in memory only if empty")
+	walletSeed := flag.String("wallet-seed", model_server.DefaultWalletSeed, "seed of the wallets withdrawals are sent from and getnewaddress derives from, any phrase, BTC and ETH take it as the words of a BIP39 mnemonic without checking them")
 
 	flag.Parse()
 
+	seeded := false
+	flag.Visit(func(f *flag.Flag) {
+		seeded = seeded || f.Name == "seed"
+	})
+	if seeded {
+		model_server.Seed(*seed)
+	}
+
+	if *walletSeed == "" {
+		err := fmt.Errorf("-wallet-seed is required")
+		fmt.Println(err)
+		return err
+	}
+	model_server.SetWalletSeed(*walletSeed)
+
+	if err := setClock(*clockMode, *clockStart, *clockScale, seeded); err != nil {
+		fmt.Println("failed to set the clock:", err)
+		return err
+	}
+
+	if *ledgerFile != "" {
+		if err := model_server.DefaultLedger.Open(*ledgerFile); err != nil {
+			fmt.Println("failed to open the ledger:", err)
+			return err
+		}
+		defer func() {
+			if err := model_server.DefaultLedger.Close(); err != nil {
+				fmt.Println("failed to close the ledger:", err)
+			}
+		}()
+	}
+
+	if *walletDir != "" {
+		if err := sky.DefaultWallets.Open(*walletDir); err != nil {
+			fmt.Println("failed to open the wallets:", err)
+			return err
+		}
+	}
+
+	if err := model_server.DefaultIdempotency.SetWindow(*idempotencyWindow); err != nil {
+		fmt.Println("failed to set the idempotency window:", err)
+		return err
+	}
+
+	if err := startMining(*mine, *blockTimes, *blockJitter, *miningScale); err != nil {
+		fmt.Println("failed to start mining:", err)
+		return err
+	}
+
+	var script *scenario.Scenario
+	if *scenarioFile != "" {
+		data, err := ioutil.ReadFile(*scenarioFile)
+		if err != nil {
+			fmt.Println("failed to read scenario:", err)
+			return err
+		}
+		if script, err = scenario.Parse(data); err != nil {
+			fmt.Println("failed to load scenario:", err)
+			return err
+		}
+	}
+
+	var load *loadgen.Config
+	if *loadFile != "" {
+		data, err := ioutil.ReadFile(*loadFile)
+		if err != nil {
+			fmt.Println("failed to read load:", err)
+			return err
+		}
+		if load, err = loadgen.Parse(data); err != nil {
+			fmt.Println("failed to load deposit load:", err)
+			return err
+		}
+	}
+
 	// Get a channel that will be closed when a shutdown signal has been
 	// triggered either from an OS signal such as SIGINT (Ctrl+C) or from
 	// another subsystem such as the RPC server.
@@ -66,9 +163,89 @@ func run() error {
 		}
 	}()
 
+	if script != nil {
+		go func() {
+			run := scenario.Execute(script)
+			if run.Status != scenario.StatusPassed {
+				fmt.Printf("Scenario %s %s: %s\n", run.Name, run.Status, run.Error)
+				return
+			}
+			fmt.Printf("Scenario %s %s\n", run.Name, run.Status)
+		}()
+	}
+
+	if load != nil {
+		go func() {
+			report, err := loadgen.Run(*load)
+			if err != nil {
+				fmt.Println("Load failed:", err)
+				return
+			}
+			data, _ := json.MarshalIndent(report, "", "  ")
+			fmt.Printf("Load %s:\n%s\n", report.Status, data)
+		}()
+	}
+
 	// Wait until the interrupt signal is received from an OS signal or
 	// shutdown is requested through one of the subsystems such as the RPC
 	// server.
 	<-interruptedChan
 	return nil
 }
+
+// setClock sets the mode of the clock, starting from start if it is not empty.
+// A seeded run defaults to a fixed clock starting at the SeedEpoch.
+func setClock(mode, start string, scale float64, seeded bool) error {
+	if mode == "" {
+		mode = model_server.ClockReal
+		if seeded {
+			mode = model_server.ClockFixed
+		}
+	}
+
+	var t time.Time
+	if start == "" && seeded {
+		t = model_server.SeedEpoch
+	}
+	if start != "" {
+		var err error
+		if t, err = time.Parse(time.RFC3339, start); err != nil {
+			return fmt.Errorf("invalid clock start %q: %v", start, err)
+		}
+	}
+	return model_server.DefaultClock.Set(mode, t, scale)
+}
+
+// startMining starts a miner for each provider, with the block times of
+// overrides, a comma separated list of COIN=duration
+func startMining(mine bool, overrides string, jitter, scale float64) error {
+	blockTimes := make(map[string]time.Duration)
+	for _, override := range strings.Split(overrides, ",") {
+		if override == "" {
+			continue
+		}
+		parts := strings.SplitN(override, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid block time %q", override)
+		}
+		blockTime, err := time.ParseDuration(parts[1])
+		if err != nil {
+			return fmt.Errorf("invalid block time %q: %v", override, err)
+		}
+		blockTimes[strings.ToUpper(parts[0])] = blockTime
+	}
+
+	for coinType := range model_server.GetProviders() {
+		config := model_server.DefaultMinerConfig(coinType)
+		if blockTime, ok := blockTimes[coinType]; ok {
+			config.BlockTime = blockTime
+		}
+		config.Jitter = jitter
+		config.Scale = scale
+		config.Paused = !mine
+		if err := model_server.StartMining(coinType, config); err != nil {
+			return err
+		}
+	}
+	return nil
+}
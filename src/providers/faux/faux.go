@@ -19,8 +19,24 @@ func (p Provider) GetType() string {
 	return "Faux"
 }
 
-func (p *Provider) CreateFakeBlock(deposit model_server.Deposit) (retBlocks interface{}, err error) {
-	return retBlocks, err
+func (p *Provider) CreateFakeBlock(deposit model_server.Deposit) (receipt model_server.Receipt, retBlocks interface{}, err error) {
+	return receipt, retBlocks, err
+}
+
+func (p *Provider) AddToMempool(deposit model_server.Deposit) (tx interface{}, err error) {
+	return tx, nil
+}
+
+func (p *Provider) MineBlock() (blocks interface{}, err error) {
+	return blocks, nil
+}
+
+func (p *Provider) Reorg(reorg model_server.Reorg) (tip interface{}, err error) {
+	return tip, nil
+}
+
+func (p *Provider) DoubleSpend(doubleSpend model_server.DoubleSpend) (tx interface{}, err error) {
+	return tx, nil
 }
 
 func (p *Provider) GetBlock(hash string) (block interface{}, err error) {
@@ -35,6 +51,57 @@ func (p *Provider) GetGetBlockHash(tx string) (block interface{}, err error) {
 	return block, nil
 }
 
+func (p *Provider) GetTransaction(txid string) (tx interface{}, err error) {
+	return tx, nil
+}
+
+func (p *Provider) GetTransactionStatus(txid string) (status model_server.TxStatus, err error) {
+	return status, nil
+}
+
 func (p *Provider) GetBlockCount() (count int32) {
 	return count
 }
+
+func (p *Provider) GetBalance(address string) (balance interface{}, err error) {
+	return balance, nil
+}
+
+func (p *Provider) GetOutputs(address string) (outputs interface{}, err error) {
+	return outputs, nil
+}
+
+func (p *Provider) GetAddressTransactions(address string, offset, limit int) (txs interface{}, err error) {
+	return txs, nil
+}
+
+func (p *Provider) IssueAsset(asset model_server.Asset) (details interface{}, err error) {
+	return details, nil
+}
+
+func (p *Provider) GetAsset(assetID string) (details interface{}, err error) {
+	return details, nil
+}
+
+func (p *Provider) GetAssetBalances(address, assetID string) (balances interface{}, err error) {
+	return balances, nil
+}
+
+func (p *Provider) SendToAddress(withdrawal model_server.Withdrawal) (tx interface{}, err error) {
+	return tx, nil
+}
+
+func (p *Provider) SendRawTransaction(rawTx string) (tx interface{}, err error) {
+	return tx, nil
+}
+
+func (p *Provider) Reset() {
+}
+
+func (p *Provider) Snapshot() (snapshot interface{}) {
+	return snapshot
+}
+
+func (p *Provider) Restore(snapshot interface{}) error {
+	return nil
+}
@@ -0,0 +1,230 @@
+package sky
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/skycoin/skycoin/src/util/droplet"
+	"github.com/skycoin/skycoin/src/util/fee"
+	"github.com/skycoin/skycoin/src/visor"
+
+	"github.com/modeneis/coind/src/server/address"
+	"github.com/modeneis/coind/src/server/model_server"
+)
+
+// FundingAddress is the address of the simulated wallet paying every fake
+// deposit. Its change outputs are indexed like the deposits.
+var FundingAddress = address.NewSkycoin([]byte("coind sky funding wallet"))
+
+const (
+	// BurnFactor is the fee of a transaction: a BurnFactor-th of its input
+	// hours, rounded up, is burned
+	BurnFactor = fee.BurnFactor
+
+	// premineCoins and premineHours are the smallest value of the pre-mined
+	// outputs the funding wallet tops up from when its change runs short.
+	// Pre-mined outputs predate the fake chain and are not indexed.
+	premineCoins = 1000000 * droplet.Multiplier
+	premineHours = 1000000000
+
+	// padCoins is paid back to the funding wallet by each output before the
+	// deposit output
+	padCoins = droplet.Multiplier
+)
+
+// fundingOutput is an output spent by the funding wallet
+type fundingOutput struct {
+	hash  string
+	coins int64
+	hours uint64
+}
+
+// newFakeTx builds a transaction from the funding wallet paying deposit at
+// output deposit.N, with the change back to the wallet as its last output.
+// Outputs before the deposit pay padCoins to the wallet. Its inputs are the
+// oldest unspent outputs of the wallet confirmed at maxHeight or below, topped
+// up with a pre-mined output, their hours accrued at the time of the block at
+// maxHeight. Must be called with the store locked.
+func (p *Provider) newFakeTx(deposit model_server.Deposit, maxHeight int64) (fakeTx, error) {
+	return p.fundFakeTx(deposit, nil, maxHeight)
+}
+
+// conflictingTx returns a transaction spending the inputs of original, topped
+// up if need be, paying deposit instead, on top of the block at maxHeight.
+// Must be called with the store locked.
+func (p *Provider) conflictingTx(original fakeTx, deposit model_server.Deposit, maxHeight int64) (fakeTx, error) {
+	return p.fundFakeTx(deposit, p.fundingInputs(original.tx, maxHeight), maxHeight)
+}
+
+// fundFakeTx builds the transaction paying deposit out of the inputs in, then
+// out of the wallet outputs confirmed at maxHeight or below, then out of a
+// pre-mined output, until they cover the coins and the hours paid along with
+// the fee. The inputs are marked as spent right away so that the next
+// transaction does not pick them. Must be called with the store locked.
+func (p *Provider) fundFakeTx(deposit model_server.Deposit, in []fundingOutput, maxHeight int64) (fakeTx, error) {
+	// the deposit was validated, its amount is whole droplets
+	droplets, _ := deposit.Units(p.GetType())
+	if droplets > math.MaxInt64-padCoins*int64(deposit.N) {
+		return fakeTx{}, fmt.Errorf("amount %d is too large", droplets)
+	}
+	coins := droplets + padCoins*int64(deposit.N)
+
+	var inCoins int64
+	var inHours uint64
+	picked := make(map[string]bool, len(in))
+	for _, out := range in {
+		inCoins += out.coins
+		inHours += out.hours
+		picked[out.hash] = true
+	}
+	funded := func() bool {
+		// a transaction must burn at least one hour
+		return inCoins >= coins && inHours > 0 && inHours-fee.RequiredFee(inHours) >= deposit.Hours
+	}
+
+	store := p.DefaultBlockStore
+	spendTime := store.headTime(maxHeight)
+	for _, out := range store.Addresses.Outputs(FundingAddress) {
+		if funded() {
+			break
+		}
+		if out.Spent() || out.Height == 0 || out.Height > maxHeight || picked[out.Hash] {
+			continue
+		}
+		hours := calculatedHours(out, spendTime)
+		in = append(in, fundingOutput{hash: out.Hash, coins: out.Amount, hours: hours})
+		inCoins += out.Amount
+		inHours += hours
+	}
+	if !funded() {
+		premine := fundingOutput{hash: newHash(), coins: premineCoins, hours: premineHours}
+		if short := coins - inCoins; short > premine.coins {
+			premine.coins = short
+		}
+		if short := BurnFactor * deposit.Hours; short > premine.hours {
+			premine.hours = short
+		}
+		in = append(in, premine)
+	}
+
+	return p.buildFakeTx(deposit, in, droplets)
+}
+
+// buildFakeTx builds the transaction spending in and paying droplets to
+// deposit, see fundFakeTx. It fails if the transaction would not burn enough
+// coin hours to be accepted by a node. Must be called with the store locked.
+func (p *Provider) buildFakeTx(deposit model_server.Deposit, in []fundingOutput, droplets int64) (fakeTx, error) {
+	tx := visor.ReadableTransaction{Hash: deposit.Tx, InnerHash: newHash()}
+	if tx.Hash == "" {
+		tx.Hash = newHash()
+	}
+
+	var inCoins int64
+	var inHours uint64
+	for _, out := range in {
+		tx.In = append(tx.In, out.hash)
+		tx.Sigs = append(tx.Sigs, newSig())
+		inCoins += out.coins
+		inHours += out.hours
+	}
+
+	for n := uint32(0); n < deposit.N; n++ {
+		tx.Out = append(tx.Out, newOutput(FundingAddress, padCoins, 0))
+	}
+	out := newOutput(deposit.Address, droplets, deposit.Hours)
+	tx.Out = append(tx.Out, out)
+
+	changeCoins := inCoins - droplets - padCoins*int64(deposit.N)
+	changeHours := inHours - fee.RequiredFee(inHours) - deposit.Hours
+	if changeCoins > 0 {
+		tx.Out = append(tx.Out, newOutput(FundingAddress, changeCoins, changeHours))
+	}
+	tx.Length = txLength(len(tx.In), len(tx.Out))
+
+	var outHours uint64
+	for _, o := range tx.Out {
+		outHours += o.Hours
+	}
+	if outHours > inHours {
+		return fakeTx{}, fee.ErrTxnInsufficientCoinHours
+	}
+	if err := fee.VerifyTransactionFeeForHours(outHours, inHours-outHours); err != nil {
+		return fakeTx{}, err
+	}
+
+	index := p.DefaultBlockStore.Addresses
+	for _, hash := range tx.In {
+		// pre-mined inputs are not indexed
+		_ = index.SpendOutput(hash, tx.Hash, 0, "", 0)
+	}
+
+	deposit.Amount = out.Coins
+	deposit.Value = 0
+	return fakeTx{tx: tx, uxID: out.Hash, deposit: deposit}, nil
+}
+
+// fundingInputs returns the outputs spent by tx, the indexed ones with their
+// hours at the time of the block at maxHeight, the pre-mined ones valued from
+// what tx pays and burns. Must be called with the store locked.
+func (p *Provider) fundingInputs(tx visor.ReadableTransaction, maxHeight int64) []fundingOutput {
+	var outCoins, indexedCoins int64
+	var outHours, indexedHours uint64
+	for _, out := range tx.Out {
+		coins, _ := droplet.FromString(out.Coins)
+		outCoins += int64(coins)
+		outHours += out.Hours
+	}
+
+	store := p.DefaultBlockStore
+	spendTime := store.headTime(maxHeight)
+	in := make([]fundingOutput, 0, len(tx.In))
+	var premined []int
+	for _, hash := range tx.In {
+		out, ok := store.Addresses.Output(hash)
+		if !ok {
+			premined = append(premined, len(in))
+			in = append(in, fundingOutput{hash: hash})
+			continue
+		}
+		hours := calculatedHours(out, spendTime)
+		in = append(in, fundingOutput{hash: hash, coins: out.Amount, hours: hours})
+		indexedCoins += out.Amount
+		indexedHours += hours
+	}
+
+	// the first pre-mined input makes up for the rest, burning as many hours
+	// as are kept
+	if len(premined) > 0 {
+		first := &in[premined[0]]
+		if outCoins > indexedCoins {
+			first.coins = outCoins - indexedCoins
+		}
+		if hours := BurnFactor * outHours; hours > indexedHours {
+			first.hours = hours - indexedHours
+		}
+	}
+	return in
+}
+
+// newOutput returns an output with a new uxid
+func newOutput(address string, droplets int64, hours uint64) visor.ReadableTransactionOutput {
+	coins, _ := droplet.ToString(uint64(droplets))
+	return visor.ReadableTransactionOutput{
+		Hash:    newHash(),
+		Address: address,
+		Coins:   coins,
+		Hours:   hours,
+	}
+}
+
+// newSig returns a random signature, 65 bytes in hex
+func newSig() string {
+	return newHash() + newHash() + "00"
+}
+
+// txLength is the size of an encoded transaction: its length, type and inner
+// hash, then its signatures, inputs and outputs, each list prefixed with its
+// length
+func txLength(in, out int) uint32 {
+	return uint32(4 + 1 + 32 + 4 + 65*in + 4 + 32*in + 4 + 37*out)
+}
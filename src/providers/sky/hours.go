@@ -0,0 +1,77 @@
+package sky
+
+import (
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/visor"
+	"github.com/skycoin/skycoin/src/wallet"
+
+	"github.com/modeneis/coind/src/server/model_server"
+)
+
+// Coin hours accrue like on a skycoin node: a confirmed output holds the hours
+// it was created with, plus one hour per coin held for an hour since its block,
+// counted at the time of the head block. The virtual clock only accrues hours
+// once a block is mined after it moves. Unconfirmed outputs are created at the
+// head and have not accrued any hours yet.
+
+// blockHead returns the header of the block at height seq. Must be called with
+// the store locked.
+func (s *BlockStoreSky) blockHead(seq int64) (visor.ReadableBlockHeader, bool) {
+	blocks, ok := s.HashBlocks[s.BlockHashes[seq]]
+	if !ok || len(blocks.Blocks) == 0 {
+		return visor.ReadableBlockHeader{}, false
+	}
+	return blocks.Blocks[0].Head, true
+}
+
+// headTime returns the time of the block at height seq, 0 if there is none.
+// Must be called with the store locked.
+func (s *BlockStoreSky) headTime(seq int64) uint64 {
+	head, _ := s.blockHead(seq)
+	return head.Time
+}
+
+// calculatedHours returns the hours of out at the time t of a head block, see
+// coin.UxOut.CoinHours
+func calculatedHours(out model_server.UxOut, t uint64) uint64 {
+	if out.Height == 0 || t < uint64(out.Time) {
+		return out.Hours
+	}
+
+	ux := coin.UxOut{
+		Head: coin.UxHead{Time: uint64(out.Time), BkSeq: uint64(out.Height)},
+		Body: coin.UxBody{Coins: uint64(out.Amount), Hours: out.Hours},
+	}
+	hours, err := ux.CoinHours(t)
+	if err != nil {
+		// like the node, overflowing hours are reported as 0
+		return 0
+	}
+	return hours
+}
+
+// balance sums the unspent outputs of addresses like skycoin's /balance, with
+// their hours at the time of the tip. The confirmed balance still counts the
+// outputs spent by unconfirmed transactions, the predicted balance counts the
+// unconfirmed outputs instead. Must be called with the store locked.
+func (p *Provider) balance(addresses ...string) wallet.BalancePair {
+	store := p.DefaultBlockStore
+	headTime := store.headTime(int64(store.BestBlockHeight))
+
+	var bal wallet.BalancePair
+	for _, address := range addresses {
+		for _, out := range store.Addresses.Outputs(address) {
+			hours := calculatedHours(out, headTime)
+
+			if out.Height > 0 && out.SpentHeight == 0 {
+				bal.Confirmed.Coins += uint64(out.Amount)
+				bal.Confirmed.Hours += hours
+			}
+			if !out.Spent() {
+				bal.Predicted.Coins += uint64(out.Amount)
+				bal.Predicted.Hours += hours
+			}
+		}
+	}
+	return bal
+}
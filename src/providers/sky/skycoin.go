@@ -1,15 +1,17 @@
 package sky
 
 import (
-	"crypto/rand"
-	"strconv"
+	"encoding/json"
+	"fmt"
 	"sync"
+	"time"
 
 	"github.com/btcsuite/btcd/btcjson"
 	"github.com/skycoin/skycoin/src/api/webrpc"
 	"github.com/skycoin/skycoin/src/cipher"
 	"github.com/skycoin/skycoin/src/coin"
 	"github.com/skycoin/skycoin/src/gui"
+	"github.com/skycoin/skycoin/src/util/droplet"
 	"github.com/skycoin/skycoin/src/visor"
 
 	"github.com/modeneis/coind/src/server/model_server"
@@ -23,6 +25,11 @@ type BlockStoreSky struct {
 	BlockTX         map[string]string
 	HashBlocks      map[string]*visor.ReadableBlocks
 	NextHash        string
+	Addresses       *model_server.AddressIndex
+
+	mempool  []fakeTx          // unconfirmed transactions, oldest first
+	orphaned map[string]string // block hash of transactions dropped by a reorg
+	uxIDs    map[string]string // uxid of the deposit output of each fake transaction
 }
 
 // New creates a new fake SKY, and sets up important connection details.
@@ -46,11 +53,109 @@ type Provider struct {
 }
 
 func (p *Provider) Start() {
-	p.DefaultBlockStore = &BlockStoreSky{
+	p.DefaultBlockStore = newBlockStoreSky()
+}
+
+func newBlockStoreSky() *BlockStoreSky {
+	return &BlockStoreSky{
 		BlockHashes: make(map[int64]string),
 		HashBlocks:  make(map[string]*visor.ReadableBlocks),
 		BlockTX:     make(map[string]string),
+		Addresses:   model_server.NewAddressIndex(),
+		orphaned:    make(map[string]string),
+		uxIDs:       make(map[string]string),
+	}
+}
+
+// clone returns a deep copy of the store data. Must be called with the store locked.
+func (s *BlockStoreSky) clone() *BlockStoreSky {
+	c := newBlockStoreSky()
+	c.BestBlockHeight = s.BestBlockHeight
+	c.NextHash = s.NextHash
+	for seq, hash := range s.BlockHashes {
+		c.BlockHashes[seq] = hash
+	}
+	for hash, tx := range s.BlockTX {
+		c.BlockTX[hash] = tx
+	}
+	for hash, blocks := range s.HashBlocks {
+		c.HashBlocks[hash] = copyReadableBlocks(blocks)
+	}
+	c.Addresses = s.Addresses.Clone()
+	c.mempool = append([]fakeTx(nil), s.mempool...)
+	for txid, hash := range s.orphaned {
+		c.orphaned[txid] = hash
+	}
+	for txid, uxID := range s.uxIDs {
+		c.uxIDs[txid] = uxID
+	}
+	return c
+}
+
+// load replaces the store data with the data of other. Must be called with the store locked.
+func (s *BlockStoreSky) load(other *BlockStoreSky) {
+	s.BestBlockHeight = other.BestBlockHeight
+	s.BlockHashes = other.BlockHashes
+	s.BlockTX = other.BlockTX
+	s.HashBlocks = other.HashBlocks
+	s.NextHash = other.NextHash
+	s.Addresses = other.Addresses
+	s.mempool = other.mempool
+	s.orphaned = other.orphaned
+	s.uxIDs = other.uxIDs
+}
+
+// copyReadableBlocks returns a deep copy of blocks
+func copyReadableBlocks(blocks *visor.ReadableBlocks) *visor.ReadableBlocks {
+	if blocks == nil {
+		return nil
+	}
+
+	c := &visor.ReadableBlocks{
+		Blocks: make([]visor.ReadableBlock, len(blocks.Blocks)),
 	}
+	for i, block := range blocks.Blocks {
+		txns := make([]visor.ReadableTransaction, len(block.Body.Transactions))
+		for j, tx := range block.Body.Transactions {
+			tx.Sigs = append([]string(nil), tx.Sigs...)
+			tx.In = append([]string(nil), tx.In...)
+			tx.Out = append([]visor.ReadableTransactionOutput(nil), tx.Out...)
+			txns[j] = tx
+		}
+		block.Body.Transactions = txns
+		c.Blocks[i] = block
+	}
+	return c
+}
+
+// Reset drops every fake block, going back to an empty chain
+func (p *Provider) Reset() {
+	p.DefaultBlockStore.Lock()
+	defer p.DefaultBlockStore.Unlock()
+
+	p.DefaultBlockStore.load(newBlockStoreSky())
+}
+
+// Snapshot returns a copy of the block store
+func (p *Provider) Snapshot() (snapshot interface{}) {
+	p.DefaultBlockStore.RLock()
+	defer p.DefaultBlockStore.RUnlock()
+
+	return p.DefaultBlockStore.clone()
+}
+
+// Restore replaces the block store with a copy of a snapshot taken by Snapshot
+func (p *Provider) Restore(snapshot interface{}) error {
+	store, ok := snapshot.(*BlockStoreSky)
+	if !ok {
+		return fmt.Errorf("invalid %s snapshot %T", p.GetType(), snapshot)
+	}
+
+	p.DefaultBlockStore.Lock()
+	defer p.DefaultBlockStore.Unlock()
+
+	p.DefaultBlockStore.load(store.clone())
+	return nil
 }
 
 // Name is the name used to retrieve this provider later.
@@ -63,82 +168,605 @@ func (p *Provider) GetType() string {
 	return "SKY"
 }
 
-func (p *Provider) CreateFakeBlock(deposit model_server.Deposit) (retBlocks interface{}, err error) {
+// fakeTx is a transaction created for a deposit, along with the uxid of the
+// deposit output. Withdrawals have no uxid. Received is when it entered the
+// mempool.
+type fakeTx struct {
+	tx       visor.ReadableTransaction
+	uxID     string
+	deposit  model_server.Deposit
+	received time.Time
+}
+
+// CreateFakeBlock appends a block confirming deposit to the chain, and
+// returns the receipt of the deposit along with the block. A deposit for a
+// later height is pending until the block at that height is connected, and the
+// pending transaction is returned. A deposit for a past height is added to the
+// block at that height, which is returned.
+func (p *Provider) CreateFakeBlock(deposit model_server.Deposit) (receipt model_server.Receipt, retBlocks interface{}, err error) {
+	template, err := p.template()
+	if err != nil {
+		return receipt, nil, err
+	}
+
 	p.DefaultBlockStore.Lock()
-	var blocks *visor.ReadableBlocks
-	defer func() {
-		//DefaultBlockStore.BestBlockHeight++
-		hash := blocks.Blocks[0].Head.BlockHash
-		tx := blocks.Blocks[0].Head.BodyHash
+	defer p.DefaultBlockStore.Unlock()
+
+	if err = p.DefaultBlockStore.checkNewTx(deposit.Tx); err != nil {
+		return receipt, nil, err
+	}
+
+	tip := int64(p.DefaultBlockStore.BestBlockHeight)
+	maxHeight := tip
+	if deposit.Height != 0 && deposit.Height <= tip {
+		maxHeight = deposit.Height - 1
+	}
+	fake, err := p.newFakeTx(deposit, maxHeight)
+	if err != nil {
+		return receipt, nil, err
+	}
+
+	switch {
+	case deposit.Height == 0 || deposit.Height == tip+1:
+		blocks := p.connectBlock(template, []fakeTx{fake}, nil)
+		return p.receipt(fake, blocks.Blocks[0].Head), blocks, nil
+	case deposit.Height > tip:
+		p.addToMempool(fake)
+		return p.receipt(fake, visor.ReadableBlockHeader{}), &fake.tx, nil
+	default:
+		blocks := p.backfill(&fake)
+		return p.receipt(fake, blocks.Blocks[0].Head), blocks, nil
+	}
+}
+
+// receipt returns the receipt of the deposit paid by fake, confirmed by the
+// block with the given header, or pending if it is empty
+func (p *Provider) receipt(fake fakeTx, head visor.ReadableBlockHeader) model_server.Receipt {
+	receipt := model_server.Receipt{
+		CoinType:  p.GetType(),
+		TxID:      fake.tx.Hash,
+		N:         fake.deposit.N,
+		Address:   fake.deposit.Address,
+		Status:    model_server.TxPending,
+		Timestamp: model_server.Now().Unix(),
+	}
+	if droplets, err := droplet.FromString(fake.deposit.Amount); err == nil {
+		receipt.Amount = model_server.FormatAmount(p.GetType(), int64(droplets))
+	}
+
+	if head.BlockHash != "" {
+		receipt.Status = model_server.TxConfirmed
+		receipt.BlockHash = head.BlockHash
+		receipt.Height = int64(head.BkSeq)
+		receipt.Timestamp = int64(head.Time)
+	}
+	return receipt
+}
 
-		p.DefaultBlockStore.BestBlockHeight = int32(blocks.Blocks[0].Head.BkSeq)
-		p.DefaultBlockStore.BlockHashes[int64(p.DefaultBlockStore.BestBlockHeight)] = hash
+// AddToMempool adds an unconfirmed transaction paying deposit, it is confirmed
+// by the next mined block, or by the block at the height of the deposit. A
+// deposit for a past height is added to the block at that height right away.
+func (p *Provider) AddToMempool(deposit model_server.Deposit) (tx interface{}, err error) {
+	p.DefaultBlockStore.Lock()
+	defer p.DefaultBlockStore.Unlock()
 
-		p.DefaultBlockStore.BlockTX[hash] = tx
-		p.DefaultBlockStore.HashBlocks[hash] = blocks
+	if err = p.DefaultBlockStore.checkNewTx(deposit.Tx); err != nil {
+		return nil, err
+	}
 
-		// Update NextHash of previous block
-		bestHeight := int64(p.DefaultBlockStore.BestBlockHeight)
-		prevBlockHash := p.DefaultBlockStore.BlockHashes[bestHeight]
+	tip := int64(p.DefaultBlockStore.BestBlockHeight)
+	backfill := deposit.Height != 0 && deposit.Height <= tip
+	maxHeight := tip
+	if backfill {
+		maxHeight = deposit.Height - 1
+	}
+	fake, err := p.newFakeTx(deposit, maxHeight)
+	if err != nil {
+		return nil, err
+	}
 
-		prevBlock := p.DefaultBlockStore.HashBlocks[prevBlockHash]
+	if backfill {
+		p.backfill(&fake)
+	} else {
+		p.addToMempool(fake)
+	}
+	return &fake.tx, nil
+}
 
-		if prevBlock != nil && len(prevBlock.Blocks) > 0 {
-			prevBlock.Blocks[0].Head.PreviousBlockHash = hash
-			p.DefaultBlockStore.HashBlocks[prevBlockHash] = prevBlock
+// checkNewTx fails if txid is already confirmed or pending. Must be called
+// with the store locked.
+func (s *BlockStoreSky) checkNewTx(txid string) error {
+	if txid == "" {
+		return nil
+	}
+	if _, ok := s.BlockTX[txid]; ok {
+		return fmt.Errorf("transaction %s is already confirmed", txid)
+	}
+	for _, fake := range s.mempool {
+		if fake.tx.Hash == txid {
+			return fmt.Errorf("transaction %s is already pending", txid)
 		}
+	}
+	return nil
+}
 
-		// Update Hash of new block
-		p.DefaultBlockStore.HashBlocks[blocks.Blocks[0].Head.BlockHash] = blocks
+// takeMempool removes the transactions of the mempool for which due is true
+// and returns them, oldest first. Must be called with the store locked.
+func (s *BlockStoreSky) takeMempool(due func(fakeTx) bool) []fakeTx {
+	var taken, kept []fakeTx
+	for _, fake := range s.mempool {
+		if due(fake) {
+			taken = append(taken, fake)
+		} else {
+			kept = append(kept, fake)
+		}
+	}
+	s.mempool = kept
+	return taken
+}
 
-		p.DefaultBlockStore.Unlock()
-	}()
+// addToMempool indexes and announces an unconfirmed transaction. Must be
+// called with the store locked.
+func (p *Provider) addToMempool(fake fakeTx) {
+	fake.received = model_server.Now()
+	p.DefaultBlockStore.mempool = append(p.DefaultBlockStore.mempool, fake)
+	p.DefaultBlockStore.uxIDs[fake.tx.Hash] = fake.uxID
+	p.indexTransaction(visor.ReadableBlockHeader{}, fake.tx)
+	if e, ok := p.depositEvent(fake); ok {
+		e.Type = model_server.EventDepositAccepted
+		model_server.PublishEvent(e)
+	}
+}
 
-	//get metadata
-	var meta *visor.BlockchainMetadata
-	meta, err = p.SkyRESTClinet.BlockchainMetadata()
+// MineBlock appends a block confirming every transaction of the mempool but
+// those for a later height, or an empty block if there are none
+func (p *Provider) MineBlock() (blocks interface{}, err error) {
+	template, err := p.template()
 	if err != nil {
 		return nil, err
 	}
 
-	//get latest block
-	blocks, err = p.SkyRESTClinet.Blocks(int(meta.Head.BkSeq), int(meta.Head.BkSeq))
+	p.DefaultBlockStore.Lock()
+	defer p.DefaultBlockStore.Unlock()
+
+	pending := p.DefaultBlockStore.takeMempool(func(fake fakeTx) bool {
+		return fake.deposit.Height == 0
+	})
+
+	known := make(map[string]bool, len(pending))
+	for _, tx := range pending {
+		known[tx.tx.Hash] = true
+	}
+
+	return p.connectBlock(template, pending, known), nil
+}
+
+// Reorg disconnects the last reorg.Depth blocks and connects a block for each
+// entry of reorg.Blocks in their place. It returns the new tip.
+func (p *Provider) Reorg(reorg model_server.Reorg) (tip interface{}, err error) {
+	var template visor.ReadableBlock
+	if len(reorg.Blocks) > 0 {
+		if template, err = p.template(); err != nil {
+			return nil, err
+		}
+	}
+
+	p.DefaultBlockStore.Lock()
+	defer p.DefaultBlockStore.Unlock()
+
+	if err = reorg.Validate(p.GetType(), int64(p.DefaultBlockStore.BestBlockHeight)); err != nil {
+		return nil, err
+	}
+
+	// the branch is funded on top of the fork point before anything is
+	// disconnected, and the inputs it marked as spent are restored if it can
+	// not be, so that a failed reorg leaves the chain untouched
+	store := p.DefaultBlockStore
+	fork := int64(store.BestBlockHeight) - int64(reorg.Depth)
+	addresses := store.Addresses.Clone()
+	branch := make([][]fakeTx, 0, len(reorg.Blocks))
+	for _, deposits := range reorg.Blocks {
+		txs := make([]fakeTx, 0, len(deposits))
+		for _, deposit := range deposits {
+			fake, err := p.newFakeTx(deposit, fork)
+			if err != nil {
+				store.Addresses = addresses
+				return nil, err
+			}
+			txs = append(txs, fake)
+		}
+		branch = append(branch, txs)
+	}
+
+	disconnected := p.disconnectBlocks(reorg.Depth)
+	for _, txs := range branch {
+		p.connectBlock(template, txs, disconnected)
+	}
+
+	return p.bestBlock(0), nil
+}
+
+// DoubleSpend replaces the transaction doubleSpend.TxID with a conflicting one
+// spending the same inputs, see model_server.DoubleSpend. It returns the
+// conflicting transaction.
+func (p *Provider) DoubleSpend(doubleSpend model_server.DoubleSpend) (tx interface{}, err error) {
+	if err = doubleSpend.Validate(p.GetType()); err != nil {
+		return nil, err
+	}
+
+	template, err := p.template()
 	if err != nil {
 		return nil, err
 	}
 
-	//add a fake block to it
-	txFound := visor.ReadableTransactionOutput{}
+	p.DefaultBlockStore.Lock()
+	defer p.DefaultBlockStore.Unlock()
+
+	store := p.DefaultBlockStore
+	for i, original := range store.mempool {
+		if original.tx.Hash != doubleSpend.TxID {
+			continue
+		}
+
+		spent, _ := p.depositEvent(original)
+		store.mempool = append(store.mempool[:i:i], store.mempool[i+1:]...)
+		store.Addresses.RemoveTransaction(original.tx.Hash)
+
+		conflicting, err := p.conflictingTx(original, doubleSpend.Deposit, int64(store.BestBlockHeight))
+		if err != nil {
+			return nil, err
+		}
+		p.publishDoubleSpend(spent, conflicting)
+		if doubleSpend.Mine {
+			p.connectBlock(template, []fakeTx{conflicting}, nil)
+		} else {
+			p.addToMempool(conflicting)
+		}
+		return &conflicting.tx, nil
+	}
+
+	hash, ok := store.BlockTX[doubleSpend.TxID]
+	if !ok {
+		return nil, fmt.Errorf("transaction %s is neither pending nor confirmed", doubleSpend.TxID)
+	}
+
+	// collect the blocks from the one holding the original up to the tip
+	var branch [][]fakeTx
+	var conflicting fakeTx
+	var spent model_server.Event
+	seq := int64(store.HashBlocks[hash].Blocks[0].Head.BkSeq)
+	for s := seq; s <= int64(store.BestBlockHeight); s++ {
+		var txs []fakeTx
+		for _, block := range store.HashBlocks[store.BlockHashes[s]].Blocks {
+			for _, blockTx := range block.Body.Transactions {
+				fake := store.storedFakeTx(blockTx)
+				if fake.tx.Hash == doubleSpend.TxID {
+					spent, _ = p.depositEvent(fake)
+					spent.Height = s
+					spent.BlockHash = hash
+					if conflicting, err = p.conflictingTx(fake, doubleSpend.Deposit, seq-1); err != nil {
+						return nil, err
+					}
+					fake = conflicting
+				}
+				txs = append(txs, fake)
+			}
+		}
+		branch = append(branch, txs)
+	}
+	// the conflicting chain is one block longer, so that it wins
+	branch = append(branch, nil)
+
+	disconnected := p.disconnectBlocks(len(branch) - 1)
+	p.publishDoubleSpend(spent, conflicting)
+	for _, txs := range branch {
+		p.connectBlock(template, txs, disconnected)
+	}
+
+	return &conflicting.tx, nil
+}
+
+// publishDoubleSpend announces that the deposit spent is replaced by the
+// conflicting transaction. Must be called with the store locked.
+func (p *Provider) publishDoubleSpend(spent model_server.Event, conflicting fakeTx) {
+	if spent.TxID == "" {
+		return
+	}
+	spent.Type = model_server.EventDepositDoubleSpent
+	spent.ReplacedBy = conflicting.tx.Hash
+	model_server.PublishEvent(spent)
+}
+
+// template fetches the latest upstream block. Fake blocks copy its header and
+// its first transaction.
+func (p *Provider) template() (visor.ReadableBlock, error) {
+	if model_server.Seeded() {
+		return seededTemplate()
+	}
+
+	meta, err := p.SkyRESTClinet.BlockchainMetadata()
+	if err != nil {
+		return visor.ReadableBlock{}, err
+	}
+
+	blocks, err := p.SkyRESTClinet.Blocks(int(meta.Head.BkSeq), int(meta.Head.BkSeq))
+	if err != nil {
+		return visor.ReadableBlock{}, err
+	}
+
 	for _, block := range blocks.Blocks {
+		if len(block.Body.Transactions) > 0 {
+			return block, nil
+		}
+	}
+	return visor.ReadableBlock{}, fmt.Errorf("upstream block %d has no transactions", meta.Head.BkSeq)
+}
 
-		var actualTX visor.ReadableTransaction
+// seededTemplate returns the first block of SkyBlockString, seeded chains are
+// built out of it so they do not depend on the live upstream
+func seededTemplate() (visor.ReadableBlock, error) {
+	var blocks visor.ReadableBlocks
+	if err := json.Unmarshal([]byte(SkyBlockString), &blocks); err != nil {
+		return visor.ReadableBlock{}, err
+	}
+	if len(blocks.Blocks) == 0 || len(blocks.Blocks[0].Body.Transactions) == 0 {
+		return visor.ReadableBlock{}, fmt.Errorf("seeded template has no transactions")
+	}
+	return blocks.Blocks[0], nil
+}
 
-		for _, tx := range block.Body.Transactions {
+// storedFakeTx rebuilds the fakeTx of a transaction of a fake block. Must be
+// called with the store locked.
+func (s *BlockStoreSky) storedFakeTx(tx visor.ReadableTransaction) fakeTx {
+	fake := fakeTx{tx: tx, uxID: s.uxIDs[tx.Hash], deposit: model_server.Deposit{Tx: tx.Hash}}
+	for n, out := range tx.Out {
+		if out.Hash == fake.uxID {
+			fake.deposit.Address = out.Address
+			fake.deposit.Amount = out.Coins
+			fake.deposit.Hours = out.Hours
+			fake.deposit.N = uint32(n)
+		}
+	}
+	return fake
+}
 
-			txFound = tx.Out[0]
-			txFound.Address = deposit.Address
-			txFound.Coins = strconv.Itoa(int(deposit.Value))
-			txFound.Hours = deposit.Hours
+// connectBlock builds a block on top of the tip out of the upstream template
+// holding txs, along with the pending transactions for its height and the
+// pending withdrawals, then stores, indexes and announces it. Transactions
+// whose hash is in known were seen before and are not announced as accepted
+// again. Must be called with the store locked. The block returned is a copy,
+// safe to use unlocked.
+func (p *Provider) connectBlock(template visor.ReadableBlock, txs []fakeTx, known map[string]bool) *visor.ReadableBlocks {
+	store := p.DefaultBlockStore
+	seq := int64(store.BestBlockHeight) + 1
 
-			tx.Out = append(tx.Out, txFound)
-			actualTX = tx
-			break
+	scheduled := store.takeMempool(func(fake fakeTx) bool {
+		return fake.uxID == "" || (fake.deposit.Height != 0 && fake.deposit.Height <= seq)
+	})
+	if len(scheduled) > 0 {
+		if known == nil {
+			known = make(map[string]bool, len(scheduled))
+		}
+		for _, fake := range scheduled {
+			known[fake.tx.Hash] = true
+		}
+		txs = append(txs, scheduled...)
+	}
+
+	head := template.Head
+	head.BkSeq = uint64(seq)
+	head.Time = uint64(model_server.Now().Unix())
+	head.BlockHash = newHash()
+	head.BodyHash = newHash()
+	if prevHash, ok := store.BlockHashes[seq-1]; ok {
+		head.PreviousBlockHash = prevHash
+	}
+
+	block := visor.ReadableBlock{
+		Head: head,
+		Body: visor.ReadableBlockBody{
+			Transactions: make([]visor.ReadableTransaction, 0, len(txs)),
+		},
+	}
+	for i := range txs {
+		txs[i].tx.Timestamp = head.Time
+		block.Body.Transactions = append(block.Body.Transactions, txs[i].tx)
+	}
+
+	blocks := &visor.ReadableBlocks{Blocks: []visor.ReadableBlock{block}}
+
+	store.BestBlockHeight = int32(seq)
+	store.BlockHashes[seq] = head.BlockHash
+	store.HashBlocks[head.BlockHash] = blocks
+	for _, tx := range txs {
+		store.BlockTX[tx.tx.Hash] = head.BlockHash
+		store.uxIDs[tx.tx.Hash] = tx.uxID
+		delete(store.orphaned, tx.tx.Hash)
+		p.indexTransaction(head, tx.tx)
+	}
+
+	p.publishBlock(head, txs, known)
+	return copyReadableBlocks(blocks)
+}
+
+// backfill adds fake to the block at the height of its deposit, below the
+// tip, as a transaction the chain had all along, then reindexes the addresses
+// and announces the deposit. Must be called with the store locked. The block
+// returned is a copy, safe to use unlocked.
+func (p *Provider) backfill(fake *fakeTx) *visor.ReadableBlocks {
+	store := p.DefaultBlockStore
+	hash := store.BlockHashes[fake.deposit.Height]
+	blocks := store.HashBlocks[hash]
+
+	block := &blocks.Blocks[0]
+	fake.tx.Timestamp = block.Head.Time
+	block.Body.Transactions = append(block.Body.Transactions, fake.tx)
+
+	store.BlockTX[fake.tx.Hash] = hash
+	store.uxIDs[fake.tx.Hash] = fake.uxID
+	delete(store.orphaned, fake.tx.Hash)
+	p.reindex()
 
+	if e, ok := p.depositEvent(*fake); ok {
+		e.Type = model_server.EventDepositAccepted
+		model_server.PublishEvent(e)
+
+		e.Type = model_server.EventDepositConfirmed
+		e.Height = fake.deposit.Height
+		e.BlockHash = hash
+		model_server.PublishEvent(e)
+	}
+	return copyReadableBlocks(blocks)
+}
+
+// reindex rebuilds the address index out of the chain, then the mempool, so
+// that a backfilled transaction is indexed in order. Must be called with the
+// store locked.
+func (p *Provider) reindex() {
+	store := p.DefaultBlockStore
+	store.Addresses = model_server.NewAddressIndex()
+
+	for seq := int64(1); seq <= int64(store.BestBlockHeight); seq++ {
+		for _, block := range store.HashBlocks[store.BlockHashes[seq]].Blocks {
+			for _, tx := range block.Body.Transactions {
+				p.indexTransaction(block.Head, tx)
+			}
 		}
+	}
+	for _, fake := range store.mempool {
+		p.indexTransaction(visor.ReadableBlockHeader{}, fake.tx)
+	}
+}
+
+// disconnectBlocks removes the last depth blocks from the chain, newest first,
+// and returns the ids of their transactions. The blocks can still be looked up
+// by hash, like the side chain blocks of a real node, and their transactions
+// are orphaned until a later block includes them again. Must be called with
+// the store locked.
+func (p *Provider) disconnectBlocks(depth int) map[string]bool {
+	store := p.DefaultBlockStore
+	txids := make(map[string]bool)
+
+	for i := 0; i < depth && store.BestBlockHeight > 0; i++ {
+		seq := int64(store.BestBlockHeight)
+		hash := store.BlockHashes[seq]
+
+		if blocks, ok := store.HashBlocks[hash]; ok {
+			for _, block := range blocks.Blocks {
+				for _, tx := range block.Body.Transactions {
+					delete(store.BlockTX, tx.Hash)
+					store.orphaned[tx.Hash] = hash
+					store.Addresses.RemoveTransaction(tx.Hash)
+					txids[tx.Hash] = true
+				}
+			}
+		}
+
+		delete(store.BlockHashes, seq)
+		store.BestBlockHeight--
+
+		model_server.PublishEvent(model_server.Event{
+			Type:      model_server.EventBlockDisconnected,
+			CoinType:  p.GetType(),
+			Height:    seq,
+			BlockHash: hash,
+		})
+	}
+
+	return txids
+}
 
-		block.Body.Transactions[0] = actualTX
+// indexTransaction records the outputs of tx, and any indexed output spent by
+// tx, in the address index. Must be called with the store locked.
+func (p *Provider) indexTransaction(head visor.ReadableBlockHeader, tx visor.ReadableTransaction) {
+	index := p.DefaultBlockStore.Addresses
+	height := int64(head.BkSeq)
+	time := int64(head.Time)
 
-		if txFound.Address != "" {
-			break
+	for _, in := range tx.In {
+		// pre-mined inputs are not indexed
+		_ = index.SpendOutput(in, tx.Hash, height, head.BlockHash, time)
+	}
+
+	for n, out := range tx.Out {
+		droplets, _ := droplet.FromString(out.Coins)
+		index.AddOutput(model_server.UxOut{
+			Hash:      out.Hash,
+			TxID:      tx.Hash,
+			N:         uint32(n),
+			Address:   out.Address,
+			Amount:    int64(droplets),
+			Hours:     out.Hours,
+			Height:    height,
+			BlockHash: head.BlockHash,
+			Time:      time,
+		})
+	}
+}
+
+// publishBlock publishes the events of a new block and of the deposits it
+// confirms. Must be called with the store locked.
+func (p *Provider) publishBlock(head visor.ReadableBlockHeader, txs []fakeTx, known map[string]bool) {
+	deposits := make([]model_server.Event, 0, len(txs))
+	for _, tx := range txs {
+		deposit, ok := p.depositEvent(tx)
+		if !ok {
+			continue
+		}
+		deposits = append(deposits, deposit)
+
+		if !known[tx.tx.Hash] {
+			accepted := deposit
+			accepted.Type = model_server.EventDepositAccepted
+			model_server.PublishEvent(accepted)
 		}
 	}
 
-	return blocks, err
+	model_server.PublishEvent(model_server.Event{
+		Type:      model_server.EventBlockConnected,
+		CoinType:  p.GetType(),
+		Height:    int64(head.BkSeq),
+		BlockHash: head.BlockHash,
+	})
+
+	for _, confirmed := range deposits {
+		confirmed.Type = model_server.EventDepositConfirmed
+		confirmed.Height = int64(head.BkSeq)
+		confirmed.BlockHash = head.BlockHash
+		model_server.PublishEvent(confirmed)
+	}
+}
+
+// depositEvent returns the event of the deposit paid by tx, without its type.
+// Must be called with the store locked.
+func (p *Provider) depositEvent(tx fakeTx) (model_server.Event, bool) {
+	out, ok := p.DefaultBlockStore.Addresses.Output(tx.uxID)
+	if !ok {
+		return model_server.Event{}, false
+	}
+
+	return model_server.Event{
+		CoinType: p.GetType(),
+		TxID:     tx.tx.Hash,
+		N:        out.N,
+		Address:  out.Address,
+		Amount:   out.Amount,
+	}, true
+}
+
+// newHash returns a random hash for a fake block, transaction or output
+func newHash() string {
+	b := make([]byte, 128)
+	model_server.RandomBytes(b)
+	return cipher.SumSHA256(b).Hex()
 }
 
 func (p *Provider) GetBlock(hash string) (block interface{}, err error) {
+	p.DefaultBlockStore.RLock()
+	defer p.DefaultBlockStore.RUnlock()
+
 	if b, ok := p.DefaultBlockStore.HashBlocks[hash]; ok {
-		block = b
+		block = copyReadableBlocks(b)
 		return
 	} else {
 		return nil, &btcjson.RPCError{
@@ -149,6 +777,15 @@ func (p *Provider) GetBlock(hash string) (block interface{}, err error) {
 }
 
 func (p *Provider) GetBestBlock(seq int64) (block interface{}, err error) {
+	p.DefaultBlockStore.RLock()
+	defer p.DefaultBlockStore.RUnlock()
+
+	return p.bestBlock(seq), nil
+}
+
+// bestBlock returns the hash of the block at seq, or of the tip if seq is 0,
+// along with the height of the tip. Must be called with the store locked.
+func (p *Provider) bestBlock(seq int64) (block interface{}) {
 	if seq == 0 {
 		seq = int64(p.DefaultBlockStore.BestBlockHeight)
 	}
@@ -158,12 +795,15 @@ func (p *Provider) GetBestBlock(seq int64) (block interface{}, err error) {
 			Height: p.DefaultBlockStore.BestBlockHeight,
 		}
 	}
-	return block, err
+	return block
 }
 
 func (p *Provider) GetGetBlockHash(tx string) (block interface{}, err error) {
+	p.DefaultBlockStore.RLock()
+	defer p.DefaultBlockStore.RUnlock()
+
 	if hash, ok := p.DefaultBlockStore.BlockTX[tx]; ok {
-		block = p.DefaultBlockStore.HashBlocks[hash]
+		block = copyReadableBlocks(p.DefaultBlockStore.HashBlocks[hash])
 	} else {
 		err = &btcjson.RPCError{
 			Code:    btcjson.ErrRPCBlockNotFound,
@@ -174,10 +814,265 @@ func (p *Provider) GetGetBlockHash(tx string) (block interface{}, err error) {
 	return block, err
 }
 
+// Transaction is skycoin's /transaction response, extended with the hash of
+// the block holding the transaction. Status.Height is the number of
+// confirmations and Status.BlockSeq the height of the block. An orphaned
+// transaction has Orphaned and Status.Unknown set, along with the block it was
+// dropped from.
+type Transaction struct {
+	visor.TransactionResult
+	BlockHash string `json:"block_hash,omitempty"`
+	Orphaned  bool   `json:"orphaned,omitempty"`
+}
+
+// GetTransaction looks up txid in the chain, the mempool and the transactions
+// orphaned by reorgs
+func (p *Provider) GetTransaction(txid string) (tx interface{}, err error) {
+	p.DefaultBlockStore.RLock()
+	defer p.DefaultBlockStore.RUnlock()
+
+	store := p.DefaultBlockStore
+	if hash, ok := store.BlockTX[txid]; ok {
+		return p.blockTransaction(hash, txid, model_server.TxConfirmed)
+	}
+	for _, fake := range store.mempool {
+		if fake.tx.Hash == txid {
+			return &Transaction{
+				TransactionResult: visor.TransactionResult{
+					Status:      visor.NewUnconfirmedTransactionStatus(),
+					Transaction: fake.tx,
+				},
+			}, nil
+		}
+	}
+	if hash, ok := store.orphaned[txid]; ok {
+		return p.blockTransaction(hash, txid, model_server.TxOrphaned)
+	}
+
+	return nil, fmt.Errorf("transaction %s not found", txid)
+}
+
+// GetTransactionStatus looks up txid like GetTransaction, and returns where it
+// stands
+func (p *Provider) GetTransactionStatus(txid string) (status model_server.TxStatus, err error) {
+	p.DefaultBlockStore.RLock()
+	defer p.DefaultBlockStore.RUnlock()
+
+	store := p.DefaultBlockStore
+	state := model_server.TxConfirmed
+	hash, ok := store.BlockTX[txid]
+	if !ok {
+		for _, fake := range store.mempool {
+			if fake.tx.Hash == txid {
+				return model_server.TxStatus{
+					TxID:   txid,
+					Status: model_server.TxPending,
+					Time:   fake.received.Unix(),
+				}, nil
+			}
+		}
+		state = model_server.TxOrphaned
+		if hash, ok = store.orphaned[txid]; !ok {
+			return status, fmt.Errorf("transaction %s not found", txid)
+		}
+	}
+
+	tx, err := p.blockTransaction(hash, txid, state)
+	if err != nil {
+		return status, err
+	}
+	return model_server.TxStatus{
+		TxID:          txid,
+		Status:        state,
+		Confirmations: int64(tx.Status.Height),
+		Height:        int64(tx.Status.BlockSeq),
+		BlockHash:     hash,
+		Time:          int64(tx.Time),
+	}, nil
+}
+
+// blockTransaction returns txid, found in the block with the given hash. Must
+// be called with the store locked.
+func (p *Provider) blockTransaction(hash, txid, status string) (*Transaction, error) {
+	blocks, ok := p.DefaultBlockStore.HashBlocks[hash]
+	if !ok || len(blocks.Blocks) == 0 {
+		return nil, fmt.Errorf("block %s of transaction %s not found", hash, txid)
+	}
+	tx, ok := p.findTransaction(hash, txid)
+	if !ok {
+		return nil, fmt.Errorf("transaction %s not found in block %s", txid, hash)
+	}
+
+	head := blocks.Blocks[0].Head
+	result := &Transaction{
+		TransactionResult: visor.TransactionResult{
+			Status:      visor.TransactionStatus{Unknown: true, BlockSeq: head.BkSeq},
+			Time:        head.Time,
+			Transaction: tx,
+		},
+		BlockHash: hash,
+		Orphaned:  status == model_server.TxOrphaned,
+	}
+	if status == model_server.TxConfirmed {
+		depth := model_server.Confirmations(int64(p.DefaultBlockStore.BestBlockHeight), int64(head.BkSeq))
+		result.Status = visor.NewConfirmedTransactionStatus(uint64(depth), head.BkSeq)
+	}
+	return result, nil
+}
+
 func (p *Provider) GetBlockCount() (count int32) {
+	p.DefaultBlockStore.RLock()
+	defer p.DefaultBlockStore.RUnlock()
+
 	return int32(p.DefaultBlockStore.BestBlockHeight)
 }
 
+// GetBalance returns the balance of address in the format of skycoin's
+// /balance, with the coin hours accrued at the time of the tip
+func (p *Provider) GetBalance(address string) (balance interface{}, err error) {
+	p.DefaultBlockStore.RLock()
+	defer p.DefaultBlockStore.RUnlock()
+
+	bal := p.balance(address)
+	return &bal, nil
+}
+
+// GetOutputs returns the unspent outputs of address in the format of skycoin's
+// /outputs, with the coin hours accrued at the time of the tip
+func (p *Provider) GetOutputs(address string) (outputs interface{}, err error) {
+	p.DefaultBlockStore.RLock()
+	defer p.DefaultBlockStore.RUnlock()
+
+	store := p.DefaultBlockStore
+	head, _ := store.blockHead(int64(store.BestBlockHeight))
+
+	set := visor.ReadableOutputSet{
+		HeadOutputs:     visor.ReadableOutputs{},
+		OutgoingOutputs: visor.ReadableOutputs{},
+		IncomingOutputs: visor.ReadableOutputs{},
+	}
+
+	for _, out := range p.DefaultBlockStore.Addresses.Outputs(address) {
+		ro, err := newReadableOutput(out, head)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case out.Height == 0 && !out.Spent():
+			set.IncomingOutputs = append(set.IncomingOutputs, ro)
+		case out.Height > 0 && !out.Spent():
+			set.HeadOutputs = append(set.HeadOutputs, ro)
+		case out.Height > 0 && out.SpentHeight == 0:
+			set.HeadOutputs = append(set.HeadOutputs, ro)
+			set.OutgoingOutputs = append(set.OutgoingOutputs, ro)
+		}
+	}
+
+	return &set, nil
+}
+
+// GetAddressTransactions returns a page of the transactions of address, newest
+// first, in the format of skycoin's /transactions
+func (p *Provider) GetAddressTransactions(address string, offset, limit int) (txs interface{}, err error) {
+	p.DefaultBlockStore.RLock()
+	defer p.DefaultBlockStore.RUnlock()
+
+	history, total := p.DefaultBlockStore.Addresses.Transactions(address, offset, limit)
+
+	results := make([]visor.TransactionResult, 0, len(history))
+	for _, entry := range history {
+		result := visor.TransactionResult{
+			Status:      visor.NewUnconfirmedTransactionStatus(),
+			Time:        uint64(entry.Time),
+			Transaction: visor.ReadableTransaction{Hash: entry.TxID},
+		}
+
+		if entry.Height > 0 {
+			depth := model_server.Confirmations(int64(p.DefaultBlockStore.BestBlockHeight), entry.Height)
+			result.Status = visor.NewConfirmedTransactionStatus(uint64(depth), uint64(entry.Height))
+		}
+
+		if tx, ok := p.findTransaction(entry.BlockHash, entry.TxID); ok {
+			result.Transaction = tx
+		}
+
+		results = append(results, result)
+	}
+
+	return &model_server.TransactionPage{
+		Address:      address,
+		Total:        total,
+		Offset:       offset,
+		Limit:        limit,
+		Transactions: results,
+	}, nil
+}
+
+// IssueAsset is not supported, skycoin has no assets
+func (p *Provider) IssueAsset(asset model_server.Asset) (details interface{}, err error) {
+	return nil, fmt.Errorf("%s has no assets", p.GetType())
+}
+
+// GetAsset is not supported, skycoin has no assets
+func (p *Provider) GetAsset(assetID string) (details interface{}, err error) {
+	return nil, fmt.Errorf("%s has no assets", p.GetType())
+}
+
+// GetAssetBalances is not supported, skycoin has no assets
+func (p *Provider) GetAssetBalances(address, assetID string) (balances interface{}, err error) {
+	return nil, fmt.Errorf("%s has no assets", p.GetType())
+}
+
+// findTransaction looks up txid in the stored block with the given hash, or in
+// the mempool if there is no such block
+func (p *Provider) findTransaction(blockHash, txid string) (visor.ReadableTransaction, bool) {
+	blocks, ok := p.DefaultBlockStore.HashBlocks[blockHash]
+	if !ok {
+		for _, tx := range p.DefaultBlockStore.mempool {
+			if tx.tx.Hash == txid {
+				return tx.tx, true
+			}
+		}
+		return visor.ReadableTransaction{}, false
+	}
+
+	for _, block := range blocks.Blocks {
+		for _, tx := range block.Body.Transactions {
+			if tx.Hash == txid {
+				return tx, true
+			}
+		}
+	}
+	return visor.ReadableTransaction{}, false
+}
+
+// newReadableOutput converts an indexed output to skycoin's ReadableOutput,
+// with its hours at the time of head. Like on a node, unconfirmed outputs are
+// reported as created by the head block.
+func newReadableOutput(out model_server.UxOut, head visor.ReadableBlockHeader) (visor.ReadableOutput, error) {
+	coins, err := droplet.ToString(uint64(out.Amount))
+	if err != nil {
+		return visor.ReadableOutput{}, err
+	}
+
+	ro := visor.ReadableOutput{
+		Hash:              out.Hash,
+		Time:              uint64(out.Time),
+		BkSeq:             uint64(out.Height),
+		SourceTransaction: out.TxID,
+		Address:           out.Address,
+		Coins:             coins,
+		Hours:             out.Hours,
+		CalculatedHours:   calculatedHours(out, head.Time),
+	}
+	if out.Height == 0 {
+		ro.Time = head.Time
+		ro.BkSeq = head.BkSeq
+	}
+	return ro, nil
+}
+
 //
 //func (s *SkycoinFake) CreateFakeBlockRPC(deposit Deposit) (blocks visor.ReadableBlocks) {
 //
@@ -294,9 +1189,10 @@ func (p *Provider) GetBlockCount() (count int32) {
 //}
 
 func CreateSkycoinBlock() *coin.Block {
-	prev := coin.Block{Head: coin.BlockHeader{Version: 0x02, Time: 100, BkSeq: 98}}
+	currentTime := uint64(model_server.Now().Unix())
+	prev := coin.Block{Head: coin.BlockHeader{Version: 0x02, Time: currentTime - 33, BkSeq: 98}}
 	b := make([]byte, 128)
-	rand.Read(b)
+	model_server.RandomBytes(b)
 	uxHash := cipher.SumSHA256(b)
 
 	tx, _ := createTransaction("", 50e1)
@@ -304,7 +1200,6 @@ func CreateSkycoinBlock() *coin.Block {
 
 	// valid block is fine
 	fee := uint64(121)
-	currentTime := uint64(133)
 	block, err := coin.NewBlock(prev, currentTime, uxHash, txns, _makeFeeCalc(fee))
 	if err != nil {
 		panic(err)
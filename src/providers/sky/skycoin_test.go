@@ -49,7 +49,7 @@ func TestCreateFakeDepositSkycoin(t *testing.T) {
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
 
-			b, err := sky.CreateFakeBlock(tc.Deposit)
+			_, b, err := sky.CreateFakeBlock(tc.Deposit)
 			require.NoError(t, err)
 
 			txFound := visor.ReadableTransactionOutput{}
@@ -0,0 +1,262 @@
+package sky
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/gui"
+	"github.com/skycoin/skycoin/src/visor"
+	"github.com/skycoin/skycoin/src/wallet"
+
+	"github.com/modeneis/coind/src/server/model_server"
+)
+
+// Wallets are the wallets of the emulated wallet API of a skycoin node. They
+// are kept in memory, and saved as .wlt files once a directory is opened.
+type Wallets struct {
+	sync.Mutex
+	dir     string
+	wallets wallet.Wallets
+}
+
+// DefaultWallets are the wallets of the SKY provider, they outlive resets of
+// the chain
+var DefaultWallets = NewWallets()
+
+// NewWallets creates an empty set of wallets, kept in memory only
+func NewWallets() *Wallets {
+	return &Wallets{wallets: wallet.Wallets{}}
+}
+
+// Open loads the wallets saved in dir, creating it if needed, and saves every
+// wallet created or changed from now on there
+func (ws *Wallets) Open(dir string) error {
+	ws.Lock()
+	defer ws.Unlock()
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create wallet directory %s: %v", dir, err)
+	}
+	loaded, err := wallet.LoadWallets(dir)
+	if err != nil {
+		return fmt.Errorf("failed to load the wallets of %s: %v", dir, err)
+	}
+	for id, w := range loaded {
+		ws.wallets[id] = w
+	}
+	ws.dir = dir
+	return nil
+}
+
+// save writes w to the wallet directory, if one is open. Must be called with
+// the wallets locked.
+func (ws *Wallets) save(w *wallet.Wallet) error {
+	if ws.dir == "" {
+		return nil
+	}
+	return w.Save(ws.dir)
+}
+
+// get returns the wallet id. Must be called with the wallets locked.
+func (ws *Wallets) get(id string) (*wallet.Wallet, error) {
+	w, ok := ws.wallets.Get(id)
+	if !ok {
+		return nil, wallet.ErrWalletNotExist
+	}
+	return w, nil
+}
+
+// newWalletFilename returns an unused wallet id, dated by the clock of the
+// chain. Must be called with the wallets locked.
+func (ws *Wallets) newWalletFilename() string {
+	for {
+		padding := make([]byte, 2)
+		model_server.RandomIDBytes(padding)
+		id := fmt.Sprintf("%s_%s.%s", model_server.Now().Format(wallet.WalletTimestampFormat), hex.EncodeToString(padding), wallet.WalletExt)
+		if _, ok := ws.wallets[id]; !ok {
+			return id
+		}
+	}
+}
+
+// CreateWallet creates a deterministic wallet out of seed, like a node's
+// /wallet/create: its first address is derived, then the next scanN-1 are
+// scanned and kept up to the last one holding coins. A seed already used by
+// another wallet is rejected.
+func (p *Provider) CreateWallet(seed, label string, scanN uint64) (*wallet.ReadableWallet, error) {
+	ws := DefaultWallets
+	ws.Lock()
+	defer ws.Unlock()
+
+	w, err := wallet.NewWallet(ws.newWalletFilename(), wallet.Options{Seed: seed, Label: label})
+	if err != nil {
+		return nil, err
+	}
+	w.Meta["tm"] = fmt.Sprintf("%v", model_server.Now().Unix())
+	if _, err := w.GenerateAddresses(1); err != nil {
+		return nil, err
+	}
+	for id, other := range ws.wallets {
+		if len(other.Entries) > 0 && other.Entries[0].Address == w.Entries[0].Address {
+			return nil, fmt.Errorf("duplicate wallet with %v", id)
+		}
+	}
+	if scanN > 1 {
+		if err := w.ScanAddresses(scanN-1, p); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := ws.wallets.Add(*w); err != nil {
+		return nil, err
+	}
+	if err := ws.save(w); err != nil {
+		ws.wallets.Remove(w.GetID())
+		return nil, err
+	}
+	return wallet.NewReadableWallet(*w), nil
+}
+
+// NewWalletAddresses derives the next num addresses of the wallet id, like a
+// node's /wallet/newAddress
+func (p *Provider) NewWalletAddresses(id string, num uint64) ([]string, error) {
+	ws := DefaultWallets
+	ws.Lock()
+	defer ws.Unlock()
+
+	w, err := ws.get(id)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := w.GenerateAddresses(num)
+	if err != nil {
+		return nil, err
+	}
+	if err := ws.save(w); err != nil {
+		return nil, err
+	}
+
+	addresses := make([]string, len(addrs))
+	for i, a := range addrs {
+		addresses[i] = a.String()
+	}
+	return addresses, nil
+}
+
+// WalletBalance sums the balances of the addresses of the wallet id, like a
+// node's /wallet/balance
+func (p *Provider) WalletBalance(id string) (wallet.BalancePair, error) {
+	ws := DefaultWallets
+	ws.Lock()
+	defer ws.Unlock()
+
+	w, err := ws.get(id)
+	if err != nil {
+		return wallet.BalancePair{}, err
+	}
+
+	p.DefaultBlockStore.RLock()
+	defer p.DefaultBlockStore.RUnlock()
+
+	return p.balance(walletAddresses(w)...), nil
+}
+
+// WalletSpend sends coins droplets from the wallet id to dst, like a node's
+// /wallet/spend. The transaction is signed with the keys of the wallet and
+// settles on the fake chain with the next block.
+func (p *Provider) WalletSpend(id, dst string, coins uint64) (*gui.SpendResult, error) {
+	dest, err := cipher.DecodeBase58Address(dst)
+	if err != nil {
+		return nil, fmt.Errorf("invalid destination address: %v", err)
+	}
+
+	ws := DefaultWallets
+	ws.Lock()
+	defer ws.Unlock()
+
+	w, err := ws.get(id)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]cipher.SecKey, len(w.Entries))
+	for i, e := range w.Entries {
+		keys[i] = e.Secret
+	}
+
+	p.DefaultBlockStore.Lock()
+	defer p.DefaultBlockStore.Unlock()
+
+	txn, err := p.spend(keys, coins, dest)
+	if err != nil {
+		return nil, err
+	}
+	readable, err := p.injectTransaction(txn)
+	if err != nil {
+		return nil, err
+	}
+
+	bal := p.balance(walletAddresses(w)...)
+	return &gui.SpendResult{Balance: &bal, Transaction: &readable}, nil
+}
+
+// WalletTransactions returns the unconfirmed transactions paying an address
+// of the wallet id, like a node's /wallet/transactions
+func (p *Provider) WalletTransactions(id string) (*gui.UnconfirmedTxnsResponse, error) {
+	ws := DefaultWallets
+	ws.Lock()
+	defer ws.Unlock()
+
+	w, err := ws.get(id)
+	if err != nil {
+		return nil, err
+	}
+	owned := map[string]bool{}
+	for _, address := range walletAddresses(w) {
+		owned[address] = true
+	}
+
+	p.DefaultBlockStore.RLock()
+	defer p.DefaultBlockStore.RUnlock()
+
+	txns := []visor.ReadableUnconfirmedTxn{}
+	for _, fake := range p.DefaultBlockStore.mempool {
+		for _, out := range fake.tx.Out {
+			if owned[out.Address] {
+				txns = append(txns, visor.ReadableUnconfirmedTxn{
+					Txn:       fake.tx,
+					Received:  fake.received,
+					Checked:   fake.received,
+					Announced: fake.received,
+					IsValid:   true,
+				})
+				break
+			}
+		}
+	}
+	return &gui.UnconfirmedTxnsResponse{Transactions: txns}, nil
+}
+
+// GetBalanceOfAddrs returns the balance of each address, to scan the
+// addresses of the wallets ahead
+func (p *Provider) GetBalanceOfAddrs(addrs []cipher.Address) ([]wallet.BalancePair, error) {
+	p.DefaultBlockStore.RLock()
+	defer p.DefaultBlockStore.RUnlock()
+
+	balances := make([]wallet.BalancePair, len(addrs))
+	for i, a := range addrs {
+		balances[i] = p.balance(a.String())
+	}
+	return balances, nil
+}
+
+// walletAddresses returns the addresses of w, in order
+func walletAddresses(w *wallet.Wallet) []string {
+	addresses := make([]string, len(w.Entries))
+	for i, e := range w.Entries {
+		addresses[i] = e.Address.String()
+	}
+	return addresses
+}
@@ -0,0 +1,229 @@
+package sky
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/gui"
+	"github.com/skycoin/skycoin/src/util/fee"
+	"github.com/skycoin/skycoin/src/visor"
+	"github.com/skycoin/skycoin/src/wallet"
+
+	"github.com/modeneis/coind/src/server/model_server"
+)
+
+// SendToAddress spends withdrawal out of the SKY wallet, like a node's
+// /wallet/spend, and returns a gui.SpendResult with the balance of the wallet
+// and the pending transaction. Every address of the wallet is spent from, the
+// change goes back to one of them.
+func (p *Provider) SendToAddress(withdrawal model_server.Withdrawal) (tx interface{}, err error) {
+	if err = withdrawal.Validate(p.GetType()); err != nil {
+		return nil, err
+	}
+	droplets, _ := model_server.ParseAmount(p.GetType(), withdrawal.Amount)
+	if err = visor.DropletPrecisionCheck(uint64(droplets)); err != nil {
+		return nil, err
+	}
+	dest, err := cipher.DecodeBase58Address(withdrawal.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := model_server.GetWallet(p.GetType())
+	if err != nil {
+		return nil, err
+	}
+	var addresses []string
+	var keys []cipher.SecKey
+	for _, a := range w.Addresses() {
+		secret, err := w.SecretKey(a.Address)
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, a.Address)
+		keys = append(keys, cipher.NewSecKey(secret))
+	}
+
+	p.DefaultBlockStore.Lock()
+	defer p.DefaultBlockStore.Unlock()
+
+	txn, err := p.spend(keys, uint64(droplets), dest)
+	if err != nil {
+		return nil, err
+	}
+	readable, err := p.injectTransaction(txn)
+	if err != nil {
+		return nil, err
+	}
+
+	bal := p.balance(addresses...)
+	return &gui.SpendResult{Balance: &bal, Transaction: &readable}, nil
+}
+
+// SendRawTransaction verifies a signed transaction, the hex of a serialized
+// coin.Transaction, and adds it to the mempool like a node's
+// /injectTransaction. It returns the txid. Its inputs must be confirmed
+// unspent outputs of the fake chain.
+func (p *Provider) SendRawTransaction(rawTx string) (tx interface{}, err error) {
+	b, err := hex.DecodeString(rawTx)
+	if err != nil {
+		return nil, fmt.Errorf("invalid raw transaction: %v", err)
+	}
+	txn, err := coin.TransactionDeserialize(b)
+	if err != nil {
+		return nil, err
+	}
+
+	p.DefaultBlockStore.Lock()
+	defer p.DefaultBlockStore.Unlock()
+
+	readable, err := p.injectTransaction(txn)
+	if err != nil {
+		return nil, err
+	}
+	return readable.Hash, nil
+}
+
+// spend builds a transaction paying coins to dest out of the confirmed unspent
+// outputs of the addresses of keys, like a wallet of the node: the outputs are
+// chosen to maximize the number spent, the hours left after the fee are split
+// between dest and the change, which goes back to the address of the first
+// output spent. Must be called with the store locked.
+func (p *Provider) spend(keys []cipher.SecKey, coins uint64, dest cipher.Address) (coin.Transaction, error) {
+	store := p.DefaultBlockStore
+	headTime := store.headTime(int64(store.BestBlockHeight))
+
+	secrets := make(map[cipher.Address]cipher.SecKey, len(keys))
+	var unspent []wallet.UxBalance
+	var total uint64
+	for _, key := range keys {
+		addr := cipher.AddressFromSecKey(key)
+		secrets[addr] = key
+
+		for _, out := range store.Addresses.Outputs(addr.String()) {
+			if out.Spent() && out.SpentHeight == 0 {
+				return coin.Transaction{}, wallet.ErrSpendingUnconfirmed
+			}
+			if out.Spent() || out.Height == 0 {
+				continue
+			}
+			hash, err := cipher.SHA256FromHex(out.Hash)
+			if err != nil {
+				return coin.Transaction{}, err
+			}
+			unspent = append(unspent, wallet.UxBalance{
+				Hash:    hash,
+				BkSeq:   uint64(out.Height),
+				Address: addr,
+				Coins:   uint64(out.Amount),
+				Hours:   calculatedHours(out, headTime),
+			})
+			total += uint64(out.Amount)
+		}
+	}
+	if total < coins {
+		return coin.Transaction{}, wallet.ErrInsufficientBalance
+	}
+
+	spends, err := wallet.ChooseSpendsMaximizeUxOuts(unspent, coins)
+	if err != nil {
+		return coin.Transaction{}, err
+	}
+
+	var txn coin.Transaction
+	toSign := make([]cipher.SecKey, len(spends))
+	var spendingCoins, spendingHours uint64
+	for i, ux := range spends {
+		txn.PushInput(ux.Hash)
+		toSign[i] = secrets[ux.Address]
+		spendingCoins += ux.Coins
+		spendingHours += ux.Hours
+	}
+	if spendingHours == 0 {
+		return coin.Transaction{}, fee.ErrTxnNoFee
+	}
+
+	changeCoins := spendingCoins - coins
+	haveChange := changeCoins > 0
+	changeHours, addrHours, outputHours := wallet.DistributeSpendHours(spendingHours, 1, haveChange)
+	if err := fee.VerifyTransactionFeeForHours(outputHours, spendingHours-outputHours); err != nil {
+		return coin.Transaction{}, err
+	}
+
+	if haveChange {
+		txn.PushOutput(spends[0].Address, changeCoins, changeHours)
+	}
+	txn.PushOutput(dest, coins, addrHours[0])
+
+	txn.SignInputs(toSign)
+	txn.UpdateHeader()
+	return txn, nil
+}
+
+// injectTransaction checks txn against the tip like a node accepting a
+// transaction: it must be well formed and signed, spend confirmed unspent
+// outputs, neither create nor destroy coins and burn enough coin hours. It is
+// then added to the mempool, to be confirmed by the next block. Must be called
+// with the store locked.
+func (p *Provider) injectTransaction(txn coin.Transaction) (visor.ReadableTransaction, error) {
+	if err := txn.Verify(); err != nil {
+		return visor.ReadableTransaction{}, err
+	}
+
+	store := p.DefaultBlockStore
+	if err := store.checkNewTx(txn.Hash().Hex()); err != nil {
+		return visor.ReadableTransaction{}, err
+	}
+
+	headTime := store.headTime(int64(store.BestBlockHeight))
+	uxIn := make(coin.UxArray, len(txn.In))
+	for i, in := range txn.In {
+		out, ok := store.Addresses.Output(in.Hex())
+		switch {
+		case !ok:
+			return visor.ReadableTransaction{}, fmt.Errorf("unspent output of %s does not exist", in.Hex())
+		case out.Spent():
+			return visor.ReadableTransaction{}, fmt.Errorf("output %s is already spent by %s", in.Hex(), out.SpentTxID)
+		case out.Height == 0:
+			return visor.ReadableTransaction{}, fmt.Errorf("output %s is not confirmed", in.Hex())
+		}
+
+		addr, err := cipher.DecodeBase58Address(out.Address)
+		if err != nil {
+			return visor.ReadableTransaction{}, err
+		}
+		if err := cipher.ChkSig(addr, cipher.AddSHA256(txn.InnerHash, in), txn.Sigs[i]); err != nil {
+			return visor.ReadableTransaction{}, errors.New("Signature not valid for output being spent")
+		}
+
+		uxIn[i] = coin.UxOut{
+			Head: coin.UxHead{Time: uint64(out.Time), BkSeq: uint64(out.Height)},
+			Body: coin.UxBody{Address: addr, Coins: uint64(out.Amount), Hours: out.Hours},
+		}
+	}
+
+	uxOut := make(coin.UxArray, len(txn.Out))
+	for i, out := range txn.Out {
+		uxOut[i] = coin.UxOut{Body: coin.UxBody{Address: out.Address, Coins: out.Coins, Hours: out.Hours}}
+	}
+	if err := coin.VerifyTransactionCoinsSpending(uxIn, uxOut); err != nil {
+		return visor.ReadableTransaction{}, err
+	}
+	if err := coin.VerifyTransactionHoursSpending(headTime, uxIn, uxOut); err != nil {
+		return visor.ReadableTransaction{}, err
+	}
+	if err := visor.VerifySingleTxnSoftConstraints(txn, headTime, uxIn, visor.DefaultMaxBlockSize); err != nil {
+		return visor.ReadableTransaction{}, err
+	}
+
+	readable, err := visor.NewReadableTransaction(&visor.Transaction{Txn: txn})
+	if err != nil {
+		return visor.ReadableTransaction{}, err
+	}
+	// a withdrawal pays no deposit, it has no uxid
+	p.addToMempool(fakeTx{tx: *readable})
+	return *readable, nil
+}
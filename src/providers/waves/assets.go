@@ -0,0 +1,127 @@
+package waves
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/modeneis/waves-go-client/model"
+
+	"github.com/modeneis/coind/src/server/model_server"
+)
+
+// Limits of the assets issued on the waves network
+const (
+	MinAssetNameLength     = 4
+	MaxAssetNameLength     = 16
+	MaxAssetDescriptionLen = 1000
+	MaxAssetDecimals       = 8
+)
+
+// AssetDetails mirrors the node's /assets/details/{assetId} response. Fake
+// assets are issued by the funding wallet, which pays every asset deposit.
+type AssetDetails struct {
+	AssetID        string `json:"assetId"`
+	IssueHeight    int64  `json:"issueHeight"`
+	IssueTimestamp int64  `json:"issueTimestamp"`
+	Issuer         string `json:"issuer"`
+	Name           string `json:"name"`
+	Description    string `json:"description"`
+	Decimals       int32  `json:"decimals"`
+	Reissuable     bool   `json:"reissuable"`
+	Quantity       int64  `json:"quantity"`
+}
+
+// IssueAsset records the metadata of a new asset, at the current tip, so that
+// deposits can pay it. No issue transaction is mined.
+func (p *Provider) IssueAsset(asset model_server.Asset) (details interface{}, err error) {
+	if len(asset.Name) < MinAssetNameLength || len(asset.Name) > MaxAssetNameLength {
+		return nil, fmt.Errorf("asset name must be %d to %d bytes", MinAssetNameLength, MaxAssetNameLength)
+	}
+	if len(asset.Description) > MaxAssetDescriptionLen {
+		return nil, fmt.Errorf("asset description must not be above %d bytes", MaxAssetDescriptionLen)
+	}
+	if asset.Decimals < 0 || asset.Decimals > MaxAssetDecimals {
+		return nil, fmt.Errorf("asset decimals must be 0 to %d", MaxAssetDecimals)
+	}
+	quantity, err := model_server.ParseUnits(asset.Decimals, asset.Quantity)
+	if err != nil {
+		return nil, fmt.Errorf("invalid asset quantity: %v", err)
+	}
+
+	p.DefaultBlockStore.Lock()
+	defer p.DefaultBlockStore.Unlock()
+
+	store := p.DefaultBlockStore
+	if asset.AssetID == "" {
+		asset.AssetID = newID(32)
+	} else if _, ok := store.assets[asset.AssetID]; ok {
+		return nil, fmt.Errorf("asset %s is already issued", asset.AssetID)
+	}
+
+	issued := &AssetDetails{
+		AssetID:        asset.AssetID,
+		IssueHeight:    int64(store.BestBlockHeight),
+		IssueTimestamp: timestamp(model_server.Now()),
+		Issuer:         FundingAddress,
+		Name:           asset.Name,
+		Description:    asset.Description,
+		Decimals:       asset.Decimals,
+		Reissuable:     asset.Reissuable,
+		Quantity:       quantity,
+	}
+	store.assets[issued.AssetID] = issued
+
+	c := *issued
+	return &c, nil
+}
+
+// GetAsset returns the metadata of an issued asset
+func (p *Provider) GetAsset(assetID string) (details interface{}, err error) {
+	p.DefaultBlockStore.RLock()
+	defer p.DefaultBlockStore.RUnlock()
+
+	asset, ok := p.DefaultBlockStore.assets[assetID]
+	if !ok {
+		return nil, fmt.Errorf("asset %s is not issued", assetID)
+	}
+	c := *asset
+	return &c, nil
+}
+
+// GetAssetBalances returns the confirmed balances of address in every asset
+// it holds, like the node's /assets/balance/{address}, or in assetID only,
+// like /assets/balance/{address}/{assetId}
+func (p *Provider) GetAssetBalances(address, assetID string) (balances interface{}, err error) {
+	p.DefaultBlockStore.RLock()
+	defer p.DefaultBlockStore.RUnlock()
+
+	store := p.DefaultBlockStore
+	held := store.Addresses.AssetBalances(address)
+
+	if assetID != "" {
+		if _, ok := store.assets[assetID]; !ok {
+			return nil, fmt.Errorf("asset %s is not issued", assetID)
+		}
+		return &model.Balances{
+			Address: address,
+			AssetID: assetID,
+			Balance: int(held[assetID].Confirmed.Coins),
+		}, nil
+	}
+
+	assets := &model.Assets{Address: address, Balances: []model.Balances{}}
+	for id, bal := range held {
+		if id == "" || bal.Confirmed.Coins == 0 {
+			continue
+		}
+		assets.Balances = append(assets.Balances, model.Balances{
+			AssetID: id,
+			Issued:  store.assets[id] != nil && store.assets[id].Issuer == address,
+			Balance: int(bal.Confirmed.Coins),
+		})
+	}
+	sort.Slice(assets.Balances, func(i, j int) bool {
+		return assets.Balances[i].AssetID < assets.Balances[j].AssetID
+	})
+	return assets, nil
+}
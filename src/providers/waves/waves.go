@@ -1,13 +1,19 @@
 package waves
 
 import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
 	"sync"
+	"time"
 
 	"github.com/modeneis/waves-go-client/client"
 	"github.com/modeneis/waves-go-client/model"
 
 	"github.com/btcsuite/btcd/btcjson"
+	"github.com/skycoin/skycoin/src/cipher/base58"
 
+	"github.com/modeneis/coind/src/server/address"
 	"github.com/modeneis/coind/src/server/model_server"
 )
 
@@ -17,6 +23,22 @@ import (
 
 //return back
 
+const (
+	// transferType is the type of waves transfer transactions
+	transferType = 4
+	// TransferFee is the fee of a transfer in wavelets, the minimum fee of
+	// the network
+	TransferFee = 100000
+)
+
+// FundingPublicKey is the public key of the simulated wallet sending every
+// fake transfer, and FundingAddress its address
+var (
+	FundingPublicKey = base58.Hex2Base58String(fundingKey[:])
+	FundingAddress   = address.NewWavesFromPublicKey(fundingKey[:])
+	fundingKey       = sha256.Sum256([]byte("coind waves funding wallet"))
+)
+
 // BlockStore holds fake block data
 type BlockStoreWaves struct {
 	sync.RWMutex
@@ -25,6 +47,12 @@ type BlockStoreWaves struct {
 	BlockTX         map[string]string
 	HashBlocks      map[string]*model.Blocks
 	NextHash        string
+	Addresses       *model_server.AddressIndex
+
+	mempool   []model.Transactions // unconfirmed transfers, oldest first
+	orphaned  map[string]string    // block signature of transfers dropped by a reorg
+	scheduled map[string]int64     // height of the block to confirm a pending transfer in
+	assets    map[string]*AssetDetails
 }
 
 // WavesFake is the main fields for waves fake coin
@@ -54,77 +82,720 @@ func (p *Provider) GetType() string {
 }
 
 func (p *Provider) Start() {
-	p.DefaultBlockStore = &BlockStoreWaves{
+	p.DefaultBlockStore = newBlockStoreWaves()
+}
+
+func newBlockStoreWaves() *BlockStoreWaves {
+	return &BlockStoreWaves{
 		BlockHashes: make(map[int64]string),
 		HashBlocks:  make(map[string]*model.Blocks),
 		BlockTX:     make(map[string]string),
+		Addresses:   model_server.NewAddressIndex(),
+		orphaned:    make(map[string]string),
+		scheduled:   make(map[string]int64),
+		assets:      make(map[string]*AssetDetails),
+	}
+}
+
+// clone returns a deep copy of the store data. Must be called with the store locked.
+func (s *BlockStoreWaves) clone() *BlockStoreWaves {
+	c := newBlockStoreWaves()
+	c.BestBlockHeight = s.BestBlockHeight
+	c.NextHash = s.NextHash
+	for height, hash := range s.BlockHashes {
+		c.BlockHashes[height] = hash
+	}
+	for hash, tx := range s.BlockTX {
+		c.BlockTX[hash] = tx
+	}
+	for hash, blocks := range s.HashBlocks {
+		c.HashBlocks[hash] = copyBlocks(blocks)
+	}
+	c.Addresses = s.Addresses.Clone()
+	c.mempool = append([]model.Transactions(nil), s.mempool...)
+	for id, hash := range s.orphaned {
+		c.orphaned[id] = hash
+	}
+	for id, height := range s.scheduled {
+		c.scheduled[id] = height
+	}
+	for id, asset := range s.assets {
+		details := *asset
+		c.assets[id] = &details
+	}
+	return c
+}
+
+// load replaces the store data with the data of other. Must be called with the store locked.
+func (s *BlockStoreWaves) load(other *BlockStoreWaves) {
+	s.BestBlockHeight = other.BestBlockHeight
+	s.BlockHashes = other.BlockHashes
+	s.BlockTX = other.BlockTX
+	s.HashBlocks = other.HashBlocks
+	s.NextHash = other.NextHash
+	s.Addresses = other.Addresses
+	s.mempool = other.mempool
+	s.orphaned = other.orphaned
+	s.scheduled = other.scheduled
+	s.assets = other.assets
+}
+
+// copyBlocks returns a deep copy of a block
+func copyBlocks(blocks *model.Blocks) *model.Blocks {
+	if blocks == nil {
+		return nil
+	}
+
+	c := *blocks
+	c.Features = append([]int(nil), blocks.Features...)
+	c.Transactions = append([]model.Transactions(nil), blocks.Transactions...)
+	return &c
+}
+
+// Reset drops every fake block, going back to an empty chain
+func (p *Provider) Reset() {
+	p.DefaultBlockStore.Lock()
+	defer p.DefaultBlockStore.Unlock()
+
+	p.DefaultBlockStore.load(newBlockStoreWaves())
+}
+
+// Snapshot returns a copy of the block store
+func (p *Provider) Snapshot() (snapshot interface{}) {
+	p.DefaultBlockStore.RLock()
+	defer p.DefaultBlockStore.RUnlock()
+
+	return p.DefaultBlockStore.clone()
+}
+
+// Restore replaces the block store with a copy of a snapshot taken by Snapshot
+func (p *Provider) Restore(snapshot interface{}) error {
+	store, ok := snapshot.(*BlockStoreWaves)
+	if !ok {
+		return fmt.Errorf("invalid %s snapshot %T", p.GetType(), snapshot)
 	}
+
+	p.DefaultBlockStore.Lock()
+	defer p.DefaultBlockStore.Unlock()
+
+	p.DefaultBlockStore.load(store.clone())
+	return nil
 }
 
-func (p *Provider) CreateFakeBlock(deposit model_server.Deposit) (retBlocks interface{}, err error) {
+// CreateFakeBlock appends a block confirming deposit to the chain, and
+// returns the receipt of the deposit along with the block. A deposit for a
+// later height is pending until the block at that height is connected, and the
+// pending transfer is returned. A deposit for a past height is added to the
+// block at that height, which is returned.
+func (p *Provider) CreateFakeBlock(deposit model_server.Deposit) (receipt model_server.Receipt, retBlocks interface{}, err error) {
+	template, err := p.template()
+	if err != nil {
+		return receipt, nil, err
+	}
 
-	var blocks *model.Blocks
 	p.DefaultBlockStore.Lock()
-	defer func() {
-		//DefaultBlockStore.BestBlockHeight++
-		hash := blocks.Signature
-		tx := blocks.Signature
+	defer p.DefaultBlockStore.Unlock()
 
-		p.DefaultBlockStore.BestBlockHeight++
-		p.DefaultBlockStore.BlockHashes[int64(p.DefaultBlockStore.BestBlockHeight)] = hash
-		p.DefaultBlockStore.HashBlocks[hash] = blocks
-		p.DefaultBlockStore.BlockTX[hash] = tx
+	transfer, err := p.DefaultBlockStore.newTransfer(deposit)
+	if err != nil {
+		return receipt, nil, err
+	}
+	if err = p.DefaultBlockStore.checkNewTx(transfer.ID); err != nil {
+		return receipt, nil, err
+	}
+
+	tip := int64(p.DefaultBlockStore.BestBlockHeight)
+	switch {
+	case deposit.Height == 0 || deposit.Height == tip+1:
+		blocks := p.connectBlock(template, []model.Transactions{transfer}, nil)
+		return p.receipt(transfer, blocks), blocks, nil
+	case deposit.Height > tip:
+		p.DefaultBlockStore.scheduled[transfer.ID] = deposit.Height
+		p.addToMempool(transfer)
+		return p.receipt(transfer, nil), &transfer, nil
+	default:
+		blocks := p.backfill(&transfer, deposit.Height)
+		return p.receipt(transfer, blocks), blocks, nil
+	}
+}
+
+// receipt returns the receipt of the deposit paid by transfer, confirmed by
+// blocks, or pending if blocks is nil. Must be called with the store locked.
+func (p *Provider) receipt(transfer model.Transactions, blocks *model.Blocks) model_server.Receipt {
+	decimals := model_server.Decimals[p.GetType()]
+	if asset, ok := p.DefaultBlockStore.assets[transfer.AssetID]; ok {
+		decimals = asset.Decimals
+	}
 
-		// Update NextHash of previous block
-		bestHeight := int64(p.DefaultBlockStore.BestBlockHeight)
-		prevBlockHash := p.DefaultBlockStore.BlockHashes[bestHeight]
+	receipt := model_server.Receipt{
+		CoinType:  p.GetType(),
+		TxID:      transfer.ID,
+		Address:   transfer.Recipient,
+		Amount:    model_server.FormatUnits(decimals, transfer.Amount),
+		AssetID:   transfer.AssetID,
+		Status:    model_server.TxPending,
+		Timestamp: transfer.Timestamp / 1000,
+	}
+	if blocks != nil {
+		receipt.Status = model_server.TxConfirmed
+		receipt.BlockHash = blocks.Signature
+		receipt.Height = blocks.Height
+		receipt.Timestamp = blocks.Timestamp / 1000
+	}
+	return receipt
+}
+
+// AddToMempool adds an unconfirmed transfer paying deposit, it is confirmed by
+// the next mined block, or by the block at the height of the deposit. A
+// deposit for a past height is added to the block at that height right away.
+func (p *Provider) AddToMempool(deposit model_server.Deposit) (tx interface{}, err error) {
+	p.DefaultBlockStore.Lock()
+	defer p.DefaultBlockStore.Unlock()
+
+	transfer, err := p.DefaultBlockStore.newTransfer(deposit)
+	if err != nil {
+		return nil, err
+	}
+	if err = p.DefaultBlockStore.checkNewTx(transfer.ID); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case deposit.Height == 0:
+		p.addToMempool(transfer)
+	case deposit.Height > int64(p.DefaultBlockStore.BestBlockHeight):
+		p.DefaultBlockStore.scheduled[transfer.ID] = deposit.Height
+		p.addToMempool(transfer)
+	default:
+		p.backfill(&transfer, deposit.Height)
+	}
+	return &transfer, nil
+}
 
-		prevBlock := p.DefaultBlockStore.HashBlocks[prevBlockHash]
+// checkNewTx fails if id is already confirmed or pending. Must be called with
+// the store locked.
+func (s *BlockStoreWaves) checkNewTx(id string) error {
+	if _, ok := s.BlockTX[id]; ok {
+		return fmt.Errorf("transaction %s is already confirmed", id)
+	}
+	for _, pending := range s.mempool {
+		if pending.ID == id {
+			return fmt.Errorf("transaction %s is already pending", id)
+		}
+	}
+	return nil
+}
+
+// takeMempool removes the transfers of the mempool for which due is true and
+// returns them, oldest first. Must be called with the store locked.
+func (s *BlockStoreWaves) takeMempool(due func(model.Transactions) bool) []model.Transactions {
+	var taken, kept []model.Transactions
+	for _, pending := range s.mempool {
+		if due(pending) {
+			taken = append(taken, pending)
+			delete(s.scheduled, pending.ID)
+		} else {
+			kept = append(kept, pending)
+		}
+	}
+	s.mempool = kept
+	return taken
+}
+
+// addToMempool indexes and announces an unconfirmed transfer. Must be called
+// with the store locked.
+func (p *Provider) addToMempool(transfer model.Transactions) {
+	p.DefaultBlockStore.mempool = append(p.DefaultBlockStore.mempool, transfer)
+	p.DefaultBlockStore.indexTransfer(transfer, 0, "", transfer.Timestamp)
+
+	if isDeposit(transfer) {
+		accepted := p.depositEvent(transfer)
+		accepted.Type = model_server.EventDepositAccepted
+		model_server.PublishEvent(accepted)
+	}
+}
+
+// MineBlock appends a block confirming every transfer of the mempool but those
+// for a later height, or an empty block if there are none
+func (p *Provider) MineBlock() (blocks interface{}, err error) {
+	template, err := p.template()
+	if err != nil {
+		return nil, err
+	}
+
+	p.DefaultBlockStore.Lock()
+	defer p.DefaultBlockStore.Unlock()
+
+	store := p.DefaultBlockStore
+	pending := store.takeMempool(func(tx model.Transactions) bool {
+		return store.scheduled[tx.ID] == 0
+	})
+
+	known := make(map[string]bool, len(pending))
+	for _, tx := range pending {
+		known[tx.ID] = true
+	}
+
+	return p.connectBlock(template, pending, known), nil
+}
+
+// Reorg disconnects the last reorg.Depth blocks and connects a block for each
+// entry of reorg.Blocks in their place. It returns the new tip.
+func (p *Provider) Reorg(reorg model_server.Reorg) (tip interface{}, err error) {
+	var template *model.Blocks
+	if len(reorg.Blocks) > 0 {
+		if template, err = p.template(); err != nil {
+			return nil, err
+		}
+	}
+
+	p.DefaultBlockStore.Lock()
+	defer p.DefaultBlockStore.Unlock()
+
+	if err = reorg.Validate(p.GetType(), int64(p.DefaultBlockStore.BestBlockHeight)); err != nil {
+		return nil, err
+	}
+
+	branch := make([][]model.Transactions, 0, len(reorg.Blocks))
+	for _, deposits := range reorg.Blocks {
+		txs := make([]model.Transactions, 0, len(deposits))
+		for _, deposit := range deposits {
+			transfer, err := p.DefaultBlockStore.newTransfer(deposit)
+			if err != nil {
+				return nil, err
+			}
+			txs = append(txs, transfer)
+		}
+		branch = append(branch, txs)
+	}
+
+	disconnected := p.disconnectBlocks(reorg.Depth)
+	for _, txs := range branch {
+		p.connectBlock(template, txs, disconnected)
+	}
+
+	return p.bestBlock(), nil
+}
+
+// DoubleSpend replaces the transfer doubleSpend.TxID with a conflicting one
+// from the same sender at the same timestamp, see model_server.DoubleSpend. It
+// returns the conflicting transfer.
+func (p *Provider) DoubleSpend(doubleSpend model_server.DoubleSpend) (tx interface{}, err error) {
+	if err = doubleSpend.Validate(p.GetType()); err != nil {
+		return nil, err
+	}
+
+	template, err := p.template()
+	if err != nil {
+		return nil, err
+	}
+
+	p.DefaultBlockStore.Lock()
+	defer p.DefaultBlockStore.Unlock()
+
+	store := p.DefaultBlockStore
+	for i, original := range store.mempool {
+		if original.ID != doubleSpend.TxID {
+			continue
+		}
+
+		conflicting, err := store.conflictingTransfer(original, doubleSpend.Deposit)
+		if err != nil {
+			return nil, err
+		}
+
+		store.mempool = append(store.mempool[:i:i], store.mempool[i+1:]...)
+		delete(store.scheduled, original.ID)
+		store.Addresses.RemoveTransaction(original.ID)
+
+		p.publishDoubleSpend(original, conflicting, "")
+		if doubleSpend.Mine {
+			p.connectBlock(template, []model.Transactions{conflicting}, nil)
+		} else {
+			p.addToMempool(conflicting)
+		}
+		return &conflicting, nil
+	}
+
+	hash, ok := store.BlockTX[doubleSpend.TxID]
+	if !ok {
+		return nil, fmt.Errorf("transaction %s is neither pending nor confirmed", doubleSpend.TxID)
+	}
 
-		if prevBlock != nil && len(prevBlock.Transactions) > 0 {
-			prevBlock.Reference = hash
-			p.DefaultBlockStore.HashBlocks[prevBlockHash] = prevBlock
+	// collect the blocks from the one holding the original up to the tip
+	var branch [][]model.Transactions
+	var original, conflicting model.Transactions
+	for height := store.HashBlocks[hash].Height; height <= int64(store.BestBlockHeight); height++ {
+		var txs []model.Transactions
+		for _, blockTx := range store.HashBlocks[store.BlockHashes[height]].Transactions {
+			if blockTx.ID == doubleSpend.TxID {
+				original = blockTx
+				if conflicting, err = store.conflictingTransfer(blockTx, doubleSpend.Deposit); err != nil {
+					return nil, err
+				}
+				blockTx = conflicting
+			}
+			txs = append(txs, blockTx)
 		}
+		branch = append(branch, txs)
+	}
+	// the conflicting chain is one block longer, so that it wins
+	branch = append(branch, nil)
 
-		// Update Hash of new block
-		p.DefaultBlockStore.HashBlocks[blocks.Signature] = blocks
+	disconnected := p.disconnectBlocks(len(branch) - 1)
+	p.publishDoubleSpend(original, conflicting, hash)
+	for _, txs := range branch {
+		p.connectBlock(template, txs, disconnected)
+	}
 
-		p.DefaultBlockStore.Unlock()
-	}()
+	return &conflicting, nil
+}
 
-	blocks, _, err = client.NewBlocksService(p.MainNET).GetBlocksLast()
+// conflictingTransfer returns a transfer from the sender of original, at the
+// same timestamp, paying deposit instead. Must be called with the store locked.
+func (s *BlockStoreWaves) conflictingTransfer(original model.Transactions, deposit model_server.Deposit) (model.Transactions, error) {
+	conflicting, err := s.newTransfer(deposit)
+	if err != nil {
+		return conflicting, err
+	}
+	conflicting.Timestamp = original.Timestamp
+	return conflicting, nil
+}
+
+// publishDoubleSpend announces that the transfer original, confirmed in the
+// block blockHash if it is not pending, is replaced by the conflicting one
+func (p *Provider) publishDoubleSpend(original, conflicting model.Transactions, blockHash string) {
+	spent := p.depositEvent(original)
+	spent.Type = model_server.EventDepositDoubleSpent
+	spent.Height = original.Height
+	spent.BlockHash = blockHash
+	spent.ReplacedBy = conflicting.ID
+	model_server.PublishEvent(spent)
+}
+
+// template fetches the latest upstream block, fake blocks copy its header and
+// its first transfer. Seeded chains use WavesBlockString instead.
+func (p *Provider) template() (*model.Blocks, error) {
+	if model_server.Seeded() {
+		var blocks model.Blocks
+		if err := json.Unmarshal([]byte(WavesBlockString), &blocks); err != nil {
+			return nil, err
+		}
+		return &blocks, nil
+	}
+
+	blocks, _, err := client.NewBlocksService(p.MainNET).GetBlocksLast()
 	if err != nil {
 		return nil, err
 	}
-	//add fake block to existing block
+	return blocks, nil
+}
+
+// newTransfer returns a transfer from the funding wallet paying deposit in
+// WAVES, or in an issued asset, signed at the current time of the clock. The
+// fee is TransferFee units of WAVES, or of the fee asset of the deposit. An
+// asset deposit must not take the amount held by every address above the
+// issued quantity. Must be called with the store locked.
+func (s *BlockStoreWaves) newTransfer(deposit model_server.Deposit) (model.Transactions, error) {
+	tx := model.Transactions{
+		Type:            transferType,
+		ID:              deposit.Tx,
+		Sender:          FundingAddress,
+		SenderPublicKey: FundingPublicKey,
+		Recipient:       deposit.Address,
+		Fee:             TransferFee,
+		Timestamp:       timestamp(model_server.Now()),
+		Signature:       newID(64),
+		AssetID:         deposit.AssetID,
+	}
+	if tx.ID == "" {
+		tx.ID = newID(32)
+	}
+	if deposit.Attachment != "" {
+		tx.Attachment = base58.Hex2Base58String([]byte(deposit.Attachment))
+	}
+	if deposit.FeeAssetID != "" {
+		if _, ok := s.assets[deposit.FeeAssetID]; !ok {
+			return tx, fmt.Errorf("fee asset %s is not issued", deposit.FeeAssetID)
+		}
+		tx.FeeAsset = deposit.FeeAssetID
+	}
+
+	if deposit.AssetID == "" {
+		// the deposit was validated, its amount is whole wavelets
+		tx.Amount, _ = deposit.Units("WAVES")
+		return tx, nil
+	}
+
+	asset, ok := s.assets[deposit.AssetID]
+	if !ok {
+		return tx, fmt.Errorf("asset %s is not issued", deposit.AssetID)
+	}
+	amount, err := deposit.AssetUnits(asset.Decimals)
+	if err != nil {
+		return tx, fmt.Errorf("invalid amount of asset %s: %v", asset.AssetID, err)
+	}
+	if supply := s.Addresses.AssetSupply(asset.AssetID); amount > asset.Quantity-supply {
+		return tx, fmt.Errorf("asset %s has %d units left of the %d issued", asset.AssetID, asset.Quantity-supply, asset.Quantity)
+	}
+	tx.Amount = amount
+	return tx, nil
+}
+
+// transferOutput returns the entry of the address index crediting the
+// recipient of tx at the timestamp at, confirmed in the block hash at height
+// if height is not 0
+func transferOutput(tx model.Transactions, height int64, hash string, at int64) model_server.UxOut {
+	return model_server.UxOut{
+		TxID:      tx.ID,
+		Address:   tx.Recipient,
+		Amount:    tx.Amount,
+		Asset:     tx.AssetID,
+		Height:    height,
+		BlockHash: hash,
+		Time:      at,
+	}
+}
+
+// indexTransfer records tx in the address index at the timestamp at,
+// confirmed in the block hash at height if height is not 0. The recipient is
+// credited, and the sender of a withdrawal is debited of the amount, entry 1,
+// and of the fee in WAVES, entry 2. The funding wallet is never debited. Must
+// be called with the store locked.
+func (s *BlockStoreWaves) indexTransfer(tx model.Transactions, height int64, hash string, at int64) {
+	s.Addresses.AddOutput(transferOutput(tx, height, hash, at))
+	if isDeposit(tx) {
+		return
+	}
 
-	var fakeTX model.Transactions
+	debit := transferOutput(tx, height, hash, at)
+	debit.Address = tx.Sender
+	debit.N = 1
+	s.Addresses.Debit(debit)
+
+	debit.N = 2
+	debit.Amount = tx.Fee
+	debit.Asset = ""
+	s.Addresses.Debit(debit)
+}
+
+// wavesFee returns the fee of tx counted by its block, the blocks only sum
+// the fees paid in WAVES
+func wavesFee(tx model.Transactions) int64 {
+	if tx.FeeAsset != "" {
+		return 0
+	}
+	return tx.Fee
+}
+
+// isDeposit reports whether tx is a fake deposit sent by the funding wallet,
+// rather than a withdrawal
+func isDeposit(tx model.Transactions) bool {
+	return tx.Sender == FundingAddress
+}
+
+// timestamp returns t in milliseconds, the unit of the waves timestamps
+func timestamp(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// connectBlock builds a block on top of the tip out of the upstream template
+// holding txs, along with the pending transfers for its height and the pending
+// withdrawals, then stores, indexes and announces it. Transfers whose id is in
+// known were seen before and are not announced as accepted again. Must be
+// called with the store locked. The block returned is a copy, safe to use
+// unlocked.
+func (p *Provider) connectBlock(template *model.Blocks, txs []model.Transactions, known map[string]bool) *model.Blocks {
+	store := p.DefaultBlockStore
+	height := int64(store.BestBlockHeight) + 1
+
+	scheduled := store.takeMempool(func(tx model.Transactions) bool {
+		h := store.scheduled[tx.ID]
+		return !isDeposit(tx) || (h != 0 && h <= height)
+	})
+	if len(scheduled) > 0 {
+		if known == nil {
+			known = make(map[string]bool, len(scheduled))
+		}
+		for _, tx := range scheduled {
+			known[tx.ID] = true
+		}
+		txs = append(txs, scheduled...)
+	}
+
+	blocks := copyBlocks(template)
+	blocks.Height = height
+	blocks.Signature = newID(64)
+	blocks.Timestamp = timestamp(model_server.Now())
+	if prevHash, ok := store.BlockHashes[height-1]; ok {
+		blocks.Reference = prevHash
+	}
+
+	blocks.Transactions = make([]model.Transactions, 0, len(txs))
+	blocks.Fee = 0
+	for _, tx := range txs {
+		tx.Height = height
+		blocks.Transactions = append(blocks.Transactions, tx)
+		blocks.Fee += wavesFee(tx)
+	}
+	blocks.TransactionCount = len(blocks.Transactions)
+
+	hash := blocks.Signature
+	store.BestBlockHeight = int32(height)
+	store.BlockHashes[height] = hash
+	store.HashBlocks[hash] = blocks
 	for _, tx := range blocks.Transactions {
+		store.BlockTX[tx.ID] = hash
+		delete(store.orphaned, tx.ID)
+		store.indexTransfer(tx, height, hash, blocks.Timestamp)
+	}
+
+	p.publishBlock(blocks, known)
+	return copyBlocks(blocks)
+}
 
-		//TODO: properly test and select the best block
-		if tx.Recipient != "" {
-			fakeTX.ID = tx.ID
-			fakeTX.Signature = tx.Signature
-			fakeTX.Height = tx.Height
+// backfill adds transfer to the block at height, below the tip, as a transfer
+// the chain had all along, then reindexes the addresses and announces the
+// deposit. Must be called with the store locked. The block returned is a copy,
+// safe to use unlocked.
+func (p *Provider) backfill(transfer *model.Transactions, height int64) *model.Blocks {
+	store := p.DefaultBlockStore
+	hash := store.BlockHashes[height]
+	blocks := store.HashBlocks[hash]
+
+	transfer.Height = height
+	transfer.Timestamp = blocks.Timestamp
+	blocks.Transactions = append(blocks.Transactions, *transfer)
+	blocks.TransactionCount = len(blocks.Transactions)
+	blocks.Fee += wavesFee(*transfer)
+
+	store.BlockTX[transfer.ID] = hash
+	delete(store.orphaned, transfer.ID)
+	p.reindex()
+
+	e := p.depositEvent(*transfer)
+	e.Type = model_server.EventDepositAccepted
+	model_server.PublishEvent(e)
+
+	e.Type = model_server.EventDepositConfirmed
+	e.Height = height
+	e.BlockHash = hash
+	model_server.PublishEvent(e)
+	return copyBlocks(blocks)
+}
 
-			fakeTX.Recipient = deposit.Address
-			fakeTX.Amount = deposit.Value
-			break
+// reindex rebuilds the address index out of the chain, then the mempool, so
+// that a backfilled transfer is indexed in order. Must be called with the
+// store locked.
+func (p *Provider) reindex() {
+	store := p.DefaultBlockStore
+	store.Addresses = model_server.NewAddressIndex()
+
+	for height := int64(1); height <= int64(store.BestBlockHeight); height++ {
+		hash := store.BlockHashes[height]
+		blocks := store.HashBlocks[hash]
+		for _, tx := range blocks.Transactions {
+			store.indexTransfer(tx, height, hash, blocks.Timestamp)
 		}
 	}
+	for _, pending := range store.mempool {
+		store.indexTransfer(pending, 0, "", pending.Timestamp)
+	}
+}
 
-	//add fake tx to transactions
-	blocks.Transactions = append(blocks.Transactions, fakeTX)
+// disconnectBlocks removes the last depth blocks from the chain, newest first,
+// and returns the ids of their transactions. The blocks can still be looked up
+// by signature, and their transfers are orphaned until a later block includes
+// them again. Must be called with the store locked.
+func (p *Provider) disconnectBlocks(depth int) map[string]bool {
+	store := p.DefaultBlockStore
+	txids := make(map[string]bool)
+
+	for i := 0; i < depth && store.BestBlockHeight > 0; i++ {
+		height := int64(store.BestBlockHeight)
+		hash := store.BlockHashes[height]
+
+		if blocks, ok := store.HashBlocks[hash]; ok {
+			for _, tx := range blocks.Transactions {
+				delete(store.BlockTX, tx.ID)
+				store.orphaned[tx.ID] = hash
+				store.Addresses.RemoveTransaction(tx.ID)
+				txids[tx.ID] = true
+			}
+		}
 
-	//return blocks with fake tx added
+		delete(store.BlockHashes, height)
+		store.BestBlockHeight--
 
-	return blocks, err
+		model_server.PublishEvent(model_server.Event{
+			Type:      model_server.EventBlockDisconnected,
+			CoinType:  p.GetType(),
+			Height:    height,
+			BlockHash: hash,
+		})
+	}
+
+	return txids
+}
+
+// publishBlock publishes the events of a new block and of the deposits it
+// confirms
+func (p *Provider) publishBlock(blocks *model.Blocks, known map[string]bool) {
+	deposits := make([]model_server.Event, 0, len(blocks.Transactions))
+	for _, tx := range blocks.Transactions {
+		if !isDeposit(tx) {
+			continue
+		}
+		deposit := p.depositEvent(tx)
+		deposits = append(deposits, deposit)
+
+		if !known[tx.ID] {
+			accepted := deposit
+			accepted.Type = model_server.EventDepositAccepted
+			model_server.PublishEvent(accepted)
+		}
+	}
+
+	model_server.PublishEvent(model_server.Event{
+		Type:      model_server.EventBlockConnected,
+		CoinType:  p.GetType(),
+		Height:    blocks.Height,
+		BlockHash: blocks.Signature,
+	})
+
+	for _, confirmed := range deposits {
+		confirmed.Type = model_server.EventDepositConfirmed
+		confirmed.Height = blocks.Height
+		confirmed.BlockHash = blocks.Signature
+		model_server.PublishEvent(confirmed)
+	}
+}
+
+// depositEvent returns the event of the deposit paid by tx, without its type
+func (p *Provider) depositEvent(tx model.Transactions) model_server.Event {
+	return model_server.Event{
+		CoinType: p.GetType(),
+		TxID:     tx.ID,
+		Address:  tx.Recipient,
+		Amount:   tx.Amount,
+		AssetID:  tx.AssetID,
+	}
+}
+
+// newID returns a random base58 id of size bytes, 32 for transaction ids and
+// 64 for signatures
+func newID(size int) string {
+	b := make([]byte, size)
+	model_server.RandomBytes(b)
+	return base58.Hex2Base58String(b)
 }
 
 func (p *Provider) GetBlock(hash string) (block interface{}, err error) {
+	p.DefaultBlockStore.RLock()
+	defer p.DefaultBlockStore.RUnlock()
 
 	if b, ok := p.DefaultBlockStore.HashBlocks[hash]; ok {
-		block = b
+		block = copyBlocks(b)
 		return
 	} else {
 		return nil, &btcjson.RPCError{
@@ -136,19 +807,30 @@ func (p *Provider) GetBlock(hash string) (block interface{}, err error) {
 }
 
 func (p *Provider) GetBestBlock(seq int64) (block interface{}, err error) {
+	p.DefaultBlockStore.RLock()
+	defer p.DefaultBlockStore.RUnlock()
+
+	return p.bestBlock(), nil
+}
+
+// bestBlock returns the hash and the height of the tip. Must be called with
+// the store locked.
+func (p *Provider) bestBlock() (block interface{}) {
 	if hash, ok := p.DefaultBlockStore.BlockHashes[int64(p.DefaultBlockStore.BestBlockHeight)]; ok {
 		block = &btcjson.GetBestBlockResult{
 			Hash:   hash,
 			Height: p.DefaultBlockStore.BestBlockHeight,
 		}
-		return block, nil
 	}
-	return block, err
+	return block
 }
 
 func (p *Provider) GetGetBlockHash(tx string) (block interface{}, err error) {
+	p.DefaultBlockStore.RLock()
+	defer p.DefaultBlockStore.RUnlock()
+
 	if hash, ok := p.DefaultBlockStore.BlockTX[tx]; ok {
-		block = p.DefaultBlockStore.HashBlocks[hash]
+		block = copyBlocks(p.DefaultBlockStore.HashBlocks[hash])
 	} else {
 		err = &btcjson.RPCError{
 			Code:    btcjson.ErrRPCBlockNotFound,
@@ -158,6 +840,178 @@ func (p *Provider) GetGetBlockHash(tx string) (block interface{}, err error) {
 	return block, err
 }
 
+// TransactionInfo is the node's /transactions/info/{id} response, extended
+// like /transactions/status with the status and confirmations of the transfer,
+// and with the signature of its block. Height is the height of the block, or 0
+// while the transfer is pending.
+type TransactionInfo struct {
+	model.Transactions
+	Status         string `json:"status"`
+	Confirmations  int64  `json:"confirmations"`
+	BlockSignature string `json:"blockSignature,omitempty"`
+}
+
+// GetTransaction looks up a transfer in the chain, the mempool and the
+// transfers orphaned by reorgs
+func (p *Provider) GetTransaction(txid string) (tx interface{}, err error) {
+	p.DefaultBlockStore.RLock()
+	defer p.DefaultBlockStore.RUnlock()
+
+	store := p.DefaultBlockStore
+	if hash, ok := store.BlockTX[txid]; ok {
+		return p.blockTransaction(hash, txid, model_server.TxConfirmed)
+	}
+	for _, pending := range store.mempool {
+		if pending.ID == txid {
+			return &TransactionInfo{
+				Transactions: pending,
+				Status:       model_server.TxPending,
+			}, nil
+		}
+	}
+	if hash, ok := store.orphaned[txid]; ok {
+		return p.blockTransaction(hash, txid, model_server.TxOrphaned)
+	}
+
+	return nil, fmt.Errorf("transaction %s not found", txid)
+}
+
+// GetTransactionStatus looks up a transfer like GetTransaction, and returns
+// where it stands
+func (p *Provider) GetTransactionStatus(txid string) (status model_server.TxStatus, err error) {
+	tx, err := p.GetTransaction(txid)
+	if err != nil {
+		return status, err
+	}
+
+	info := tx.(*TransactionInfo)
+	return model_server.TxStatus{
+		TxID:          info.ID,
+		Status:        info.Status,
+		Confirmations: info.Confirmations,
+		Height:        info.Height,
+		BlockHash:     info.BlockSignature,
+		Time:          info.Timestamp / int64(time.Second/time.Millisecond),
+	}, nil
+}
+
+// blockTransaction returns the transfer txid, found in the block with the
+// given signature. Must be called with the store locked.
+func (p *Provider) blockTransaction(hash, txid, status string) (*TransactionInfo, error) {
+	block, ok := p.DefaultBlockStore.HashBlocks[hash]
+	if !ok {
+		return nil, fmt.Errorf("block %s of transaction %s not found", hash, txid)
+	}
+
+	for _, tx := range block.Transactions {
+		if tx.ID != txid {
+			continue
+		}
+		info := &TransactionInfo{
+			Transactions:   tx,
+			Status:         status,
+			BlockSignature: hash,
+		}
+		if status == model_server.TxConfirmed {
+			info.Confirmations = model_server.Confirmations(int64(p.DefaultBlockStore.BestBlockHeight), block.Height)
+		}
+		return info, nil
+	}
+	return nil, fmt.Errorf("transaction %s not found in block %s", txid, hash)
+}
+
 func (p *Provider) GetBlockCount() (count int32) {
-	return count
+	p.DefaultBlockStore.RLock()
+	defer p.DefaultBlockStore.RUnlock()
+
+	return p.DefaultBlockStore.BestBlockHeight
+}
+
+// AddressBalance mirrors the node's /addresses/balance/{address} response,
+// extended with the balance including unconfirmed transfers
+type AddressBalance struct {
+	Address       string `json:"address"`
+	Confirmations int    `json:"confirmations"`
+	Balance       int64  `json:"balance"`
+	Unconfirmed   int64  `json:"unconfirmed"`
+}
+
+// GetBalance returns the WAVES balance of address
+func (p *Provider) GetBalance(address string) (balance interface{}, err error) {
+	p.DefaultBlockStore.RLock()
+	defer p.DefaultBlockStore.RUnlock()
+
+	bal := p.DefaultBlockStore.Addresses.Balance(address)
+	return &AddressBalance{
+		Address:       address,
+		Confirmations: 1,
+		Balance:       bal.Confirmed.Coins,
+		Unconfirmed:   bal.Unconfirmed.Coins,
+	}, nil
+}
+
+// GetOutputs is not supported, waves is account based and has no outputs
+func (p *Provider) GetOutputs(address string) (outputs interface{}, err error) {
+	return nil, fmt.Errorf("%s is account based and has no unspent outputs", p.GetType())
 }
+
+// GetAddressTransactions returns a page of the transfers of address, newest first
+func (p *Provider) GetAddressTransactions(address string, offset, limit int) (txs interface{}, err error) {
+	p.DefaultBlockStore.RLock()
+	defer p.DefaultBlockStore.RUnlock()
+
+	history, total := p.DefaultBlockStore.Addresses.Transactions(address, offset, limit)
+
+	results := make([]model.Transactions, 0, len(history))
+	for _, entry := range history {
+		tx := model.Transactions{ID: entry.TxID, Height: entry.Height}
+		if block, ok := p.DefaultBlockStore.HashBlocks[entry.BlockHash]; ok {
+			for _, blockTx := range block.Transactions {
+				if blockTx.ID == entry.TxID && (blockTx.Recipient == address || blockTx.Sender == address) {
+					tx = blockTx
+					tx.Height = entry.Height
+				}
+			}
+		} else {
+			for _, pending := range p.DefaultBlockStore.mempool {
+				if pending.ID == entry.TxID {
+					tx = pending
+				}
+			}
+		}
+		results = append(results, tx)
+	}
+
+	return &model_server.TransactionPage{
+		Address:      address,
+		Total:        total,
+		Offset:       offset,
+		Limit:        limit,
+		Transactions: results,
+	}, nil
+}
+
+// WavesBlockString is a block of the waves mainnet, the template of seeded
+// chains
+var WavesBlockString = `{
+    "version": 3,
+    "timestamp": 1523460325440,
+    "reference": "4ZgjaHybzdW3JsHrMxDb6bHdtmRAKBUDFbXbpsuEuPQ3xuM4iU6SWErGhd1EVXgL5VbBixHThpZWbzLHC9P4DSWZ",
+    "generator": "3PMj3yGPBEa1Sx9X4TSBFeJCMMaE3wvKR4N",
+    "signature": "2FJ9TvDcBeNhsGGh6quMGCo1W3uqvSzPLcwvUQnhoW5sNhv5KkGfBjFWMEuUkxLgNaS7MMwi4H7ck9kvz2DKCjAo",
+    "transactionCount": 1,
+    "fee": 100000,
+    "transactions": [
+        {
+            "type": 4,
+            "id": "3KZwCgCNa6eXmMvLRTRgGBbfyDCYhCcS6MB6GpRT2Ak8",
+            "sender": "3PMj3yGPBEa1Sx9X4TSBFeJCMMaE3wvKR4N",
+            "recipient": "3P31zvGdh6ai6JK6zZ18TjYzJsa1B83YPoj",
+            "amount": 100000000,
+            "fee": 100000,
+            "timestamp": 1523460321234,
+            "height": 968500
+        }
+    ],
+    "height": 968500
+}`
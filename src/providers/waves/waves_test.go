@@ -34,7 +34,7 @@ func TestCreateFakeDepositWaves(t *testing.T) {
 			waves := waves.Provider{}
 			waves.Start()
 
-			blocks, err := waves.CreateFakeBlock(tc.Deposit)
+			_, blocks, err := waves.CreateFakeBlock(tc.Deposit)
 			require.NoError(t, err)
 
 			txFound := model.Transactions{}
@@ -0,0 +1,155 @@
+package waves
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/modeneis/waves-go-client/model"
+	"github.com/skycoin/skycoin/src/cipher/base58"
+
+	"github.com/modeneis/coind/src/server/address"
+	"github.com/modeneis/coind/src/server/model_server"
+)
+
+// SendToAddress broadcasts a transfer of withdrawal from the first address of
+// the WAVES wallet, like a node's /assets/transfer, and returns it. The fee is
+// TransferFee.
+func (p *Provider) SendToAddress(withdrawal model_server.Withdrawal) (tx interface{}, err error) {
+	if err = withdrawal.Validate(p.GetType()); err != nil {
+		return nil, err
+	}
+
+	w, err := model_server.GetWallet(p.GetType())
+	if err != nil {
+		return nil, err
+	}
+	sender := w.Addresses()[0].Address
+	pubKey, err := w.PublicKey(sender)
+	if err != nil {
+		return nil, err
+	}
+
+	p.DefaultBlockStore.Lock()
+	defer p.DefaultBlockStore.Unlock()
+
+	transfer := model.Transactions{
+		Type:            transferType,
+		ID:              newID(32),
+		Sender:          sender,
+		SenderPublicKey: base58.Hex2Base58String(pubKey),
+		Recipient:       withdrawal.Address,
+		AssetID:         withdrawal.AssetID,
+		Fee:             TransferFee,
+		Timestamp:       timestamp(model_server.Now()),
+		Signature:       newID(64),
+	}
+	if withdrawal.AssetID == "" {
+		transfer.Amount, _ = model_server.ParseAmount(p.GetType(), withdrawal.Amount)
+	} else {
+		asset, ok := p.DefaultBlockStore.assets[withdrawal.AssetID]
+		if !ok {
+			return nil, fmt.Errorf("asset %s is not issued", withdrawal.AssetID)
+		}
+		if transfer.Amount, err = model_server.ParseUnits(asset.Decimals, withdrawal.Amount); err != nil {
+			return nil, fmt.Errorf("invalid amount of asset %s: %v", asset.AssetID, err)
+		}
+	}
+
+	if err = p.broadcast(transfer); err != nil {
+		return nil, err
+	}
+	return &transfer, nil
+}
+
+// SendRawTransaction broadcasts a signed transfer, the JSON accepted by a
+// node's /assets/broadcast/transfer, and returns it. The sender is the address
+// of the sender public key. Signatures are not verified, but must be present.
+func (p *Provider) SendRawTransaction(rawTx string) (tx interface{}, err error) {
+	var transfer model.Transactions
+	if err = json.Unmarshal([]byte(rawTx), &transfer); err != nil {
+		return nil, fmt.Errorf("invalid transfer: %v", err)
+	}
+	if transfer.Type == 0 {
+		transfer.Type = transferType
+	}
+	if transfer.Type != transferType {
+		return nil, fmt.Errorf("transaction type %d is not supported, only transfers are", transfer.Type)
+	}
+
+	pubKey, err := base58.Base582Hex(transfer.SenderPublicKey)
+	if err != nil || len(pubKey) != 32 {
+		return nil, fmt.Errorf("invalid sender public key %q", transfer.SenderPublicKey)
+	}
+	sender := address.NewWavesFromPublicKey(pubKey)
+	if transfer.Sender != "" && transfer.Sender != sender {
+		return nil, fmt.Errorf("sender %s is not the address of the sender public key", transfer.Sender)
+	}
+	transfer.Sender = sender
+
+	if err = address.ValidateWaves(transfer.Recipient); err != nil {
+		return nil, err
+	}
+	if transfer.Amount <= 0 {
+		return nil, fmt.Errorf("amount must be positive")
+	}
+	if transfer.FeeAsset != "" {
+		return nil, fmt.Errorf("fees are paid in WAVES only")
+	}
+	if transfer.Signature == "" {
+		return nil, fmt.Errorf("signature is required")
+	}
+	if transfer.ID == "" {
+		transfer.ID = newID(32)
+	}
+	if transfer.Timestamp == 0 {
+		transfer.Timestamp = timestamp(model_server.Now())
+	}
+	transfer.Height = 0
+
+	p.DefaultBlockStore.Lock()
+	defer p.DefaultBlockStore.Unlock()
+
+	if err = p.broadcast(transfer); err != nil {
+		return nil, err
+	}
+	return &transfer, nil
+}
+
+// broadcast checks the fee of a withdrawal and the funds of its sender, then
+// adds it to the mempool, to be confirmed by the next block. Pending transfers
+// are spent already, incoming ones are not available yet. Must be called with
+// the store locked.
+func (p *Provider) broadcast(transfer model.Transactions) error {
+	store := p.DefaultBlockStore
+	if err := store.checkNewTx(transfer.ID); err != nil {
+		return err
+	}
+	if transfer.AssetID != "" {
+		if _, ok := store.assets[transfer.AssetID]; !ok {
+			return fmt.Errorf("asset %s is not issued", transfer.AssetID)
+		}
+	}
+	if transfer.Fee < TransferFee {
+		return fmt.Errorf("fee %d does not reach the minimum of %d wavelets", transfer.Fee, TransferFee)
+	}
+
+	balances := store.Addresses.AssetBalances(transfer.Sender)
+	spent := map[string]int64{"": transfer.Fee}
+	spent[transfer.AssetID] += transfer.Amount
+	for asset, amount := range spent {
+		available := balances[asset].Confirmed.Coins
+		if pending := balances[asset].Unconfirmed.Coins; pending < available {
+			available = pending
+		}
+		if available < amount {
+			name := p.GetType()
+			if asset != "" {
+				name = "asset " + asset
+			}
+			return fmt.Errorf("insufficient funds: %s holds %d of %s, the transfer spends %d", transfer.Sender, available, name, amount)
+		}
+	}
+
+	p.addToMempool(transfer)
+	return nil
+}
@@ -0,0 +1,28 @@
+// Package address validates and generates the deposit addresses of each coin
+package address
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Validate checks that address is a well formed address of coinType, with a
+// valid checksum. Coins without a known address format are not checked.
+func Validate(coinType, address string) error {
+	if address == "" {
+		return fmt.Errorf("address is required")
+	}
+
+	switch strings.ToUpper(coinType) {
+	case "SKY":
+		return ValidateSkycoin(address)
+	case "WAVES":
+		return ValidateWaves(address)
+	case "BTC":
+		return ValidateBitcoin(address)
+	case "ETH":
+		return ValidateEthereum(address)
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,67 @@
+package address
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	tt := []struct {
+		coinType string
+		address  string
+		valid    bool
+	}{
+		{"SKY", "2GgFvqoyk9RjwVzj8tqfcXVXB4orBwoc9qv", true},
+		{"SKY", "cBnu9sUvv12dovBmjQKTtfE4rbjMmf3fzW", true},
+		{"SKY", "2GgFvqoyk9RjwVzj8tqfcXVXB4orBwoc9qw", false},
+		{"SKY", "1FeDtFhARLxjKUPPkQqEBL78tisenc9znS", false},
+		{"SKY", "", false},
+
+		{"WAVES", "3PFnbq8kQjYyPwHMaSnbyQ78t15uU6nbkqi", true},
+		{"WAVES", "3P31zvGdh6ai6JK6zZ18TjYzJsa1B83YPoj", true},
+		{"WAVES", "3PFnbq8kQjYyPwHMaSnbyQ78t15uU6nbkqj", false},
+		{"WAVES", "3MzZCGFyuxgC4ZmtKRS7vpJTs75ZXdkbp1K", false}, // testnet
+		{"WAVES", "2GgFvqoyk9RjwVzj8tqfcXVXB4orBwoc9qv", false},
+
+		{"BTC", "1FeDtFhARLxjKUPPkQqEBL78tisenc9znS", true},
+		{"BTC", "3J98t1WpEZ73CNmQviecrnyiWrnqRhWNLy", true},
+		{"BTC", "1FeDtFhARLxjKUPPkQqEBL78tisenc9znT", false},
+		{"BTC", "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4", true},
+		{"BTC", "BC1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T4", true},
+		{"BTC", "tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sl5k7", true},
+		{"BTC", "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0", true},
+		{"BTC", "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t5", false},
+		{"BTC", "bc1Qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4", false},
+		{"BTC", "bc1pw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4", false}, // bech32 checksum on version 1
+
+		{"ETH", "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", true},
+		{"ETH", "0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359", true},
+		{"ETH", "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", true},
+		{"ETH", "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAeD", false},
+		{"ETH", "5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", false},
+		{"ETH", "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeA", false},
+		{"ETH", "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAeg", false},
+
+		{"Faux", "anything", true},
+	}
+
+	for _, tc := range tt {
+		err := Validate(tc.coinType, tc.address)
+		if tc.valid {
+			require.NoError(t, err, "%s %s", tc.coinType, tc.address)
+		} else {
+			require.Error(t, err, "%s %s", tc.coinType, tc.address)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	seed := []byte("0123456789abcdef0123456789abcdef")
+	require.NoError(t, ValidateSkycoin(NewSkycoin(seed)))
+	require.NoError(t, ValidateWaves(NewWaves(seed)))
+	require.NoError(t, ValidateWaves(NewWavesFromPublicKey(seed)))
+	require.NoError(t, ValidateEthereum(NewEthereum(seed)))
+	require.NoError(t, ValidateBitcoin(NewBitcoin(seed)))
+	require.Equal(t, "0x"+eip55("5aaeb6053f3e94c9b9a09f33669435e7ef1beaed"), "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed")
+}
@@ -0,0 +1,163 @@
+package address
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+
+	"github.com/skycoin/skycoin/src/cipher/base58"
+)
+
+// bitcoinVersions are the version bytes of the P2PKH and P2SH addresses of the
+// mainnet and the testnet
+var bitcoinVersions = map[byte]bool{
+	0x00: true,
+	0x05: true,
+	0x6f: true,
+	0xc4: true,
+}
+
+// bitcoinHRPs are the human readable parts of the segwit addresses of the
+// mainnet, the testnet and regtest
+var bitcoinHRPs = map[string]bool{
+	"bc":   true,
+	"tb":   true,
+	"bcrt": true,
+}
+
+// ValidateBitcoin checks a base58check legacy address, or a bech32 segwit one
+func ValidateBitcoin(address string) error {
+	if hrp := strings.ToLower(address); strings.Contains(hrp, "1") && bitcoinHRPs[hrp[:strings.LastIndex(hrp, "1")]] {
+		return validateSegwit(address)
+	}
+
+	b, err := base58.Base582Hex(address)
+	if err != nil {
+		return fmt.Errorf("invalid bitcoin address: %v", err)
+	}
+	if len(b) != 25 {
+		return fmt.Errorf("invalid bitcoin address: length is %d bytes, not 25", len(b))
+	}
+	if !bitcoinVersions[b[0]] {
+		return fmt.Errorf("invalid bitcoin address: unknown version %d", b[0])
+	}
+	first := sha256.Sum256(b[:21])
+	second := sha256.Sum256(first[:])
+	if !bytes.Equal(second[:4], b[21:]) {
+		return fmt.Errorf("invalid bitcoin address: invalid checksum")
+	}
+	return nil
+}
+
+// NewBitcoin returns the mainnet P2PKH address of the 20 bytes public key hash
+func NewBitcoin(hash []byte) string {
+	b := append([]byte{0x00}, hash[:20]...)
+	first := sha256.Sum256(b)
+	second := sha256.Sum256(first[:])
+	return base58.Hex2Base58String(append(b, second[:4]...))
+}
+
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// bech32 checksum constants of BIP173, for witness version 0, and of BIP350,
+// for the later versions
+const (
+	bech32Const  = 1
+	bech32mConst = 0x2bc830a3
+)
+
+// validateSegwit checks the checksum, the witness version and the witness
+// program length of a segwit address
+func validateSegwit(address string) error {
+	if len(address) > 90 {
+		return fmt.Errorf("invalid bitcoin address: longer than 90 characters")
+	}
+	if address != strings.ToLower(address) && address != strings.ToUpper(address) {
+		return fmt.Errorf("invalid bitcoin address: mixed case")
+	}
+	address = strings.ToLower(address)
+
+	sep := strings.LastIndex(address, "1")
+	hrp, data := address[:sep], address[sep+1:]
+	if len(data) < 7 {
+		return fmt.Errorf("invalid bitcoin address: too short")
+	}
+
+	values := make([]byte, len(data))
+	for i := range data {
+		v := strings.IndexByte(bech32Charset, data[i])
+		if v < 0 {
+			return fmt.Errorf("invalid bitcoin address: invalid character %q", data[i])
+		}
+		values[i] = byte(v)
+	}
+
+	checksum := bech32Polymod(append(bech32ExpandHRP(hrp), values...))
+	version := values[0]
+	switch {
+	case version > 16:
+		return fmt.Errorf("invalid bitcoin address: unknown witness version %d", version)
+	case version == 0 && checksum != bech32Const, version > 0 && checksum != bech32mConst:
+		return fmt.Errorf("invalid bitcoin address: invalid checksum")
+	}
+
+	program, err := convertBits(values[1:len(values)-6], 5, 8)
+	if err != nil {
+		return fmt.Errorf("invalid bitcoin address: %v", err)
+	}
+	if len(program) < 2 || len(program) > 40 {
+		return fmt.Errorf("invalid bitcoin address: witness program is %d bytes", len(program))
+	}
+	if version == 0 && len(program) != 20 && len(program) != 32 {
+		return fmt.Errorf("invalid bitcoin address: version 0 witness program is %d bytes, not 20 or 32", len(program))
+	}
+	return nil
+}
+
+func bech32Polymod(values []byte) uint32 {
+	generator := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := uint(0); i < 5; i++ {
+			if (top>>i)&1 == 1 {
+				chk ^= generator[i]
+			}
+		}
+	}
+	return chk
+}
+
+func bech32ExpandHRP(hrp string) []byte {
+	expanded := make([]byte, 0, 2*len(hrp)+1)
+	for i := range hrp {
+		expanded = append(expanded, hrp[i]>>5)
+	}
+	expanded = append(expanded, 0)
+	for i := range hrp {
+		expanded = append(expanded, hrp[i]&31)
+	}
+	return expanded
+}
+
+// convertBits regroups data of from bits per byte into bytes of to bits,
+// rejecting non zero padding
+func convertBits(data []byte, from, to uint) ([]byte, error) {
+	var acc, bits uint
+	var out []byte
+	maxv := uint(1)<<to - 1
+	for _, v := range data {
+		acc = acc<<from | uint(v)
+		bits += from
+		for bits >= to {
+			bits -= to
+			out = append(out, byte(acc>>bits&maxv))
+		}
+	}
+	if bits >= from || (acc<<(to-bits))&maxv != 0 {
+		return nil, fmt.Errorf("invalid padding")
+	}
+	return out, nil
+}
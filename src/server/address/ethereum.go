@@ -0,0 +1,56 @@
+package address
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/modeneis/coind/src/server/digest"
+)
+
+// ValidateEthereum checks an 0x prefixed, 20 bytes hex address. Mixed case
+// addresses must carry a valid EIP-55 checksum.
+func ValidateEthereum(address string) error {
+	if !strings.HasPrefix(address, "0x") && !strings.HasPrefix(address, "0X") {
+		return fmt.Errorf("invalid ethereum address: missing 0x prefix")
+	}
+	digits := address[2:]
+	if len(digits) != 40 {
+		return fmt.Errorf("invalid ethereum address: length is %d hex digits, not 40", len(digits))
+	}
+	if _, err := hex.DecodeString(digits); err != nil {
+		return fmt.Errorf("invalid ethereum address: %v", err)
+	}
+
+	if digits == strings.ToLower(digits) || digits == strings.ToUpper(digits) {
+		return nil
+	}
+	if digits != eip55(digits) {
+		return fmt.Errorf("invalid ethereum address: invalid EIP-55 checksum")
+	}
+	return nil
+}
+
+// NewEthereum returns the EIP-55 checksummed address of the 20 bytes hash
+func NewEthereum(hash []byte) string {
+	return "0x" + eip55(hex.EncodeToString(hash[:20]))
+}
+
+// eip55 upper cases the letters of digits whose nibble in the keccak256 of
+// the lower cased digits is 8 or more
+func eip55(digits string) string {
+	digits = strings.ToLower(digits)
+	sum := digest.Keccak256([]byte(digits))
+
+	b := []byte(digits)
+	for i, c := range b {
+		nibble := sum[i/2] >> 4
+		if i%2 == 1 {
+			nibble = sum[i/2] & 0x0f
+		}
+		if c >= 'a' && nibble >= 8 {
+			b[i] = c - 'a' + 'A'
+		}
+	}
+	return string(b)
+}
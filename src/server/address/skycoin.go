@@ -0,0 +1,21 @@
+package address
+
+import (
+	"fmt"
+
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+// ValidateSkycoin checks a Skycoin address the way the Skycoin node parses it
+func ValidateSkycoin(address string) error {
+	if _, err := cipher.DecodeBase58Address(address); err != nil {
+		return fmt.Errorf("invalid skycoin address: %v", err)
+	}
+	return nil
+}
+
+// NewSkycoin returns the address of the key pair derived from seed
+func NewSkycoin(seed []byte) string {
+	pubKey, _ := cipher.GenerateDeterministicKeyPair(seed)
+	return cipher.AddressFromPubKey(pubKey).String()
+}
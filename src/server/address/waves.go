@@ -0,0 +1,63 @@
+package address
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/skycoin/skycoin/src/cipher/base58"
+
+	"github.com/modeneis/coind/src/server/digest"
+)
+
+// WavesChainID is the network byte of the accepted Waves addresses, 'W' for
+// the mainnet and 'T' for the testnet
+var WavesChainID byte = 'W'
+
+const (
+	wavesAddressVersion = 1
+	wavesAddressLength  = 26
+)
+
+// ValidateWaves checks the version, the network byte and the checksum of a
+// Waves address
+func ValidateWaves(address string) error {
+	b, err := base58.Base582Hex(address)
+	if err != nil {
+		return fmt.Errorf("invalid waves address: %v", err)
+	}
+	if len(b) != wavesAddressLength {
+		return fmt.Errorf("invalid waves address: length is %d bytes, not %d", len(b), wavesAddressLength)
+	}
+	if b[0] != wavesAddressVersion {
+		return fmt.Errorf("invalid waves address: unknown version %d", b[0])
+	}
+	if b[1] != WavesChainID {
+		return fmt.Errorf("invalid waves address: network byte is %q, not %q", b[1], WavesChainID)
+	}
+	if !bytes.Equal(wavesChecksum(b[:22]), b[22:]) {
+		return fmt.Errorf("invalid waves address: invalid checksum")
+	}
+	return nil
+}
+
+// NewWaves returns the address of the 20 bytes public key hash
+func NewWaves(hash []byte) string {
+	b := append([]byte{wavesAddressVersion, WavesChainID}, hash[:20]...)
+	return base58.Hex2Base58String(append(b, wavesChecksum(b)...))
+}
+
+// NewWavesFromPublicKey returns the address of a 32 bytes public key
+func NewWavesFromPublicKey(pubKey []byte) string {
+	return NewWaves(wavesHash(pubKey))
+}
+
+// wavesChecksum is the first 4 bytes of wavesHash(b)
+func wavesChecksum(b []byte) []byte {
+	return wavesHash(b)[:4]
+}
+
+// wavesHash is keccak256(blake2b256(b)), the hash of the public keys and of
+// the address checksums
+func wavesHash(b []byte) []byte {
+	return digest.Keccak256(digest.Blake2b256(b))
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/modeneis/coind/src/server/model_server"
+	"github.com/modeneis/coind/src/server/utils"
+)
+
+// GetBalance writes the confirmed and unconfirmed balance of address
+func GetBalance(coinType, address string, w http.ResponseWriter) (err error) {
+	provider, err := model_server.GetProvider(coinType)
+	if err != nil {
+		err = fmt.Errorf("CoinType (%s) not supported", coinType)
+		return err
+	}
+
+	result, err := provider.GetBalance(address)
+	if err != nil {
+		return err
+	}
+
+	if err = utils.JSONResponse(w, result); err != nil {
+		err = fmt.Errorf("GetBalance got Err when running JSONResponse %v", err)
+		return err
+	}
+	return nil
+}
+
+// GetOutputs writes the unspent outputs of address
+func GetOutputs(coinType, address string, w http.ResponseWriter) (err error) {
+	provider, err := model_server.GetProvider(coinType)
+	if err != nil {
+		err = fmt.Errorf("CoinType (%s) not supported", coinType)
+		return err
+	}
+
+	result, err := provider.GetOutputs(address)
+	if err != nil {
+		return err
+	}
+
+	if err = utils.JSONResponse(w, result); err != nil {
+		err = fmt.Errorf("GetOutputs got Err when running JSONResponse %v", err)
+		return err
+	}
+	return nil
+}
+
+// GetAddressTransactions writes a page of the transaction history of address
+func GetAddressTransactions(coinType, address string, offset, limit int, w http.ResponseWriter) (err error) {
+	provider, err := model_server.GetProvider(coinType)
+	if err != nil {
+		err = fmt.Errorf("CoinType (%s) not supported", coinType)
+		return err
+	}
+
+	result, err := provider.GetAddressTransactions(address, offset, limit)
+	if err != nil {
+		return err
+	}
+
+	if err = utils.JSONResponse(w, result); err != nil {
+		err = fmt.Errorf("GetAddressTransactions got Err when running JSONResponse %v", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/drewolson/testflight"
+	"github.com/skycoin/skycoin/src/gui"
+	"github.com/skycoin/skycoin/src/visor"
+	"github.com/skycoin/skycoin/src/wallet"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modeneis/coind/src/providers/sky"
+	"github.com/modeneis/coind/src/providers/waves"
+	"github.com/modeneis/coind/src/server/api"
+	"github.com/modeneis/coind/src/server/model_server"
+	"github.com/modeneis/coind/src/server/utils"
+)
+
+// useFakeUpstream replaces the registered providers with ones that read their
+// template blocks from a local server instead of the public explorers
+func useFakeUpstream(t *testing.T) func() {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/blockchain/metadata", func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, utils.JSONResponse(w, visor.BlockchainMetadata{Head: visor.ReadableBlockHeader{BkSeq: 1}}))
+	})
+	mux.HandleFunc("/api/blocks", func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write([]byte(sky.SkyBlockString))
+		require.NoError(t, err)
+	})
+	mux.HandleFunc("/blocks/last", func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write([]byte(waves.WavesBlockString))
+		require.NoError(t, err)
+	})
+	upstream := httptest.NewServer(mux)
+
+	skyProvider := &sky.Provider{SkyRESTClinet: &gui.Client{Addr: upstream.URL + "/api/"}}
+	skyProvider.Start()
+	wavesProvider := &waves.Provider{MainNET: upstream.URL}
+	wavesProvider.Start()
+	model_server.UseProviders(skyProvider, wavesProvider)
+
+	return func() {
+		upstream.Close()
+		model_server.UseProviders(sky.New(), waves.New())
+	}
+}
+
+func TestAddressQueries(t *testing.T) {
+	defer useFakeUpstream(t)()
+
+	skyAddress := "cBnu9sUvv12dovBmjQKTtfE4rbjMmf3fzW"
+	wavesAddress := "3PFnbq8kQjYyPwHMaSnbyQ78t15uU6nbkqi"
+
+	deposits := []model_server.Deposit{
+		{Address: skyAddress, Value: 10, Hours: 5, CoinType: api.CoinTypeSKY},
+		{Address: skyAddress, Value: 3, Hours: 1, CoinType: api.CoinTypeSKY},
+		{Address: wavesAddress, Value: 560100000000, CoinType: api.CoinTypeWAVES},
+	}
+
+	mux := api.InitRouting()
+
+	testflight.WithServer(mux, func(r *testflight.Requester) {
+		for _, deposit := range deposits {
+			raw, err := json.Marshal([]model_server.Deposit{deposit})
+			require.NoError(t, err)
+			response := r.Post("/api/nextdeposit", "application/json", string(raw))
+			require.Equal(t, http.StatusOK, response.StatusCode)
+		}
+
+		t.Run("sky balance", func(t *testing.T) {
+			response := r.Get("/api/balance?cointype=SKY&address=" + skyAddress)
+			require.Equal(t, http.StatusOK, response.StatusCode)
+
+			var bal wallet.BalancePair
+			require.NoError(t, json.Unmarshal(response.RawBody, &bal))
+			require.Equal(t, uint64(13e6), bal.Confirmed.Coins)
+			require.Equal(t, uint64(6), bal.Confirmed.Hours)
+			require.Equal(t, bal.Confirmed, bal.Predicted)
+		})
+
+		t.Run("sky outputs", func(t *testing.T) {
+			response := r.Get("/api/outputs?cointype=SKY&address=" + skyAddress)
+			require.Equal(t, http.StatusOK, response.StatusCode)
+
+			var outputs visor.ReadableOutputSet
+			require.NoError(t, json.Unmarshal(response.RawBody, &outputs))
+			require.Len(t, outputs.HeadOutputs, 2)
+			require.Empty(t, outputs.IncomingOutputs)
+			require.Equal(t, "10.000000", outputs.HeadOutputs[0].Coins)
+			require.NotEqual(t, outputs.HeadOutputs[0].Hash, outputs.HeadOutputs[1].Hash)
+		})
+
+		t.Run("sky transactions are paginated", func(t *testing.T) {
+			var page struct {
+				Total        int                       `json:"total"`
+				Transactions []visor.TransactionResult `json:"transactions"`
+			}
+
+			response := r.Get("/api/address_transactions?cointype=SKY&limit=1&address=" + skyAddress)
+			require.Equal(t, http.StatusOK, response.StatusCode)
+			require.NoError(t, json.Unmarshal(response.RawBody, &page))
+			require.Equal(t, 2, page.Total)
+			require.Len(t, page.Transactions, 1)
+			require.True(t, page.Transactions[0].Status.Confirmed)
+			require.Equal(t, uint64(2), page.Transactions[0].Status.BlockSeq)
+			require.Equal(t, uint64(1), page.Transactions[0].Status.Height)
+
+			response = r.Get("/api/address_transactions?cointype=SKY&limit=1&offset=1&address=" + skyAddress)
+			require.Equal(t, http.StatusOK, response.StatusCode)
+			require.NoError(t, json.Unmarshal(response.RawBody, &page))
+			require.Len(t, page.Transactions, 1)
+			require.Equal(t, uint64(1), page.Transactions[0].Status.BlockSeq)
+			require.Equal(t, uint64(2), page.Transactions[0].Status.Height)
+
+			response = r.Get("/api/address_transactions?cointype=SKY&limit=1&offset=2&address=" + skyAddress)
+			require.Equal(t, http.StatusOK, response.StatusCode)
+			require.NoError(t, json.Unmarshal(response.RawBody, &page))
+			require.Empty(t, page.Transactions)
+		})
+
+		t.Run("waves balance", func(t *testing.T) {
+			response := r.Get("/api/balance?cointype=WAVES&address=" + wavesAddress)
+			require.Equal(t, http.StatusOK, response.StatusCode)
+
+			var bal waves.AddressBalance
+			require.NoError(t, json.Unmarshal(response.RawBody, &bal))
+			require.Equal(t, int64(560100000000), bal.Balance)
+		})
+
+		t.Run("waves has no outputs", func(t *testing.T) {
+			response := r.Get("/api/outputs?cointype=WAVES&address=" + wavesAddress)
+			require.Equal(t, http.StatusBadRequest, response.StatusCode)
+		})
+
+		t.Run("address is required", func(t *testing.T) {
+			response := r.Get("/api/balance?cointype=SKY")
+			require.Equal(t, http.StatusBadRequest, response.StatusCode)
+		})
+	})
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/modeneis/coind/src/server/model_server"
+	"github.com/modeneis/coind/src/server/utils"
+)
+
+// ResetChains resets the chain of coinType, or of every coin if coinType is empty
+func ResetChains(coinType string, w http.ResponseWriter) (err error) {
+	if err = model_server.ResetChains(coinType); err != nil {
+		return err
+	}
+
+	coinTypes := []string{coinType}
+	if coinType == "" {
+		coinTypes = coinTypes[:0]
+		for name := range model_server.GetProviders() {
+			coinTypes = append(coinTypes, name)
+		}
+		sort.Strings(coinTypes)
+	}
+
+	if err = utils.JSONResponse(w, coinTypes); err != nil {
+		err = fmt.Errorf("ResetChains got Err when running JSONResponse %v", err)
+		return err
+	}
+	return nil
+}
+
+// TakeSnapshot stores a named snapshot of every chain
+func TakeSnapshot(name string, w http.ResponseWriter) (err error) {
+	info, err := model_server.TakeSnapshot(name)
+	if err != nil {
+		return err
+	}
+
+	if err = utils.JSONResponse(w, info); err != nil {
+		err = fmt.Errorf("TakeSnapshot got Err when running JSONResponse %v", err)
+		return err
+	}
+	return nil
+}
+
+// RestoreSnapshot restores every chain from a named snapshot
+func RestoreSnapshot(name string, w http.ResponseWriter) (err error) {
+	if err = model_server.RestoreSnapshot(name); err != nil {
+		return err
+	}
+
+	if err = utils.JSONResponse(w, name); err != nil {
+		err = fmt.Errorf("RestoreSnapshot got Err when running JSONResponse %v", err)
+		return err
+	}
+	return nil
+}
+
+// DeleteSnapshot removes a named snapshot
+func DeleteSnapshot(name string, w http.ResponseWriter) (err error) {
+	if err = model_server.DeleteSnapshot(name); err != nil {
+		return err
+	}
+
+	if err = utils.JSONResponse(w, name); err != nil {
+		err = fmt.Errorf("DeleteSnapshot got Err when running JSONResponse %v", err)
+		return err
+	}
+	return nil
+}
+
+// ListSnapshots writes every stored snapshot
+func ListSnapshots(w http.ResponseWriter) (err error) {
+	if err = utils.JSONResponse(w, model_server.ListSnapshots()); err != nil {
+		err = fmt.Errorf("ListSnapshots got Err when running JSONResponse %v", err)
+		return err
+	}
+	return nil
+}
+
+// Reorg replaces the last blocks of the chain of coinType with a new branch
+// and writes the new tip
+func Reorg(coinType string, reorg model_server.Reorg, w http.ResponseWriter) (err error) {
+	provider, err := model_server.GetProvider(coinType)
+	if err != nil {
+		err = fmt.Errorf("CoinType (%s) not supported", coinType)
+		return err
+	}
+
+	tip, err := provider.Reorg(reorg)
+	if err != nil {
+		return err
+	}
+
+	if err = utils.JSONResponse(w, tip); err != nil {
+		err = fmt.Errorf("Reorg got Err when running JSONResponse %v", err)
+		return err
+	}
+	return nil
+}
+
+// DoubleSpend replaces a transaction of coinType with a conflicting one and
+// writes the conflicting transaction
+func DoubleSpend(coinType string, doubleSpend model_server.DoubleSpend, w http.ResponseWriter) (err error) {
+	provider, err := model_server.GetProvider(coinType)
+	if err != nil {
+		err = fmt.Errorf("CoinType (%s) not supported", coinType)
+		return err
+	}
+
+	tx, err := provider.DoubleSpend(doubleSpend)
+	if err != nil {
+		return err
+	}
+
+	if err = utils.JSONResponse(w, tx); err != nil {
+		err = fmt.Errorf("DoubleSpend got Err when running JSONResponse %v", err)
+		return err
+	}
+	return nil
+}
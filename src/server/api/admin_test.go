@@ -0,0 +1,85 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/drewolson/testflight"
+	"github.com/skycoin/skycoin/src/wallet"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modeneis/coind/src/server/api"
+	"github.com/modeneis/coind/src/server/model_server"
+)
+
+func TestSnapshotRestoreReset(t *testing.T) {
+	defer useFakeUpstream(t)()
+
+	address := "cBnu9sUvv12dovBmjQKTtfE4rbjMmf3fzW"
+
+	mux := api.InitRouting()
+
+	testflight.WithServer(mux, func(r *testflight.Requester) {
+		deposit := func(value int64) {
+			raw, err := json.Marshal([]model_server.Deposit{{Address: address, Value: value, CoinType: api.CoinTypeSKY}})
+			require.NoError(t, err)
+			response := r.Post("/api/nextdeposit", "application/json", string(raw))
+			require.Equal(t, http.StatusOK, response.StatusCode)
+		}
+
+		balance := func() uint64 {
+			response := r.Get("/api/balance?cointype=SKY&address=" + address)
+			require.Equal(t, http.StatusOK, response.StatusCode)
+
+			var bal wallet.BalancePair
+			require.NoError(t, json.Unmarshal(response.RawBody, &bal))
+			return bal.Confirmed.Coins
+		}
+
+		deposit(1)
+
+		response := r.Post("/api/admin/snapshot?name=one", "", "")
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		deposit(2)
+		require.Equal(t, uint64(3e6), balance())
+
+		response = r.Get("/api/admin/snapshot")
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		var infos []model_server.SnapshotInfo
+		require.NoError(t, json.Unmarshal(response.RawBody, &infos))
+		require.Len(t, infos, 1)
+		require.Equal(t, "one", infos[0].Name)
+		require.Equal(t, []string{api.CoinTypeSKY, api.CoinTypeWAVES}, infos[0].CoinTypes)
+
+		response = r.Post("/api/admin/restore?name=one", "", "")
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.Equal(t, uint64(1e6), balance())
+
+		// a snapshot can be restored more than once
+		deposit(5)
+		response = r.Post("/api/admin/restore?name=one", "", "")
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.Equal(t, uint64(1e6), balance())
+
+		response = r.Post("/api/admin/restore?name=unknown", "", "")
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+
+		response = r.Post("/api/admin/reset?cointype=SKY", "", "")
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.Equal(t, uint64(0), balance())
+
+		response = r.Get("/api/get_block_count?cointype=SKY")
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.Equal(t, "0", response.Body)
+
+		response = r.Post("/api/admin/reset?cointype=BTC", "", "")
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+
+		response = r.Delete("/api/admin/snapshot?name=one", "", "")
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		response = r.Post("/api/admin/restore?name=one", "", "")
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+	})
+}
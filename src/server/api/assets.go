@@ -0,0 +1,70 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/modeneis/coind/src/server/model_server"
+	"github.com/modeneis/coind/src/server/utils"
+)
+
+// IssueAsset issues a fake asset on the chain of coinType and writes its details
+func IssueAsset(coinType string, asset model_server.Asset, w http.ResponseWriter) (err error) {
+	provider, err := model_server.GetProvider(coinType)
+	if err != nil {
+		err = fmt.Errorf("CoinType (%s) not supported", coinType)
+		return err
+	}
+
+	result, err := provider.IssueAsset(asset)
+	if err != nil {
+		return err
+	}
+
+	if err = utils.JSONResponse(w, result); err != nil {
+		err = fmt.Errorf("IssueAsset got Err when running JSONResponse %v", err)
+		return err
+	}
+	return nil
+}
+
+// GetAsset writes the details of an issued asset
+func GetAsset(coinType, assetID string, w http.ResponseWriter) (err error) {
+	provider, err := model_server.GetProvider(coinType)
+	if err != nil {
+		err = fmt.Errorf("CoinType (%s) not supported", coinType)
+		return err
+	}
+
+	result, err := provider.GetAsset(assetID)
+	if err != nil {
+		return err
+	}
+
+	if err = utils.JSONResponse(w, result); err != nil {
+		err = fmt.Errorf("GetAsset got Err when running JSONResponse %v", err)
+		return err
+	}
+	return nil
+}
+
+// GetAssetBalances writes the asset balances of address, or its balance in
+// assetID if it is not empty
+func GetAssetBalances(coinType, address, assetID string, w http.ResponseWriter) (err error) {
+	provider, err := model_server.GetProvider(coinType)
+	if err != nil {
+		err = fmt.Errorf("CoinType (%s) not supported", coinType)
+		return err
+	}
+
+	result, err := provider.GetAssetBalances(address, assetID)
+	if err != nil {
+		return err
+	}
+
+	if err = utils.JSONResponse(w, result); err != nil {
+		err = fmt.Errorf("GetAssetBalances got Err when running JSONResponse %v", err)
+		return err
+	}
+	return nil
+}
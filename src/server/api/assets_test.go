@@ -0,0 +1,109 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/drewolson/testflight"
+	"github.com/modeneis/waves-go-client/model"
+	"github.com/skycoin/skycoin/src/cipher/base58"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modeneis/coind/src/providers/waves"
+	"github.com/modeneis/coind/src/server/api"
+	"github.com/modeneis/coind/src/server/model_server"
+)
+
+func TestWavesAssets(t *testing.T) {
+	defer useFakeUpstream(t)()
+
+	const recipient = "3P31zvGdh6ai6JK6zZ18TjYzJsa1B83YPoj"
+
+	testflight.WithServer(api.InitRouting(), func(r *testflight.Requester) {
+		deposit := func(deposit model_server.Deposit) int {
+			raw, err := json.Marshal([]model_server.Deposit{deposit})
+			require.NoError(t, err)
+			return r.Post("/api/nextdeposit", "application/json", string(raw)).StatusCode
+		}
+
+		response := r.Post("/api/admin/assets?cointype=WAVES", "application/json",
+			`{"assetid": "TOKEN", "name": "Token", "description": "a test token", "decimals": 2, "quantity": "1000"}`)
+		require.Equal(t, http.StatusOK, response.StatusCode, response.Body)
+
+		response = r.Post("/api/admin/assets?cointype=WAVES", "application/json", `{"assetid": "TOKEN", "name": "Token", "quantity": "1"}`)
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+		response = r.Post("/api/admin/assets?cointype=WAVES", "application/json", `{"name": "Tok", "quantity": "1"}`)
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+		response = r.Post("/api/admin/assets?cointype=SKY", "application/json", `{"name": "Token", "quantity": "1"}`)
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+
+		response = r.Get("/api/assets/details?cointype=WAVES&assetid=TOKEN")
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		var details waves.AssetDetails
+		require.NoError(t, json.Unmarshal(response.RawBody, &details))
+		require.Equal(t, "Token", details.Name)
+		require.Equal(t, "a test token", details.Description)
+		require.Equal(t, int32(2), details.Decimals)
+		require.Equal(t, int64(100000), details.Quantity)
+		require.Equal(t, waves.FundingAddress, details.Issuer)
+
+		response = r.Get("/api/assets/details?cointype=WAVES&assetid=MISSING")
+		require.Equal(t, http.StatusNotFound, response.StatusCode)
+
+		require.Equal(t, http.StatusOK, deposit(model_server.Deposit{
+			Address: recipient, Amount: "1.25", AssetID: "TOKEN", Attachment: "invoice 42", Tx: "token-tx", CoinType: api.CoinTypeWAVES,
+		}))
+		require.Equal(t, http.StatusOK, deposit(model_server.Deposit{Address: recipient, Value: 7, CoinType: api.CoinTypeWAVES}))
+
+		require.Equal(t, http.StatusBadRequest, deposit(model_server.Deposit{Address: recipient, Value: 1, AssetID: "MISSING", CoinType: api.CoinTypeWAVES}))
+		require.Equal(t, http.StatusBadRequest, deposit(model_server.Deposit{Address: recipient, Amount: "0.125", AssetID: "TOKEN", CoinType: api.CoinTypeWAVES}))
+		require.Equal(t, http.StatusBadRequest, deposit(model_server.Deposit{Address: "24jt6Yyvs7Z5ARctzVgysq8mQPrqhrzFvxr", Value: 1, AssetID: "TOKEN", CoinType: api.CoinTypeSKY}))
+
+		response = r.Get("/api/transaction?cointype=WAVES&txid=token-tx")
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		var info waves.TransactionInfo
+		require.NoError(t, json.Unmarshal(response.RawBody, &info))
+		require.Equal(t, "TOKEN", info.AssetID)
+		require.Equal(t, int64(125), info.Amount)
+		require.Equal(t, "", info.FeeAsset)
+		require.Equal(t, int64(waves.TransferFee), info.Fee)
+		require.Equal(t, base58.Hex2Base58String([]byte("invoice 42")), info.Attachment)
+
+		// the WAVES balance leaves the asset out
+		response = r.Get("/api/balance?cointype=WAVES&address=" + recipient)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		var bal waves.AddressBalance
+		require.NoError(t, json.Unmarshal(response.RawBody, &bal))
+		require.Equal(t, int64(7), bal.Balance)
+
+		response = r.Get("/api/assets/balance?cointype=WAVES&address=" + recipient)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		var assets model.Assets
+		require.NoError(t, json.Unmarshal(response.RawBody, &assets))
+		require.Equal(t, recipient, assets.Address)
+		require.Equal(t, []model.Balances{{AssetID: "TOKEN", Balance: 125}}, assets.Balances)
+
+		response = r.Get("/api/assets/balance?cointype=WAVES&assetid=TOKEN&address=" + recipient)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		var balance model.Balances
+		require.NoError(t, json.Unmarshal(response.RawBody, &balance))
+		require.Equal(t, model.Balances{Address: recipient, AssetID: "TOKEN", Balance: 125}, balance)
+
+		// deposits may pay their fee in an issued asset
+		require.Equal(t, http.StatusOK, deposit(model_server.Deposit{
+			Address: recipient, Value: 1, FeeAssetID: "TOKEN", Tx: "fee-tx", CoinType: api.CoinTypeWAVES,
+		}))
+		response = r.Get("/api/transaction?cointype=WAVES&txid=fee-tx")
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.NoError(t, json.Unmarshal(response.RawBody, &info))
+		require.Equal(t, "TOKEN", info.FeeAsset)
+		require.Equal(t, http.StatusBadRequest, deposit(model_server.Deposit{Address: recipient, Value: 1, FeeAssetID: "MISSING", CoinType: api.CoinTypeWAVES}))
+		require.Equal(t, http.StatusBadRequest, deposit(model_server.Deposit{Address: "24jt6Yyvs7Z5ARctzVgysq8mQPrqhrzFvxr", Value: 1, FeeAssetID: "TOKEN", CoinType: api.CoinTypeSKY}))
+
+		// deposits cannot pay more of an asset than was issued
+		require.Equal(t, http.StatusBadRequest, deposit(model_server.Deposit{Address: recipient, Amount: "998.76", AssetID: "TOKEN", CoinType: api.CoinTypeWAVES}))
+		require.Equal(t, http.StatusOK, deposit(model_server.Deposit{Address: recipient, Amount: "998.75", AssetID: "TOKEN", CoinType: api.CoinTypeWAVES}))
+		require.Equal(t, http.StatusBadRequest, deposit(model_server.Deposit{Address: recipient, Amount: "0.01", AssetID: "TOKEN", CoinType: api.CoinTypeWAVES}))
+	})
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/modeneis/coind/src/server/model_server"
+	"github.com/modeneis/coind/src/server/utils"
+)
+
+// SetClock switches the clock to mode, from start if it is not zero, and
+// writes the clock status
+func SetClock(mode string, start time.Time, scale float64, w http.ResponseWriter) (err error) {
+	if err = model_server.DefaultClock.Set(mode, start, scale); err != nil {
+		return err
+	}
+	return ClockStatus(w)
+}
+
+// AdvanceClock moves the clock forward by d and writes the clock status
+func AdvanceClock(d time.Duration, w http.ResponseWriter) (err error) {
+	if err = model_server.DefaultClock.Advance(d); err != nil {
+		return err
+	}
+	return ClockStatus(w)
+}
+
+// ClockStatus writes the mode and the current time of the clock
+func ClockStatus(w http.ResponseWriter) (err error) {
+	if err = utils.JSONResponse(w, model_server.DefaultClock.Status()); err != nil {
+		err = fmt.Errorf("ClockStatus got Err when running JSONResponse %v", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/drewolson/testflight"
+	"github.com/skycoin/skycoin/src/visor"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modeneis/coind/src/server/api"
+	"github.com/modeneis/coind/src/server/model_server"
+)
+
+func TestClock(t *testing.T) {
+	defer useFakeUpstream(t)()
+	defer model_server.DefaultClock.Set(model_server.ClockReal, time.Now(), 0)
+
+	start := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	testflight.WithServer(api.InitRouting(), func(r *testflight.Requester) {
+		status := func(response *testflight.Response) model_server.ClockStatus {
+			require.Equal(t, http.StatusOK, response.StatusCode)
+			var s model_server.ClockStatus
+			require.NoError(t, json.Unmarshal(response.RawBody, &s))
+			return s
+		}
+
+		s := status(r.Post("/api/admin/clock?mode=fixed&time=2018-01-01T00:00:00Z", "", ""))
+		require.Equal(t, model_server.ClockFixed, s.Mode)
+		require.Equal(t, start, s.Now)
+
+		mine := func() visor.ReadableBlockHeader {
+			response := r.Post("/api/admin/mine?cointype=SKY", "", "")
+			require.Equal(t, http.StatusOK, response.StatusCode)
+			var blocks visor.ReadableBlocks
+			require.NoError(t, json.Unmarshal(response.RawBody, &blocks))
+			return blocks.Blocks[0].Head
+		}
+		require.Equal(t, uint64(start.Unix()), mine().Time)
+
+		s = status(r.Post("/api/admin/clock?advance=10s", "", ""))
+		require.Equal(t, start.Add(10*time.Second), s.Now)
+		require.Equal(t, uint64(start.Unix()+10), mine().Time)
+		require.Equal(t, start.Add(10*time.Second), status(r.Get("/api/admin/clock")).Now)
+
+		response := r.Post("/api/admin/clock?advance=-1s", "", "")
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+		response = r.Post("/api/admin/clock?mode=scaled", "", "")
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+		response = r.Post("/api/admin/clock?mode=fixed&time=yesterday", "", "")
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+
+		s = status(r.Post("/api/admin/clock?mode=scaled&scale=60", "", ""))
+		require.Equal(t, model_server.ClockScaled, s.Mode)
+		require.Equal(t, float64(60), s.Scale)
+	})
+}
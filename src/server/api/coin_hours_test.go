@@ -0,0 +1,109 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/drewolson/testflight"
+	"github.com/skycoin/skycoin/src/util/fee"
+	"github.com/skycoin/skycoin/src/visor"
+	"github.com/skycoin/skycoin/src/wallet"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modeneis/coind/src/providers/sky"
+	"github.com/modeneis/coind/src/server/api"
+	"github.com/modeneis/coind/src/server/model_server"
+)
+
+func TestCoinHours(t *testing.T) {
+	defer useFakeUpstream(t)()
+	defer model_server.DefaultClock.Set(model_server.ClockReal, time.Now(), 0)
+
+	const recipient = "24jt6Yyvs7Z5ARctzVgysq8mQPrqhrzFvxr"
+	start := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, model_server.DefaultClock.Set(model_server.ClockFixed, start, 0))
+
+	testflight.WithServer(api.InitRouting(), func(r *testflight.Requester) {
+		post := func(uri string, deposit model_server.Deposit) {
+			raw, err := json.Marshal([]model_server.Deposit{deposit})
+			require.NoError(t, err)
+			response := r.Post(uri, "application/json", string(raw))
+			require.Equal(t, http.StatusOK, response.StatusCode, response.Body)
+		}
+		balance := func(address string) wallet.BalancePair {
+			response := r.Get("/api/balance?cointype=SKY&address=" + address)
+			require.Equal(t, http.StatusOK, response.StatusCode)
+			var bal wallet.BalancePair
+			require.NoError(t, json.Unmarshal(response.RawBody, &bal))
+			return bal
+		}
+		outputs := func(address string) visor.ReadableOutputSet {
+			response := r.Get("/api/outputs?cointype=SKY&address=" + address)
+			require.Equal(t, http.StatusOK, response.StatusCode)
+			var set visor.ReadableOutputSet
+			require.NoError(t, json.Unmarshal(response.RawBody, &set))
+			return set
+		}
+		mine := func() {
+			response := r.Post("/api/admin/mine?cointype=SKY", "", "")
+			require.Equal(t, http.StatusOK, response.StatusCode)
+		}
+
+		post("/api/nextdeposit", model_server.Deposit{Address: recipient, Value: 10, Hours: 5, CoinType: api.CoinTypeSKY})
+		require.Equal(t, wallet.NewBalance(10000000, 5), balance(recipient).Confirmed)
+
+		// hours accrue at the time of the head block, not of the clock
+		require.NoError(t, model_server.DefaultClock.Advance(time.Hour))
+		require.Equal(t, uint64(5), balance(recipient).Confirmed.Hours)
+
+		mine()
+		bal := balance(recipient)
+		require.Equal(t, wallet.NewBalance(10000000, 15), bal.Confirmed)
+		require.Equal(t, bal.Confirmed, bal.Predicted)
+
+		set := outputs(recipient)
+		require.Len(t, set.HeadOutputs, 1)
+		require.Equal(t, uint64(5), set.HeadOutputs[0].Hours)
+		require.Equal(t, uint64(15), set.HeadOutputs[0].CalculatedHours)
+		require.Equal(t, uint64(start.Unix()), set.HeadOutputs[0].Time)
+
+		// unconfirmed outputs are created by the head and have not accrued yet
+		post("/api/mempool", model_server.Deposit{Address: recipient, Value: 2, Hours: 1, CoinType: api.CoinTypeSKY})
+		bal = balance(recipient)
+		require.Equal(t, wallet.NewBalance(10000000, 15), bal.Confirmed)
+		require.Equal(t, wallet.NewBalance(12000000, 16), bal.Predicted)
+
+		set = outputs(recipient)
+		require.Len(t, set.IncomingOutputs, 1)
+		require.Equal(t, uint64(1), set.IncomingOutputs[0].CalculatedHours)
+		require.Equal(t, uint64(start.Add(time.Hour).Unix()), set.IncomingOutputs[0].Time)
+		require.Equal(t, uint64(2), set.IncomingOutputs[0].BkSeq)
+		mine()
+
+		// the funding wallet spends its change with the hours it accrued, and
+		// burns a BurnFactor-th of them
+		require.NoError(t, model_server.DefaultClock.Advance(time.Hour))
+		mine()
+		change := outputs(sky.FundingAddress).HeadOutputs
+		require.Len(t, change, 1)
+		inHours := change[0].CalculatedHours
+		require.True(t, inHours > change[0].Hours)
+
+		txid := "c1b2c3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f60718293a4b5c6d7e8f90"
+		post("/api/nextdeposit", model_server.Deposit{Address: recipient, Value: 1, Hours: 100, Tx: txid, CoinType: api.CoinTypeSKY})
+
+		response := r.Get("/api/transaction?cointype=SKY&txid=" + txid)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		var tx sky.Transaction
+		require.NoError(t, json.Unmarshal(response.RawBody, &tx))
+		require.Equal(t, []string{change[0].Hash}, tx.Transaction.In)
+
+		var outHours uint64
+		for _, out := range tx.Transaction.Out {
+			outHours += out.Hours
+		}
+		require.Equal(t, inHours-fee.RequiredFee(inHours), outHours)
+	})
+}
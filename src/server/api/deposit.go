@@ -25,29 +25,27 @@ func init() {
 
 }
 
-// ProcessDeposits
-func ProcessDeposits(deposits []model_server.Deposit, w http.ResponseWriter) (err error) {
-	//// Add new blocks
-	var newBlock interface{}
-
-	for _, deposit := range deposits {
-
-		coinType := deposit.CoinType
-		provider, err := model_server.GetProvider(coinType)
-		if err != nil {
-			err = fmt.Errorf("CoinType (%s) not supported for deposit %v", coinType, deposit)
-			return err
-		}
+// ProcessDeposits creates a block for every deposit and writes their receipts,
+// or the last block if withBlock is set. Deposits already minted under key, or
+// with the same ClientID, are replayed, see model_server.ProcessDeposits. The
+// deposits minted are recorded in the ledger as submitted by submitter.
+func ProcessDeposits(submitter, key string, deposits []model_server.Deposit, withBlock bool, w http.ResponseWriter) (err error) {
+	receipts, newBlock, err := model_server.ProcessDeposits(submitter, key, deposits)
+	if err != nil {
+		return err
+	}
 
-		// create new block
-		newBlock, err = provider.CreateFakeBlock(deposit)
-		if err != nil {
-			return err
+	var result interface{} = receipts
+	if withBlock {
+		result = newBlock
+		if result == nil {
+			// the last deposit was replayed
+			if result, err = receiptBlock(receipts[len(receipts)-1]); err != nil {
+				return err
+			}
 		}
-
 	}
-
-	if err := utils.JSONResponse(w, newBlock); err != nil {
+	if err := utils.JSONResponse(w, result); err != nil {
 		err = fmt.Errorf("ProcessDeposits got Err when running JSONResponse %v", err)
 		return err
 	}
@@ -55,6 +53,19 @@ func ProcessDeposits(deposits []model_server.Deposit, w http.ResponseWriter) (er
 	return err
 }
 
+// receiptBlock returns the block confirming the deposit of receipt, or its
+// transaction while it is pending
+func receiptBlock(receipt model_server.Receipt) (interface{}, error) {
+	provider, err := model_server.GetProvider(receipt.CoinType)
+	if err != nil {
+		return nil, fmt.Errorf("CoinType (%s) not supported", receipt.CoinType)
+	}
+	if receipt.BlockHash == "" {
+		return provider.GetTransaction(receipt.TxID)
+	}
+	return provider.GetBlock(receipt.BlockHash)
+}
+
 // GetBlock
 func GetBlock(coinType, hash string, w http.ResponseWriter) (err error) {
 	provider, err := model_server.GetProvider(coinType)
@@ -103,6 +114,10 @@ func GetGetBlockHash(coinType string, tx string, w http.ResponseWriter) (err err
 	}
 
 	result, err := provider.GetGetBlockHash(tx)
+	if err != nil {
+		return err
+	}
+
 	if err = utils.JSONResponse(w, result); err != nil {
 		err = fmt.Errorf("ProcessDeposits got Err when running JSONResponse %v", err)
 		return err
@@ -111,6 +126,28 @@ func GetGetBlockHash(coinType string, tx string, w http.ResponseWriter) (err err
 	return nil
 }
 
+// GetTransaction writes txid along with its status and confirmations, in the
+// format of the coin
+func GetTransaction(coinType string, txid string, w http.ResponseWriter) (err error) {
+	provider, err := model_server.GetProvider(coinType)
+	if err != nil {
+		err = fmt.Errorf("CoinType (%s) not supported", coinType)
+		return err
+	}
+
+	result, err := provider.GetTransaction(txid)
+	if err != nil {
+		return err
+	}
+
+	if err = utils.JSONResponse(w, result); err != nil {
+		err = fmt.Errorf("GetTransaction got Err when running JSONResponse %v", err)
+		return err
+	}
+
+	return nil
+}
+
 //GetBlockCount
 func GetBlockCount(coinType string, w http.ResponseWriter) (err error) {
 	provider, err := model_server.GetProvider(coinType)
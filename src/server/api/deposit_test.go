@@ -9,11 +9,15 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/btcsuite/btcd/btcjson"
 	"github.com/modeneis/waves-go-client/model"
 	"github.com/skycoin/skycoin/src/visor"
 	"github.com/stretchr/testify/require"
 
+	"github.com/modeneis/coind/src/providers/sky"
+	"github.com/modeneis/coind/src/providers/waves"
 	"github.com/modeneis/coind/src/server/model_server"
 	"github.com/modeneis/coind/src/server/utils"
 	"github.com/modeneis/coind/src/server/api"
@@ -32,10 +36,10 @@ func TestNextDeposit(t *testing.T) {
 			"create new valid deposit for skycoin",
 			"POST",
 			http.StatusOK,
-			"/api/nextdeposit",
+			"/api/nextdeposit?block=true",
 			[]model_server.Deposit{
 				{
-					Address:  "1FeDtFhARLxjKUPPkQqEBL78tisenc9znS",
+					Address:  "24jt6Yyvs7Z5ARctzVgysq8mQPrqhrzFvxr",
 					Value:    10000,
 					Hours:    3455,
 					CoinType: api.CoinTypeSKY,
@@ -46,7 +50,7 @@ func TestNextDeposit(t *testing.T) {
 			"create new valid deposit for waves",
 			"POST",
 			http.StatusOK,
-			"/api/nextdeposit",
+			"/api/nextdeposit?block=true",
 			[]model_server.Deposit{
 				{
 					Address:  "3PFnbq8kQjYyPwHMaSnbyQ78t15uU6nbkqi",
@@ -151,3 +155,277 @@ func TestNextDeposit(t *testing.T) {
 	})
 
 }
+
+func TestDepositValidation(t *testing.T) {
+	defer useFakeUpstream(t)()
+
+	testflight.WithServer(api.InitRouting(), func(r *testflight.Requester) {
+		deposits := []model_server.Deposit{
+			{Address: "24jt6Yyvs7Z5ARctzVgysq8mQPrqhrzFvxr", Value: 1, CoinType: api.CoinTypeSKY},
+			{Address: "1FeDtFhARLxjKUPPkQqEBL78tisenc9znS", Value: 1, CoinType: api.CoinTypeSKY},
+			{Address: "3PFnbq8kQjYyPwHMaSnbyQ78t15uU6nbkqj", Value: 0, CoinType: api.CoinTypeWAVES},
+			{Address: "24jt6Yyvs7Z5ARctzVgysq8mQPrqhrzFvxr", Value: 1},
+		}
+		raw, err := json.Marshal(deposits)
+		require.NoError(t, err)
+
+		for _, endpoint := range []string{"/api/nextdeposit", "/api/mempool"} {
+			response := r.Post(endpoint, "application/json", string(raw))
+			require.Equal(t, http.StatusBadRequest, response.StatusCode)
+
+			var verr model_server.ValidationError
+			require.NoError(t, json.Unmarshal(response.RawBody, &verr))
+			require.Equal(t, []model_server.FieldError{
+				{Index: 1, Field: "address", Message: "invalid skycoin address: Invalid version"},
+				{Index: 2, Field: "address", Message: "invalid waves address: invalid checksum"},
+				{Index: 2, Field: "value", Message: "value must be positive"},
+				{Index: 3, Field: "cointype", Message: "cointype is required"},
+			}, verr.Errors)
+		}
+
+		// nothing was created for the valid deposit either
+		response := r.Get("/api/get_block_count?cointype=SKY")
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.Equal(t, "0", response.Body)
+	})
+}
+
+func TestDecimalAmounts(t *testing.T) {
+	defer useFakeUpstream(t)()
+
+	skyAddress := "24jt6Yyvs7Z5ARctzVgysq8mQPrqhrzFvxr"
+	wavesAddress := "3PFnbq8kQjYyPwHMaSnbyQ78t15uU6nbkqi"
+
+	testflight.WithServer(api.InitRouting(), func(r *testflight.Requester) {
+		deposits := []model_server.Deposit{
+			{Address: skyAddress, Amount: "1.5", CoinType: api.CoinTypeSKY},
+			{Address: wavesAddress, Amount: "0.00000042", CoinType: api.CoinTypeWAVES},
+		}
+		for _, deposit := range deposits {
+			raw, err := json.Marshal([]model_server.Deposit{deposit})
+			require.NoError(t, err)
+			response := r.Post("/api/nextdeposit", "application/json", string(raw))
+			require.Equal(t, http.StatusOK, response.StatusCode)
+		}
+
+		response := r.Get("/api/outputs?cointype=SKY&address=" + skyAddress)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		var outputs visor.ReadableOutputSet
+		require.NoError(t, json.Unmarshal(response.RawBody, &outputs))
+		require.Len(t, outputs.HeadOutputs, 1)
+		require.Equal(t, "1.500000", outputs.HeadOutputs[0].Coins)
+
+		response = r.Get("/api/balance?cointype=WAVES&address=" + wavesAddress)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		var bal struct{ Balance int64 }
+		require.NoError(t, json.Unmarshal(response.RawBody, &bal))
+		require.Equal(t, int64(42), bal.Balance)
+
+		invalid := []model_server.Deposit{
+			{Address: skyAddress, Amount: "1.0000001", CoinType: api.CoinTypeSKY},
+			{Address: skyAddress, Amount: "1", Value: 1, CoinType: api.CoinTypeSKY},
+			{Address: wavesAddress, Amount: "100000000000", CoinType: api.CoinTypeWAVES},
+		}
+		raw, err := json.Marshal(invalid)
+		require.NoError(t, err)
+		response = r.Post("/api/nextdeposit", "application/json", string(raw))
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+
+		var verr model_server.ValidationError
+		require.NoError(t, json.Unmarshal(response.RawBody, &verr))
+		require.Equal(t, []model_server.FieldError{
+			{Index: 0, Field: "amount", Message: "amount 1.0000001 has more than 6 decimals"},
+			{Index: 1, Field: "amount", Message: "amount and value are exclusive"},
+			{Index: 2, Field: "amount", Message: "amount 100000000000 is too large"},
+		}, verr.Errors)
+	})
+}
+
+func TestDepositPlacement(t *testing.T) {
+	defer useFakeUpstream(t)()
+
+	skyAddress := "24jt6Yyvs7Z5ARctzVgysq8mQPrqhrzFvxr"
+	wavesAddress := "3PFnbq8kQjYyPwHMaSnbyQ78t15uU6nbkqi"
+	scheduledTx := "6c0a4bd5c0a84ce7b3e3c9b0f1d3e5f7a9b1c3d5e7f9a1b3c5d7e9f1a3b5c7d9"
+	backfilledTx := "7d1b5ce6d1b95df8c4f4dac1f2e4f6a8bac2d4e6f8a0b2c4d6e8f0a2b4c6d8ea"
+
+	testflight.WithServer(api.InitRouting(), func(r *testflight.Requester) {
+		deposit := func(endpoint string, deposit model_server.Deposit) *testflight.Response {
+			raw, err := json.Marshal([]model_server.Deposit{deposit})
+			require.NoError(t, err)
+			return r.Post(endpoint, "application/json", string(raw))
+		}
+		skyTransaction := func(txid string) sky.Transaction {
+			response := r.Get("/api/transaction?cointype=SKY&txid=" + txid)
+			require.Equal(t, http.StatusOK, response.StatusCode)
+			var tx sky.Transaction
+			require.NoError(t, json.Unmarshal(response.RawBody, &tx))
+			return tx
+		}
+
+		for i := 0; i < 3; i++ {
+			response := deposit("/api/nextdeposit", model_server.Deposit{Address: skyAddress, Value: 1, CoinType: api.CoinTypeSKY})
+			require.Equal(t, http.StatusOK, response.StatusCode)
+		}
+
+		t.Run("future height", func(t *testing.T) {
+			response := deposit("/api/nextdeposit", model_server.Deposit{
+				Address: skyAddress, Value: 2, Height: 5, Tx: scheduledTx, N: 2, CoinType: api.CoinTypeSKY,
+			})
+			require.Equal(t, http.StatusOK, response.StatusCode)
+			require.Equal(t, "3", r.Get("/api/get_block_count?cointype=SKY").Body)
+			require.False(t, skyTransaction(scheduledTx).Status.Confirmed)
+
+			// the block at height 4 leaves it pending, the one at height 5 confirms it
+			for i := 0; i < 2; i++ {
+				response = deposit("/api/nextdeposit", model_server.Deposit{Address: skyAddress, Value: 1, CoinType: api.CoinTypeSKY})
+				require.Equal(t, http.StatusOK, response.StatusCode)
+				require.Equal(t, i == 1, skyTransaction(scheduledTx).Status.Confirmed)
+			}
+
+			tx := skyTransaction(scheduledTx)
+			require.Equal(t, uint64(5), tx.Status.BlockSeq)
+			require.Equal(t, scheduledTx, tx.Transaction.Hash)
+			require.True(t, len(tx.Transaction.Out) > 2)
+			require.Equal(t, skyAddress, tx.Transaction.Out[2].Address)
+			require.Equal(t, "2.000000", tx.Transaction.Out[2].Coins)
+		})
+
+		t.Run("past height", func(t *testing.T) {
+			backfilled := "2evvxxvqnu2SYXN3RtzjUC3nu9NFGgv1LgF"
+			response := deposit("/api/nextdeposit?block=true", model_server.Deposit{
+				Address: backfilled, Value: 7, Height: 2, Tx: backfilledTx, CoinType: api.CoinTypeSKY,
+			})
+			require.Equal(t, http.StatusOK, response.StatusCode)
+
+			var blocks visor.ReadableBlocks
+			require.NoError(t, json.Unmarshal(response.RawBody, &blocks))
+			require.Equal(t, uint64(2), blocks.Blocks[0].Head.BkSeq)
+			require.Len(t, blocks.Blocks[0].Body.Transactions, 2)
+			require.Equal(t, "5", r.Get("/api/get_block_count?cointype=SKY").Body)
+
+			tx := skyTransaction(backfilledTx)
+			require.Equal(t, uint64(2), tx.Status.BlockSeq)
+			require.Equal(t, uint64(4), tx.Status.Height)
+			require.Equal(t, backfilled, tx.Transaction.Out[0].Address)
+
+			response = r.Get("/api/outputs?cointype=SKY&address=" + backfilled)
+			require.Equal(t, http.StatusOK, response.StatusCode)
+			var outputs visor.ReadableOutputSet
+			require.NoError(t, json.Unmarshal(response.RawBody, &outputs))
+			require.Len(t, outputs.HeadOutputs, 1)
+			require.Equal(t, "7.000000", outputs.HeadOutputs[0].Coins)
+		})
+
+		t.Run("txid is unique", func(t *testing.T) {
+			response := deposit("/api/mempool", model_server.Deposit{Address: skyAddress, Value: 1, Tx: backfilledTx, CoinType: api.CoinTypeSKY})
+			require.Equal(t, http.StatusBadRequest, response.StatusCode)
+			require.Contains(t, response.Body, "transaction "+backfilledTx+" is already confirmed")
+		})
+
+		t.Run("waves", func(t *testing.T) {
+			response := deposit("/api/nextdeposit", model_server.Deposit{Address: wavesAddress, Value: 1, CoinType: api.CoinTypeWAVES})
+			require.Equal(t, http.StatusOK, response.StatusCode)
+
+			response = deposit("/api/mempool", model_server.Deposit{Address: wavesAddress, Value: 5, Height: 1, Tx: "backfilled", CoinType: api.CoinTypeWAVES})
+			require.Equal(t, http.StatusOK, response.StatusCode)
+			response = deposit("/api/mempool", model_server.Deposit{Address: wavesAddress, Value: 3, Height: 3, Tx: "scheduled", CoinType: api.CoinTypeWAVES})
+			require.Equal(t, http.StatusOK, response.StatusCode)
+
+			response = r.Get("/api/balance?cointype=WAVES&address=" + wavesAddress)
+			var bal waves.AddressBalance
+			require.NoError(t, json.Unmarshal(response.RawBody, &bal))
+			require.Equal(t, int64(6), bal.Balance)
+			require.Equal(t, int64(9), bal.Unconfirmed)
+
+			for height := 2; height <= 3; height++ {
+				response = r.Post("/api/admin/mine?cointype=WAVES", "", "")
+				require.Equal(t, http.StatusOK, response.StatusCode)
+			}
+
+			response = r.Get("/api/transaction?cointype=WAVES&txid=scheduled")
+			require.Equal(t, http.StatusOK, response.StatusCode)
+			var info waves.TransactionInfo
+			require.NoError(t, json.Unmarshal(response.RawBody, &info))
+			require.Equal(t, model_server.TxConfirmed, info.Status)
+			require.Equal(t, int64(3), info.Height)
+
+			response = deposit("/api/nextdeposit", model_server.Deposit{Address: wavesAddress, Value: 1, N: 1, CoinType: api.CoinTypeWAVES})
+			require.Equal(t, http.StatusBadRequest, response.StatusCode)
+			require.Contains(t, response.Body, "WAVES transactions have no outputs")
+		})
+	})
+}
+
+func TestDepositReceipts(t *testing.T) {
+	defer useFakeUpstream(t)()
+	defer model_server.DefaultClock.Set(model_server.ClockReal, time.Now(), 0)
+
+	start := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, model_server.DefaultClock.Set(model_server.ClockFixed, start, 0))
+
+	skyAddress := "24jt6Yyvs7Z5ARctzVgysq8mQPrqhrzFvxr"
+	wavesAddress := "3PFnbq8kQjYyPwHMaSnbyQ78t15uU6nbkqi"
+	skyTx := "8e2c6df7e2ca6e09d5f5ebd2f3f5f7b9cbd3e5f7a9b1c3d5e7f9a1b3c5d7e9fb"
+
+	testflight.WithServer(api.InitRouting(), func(r *testflight.Requester) {
+		raw, err := json.Marshal([]model_server.Deposit{
+			{Address: skyAddress, Amount: "1.50", N: 1, Tx: skyTx, CoinType: api.CoinTypeSKY},
+			{Address: wavesAddress, Value: 100000000, CoinType: api.CoinTypeWAVES},
+			{Address: wavesAddress, Amount: "0.5", Height: 3, Tx: "later", CoinType: api.CoinTypeWAVES},
+		})
+		require.NoError(t, err)
+
+		response := r.Post("/api/nextdeposit", "application/json", string(raw))
+		require.Equal(t, http.StatusOK, response.StatusCode, response.Body)
+		var receipts []model_server.Receipt
+		require.NoError(t, json.Unmarshal(response.RawBody, &receipts))
+		require.Len(t, receipts, 3)
+
+		response = r.Get("/api/get_blocks_by_seq?cointype=SKY&seq=1")
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		var best btcjson.GetBestBlockResult
+		require.NoError(t, json.Unmarshal(response.RawBody, &best))
+
+		require.Equal(t, model_server.Receipt{
+			CoinType:  api.CoinTypeSKY,
+			TxID:      skyTx,
+			N:         1,
+			Address:   skyAddress,
+			Amount:    "1.5",
+			Status:    model_server.TxConfirmed,
+			BlockHash: best.Hash,
+			Height:    1,
+			Timestamp: start.Unix(),
+		}, receipts[0])
+
+		require.Equal(t, api.CoinTypeWAVES, receipts[1].CoinType)
+		require.NotEmpty(t, receipts[1].TxID)
+		require.Equal(t, "1", receipts[1].Amount)
+		require.Equal(t, model_server.TxConfirmed, receipts[1].Status)
+		require.NotEmpty(t, receipts[1].BlockHash)
+		require.Equal(t, int64(1), receipts[1].Height)
+		require.Equal(t, start.Unix(), receipts[1].Timestamp)
+
+		require.Equal(t, model_server.Receipt{
+			CoinType:  api.CoinTypeWAVES,
+			TxID:      "later",
+			Address:   wavesAddress,
+			Amount:    "0.5",
+			Status:    model_server.TxPending,
+			Timestamp: start.Unix(),
+		}, receipts[2])
+
+		// the block of the last deposit is still available
+		raw, err = json.Marshal([]model_server.Deposit{{Address: skyAddress, Value: 1, CoinType: api.CoinTypeSKY}})
+		require.NoError(t, err)
+		response = r.Post("/api/nextdeposit?block=true", "application/json", string(raw))
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		var blocks visor.ReadableBlocks
+		require.NoError(t, json.Unmarshal(response.RawBody, &blocks))
+		require.Equal(t, uint64(2), blocks.Blocks[0].Head.BkSeq)
+
+		response = r.Post("/api/nextdeposit?block=maybe", "application/json", string(raw))
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+	})
+}
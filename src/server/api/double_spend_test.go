@@ -0,0 +1,179 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/drewolson/testflight"
+	"github.com/modeneis/waves-go-client/model"
+	"github.com/skycoin/skycoin/src/visor"
+	"github.com/skycoin/skycoin/src/wallet"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modeneis/coind/src/providers/sky"
+	"github.com/modeneis/coind/src/providers/waves"
+	"github.com/modeneis/coind/src/server/api"
+	"github.com/modeneis/coind/src/server/model_server"
+)
+
+// nextEvent returns the next event of type typ
+func nextEvent(t *testing.T, sub *model_server.Subscription, typ string) model_server.Event {
+	for {
+		select {
+		case e := <-sub.C:
+			if e.Type == typ {
+				return e
+			}
+		case <-time.After(time.Second):
+			require.FailNow(t, "no "+typ+" event")
+		}
+	}
+}
+
+func TestDoubleSpend(t *testing.T) {
+	defer useFakeUpstream(t)()
+
+	a := "2GgFvqoyk9RjwVzj8tqfcXVXB4orBwoc9qv"
+	b := "2evvxxvqnu2SYXN3RtzjUC3nu9NFGgv1LgF"
+	c := "cBnu9sUvv12dovBmjQKTtfE4rbjMmf3fzW"
+
+	testflight.WithServer(api.InitRouting(), func(r *testflight.Requester) {
+		post := func(endpoint string, body interface{}, v interface{}) {
+			raw, err := json.Marshal(body)
+			require.NoError(t, err)
+			response := r.Post(endpoint, "application/json", string(raw))
+			require.Equal(t, http.StatusOK, response.StatusCode, response.Body)
+			require.NoError(t, json.Unmarshal(response.RawBody, v))
+		}
+
+		balance := func(address string) wallet.BalancePair {
+			response := r.Get("/api/balance?cointype=SKY&address=" + address)
+			require.Equal(t, http.StatusOK, response.StatusCode)
+			var bal wallet.BalancePair
+			require.NoError(t, json.Unmarshal(response.RawBody, &bal))
+			return bal
+		}
+
+		transaction := func(txid string) (sky.Transaction, int) {
+			response := r.Get("/api/transaction?cointype=SKY&txid=" + txid)
+			var tx sky.Transaction
+			if response.StatusCode == http.StatusOK {
+				require.NoError(t, json.Unmarshal(response.RawBody, &tx))
+			}
+			return tx, response.StatusCode
+		}
+
+		t.Run("mempool", func(t *testing.T) {
+			var pending []visor.ReadableTransaction
+			post("/api/mempool", []model_server.Deposit{{Address: a, Value: 3, CoinType: api.CoinTypeSKY}}, &pending)
+
+			_, sub := model_server.SubscribeEvents(0)
+			defer sub.Cancel()
+
+			var conflicting visor.ReadableTransaction
+			post("/api/admin/double_spend?cointype=SKY", model_server.DoubleSpend{
+				TxID:    pending[0].Hash,
+				Deposit: model_server.Deposit{Address: b, Value: 3},
+			}, &conflicting)
+			require.NotEqual(t, pending[0].Hash, conflicting.Hash)
+			require.Equal(t, pending[0].In, conflicting.In)
+
+			spent := nextEvent(t, sub, model_server.EventDepositDoubleSpent)
+			require.Equal(t, pending[0].Hash, spent.TxID)
+			require.Equal(t, a, spent.Address)
+			require.Equal(t, conflicting.Hash, spent.ReplacedBy)
+			accepted := nextEvent(t, sub, model_server.EventDepositAccepted)
+			require.Equal(t, conflicting.Hash, accepted.TxID)
+
+			require.Equal(t, uint64(0), balance(a).Predicted.Coins)
+			require.Equal(t, uint64(3e6), balance(b).Predicted.Coins)
+			_, status := transaction(pending[0].Hash)
+			require.Equal(t, http.StatusNotFound, status)
+			tx, _ := transaction(conflicting.Hash)
+			require.True(t, tx.Status.Unconfirmed)
+
+			var blocks visor.ReadableBlocks
+			post("/api/admin/mine?cointype=SKY", nil, &blocks)
+			require.Len(t, blocks.Blocks[0].Body.Transactions, 1)
+			require.Equal(t, conflicting.Hash, blocks.Blocks[0].Body.Transactions[0].Hash)
+		})
+
+		t.Run("confirmed", func(t *testing.T) {
+			r.Post("/api/admin/reset?cointype=SKY", "", "")
+
+			var first, second []model_server.Receipt
+			post("/api/nextdeposit", []model_server.Deposit{{Address: a, Value: 1, CoinType: api.CoinTypeSKY}}, &first)
+			post("/api/nextdeposit", []model_server.Deposit{{Address: c, Value: 2, CoinType: api.CoinTypeSKY}}, &second)
+			original := first[0]
+
+			_, sub := model_server.SubscribeEvents(0)
+			defer sub.Cancel()
+
+			var conflicting visor.ReadableTransaction
+			post("/api/admin/double_spend?cointype=SKY", model_server.DoubleSpend{
+				TxID:    original.TxID,
+				Deposit: model_server.Deposit{Address: b, Value: 1},
+			}, &conflicting)
+
+			disconnected := nextEvent(t, sub, model_server.EventBlockDisconnected)
+			require.Equal(t, int64(2), disconnected.Height)
+			spent := nextEvent(t, sub, model_server.EventDepositDoubleSpent)
+			require.Equal(t, original.TxID, spent.TxID)
+			require.Equal(t, int64(1), spent.Height)
+			require.Equal(t, original.BlockHash, spent.BlockHash)
+			require.Equal(t, conflicting.Hash, spent.ReplacedBy)
+			confirmed := nextEvent(t, sub, model_server.EventDepositConfirmed)
+			require.Equal(t, conflicting.Hash, confirmed.TxID)
+			require.Equal(t, int64(1), confirmed.Height)
+
+			response := r.Get("/api/get_block_count?cointype=SKY")
+			require.Equal(t, "3", response.Body)
+
+			tx, _ := transaction(original.TxID)
+			require.True(t, tx.Status.Unknown)
+			tx, _ = transaction(conflicting.Hash)
+			require.True(t, tx.Status.Confirmed)
+			require.Equal(t, uint64(3), tx.Status.Height)
+			tx, _ = transaction(second[0].TxID)
+			require.Equal(t, uint64(2), tx.Status.Height)
+
+			require.Equal(t, uint64(0), balance(a).Confirmed.Coins)
+			require.Equal(t, uint64(1e6), balance(b).Confirmed.Coins)
+			require.Equal(t, uint64(2e6), balance(c).Confirmed.Coins)
+
+			response = r.Post("/api/admin/double_spend?cointype=SKY", "application/json", `{"txid": "`+original.TxID+`", "deposit": {"Address": "`+b+`"}}`)
+			require.Equal(t, http.StatusBadRequest, response.StatusCode)
+			response = r.Post("/api/admin/double_spend?cointype=SKY", "application/json", `{"txid": "`+conflicting.Hash+`"}`)
+			require.Equal(t, http.StatusBadRequest, response.StatusCode)
+		})
+
+		t.Run("waves", func(t *testing.T) {
+			address := "3PFnbq8kQjYyPwHMaSnbyQ78t15uU6nbkqi"
+
+			var blocks model.Blocks
+			post("/api/nextdeposit?block=true", []model_server.Deposit{{Address: address, Value: 10, CoinType: api.CoinTypeWAVES}}, &blocks)
+			original := blocks.Transactions[len(blocks.Transactions)-1]
+
+			var conflicting model.Transactions
+			post("/api/admin/double_spend?cointype=WAVES", model_server.DoubleSpend{
+				TxID:    original.ID,
+				Deposit: model_server.Deposit{Address: "3PAzf6GVXEeCjU8cQQYqy2H88Rzryowb9Pc", Value: 10},
+			}, &conflicting)
+			require.Equal(t, original.Sender, conflicting.Sender)
+			require.Equal(t, original.Timestamp, conflicting.Timestamp)
+
+			var info waves.TransactionInfo
+			response := r.Get("/api/transaction?cointype=WAVES&txid=" + original.ID)
+			require.NoError(t, json.Unmarshal(response.RawBody, &info))
+			require.Equal(t, model_server.TxOrphaned, info.Status)
+
+			info = waves.TransactionInfo{}
+			response = r.Get("/api/transaction?cointype=WAVES&txid=" + conflicting.ID)
+			require.NoError(t, json.Unmarshal(response.RawBody, &info))
+			require.Equal(t, model_server.TxConfirmed, info.Status)
+			require.Equal(t, int64(2), info.Confirmations)
+		})
+	})
+}
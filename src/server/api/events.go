@@ -0,0 +1,109 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/modeneis/coind/src/server/model_server"
+)
+
+const (
+	// eventStreamDuration ends a stream before the server write timeout
+	// kills the connection, clients resume with Last-Event-ID
+	eventStreamDuration = model_server.ServerWriteTimeout - 2*time.Second
+
+	// eventKeepAlive is how often a comment is sent on an idle stream
+	eventKeepAlive = 5 * time.Second
+
+	// eventRetry is the reconnection delay suggested to clients, in ms
+	eventRetry = 1000
+)
+
+// EventFilter selects the events sent on a stream. Events that are not about
+// a single address, such as blocks and resets, pass the address filter.
+type EventFilter struct {
+	CoinType string
+	Address  string
+}
+
+// Match reports whether e passes the filter
+func (f EventFilter) Match(e model_server.Event) bool {
+	if f.CoinType != "" && f.CoinType != e.CoinType {
+		return false
+	}
+	if f.Address != "" && e.Address != "" && f.Address != e.Address {
+		return false
+	}
+	return true
+}
+
+// StreamEvents writes the events published after lastID as Server-Sent Events,
+// until done is closed or the stream gets too old
+func StreamEvents(w http.ResponseWriter, done <-chan struct{}, lastID int64, filter EventFilter) (err error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		errCode := http.StatusInternalServerError
+		http.Error(w, fmt.Sprintf("%d streaming is not supported", errCode), errCode)
+		return fmt.Errorf("streaming is not supported by the connection")
+	}
+
+	backlog, sub := model_server.SubscribeEvents(lastID)
+	defer sub.Cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	if _, err = fmt.Fprintf(w, "retry: %d\n\n", eventRetry); err != nil {
+		return err
+	}
+
+	for _, e := range backlog {
+		if err = writeEvent(w, e, filter); err != nil {
+			return err
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+	timeout := time.After(eventStreamDuration)
+
+	for {
+		select {
+		case e, ok := <-sub.C:
+			if !ok {
+				// fell too far behind, the client resumes from its last event
+				return nil
+			}
+			if err = writeEvent(w, e, filter); err != nil {
+				return err
+			}
+		case <-keepAlive.C:
+			if _, err = fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return err
+			}
+		case <-timeout:
+			return nil
+		case <-done:
+			return nil
+		}
+		flusher.Flush()
+	}
+}
+
+func writeEvent(w http.ResponseWriter, e model_server.Event, filter EventFilter) error {
+	if !filter.Match(e) {
+		return nil
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+	return err
+}
@@ -0,0 +1,87 @@
+package api_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/modeneis/coind/src/server/api"
+	"github.com/modeneis/coind/src/server/model_server"
+)
+
+// readEvents reads n events from an event stream
+func readEvents(t *testing.T, reader *bufio.Reader, n int) []model_server.Event {
+	var events []model_server.Event
+	for len(events) < n {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+
+		if strings.HasPrefix(line, "data: ") {
+			var e model_server.Event
+			require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &e))
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+func TestEvents(t *testing.T) {
+	defer useFakeUpstream(t)()
+
+	server := httptest.NewServer(api.InitRouting())
+	defer server.Close()
+
+	skyAddress := "cBnu9sUvv12dovBmjQKTtfE4rbjMmf3fzW"
+
+	// get the id of the last event published by other tests
+	last := model_server.PublishEvent(model_server.Event{Type: "test"})
+
+	resp, err := http.Get(server.URL + "/api/events?cointype=SKY&address=" + skyAddress + "&last_event_id=" + strconv.FormatInt(last.ID, 10))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	reader := bufio.NewReader(resp.Body)
+
+	deposits := []model_server.Deposit{
+		{Address: "3PFnbq8kQjYyPwHMaSnbyQ78t15uU6nbkqi", Value: 1, CoinType: api.CoinTypeWAVES},
+		{Address: "2GgFvqoyk9RjwVzj8tqfcXVXB4orBwoc9qv", Value: 2, CoinType: api.CoinTypeSKY},
+		{Address: skyAddress, Value: 3, CoinType: api.CoinTypeSKY},
+	}
+	for _, deposit := range deposits {
+		raw, err := json.Marshal([]model_server.Deposit{deposit})
+		require.NoError(t, err)
+		response, err := http.Post(server.URL+"/api/nextdeposit", "application/json", strings.NewReader(string(raw)))
+		require.NoError(t, err)
+		require.NoError(t, response.Body.Close())
+		require.Equal(t, http.StatusOK, response.StatusCode)
+	}
+
+	events := readEvents(t, reader, 4)
+	// the block of the other sky address is sent, its deposit is not
+	require.Equal(t, model_server.EventBlockConnected, events[0].Type)
+	require.Equal(t, model_server.EventDepositAccepted, events[1].Type)
+	require.Equal(t, skyAddress, events[1].Address)
+	require.Equal(t, int64(3e6), events[1].Amount)
+	require.Equal(t, model_server.EventBlockConnected, events[2].Type)
+	require.Equal(t, model_server.EventDepositConfirmed, events[3].Type)
+	require.Equal(t, events[2].BlockHash, events[3].BlockHash)
+	for _, e := range events {
+		require.Equal(t, api.CoinTypeSKY, e.CoinType)
+	}
+
+	// resume after the first event
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/api/events?cointype=SKY&address="+skyAddress, nil)
+	require.NoError(t, err)
+	req.Header.Set("Last-Event-ID", strconv.FormatInt(events[0].ID, 10))
+	resumed, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resumed.Body.Close()
+
+	require.Equal(t, events[1:], readEvents(t, bufio.NewReader(resumed.Body), 3))
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/modeneis/coind/src/server/fault"
+	"github.com/modeneis/coind/src/server/utils"
+)
+
+// AddFault adds a fault rule and writes it back with its ID
+func AddFault(rule fault.Rule, w http.ResponseWriter) (err error) {
+	rule, err = fault.DefaultInjector.Add(rule)
+	if err != nil {
+		return err
+	}
+
+	if err = utils.JSONResponse(w, rule); err != nil {
+		err = fmt.Errorf("AddFault got Err when running JSONResponse %v", err)
+		return err
+	}
+	return nil
+}
+
+// RemoveFault removes a fault rule, or every rule if id is empty
+func RemoveFault(id string, w http.ResponseWriter) (err error) {
+	if id == "" {
+		fault.DefaultInjector.Clear()
+	} else if err = fault.DefaultInjector.Remove(id); err != nil {
+		return err
+	}
+
+	if err = utils.JSONResponse(w, id); err != nil {
+		err = fmt.Errorf("RemoveFault got Err when running JSONResponse %v", err)
+		return err
+	}
+	return nil
+}
+
+// ListFaults writes the fault rules in the order they are checked
+func ListFaults(w http.ResponseWriter) (err error) {
+	if err = utils.JSONResponse(w, fault.DefaultInjector.Rules()); err != nil {
+		err = fmt.Errorf("ListFaults got Err when running JSONResponse %v", err)
+		return err
+	}
+	return nil
+}
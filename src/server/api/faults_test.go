@@ -0,0 +1,51 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/drewolson/testflight"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modeneis/coind/src/server/api"
+	"github.com/modeneis/coind/src/server/fault"
+)
+
+func TestFaults(t *testing.T) {
+	defer useFakeUpstream(t)()
+	defer fault.DefaultInjector.Clear()
+
+	testflight.WithServer(api.Handler(), func(r *testflight.Requester) {
+		response := r.Post("/api/admin/faults", "application/json",
+			`{"route": "/api/balance", "cointype": "SKY", "action": "error", "status_code": 503, "latency": "10ms"}`)
+		require.Equal(t, http.StatusOK, response.StatusCode, response.Body)
+
+		var rule fault.Rule
+		require.NoError(t, json.Unmarshal(response.RawBody, &rule))
+		require.NotEmpty(t, rule.ID)
+
+		response = r.Get("/api/balance?cointype=SKY&address=2GgFvqoyk9RjwVzj8tqfcXVXB4orBwoc9qv")
+		require.Equal(t, http.StatusServiceUnavailable, response.StatusCode)
+		response = r.Get("/api/balance?cointype=WAVES&address=3PFnbq8kQjYyPwHMaSnbyQ78t15uU6nbkqi")
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		var rules []fault.Rule
+		response = r.Get("/api/admin/faults")
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.NoError(t, json.Unmarshal(response.RawBody, &rules))
+		require.Len(t, rules, 1)
+		require.Equal(t, 1, rules[0].Hits)
+
+		response = r.Post("/api/admin/faults", "application/json", `{"action": "sometimes"}`)
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+
+		response = r.Delete("/api/admin/faults?id="+rule.ID, "", "")
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		response = r.Delete("/api/admin/faults?id="+rule.ID, "", "")
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+
+		response = r.Get("/api/balance?cointype=SKY&address=2GgFvqoyk9RjwVzj8tqfcXVXB4orBwoc9qv")
+		require.Equal(t, http.StatusOK, response.StatusCode)
+	})
+}
@@ -0,0 +1,108 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/drewolson/testflight"
+	"github.com/skycoin/skycoin/src/util/droplet"
+	"github.com/skycoin/skycoin/src/visor"
+	"github.com/skycoin/skycoin/src/wallet"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modeneis/coind/src/providers/sky"
+	"github.com/modeneis/coind/src/providers/waves"
+	"github.com/modeneis/coind/src/server/address"
+	"github.com/modeneis/coind/src/server/api"
+	"github.com/modeneis/coind/src/server/model_server"
+)
+
+func TestFundingWallet(t *testing.T) {
+	defer useFakeUpstream(t)()
+	// blocks mined in the same second leave the change without accrued hours
+	require.NoError(t, model_server.DefaultClock.Set(model_server.ClockFixed, time.Now(), 0))
+	defer model_server.DefaultClock.Set(model_server.ClockReal, time.Now(), 0)
+
+	testflight.WithServer(api.InitRouting(), func(r *testflight.Requester) {
+		deposit := func(deposit model_server.Deposit) {
+			raw, err := json.Marshal([]model_server.Deposit{deposit})
+			require.NoError(t, err)
+			response := r.Post("/api/nextdeposit", "application/json", string(raw))
+			require.Equal(t, http.StatusOK, response.StatusCode)
+		}
+
+		t.Run("sky", func(t *testing.T) {
+			require.NoError(t, address.ValidateSkycoin(sky.FundingAddress))
+
+			transaction := func(txid string) visor.ReadableTransaction {
+				response := r.Get("/api/transaction?cointype=SKY&txid=" + txid)
+				require.Equal(t, http.StatusOK, response.StatusCode)
+				var tx sky.Transaction
+				require.NoError(t, json.Unmarshal(response.RawBody, &tx))
+				return tx.Transaction
+			}
+			sum := func(outs []visor.ReadableTransactionOutput) (coins, hours uint64) {
+				for _, out := range outs {
+					c, err := droplet.FromString(out.Coins)
+					require.NoError(t, err)
+					coins += c
+					hours += out.Hours
+				}
+				return coins, hours
+			}
+
+			first := "a1b2c3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f60718293a4b5c6d7e8f90"
+			second := "b1b2c3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f60718293a4b5c6d7e8f90"
+			deposit(model_server.Deposit{Address: "24jt6Yyvs7Z5ARctzVgysq8mQPrqhrzFvxr", Value: 10, Hours: 5, Tx: first, CoinType: api.CoinTypeSKY})
+			deposit(model_server.Deposit{Address: "24jt6Yyvs7Z5ARctzVgysq8mQPrqhrzFvxr", Amount: "2.5", Hours: 3, Tx: second, N: 1, CoinType: api.CoinTypeSKY})
+
+			tx1 := transaction(first)
+			require.NotEmpty(t, tx1.In)
+			require.Len(t, tx1.Sigs, len(tx1.In))
+			require.Len(t, tx1.Out, 2)
+			require.Equal(t, "10.000000", tx1.Out[0].Coins)
+			change := tx1.Out[1]
+			require.Equal(t, sky.FundingAddress, change.Address)
+
+			// the second deposit spends the change of the first one
+			tx2 := transaction(second)
+			require.Equal(t, []string{change.Hash}, tx2.In)
+			require.Len(t, tx2.Out, 3)
+			require.Equal(t, sky.FundingAddress, tx2.Out[0].Address)
+			require.Equal(t, "2.500000", tx2.Out[1].Coins)
+			require.Equal(t, uint64(3), tx2.Out[1].Hours)
+			require.Equal(t, sky.FundingAddress, tx2.Out[2].Address)
+			require.Equal(t, uint32(49+97+37*3), tx2.Length)
+
+			inCoins, err := droplet.FromString(change.Coins)
+			require.NoError(t, err)
+			outCoins, outHours := sum(tx2.Out)
+			require.Equal(t, inCoins, outCoins)
+			require.Equal(t, change.Hours-(change.Hours+1)/sky.BurnFactor, outHours)
+
+			// the funding wallet holds the padding output and the change
+			response := r.Get("/api/balance?cointype=SKY&address=" + sky.FundingAddress)
+			require.Equal(t, http.StatusOK, response.StatusCode)
+			var bal wallet.BalancePair
+			require.NoError(t, json.Unmarshal(response.RawBody, &bal))
+			require.Equal(t, outCoins-2500000, bal.Confirmed.Coins)
+		})
+
+		t.Run("waves", func(t *testing.T) {
+			require.NoError(t, address.ValidateWaves(waves.FundingAddress))
+
+			deposit(model_server.Deposit{Address: "3PFnbq8kQjYyPwHMaSnbyQ78t15uU6nbkqi", Value: 42, Tx: "funded", CoinType: api.CoinTypeWAVES})
+
+			response := r.Get("/api/transaction?cointype=WAVES&txid=funded")
+			require.Equal(t, http.StatusOK, response.StatusCode)
+			var info waves.TransactionInfo
+			require.NoError(t, json.Unmarshal(response.RawBody, &info))
+			require.Equal(t, waves.FundingAddress, info.Sender)
+			require.Equal(t, waves.FundingPublicKey, info.SenderPublicKey)
+			require.Equal(t, int64(waves.TransferFee), info.Fee)
+			require.Equal(t, int64(42), info.Amount)
+		})
+	})
+}
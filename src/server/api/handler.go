@@ -3,22 +3,49 @@ package api
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/modeneis/coind/src/server/fault"
 	"github.com/modeneis/coind/src/server/model_server"
+	"github.com/modeneis/coind/src/server/webhook"
 )
 
-// httpHandleNextDeposit accept deposits and create a new block, returns the block height.
+// httpHandleNextDeposit accept deposits and create a new block, returns a receipt per deposit.
 // Method: POST
-// URI: /api/nextdeposit
+// URI: /api/nextdeposit[?block=true]
 // The request body is an array of deposits, for example:
 //  [{
-//     "Address": "1FeDtFhARLxjKUPPkQqEBL78tisenc9znS",
-//     "Value":   10000,
-//     "N":       4
+//     "Address":  "24jt6Yyvs7Z5ARctzVgysq8mQPrqhrzFvxr",
+//     "Amount":   "10000.5",
+//     "CoinType": "SKY"
 //  }]
+// Amount is a decimal number of coins, converted exactly to the base units of the coin.
+// The legacy integer Value is still accepted, in coins for SKY and in base units otherwise.
+// Tx chooses the transaction id and N the index of the deposit output, for coins with outputs.
+// Height confirms the deposit in the block at that height: a later height leaves it pending until
+// that block is connected, a past height adds it to the existing block and reindexes the addresses.
+// Every deposit is paid by the funding wallet of the coin, see sky.FundingAddress and waves.FundingAddress.
+// AssetID pays a WAVES deposit in an asset issued with /api/admin/assets, Amount being in the
+// decimals of the asset and the deposits of an asset not going above its issued quantity.
+// FeeAssetID pays the fee of a WAVES transfer in an issued asset rather than in WAVES, and
+// Attachment adds a note of up to 140 bytes to it.
+// Invalid deposits are rejected with their field errors, before any block is created:
+//  {"errors": [{"index": 0, "field": "address", "message": "invalid skycoin address: Invalid checksum"}]}
+// The response holds the receipt of each deposit, in order, with its canonical decimal amount:
+//  [{"coin_type": "SKY", "txid": "...", "n": 0, "address": "24jt6Yyvs7Z5ARctzVgysq8mQPrqhrzFvxr",
+//    "amount": "10000.5", "status": "confirmed", "block_hash": "...", "height": 1, "timestamp": 1514764800}]
+// With block=true it is the block created for the last deposit instead, in the format of its coin.
+// A client retrying a submission sends the same Idempotency-Key header, or the same ClientID for
+// each deposit: the deposits minted already are not minted again, their original receipts are
+// returned instead. Keys are kept for the -idempotency-window after their last use, and reusing
+// one for other deposits, or while its first submission is in progress, is a 409 Conflict.
+// The deposits minted are recorded in the ledger, see /api/ledger, as submitted by the
+// X-Coind-Submitter header, or by the address of the client if it is not set.
 func HttpHandleNextDeposit(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Connection", "close")
 	r.Close = true
@@ -44,7 +71,24 @@ func HttpHandleNextDeposit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = ProcessDeposits(deposits, w)
+	var withBlock bool
+	if block := r.FormValue("block"); block != "" {
+		if withBlock, err = strconv.ParseBool(block); err != nil {
+			errCode := http.StatusBadRequest
+			http.Error(w, fmt.Sprintf("%d error processing data, block is invalid %v", errCode, block), errCode)
+			return
+		}
+	}
+
+	err = ProcessDeposits(submitter(r), r.Header.Get("Idempotency-Key"), deposits, withBlock, w)
+	if writeValidationError(w, err) {
+		return
+	}
+	if _, ok := err.(*model_server.IdempotencyError); ok {
+		errCode := http.StatusConflict
+		http.Error(w, fmt.Sprintf("%d error processing data: %v", errCode, err), errCode)
+		return
+	}
 	if err != nil {
 		errCode := http.StatusBadRequest
 		errMsg := fmt.Sprintf("%d error processing data: %v", errCode, err)
@@ -142,6 +186,42 @@ func HttpHandleGetBlockHash(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// HttpHandleGetTransaction looks up a transaction, with its status and confirmations
+// Method: GET
+// URI: /api/transaction?cointype=SKY&txid=...
+// The status is pending, confirmed or orphaned, in the format of the coin.
+func HttpHandleGetTransaction(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Connection", "close")
+	r.Close = true
+
+	if r.Method != http.MethodGet {
+		errCode := http.StatusMethodNotAllowed
+		http.Error(w, fmt.Sprintf("Accepts GET requests only"), errCode)
+		return
+	}
+
+	coinType := r.FormValue("cointype")
+	if coinType == "" {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data, CoinType is invalid %v", errCode, coinType), errCode)
+		return
+	}
+
+	txid := r.FormValue("txid")
+	if txid == "" {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data, txid is required", errCode), errCode)
+		return
+	}
+
+	err := GetTransaction(coinType, txid, w)
+	if err != nil {
+		errCode := http.StatusNotFound
+		http.Error(w, fmt.Sprintf("%d error processing data: %v", errCode, err), errCode)
+		return
+	}
+}
+
 func HttpHandleGetBlockCount(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Connection", "close")
 	r.Close = true
@@ -165,3 +245,1196 @@ func HttpHandleGetBlockCount(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+// HttpHandleGetBalance returns the balance of an address
+// Method: GET
+// URI: /api/balance?cointype=SKY&address=xxx
+// SKY coin hours are accrued up to the time of the head block, like on a skycoin node.
+func HttpHandleGetBalance(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Connection", "close")
+	r.Close = true
+
+	if r.Method != http.MethodGet {
+		errCode := http.StatusMethodNotAllowed
+		http.Error(w, fmt.Sprintf("Accepts GET requests only"), errCode)
+		return
+	}
+
+	coinType := r.FormValue("cointype")
+	if coinType == "" {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data, CoinType is invalid %v", errCode, coinType), errCode)
+		return
+	}
+
+	address := r.FormValue("address")
+	if address == "" {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data, address is required", errCode), errCode)
+		return
+	}
+
+	err := GetBalance(coinType, address, w)
+	if err != nil {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data: %v", errCode, err), errCode)
+		return
+	}
+}
+
+// HttpHandleGetOutputs returns the unspent outputs of an address
+// Method: GET
+// URI: /api/outputs?cointype=SKY&address=xxx
+// The calculated_hours of SKY outputs are accrued up to the time of the head block.
+func HttpHandleGetOutputs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Connection", "close")
+	r.Close = true
+
+	if r.Method != http.MethodGet {
+		errCode := http.StatusMethodNotAllowed
+		http.Error(w, fmt.Sprintf("Accepts GET requests only"), errCode)
+		return
+	}
+
+	coinType := r.FormValue("cointype")
+	if coinType == "" {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data, CoinType is invalid %v", errCode, coinType), errCode)
+		return
+	}
+
+	address := r.FormValue("address")
+	if address == "" {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data, address is required", errCode), errCode)
+		return
+	}
+
+	err := GetOutputs(coinType, address, w)
+	if err != nil {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data: %v", errCode, err), errCode)
+		return
+	}
+}
+
+// HttpHandleGetAddressTransactions returns the transaction history of an address, newest first
+// Method: GET
+// URI: /api/address_transactions?cointype=SKY&address=xxx&offset=0&limit=10
+func HttpHandleGetAddressTransactions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Connection", "close")
+	r.Close = true
+
+	if r.Method != http.MethodGet {
+		errCode := http.StatusMethodNotAllowed
+		http.Error(w, fmt.Sprintf("Accepts GET requests only"), errCode)
+		return
+	}
+
+	coinType := r.FormValue("cointype")
+	if coinType == "" {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data, CoinType is invalid %v", errCode, coinType), errCode)
+		return
+	}
+
+	address := r.FormValue("address")
+	if address == "" {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data, address is required", errCode), errCode)
+		return
+	}
+
+	offset, err := strconv.Atoi(r.FormValue("offset"))
+	if err != nil {
+		offset = 0
+	}
+
+	limit, err := strconv.Atoi(r.FormValue("limit"))
+	if err != nil {
+		limit = 0
+	}
+
+	if offset < 0 || limit < 0 {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data, offset and limit must not be negative", errCode), errCode)
+		return
+	}
+
+	err = GetAddressTransactions(coinType, address, offset, limit, w)
+	if err != nil {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data: %v", errCode, err), errCode)
+		return
+	}
+}
+
+// HttpHandleReset resets a chain to genesis, or every chain if cointype is omitted
+// Method: POST
+// URI: /api/admin/reset?cointype=SKY
+func HttpHandleReset(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Connection", "close")
+	r.Close = true
+
+	if r.Method != http.MethodPost {
+		errCode := http.StatusMethodNotAllowed
+		http.Error(w, fmt.Sprintf("Accepts POST requests only"), errCode)
+		return
+	}
+
+	err := ResetChains(r.FormValue("cointype"), w)
+	if err != nil {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data: %v", errCode, err), errCode)
+		return
+	}
+}
+
+// HttpHandleSnapshot takes (POST), deletes (DELETE) or lists (GET) snapshots of every chain
+// URI: /api/admin/snapshot?name=xxx
+func HttpHandleSnapshot(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Connection", "close")
+	r.Close = true
+
+	var err error
+	switch r.Method {
+	case http.MethodGet:
+		err = ListSnapshots(w)
+	case http.MethodPost:
+		err = TakeSnapshot(r.FormValue("name"), w)
+	case http.MethodDelete:
+		err = DeleteSnapshot(r.FormValue("name"), w)
+	default:
+		errCode := http.StatusMethodNotAllowed
+		http.Error(w, fmt.Sprintf("Accepts GET, POST and DELETE requests only"), errCode)
+		return
+	}
+
+	if err != nil {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data: %v", errCode, err), errCode)
+		return
+	}
+}
+
+// HttpHandleRestore restores every chain from a snapshot
+// Method: POST
+// URI: /api/admin/restore?name=xxx
+func HttpHandleRestore(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Connection", "close")
+	r.Close = true
+
+	if r.Method != http.MethodPost {
+		errCode := http.StatusMethodNotAllowed
+		http.Error(w, fmt.Sprintf("Accepts POST requests only"), errCode)
+		return
+	}
+
+	name := r.FormValue("name")
+	if name == "" {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data, name is required", errCode), errCode)
+		return
+	}
+
+	err := RestoreSnapshot(name, w)
+	if err != nil {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data: %v", errCode, err), errCode)
+		return
+	}
+}
+
+// HttpHandleReorg disconnects the last blocks of a chain and connects a new branch
+// Method: POST
+// URI: /api/admin/reorg?cointype=SKY
+// The request body lists the deposits of each block of the new branch, for example:
+//  {"depth": 2, "blocks": [[{"Address": "2GgFvqoyk9RjwVzj8tqfcXVXB4orBwoc9qv", "Value": 10}], []]}
+func HttpHandleReorg(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Connection", "close")
+	r.Close = true
+
+	if r.Method != http.MethodPost {
+		errCode := http.StatusMethodNotAllowed
+		http.Error(w, fmt.Sprintf("Accepts POST requests only"), errCode)
+		return
+	}
+
+	coinType := r.FormValue("cointype")
+	if coinType == "" {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data, CoinType is invalid %v", errCode, coinType), errCode)
+		return
+	}
+
+	var reorg model_server.Reorg
+	if err := json.NewDecoder(r.Body).Decode(&reorg); err != nil {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error reading JSON message: %v", errCode, err), errCode)
+		return
+	}
+
+	err := Reorg(coinType, reorg, w)
+	if writeValidationError(w, err) {
+		return
+	}
+	if err != nil {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data: %v", errCode, err), errCode)
+		return
+	}
+}
+
+// HttpHandleDoubleSpend replaces a pending or confirmed transaction with a
+// conflicting one spending the same inputs
+// Method: POST
+// URI: /api/admin/double_spend?cointype=SKY
+// The request body names the transaction and the deposit paid instead, for example:
+//  {"txid": "...", "deposit": {"Address": "2evvxxvqnu2SYXN3RtzjUC3nu9NFGgv1LgF", "Value": 10}, "mine": true}
+func HttpHandleDoubleSpend(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Connection", "close")
+	r.Close = true
+
+	if r.Method != http.MethodPost {
+		errCode := http.StatusMethodNotAllowed
+		http.Error(w, fmt.Sprintf("Accepts POST requests only"), errCode)
+		return
+	}
+
+	coinType := r.FormValue("cointype")
+	if coinType == "" {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data, CoinType is invalid %v", errCode, coinType), errCode)
+		return
+	}
+
+	var doubleSpend model_server.DoubleSpend
+	if err := json.NewDecoder(r.Body).Decode(&doubleSpend); err != nil {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error reading JSON message: %v", errCode, err), errCode)
+		return
+	}
+
+	err := DoubleSpend(coinType, doubleSpend, w)
+	if writeValidationError(w, err) {
+		return
+	}
+	if err != nil {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data: %v", errCode, err), errCode)
+		return
+	}
+}
+
+// HttpHandleIssueAsset issues a fake asset, that deposits can pay with their AssetID
+// Method: POST
+// URI: /api/admin/assets?cointype=WAVES
+// The request body describes the asset, for example:
+//  {"name": "Tether", "description": "USD token", "decimals": 6, "quantity": "1000000", "reissuable": true}
+// The asset id is random unless assetid is set. Quantity is a decimal amount of the asset.
+func HttpHandleIssueAsset(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Connection", "close")
+	r.Close = true
+
+	if r.Method != http.MethodPost {
+		errCode := http.StatusMethodNotAllowed
+		http.Error(w, fmt.Sprintf("Accepts POST requests only"), errCode)
+		return
+	}
+
+	coinType := r.FormValue("cointype")
+	if coinType == "" {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data, CoinType is invalid %v", errCode, coinType), errCode)
+		return
+	}
+
+	var asset model_server.Asset
+	if err := json.NewDecoder(r.Body).Decode(&asset); err != nil {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error reading JSON message: %v", errCode, err), errCode)
+		return
+	}
+
+	err := IssueAsset(coinType, asset, w)
+	if err != nil {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data: %v", errCode, err), errCode)
+		return
+	}
+}
+
+// HttpHandleGetAsset returns the details of an issued asset
+// Method: GET
+// URI: /api/assets/details?cointype=WAVES&assetid=xxx
+func HttpHandleGetAsset(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Connection", "close")
+	r.Close = true
+
+	if r.Method != http.MethodGet {
+		errCode := http.StatusMethodNotAllowed
+		http.Error(w, fmt.Sprintf("Accepts GET requests only"), errCode)
+		return
+	}
+
+	coinType := r.FormValue("cointype")
+	if coinType == "" {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data, CoinType is invalid %v", errCode, coinType), errCode)
+		return
+	}
+
+	assetID := r.FormValue("assetid")
+	if assetID == "" {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data, assetid is required", errCode), errCode)
+		return
+	}
+
+	err := GetAsset(coinType, assetID, w)
+	if err != nil {
+		errCode := http.StatusNotFound
+		http.Error(w, fmt.Sprintf("%d error processing data: %v", errCode, err), errCode)
+		return
+	}
+}
+
+// HttpHandleGetAssetBalances returns the confirmed asset balances of an address,
+// or its balance in a single asset
+// Method: GET
+// URI: /api/assets/balance?cointype=WAVES&address=xxx[&assetid=xxx]
+func HttpHandleGetAssetBalances(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Connection", "close")
+	r.Close = true
+
+	if r.Method != http.MethodGet {
+		errCode := http.StatusMethodNotAllowed
+		http.Error(w, fmt.Sprintf("Accepts GET requests only"), errCode)
+		return
+	}
+
+	coinType := r.FormValue("cointype")
+	if coinType == "" {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data, CoinType is invalid %v", errCode, coinType), errCode)
+		return
+	}
+
+	address := r.FormValue("address")
+	if address == "" {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data, address is required", errCode), errCode)
+		return
+	}
+
+	err := GetAssetBalances(coinType, address, r.FormValue("assetid"), w)
+	if err != nil {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data: %v", errCode, err), errCode)
+		return
+	}
+}
+
+// HttpHandleSendToAddress sends a withdrawal from the wallet of a coin,
+// confirmed by the next block
+// Method: POST
+// URI: /api/sendtoaddress?cointype=SKY
+// The request body is the withdrawal, for example:
+//  {"address": "2evvxxvqnu2SYXN3RtzjUC3nu9NFGgv1LgF", "amount": "1.5"}
+// Amount is a decimal amount of the coin, or of the asset assetid. SKY answers
+// like /wallet/spend, WAVES with the transfer. SKY spends from every address
+// of the wallet, WAVES from its first one, see /api/hdwallet. A withdrawal
+// that the wallet cannot fund, or whose fee is too low, is rejected.
+func HttpHandleSendToAddress(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Connection", "close")
+	r.Close = true
+
+	if r.Method != http.MethodPost {
+		errCode := http.StatusMethodNotAllowed
+		http.Error(w, fmt.Sprintf("Accepts POST requests only"), errCode)
+		return
+	}
+
+	coinType := r.FormValue("cointype")
+	if coinType == "" {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data, CoinType is invalid %v", errCode, coinType), errCode)
+		return
+	}
+
+	var withdrawal model_server.Withdrawal
+	if err := json.NewDecoder(r.Body).Decode(&withdrawal); err != nil {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error reading JSON message: %v", errCode, err), errCode)
+		return
+	}
+
+	err := SendToAddress(coinType, withdrawal, w)
+	if err != nil {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data: %v", errCode, err), errCode)
+		return
+	}
+}
+
+// HttpHandleSendRawTransaction adds a signed transaction to the mempool,
+// confirmed by the next block
+// Method: POST
+// URI: /api/sendrawtransaction?cointype=SKY
+// The request body holds the transaction, like skycoin's /injectTransaction:
+//  {"rawtx": "..."}
+// SKY takes the hex of a serialized transaction and answers with its txid.
+// WAVES takes a signed transfer, as a JSON object or string, and answers with
+// the transfer.
+func HttpHandleSendRawTransaction(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Connection", "close")
+	r.Close = true
+
+	if r.Method != http.MethodPost {
+		errCode := http.StatusMethodNotAllowed
+		http.Error(w, fmt.Sprintf("Accepts POST requests only"), errCode)
+		return
+	}
+
+	coinType := r.FormValue("cointype")
+	if coinType == "" {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data, CoinType is invalid %v", errCode, coinType), errCode)
+		return
+	}
+
+	var body struct {
+		RawTx json.RawMessage `json:"rawtx"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error reading JSON message: %v", errCode, err), errCode)
+		return
+	}
+	var rawTx string
+	if err := json.Unmarshal(body.RawTx, &rawTx); err != nil {
+		// not a string, the transaction is a JSON object
+		rawTx = string(body.RawTx)
+	}
+	if rawTx == "" {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data, rawtx is required", errCode), errCode)
+		return
+	}
+
+	err := SendRawTransaction(coinType, rawTx, w)
+	if err != nil {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data: %v", errCode, err), errCode)
+		return
+	}
+}
+
+// HttpHandleGetNewAddress derives the next address of the wallet of a coin
+// Method: POST
+// URI: /api/getnewaddress?cointype=BTC
+// Answers with the address, its index and, for BTC and ETH, its BIP44 path:
+//  {"address": "1LqBGSKuX5yYUonjxT5qGfpUsXKYYWeabA", "index": 0, "path": "m/44'/0'/0'/0/0"}
+func HttpHandleGetNewAddress(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Connection", "close")
+	r.Close = true
+
+	if r.Method != http.MethodPost {
+		errCode := http.StatusMethodNotAllowed
+		http.Error(w, fmt.Sprintf("Accepts POST requests only"), errCode)
+		return
+	}
+
+	coinType := r.FormValue("cointype")
+	if coinType == "" {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data, CoinType is invalid %v", errCode, coinType), errCode)
+		return
+	}
+
+	err := GetNewAddress(coinType, w)
+	if err != nil {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data: %v", errCode, err), errCode)
+		return
+	}
+}
+
+// HttpHandleGetHDWallet returns the addresses derived so far by the wallet of
+// a coin and, for BTC and ETH, the xpub of its BIP44 account, whose external
+// chain derives them. Private keys never leave coind.
+// Method: GET
+// URI: /api/hdwallet?cointype=BTC
+func HttpHandleGetHDWallet(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Connection", "close")
+	r.Close = true
+
+	if r.Method != http.MethodGet {
+		errCode := http.StatusMethodNotAllowed
+		http.Error(w, fmt.Sprintf("Accepts GET requests only"), errCode)
+		return
+	}
+
+	coinType := r.FormValue("cointype")
+	if coinType == "" {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data, CoinType is invalid %v", errCode, coinType), errCode)
+		return
+	}
+
+	err := GetHDWallet(coinType, w)
+	if err != nil {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data: %v", errCode, err), errCode)
+		return
+	}
+}
+
+// HttpHandleSkyWalletCreate creates a SKY wallet out of a seed, like the
+// /wallet/create of a skycoin node. The wallet routes emulate the node at the
+// paths a gui.Client with the address http://host:port/api/ requests.
+// Method: POST
+// URI: /api/wallet/create
+// Form: seed, label and scan, the number of addresses scanned for coins,
+// defaults to 1. Answers with the wallet, keys included, like the node.
+func HttpHandleSkyWalletCreate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Connection", "close")
+	r.Close = true
+
+	if r.Method != http.MethodPost {
+		errCode := http.StatusMethodNotAllowed
+		http.Error(w, fmt.Sprintf("Accepts POST requests only"), errCode)
+		return
+	}
+
+	seed := r.FormValue("seed")
+	if seed == "" {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data, missing seed", errCode), errCode)
+		return
+	}
+	label := r.FormValue("label")
+	if label == "" {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data, missing label", errCode), errCode)
+		return
+	}
+	var scanN uint64 = 1
+	if scan := r.FormValue("scan"); scan != "" {
+		var err error
+		if scanN, err = strconv.ParseUint(scan, 10, 64); err != nil || scanN == 0 {
+			errCode := http.StatusBadRequest
+			http.Error(w, fmt.Sprintf("%d error processing data, scan must be > 0", errCode), errCode)
+			return
+		}
+	}
+
+	err := CreateSkyWallet(seed, label, scanN, w)
+	if err != nil {
+		errCode := walletErrorCode(err)
+		http.Error(w, fmt.Sprintf("%d error processing data: %v", errCode, err), errCode)
+		return
+	}
+}
+
+// HttpHandleSkyWalletNewAddress derives addresses of a SKY wallet, like the
+// /wallet/newAddress of a skycoin node
+// Method: POST
+// URI: /api/wallet/newAddress
+// Form: id and num, the number of addresses, defaults to 1
+func HttpHandleSkyWalletNewAddress(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Connection", "close")
+	r.Close = true
+
+	if r.Method != http.MethodPost {
+		errCode := http.StatusMethodNotAllowed
+		http.Error(w, fmt.Sprintf("Accepts POST requests only"), errCode)
+		return
+	}
+
+	id := r.FormValue("id")
+	if id == "" {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data, missing wallet id", errCode), errCode)
+		return
+	}
+	var num uint64 = 1
+	if n := r.FormValue("num"); n != "" {
+		var err error
+		if num, err = strconv.ParseUint(n, 10, 64); err != nil {
+			errCode := http.StatusBadRequest
+			http.Error(w, fmt.Sprintf("%d error processing data, invalid num value", errCode), errCode)
+			return
+		}
+	}
+
+	err := NewSkyWalletAddresses(id, num, w)
+	if err != nil {
+		errCode := walletErrorCode(err)
+		http.Error(w, fmt.Sprintf("%d error processing data: %v", errCode, err), errCode)
+		return
+	}
+}
+
+// HttpHandleSkyWalletBalance returns the confirmed and predicted balance of a
+// SKY wallet, like the /wallet/balance of a skycoin node
+// Method: GET
+// URI: /api/wallet/balance?id=xxx
+func HttpHandleSkyWalletBalance(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Connection", "close")
+	r.Close = true
+
+	if r.Method != http.MethodGet {
+		errCode := http.StatusMethodNotAllowed
+		http.Error(w, fmt.Sprintf("Accepts GET requests only"), errCode)
+		return
+	}
+
+	id := r.FormValue("id")
+	if id == "" {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data, missing wallet id", errCode), errCode)
+		return
+	}
+
+	err := GetSkyWalletBalance(id, w)
+	if err != nil {
+		errCode := walletErrorCode(err)
+		http.Error(w, fmt.Sprintf("%d error processing data: %v", errCode, err), errCode)
+		return
+	}
+}
+
+// HttpHandleSkyWalletSpend sends coins from a SKY wallet, like the
+// /wallet/spend of a skycoin node. The transaction is confirmed by the next
+// block.
+// Method: POST
+// URI: /api/wallet/spend
+// Form: id, dst and coins, in droplets
+func HttpHandleSkyWalletSpend(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Connection", "close")
+	r.Close = true
+
+	if r.Method != http.MethodPost {
+		errCode := http.StatusMethodNotAllowed
+		http.Error(w, fmt.Sprintf("Accepts POST requests only"), errCode)
+		return
+	}
+
+	id := r.FormValue("id")
+	if id == "" {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data, missing wallet id", errCode), errCode)
+		return
+	}
+	dst := r.FormValue("dst")
+	if dst == "" {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data, missing destination address \"dst\"", errCode), errCode)
+		return
+	}
+	coins, err := strconv.ParseUint(r.FormValue("coins"), 10, 64)
+	if err != nil || coins == 0 {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data, invalid \"coins\" value, must > 0", errCode), errCode)
+		return
+	}
+
+	err = SkyWalletSpend(id, dst, coins, w)
+	if err != nil {
+		errCode := walletErrorCode(err)
+		http.Error(w, fmt.Sprintf("%d error processing data: %v", errCode, err), errCode)
+		return
+	}
+}
+
+// HttpHandleSkyWalletTransactions returns the unconfirmed transactions paying
+// a SKY wallet, like the /wallet/transactions of a skycoin node
+// Method: GET
+// URI: /api/wallet/transactions?id=xxx
+func HttpHandleSkyWalletTransactions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Connection", "close")
+	r.Close = true
+
+	if r.Method != http.MethodGet {
+		errCode := http.StatusMethodNotAllowed
+		http.Error(w, fmt.Sprintf("Accepts GET requests only"), errCode)
+		return
+	}
+
+	id := r.FormValue("id")
+	if id == "" {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data, missing wallet id", errCode), errCode)
+		return
+	}
+
+	err := GetSkyWalletTransactions(id, w)
+	if err != nil {
+		errCode := walletErrorCode(err)
+		http.Error(w, fmt.Sprintf("%d error processing data: %v", errCode, err), errCode)
+		return
+	}
+}
+
+// HttpHandleMempool adds unconfirmed deposits, confirmed by the next mined block
+// Method: POST
+// URI: /api/mempool
+// The request body is an array of deposits, like /api/nextdeposit.
+func HttpHandleMempool(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Connection", "close")
+	r.Close = true
+
+	if r.Method != http.MethodPost {
+		errCode := http.StatusMethodNotAllowed
+		http.Error(w, fmt.Sprintf("Accepts POST requests only"), errCode)
+		return
+	}
+
+	var deposits []model_server.Deposit
+	if err := json.NewDecoder(r.Body).Decode(&deposits); err != nil {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error reading JSON message: %v", errCode, err), errCode)
+		return
+	}
+
+	err := AddToMempool(deposits, w)
+	if writeValidationError(w, err) {
+		return
+	}
+	if err != nil {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data: %v", errCode, err), errCode)
+		return
+	}
+}
+
+// HttpHandleMine mines blocks right away, confirming the mempool
+// Method: POST
+// URI: /api/admin/mine?cointype=SKY&blocks=1
+func HttpHandleMine(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Connection", "close")
+	r.Close = true
+
+	if r.Method != http.MethodPost {
+		errCode := http.StatusMethodNotAllowed
+		http.Error(w, fmt.Sprintf("Accepts POST requests only"), errCode)
+		return
+	}
+
+	coinType := r.FormValue("cointype")
+	if coinType == "" {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data, CoinType is invalid %v", errCode, coinType), errCode)
+		return
+	}
+
+	blocks := 1
+	if s := r.FormValue("blocks"); s != "" {
+		var err error
+		if blocks, err = strconv.Atoi(s); err != nil {
+			errCode := http.StatusBadRequest
+			http.Error(w, fmt.Sprintf("%d error processing data, blocks is invalid %v", errCode, s), errCode)
+			return
+		}
+	}
+
+	err := MineBlocks(coinType, blocks, w)
+	if err != nil {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data: %v", errCode, err), errCode)
+		return
+	}
+}
+
+// HttpHandleMining reports, pauses, resumes or reconfigures block production
+// Method: GET, POST
+// URI: /api/admin/mining?cointype=SKY&action=pause|resume
+// POST with block_time, and optionally jitter and scale, reconfigures a coin:
+//  /api/admin/mining?cointype=SKY&block_time=10s&jitter=0.2&scale=1
+func HttpHandleMining(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Connection", "close")
+	r.Close = true
+
+	if r.Method == http.MethodGet {
+		if err := MiningStatus(w); err != nil {
+			errCode := http.StatusBadRequest
+			http.Error(w, fmt.Sprintf("%d error processing data: %v", errCode, err), errCode)
+		}
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		errCode := http.StatusMethodNotAllowed
+		http.Error(w, fmt.Sprintf("Accepts GET and POST requests only"), errCode)
+		return
+	}
+
+	coinType := r.FormValue("cointype")
+
+	var config *model_server.MinerConfig
+	if s := r.FormValue("block_time"); s != "" {
+		c := model_server.DefaultMinerConfig(coinType)
+		var err error
+		if c.BlockTime, err = time.ParseDuration(s); err == nil && r.FormValue("jitter") != "" {
+			c.Jitter, err = strconv.ParseFloat(r.FormValue("jitter"), 64)
+		}
+		if err == nil && r.FormValue("scale") != "" {
+			c.Scale, err = strconv.ParseFloat(r.FormValue("scale"), 64)
+		}
+		if err != nil {
+			errCode := http.StatusBadRequest
+			http.Error(w, fmt.Sprintf("%d error processing data, invalid mining configuration: %v", errCode, err), errCode)
+			return
+		}
+		config = &c
+	}
+
+	err := SetMining(coinType, r.FormValue("action"), config, w)
+	if err != nil {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data: %v", errCode, err), errCode)
+		return
+	}
+}
+
+// HttpHandleClock reports, sets or advances the clock of the fake chains
+// Method: GET, POST
+// URI: /api/admin/clock?mode=real|fixed|scaled&time=2018-01-01T00:00:00Z&scale=60
+// POST with advance moves the clock forward instead, the running miners mining a block for
+// every block interval passed before it answers:
+//  /api/admin/clock?advance=10m
+func HttpHandleClock(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Connection", "close")
+	r.Close = true
+
+	if r.Method == http.MethodGet {
+		if err := ClockStatus(w); err != nil {
+			errCode := http.StatusBadRequest
+			http.Error(w, fmt.Sprintf("%d error processing data: %v", errCode, err), errCode)
+		}
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		errCode := http.StatusMethodNotAllowed
+		http.Error(w, fmt.Sprintf("Accepts GET and POST requests only"), errCode)
+		return
+	}
+
+	if s := r.FormValue("advance"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			errCode := http.StatusBadRequest
+			http.Error(w, fmt.Sprintf("%d error processing data, advance is invalid %v", errCode, s), errCode)
+			return
+		}
+		if err := AdvanceClock(d, w); err != nil {
+			errCode := http.StatusBadRequest
+			http.Error(w, fmt.Sprintf("%d error processing data: %v", errCode, err), errCode)
+		}
+		return
+	}
+
+	var start time.Time
+	var scale float64
+	var err error
+	if s := r.FormValue("time"); s != "" {
+		start, err = time.Parse(time.RFC3339, s)
+	}
+	if s := r.FormValue("scale"); err == nil && s != "" {
+		scale, err = strconv.ParseFloat(s, 64)
+	}
+	if err != nil {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data, invalid clock configuration: %v", errCode, err), errCode)
+		return
+	}
+
+	if err := SetClock(r.FormValue("mode"), start, scale, w); err != nil {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data: %v", errCode, err), errCode)
+		return
+	}
+}
+
+// HttpHandleEvents streams chain events as Server-Sent Events
+// Method: GET
+// URI: /api/events?cointype=SKY&address=xxx
+// Send the Last-Event-ID header, or the last_event_id parameter, to resume a stream.
+func HttpHandleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		errCode := http.StatusMethodNotAllowed
+		http.Error(w, fmt.Sprintf("Accepts GET requests only"), errCode)
+		return
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.FormValue("last_event_id")
+	}
+
+	var lastID int64
+	if lastEventID != "" {
+		var err error
+		lastID, err = strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil {
+			errCode := http.StatusBadRequest
+			http.Error(w, fmt.Sprintf("%d error processing data, Last-Event-ID is invalid %v", errCode, lastEventID), errCode)
+			return
+		}
+	}
+
+	filter := EventFilter{
+		CoinType: r.FormValue("cointype"),
+		Address:  r.FormValue("address"),
+	}
+
+	err := StreamEvents(w, r.Context().Done(), lastID, filter)
+	if err != nil {
+		log.Println("event stream closed:", err)
+	}
+}
+
+// HttpHandleFaults lists, adds or removes the faults injected into the API and RPC servers
+// Method: GET, POST, DELETE
+// URI: /api/admin/faults
+// POST takes a JSON rule, for example a 503 on half of the SKY balance requests:
+//  {"route": "/api/balance", "cointype": "SKY", "action": "error", "status_code": 503, "probability": 0.5}
+// DELETE takes ?id=xxx, or removes every rule without it
+func HttpHandleFaults(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Connection", "close")
+	r.Close = true
+
+	var err error
+	switch r.Method {
+	case http.MethodGet:
+		err = ListFaults(w)
+	case http.MethodPost:
+		var rule fault.Rule
+		if err = json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			errCode := http.StatusBadRequest
+			http.Error(w, fmt.Sprintf("%d error reading JSON message: %v", errCode, err), errCode)
+			return
+		}
+		err = AddFault(rule, w)
+	case http.MethodDelete:
+		err = RemoveFault(r.FormValue("id"), w)
+	default:
+		errCode := http.StatusMethodNotAllowed
+		http.Error(w, fmt.Sprintf("Accepts GET, POST and DELETE requests only"), errCode)
+		return
+	}
+
+	if err != nil {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data: %v", errCode, err), errCode)
+		return
+	}
+}
+
+// HttpHandleLoadgen starts, reports or stops a deposit load
+// Method: GET, POST, DELETE
+// URI: /api/admin/loadgen
+// POST takes the YAML, or JSON, load as the request body, for example:
+//  {"rate": 200, "duration": "1m", "coins": {"SKY": 3, "WAVES": 1}, "addresses": 500}
+// DELETE stops the running load
+func HttpHandleLoadgen(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Connection", "close")
+	r.Close = true
+
+	var err error
+	switch r.Method {
+	case http.MethodGet:
+		err = LoadStatus(w)
+	case http.MethodPost:
+		var data []byte
+		if data, err = ioutil.ReadAll(r.Body); err != nil {
+			errCode := http.StatusBadRequest
+			http.Error(w, fmt.Sprintf("%d error reading load: %v", errCode, err), errCode)
+			return
+		}
+		err = StartLoad(data, w)
+	case http.MethodDelete:
+		err = StopLoad(w)
+	default:
+		errCode := http.StatusMethodNotAllowed
+		http.Error(w, fmt.Sprintf("Accepts GET, POST and DELETE requests only"), errCode)
+		return
+	}
+
+	if err != nil {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data: %v", errCode, err), errCode)
+		return
+	}
+}
+
+// HttpHandleWebhooks lists, registers or removes deposit webhooks
+// Method: GET, POST, DELETE
+// URI: /api/webhooks
+// POST takes a JSON body {"url": "...", "secret": "...", "cointype": "SKY", "address": "...", "confirmations": 3},
+// DELETE takes ?id=xxx
+func HttpHandleWebhooks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Connection", "close")
+	r.Close = true
+
+	var err error
+	switch r.Method {
+	case http.MethodGet:
+		err = ListWebhooks(w)
+	case http.MethodPost:
+		var hook webhook.Webhook
+		if err = json.NewDecoder(r.Body).Decode(&hook); err != nil {
+			errCode := http.StatusBadRequest
+			http.Error(w, fmt.Sprintf("%d error reading JSON message: %v", errCode, err), errCode)
+			return
+		}
+		err = RegisterWebhook(hook, w)
+	case http.MethodDelete:
+		err = RemoveWebhook(r.FormValue("id"), w)
+	default:
+		errCode := http.StatusMethodNotAllowed
+		http.Error(w, fmt.Sprintf("Accepts GET, POST and DELETE requests only"), errCode)
+		return
+	}
+
+	if err != nil {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data: %v", errCode, err), errCode)
+		return
+	}
+}
+
+// HttpHandleWebhookDeliveries lists the recent webhook deliveries
+// Method: GET
+// URI: /api/webhooks/deliveries?id=xxx
+func HttpHandleWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Connection", "close")
+	r.Close = true
+
+	if r.Method != http.MethodGet {
+		errCode := http.StatusMethodNotAllowed
+		http.Error(w, fmt.Sprintf("Accepts GET requests only"), errCode)
+		return
+	}
+
+	err := ListWebhookDeliveries(r.FormValue("id"), w)
+	if err != nil {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data: %v", errCode, err), errCode)
+		return
+	}
+}
+
+// HttpHandleScenarios uploads a scenario or reports the progress of the runs
+// Method: GET, POST
+// URI: /api/scenarios?id=xxx
+// POST takes the YAML scenario as the request body and answers with the new run.
+func HttpHandleScenarios(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Connection", "close")
+	r.Close = true
+
+	var err error
+	switch r.Method {
+	case http.MethodGet:
+		err = GetScenario(r.FormValue("id"), w)
+	case http.MethodPost:
+		var data []byte
+		if data, err = ioutil.ReadAll(r.Body); err != nil {
+			errCode := http.StatusBadRequest
+			http.Error(w, fmt.Sprintf("%d error reading scenario: %v", errCode, err), errCode)
+			return
+		}
+		err = StartScenario(data, w)
+	default:
+		errCode := http.StatusMethodNotAllowed
+		http.Error(w, fmt.Sprintf("Accepts GET and POST requests only"), errCode)
+		return
+	}
+
+	if err != nil {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data: %v", errCode, err), errCode)
+		return
+	}
+}
+
+// HttpHandleLedger queries and exports the ledger of the deposits minted by coind
+// Method: GET
+// URI: /api/ledger?cointype=xxx&address=xxx&from=xxx&to=xxx&format=json
+// from and to select the deposits recorded in a time range, in RFC3339, from included and to excluded.
+// format is json, the default, jsonl for JSON lines or csv, the last two for reconciliation exports.
+// Each entry holds the time, the submitter and the receipt of a deposit, and its current status,
+// height and block hash:
+//  {"id": 1, "time": "2018-01-01T00:00:00Z", "submitter": "teller", "receipt": {...},
+//   "status": "confirmed", "height": 1, "block_hash": "...", "updated": "2018-01-01T00:00:00Z"}
+// A deposit is orphaned when its block is disconnected and when it is double spent. Resetting its
+// chain removes it, restoring a snapshot brings back the ledger of the snapshot.
+func HttpHandleLedger(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Connection", "close")
+	r.Close = true
+
+	if r.Method != http.MethodGet {
+		errCode := http.StatusMethodNotAllowed
+		http.Error(w, fmt.Sprintf("Accepts GET requests only"), errCode)
+		return
+	}
+
+	query := model_server.LedgerQuery{
+		CoinType: r.FormValue("cointype"),
+		Address:  r.FormValue("address"),
+	}
+	for _, bound := range []struct {
+		name string
+		t    *time.Time
+	}{{"from", &query.From}, {"to", &query.To}} {
+		value := r.FormValue(bound.name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			errCode := http.StatusBadRequest
+			http.Error(w, fmt.Sprintf("%d error processing data, %s is invalid %v", errCode, bound.name, value), errCode)
+			return
+		}
+		*bound.t = t
+	}
+
+	err := GetLedger(query, r.FormValue("format"), w)
+	if err != nil {
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error processing data: %v", errCode, err), errCode)
+		return
+	}
+}
+
+// submitter returns who submitted r: the X-Coind-Submitter header, or the
+// address of the client if it is not set
+func submitter(r *http.Request) string {
+	if s := r.Header.Get("X-Coind-Submitter"); s != "" {
+		return s
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// writeValidationError answers with the field errors of err as JSON, if it is
+// a *model_server.ValidationError
+func writeValidationError(w http.ResponseWriter, err error) bool {
+	verr, ok := err.(*model_server.ValidationError)
+	if !ok {
+		return false
+	}
+
+	errCode := http.StatusBadRequest
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(errCode)
+	if err := json.NewEncoder(w).Encode(verr); err != nil {
+		log.Println("failed to write validation errors:", err)
+	}
+	return true
+}
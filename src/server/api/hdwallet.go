@@ -0,0 +1,57 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/modeneis/coind/src/server/hdwallet"
+	"github.com/modeneis/coind/src/server/model_server"
+	"github.com/modeneis/coind/src/server/utils"
+)
+
+// HDWallet is the public side of the wallet of a coin, without its keys
+type HDWallet struct {
+	CoinType  string             `json:"cointype"`
+	XPub      string             `json:"xpub,omitempty"`
+	Addresses []hdwallet.Address `json:"addresses"`
+}
+
+// GetNewAddress derives the next address of the wallet of coinType and writes it
+func GetNewAddress(coinType string, w http.ResponseWriter) (err error) {
+	wallet, err := model_server.GetWallet(coinType)
+	if err != nil {
+		err = fmt.Errorf("CoinType (%s) not supported: %v", coinType, err)
+		return err
+	}
+
+	address, err := wallet.NewAddress()
+	if err != nil {
+		return err
+	}
+
+	if err = utils.JSONResponse(w, address); err != nil {
+		err = fmt.Errorf("GetNewAddress got Err when running JSONResponse %v", err)
+		return err
+	}
+	return nil
+}
+
+// GetHDWallet writes the xpub and the addresses of the wallet of coinType
+func GetHDWallet(coinType string, w http.ResponseWriter) (err error) {
+	wallet, err := model_server.GetWallet(coinType)
+	if err != nil {
+		err = fmt.Errorf("CoinType (%s) not supported: %v", coinType, err)
+		return err
+	}
+
+	result := HDWallet{
+		CoinType:  wallet.CoinType(),
+		XPub:      wallet.XPub(),
+		Addresses: wallet.Addresses(),
+	}
+	if err = utils.JSONResponse(w, result); err != nil {
+		err = fmt.Errorf("GetHDWallet got Err when running JSONResponse %v", err)
+		return err
+	}
+	return nil
+}
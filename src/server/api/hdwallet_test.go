@@ -0,0 +1,100 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/drewolson/testflight"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modeneis/coind/src/server/address"
+	"github.com/modeneis/coind/src/server/api"
+	"github.com/modeneis/coind/src/server/hdwallet"
+	"github.com/modeneis/coind/src/server/model_server"
+)
+
+// hotWallet returns the first address of the wallet of coinType, the one
+// withdrawals are sent from
+func hotWallet(t *testing.T, r *testflight.Requester, coinType string) string {
+	response := r.Get("/api/hdwallet?cointype=" + coinType)
+	require.Equal(t, http.StatusOK, response.StatusCode, response.Body)
+	var wallet api.HDWallet
+	require.NoError(t, json.Unmarshal(response.RawBody, &wallet))
+	require.NotEmpty(t, wallet.Addresses)
+	return wallet.Addresses[0].Address
+}
+
+func TestHDWallet(t *testing.T) {
+	testflight.WithServer(api.InitRouting(), func(r *testflight.Requester) {
+		getWallet := func(coinType string) api.HDWallet {
+			response := r.Get("/api/hdwallet?cointype=" + coinType)
+			require.Equal(t, http.StatusOK, response.StatusCode, response.Body)
+			var wallet api.HDWallet
+			require.NoError(t, json.Unmarshal(response.RawBody, &wallet))
+			return wallet
+		}
+
+		for _, coinType := range []string{api.CoinTypeBTC, api.CoinTypeETH, api.CoinTypeSKY, api.CoinTypeWAVES} {
+			before := getWallet(coinType)
+			require.Equal(t, coinType, before.CoinType)
+			require.Equal(t, uint32(0), before.Addresses[0].Index)
+
+			response := r.Post("/api/getnewaddress?cointype="+coinType, "", "")
+			require.Equal(t, http.StatusOK, response.StatusCode, response.Body)
+			var next hdwallet.Address
+			require.NoError(t, json.Unmarshal(response.RawBody, &next))
+			require.Equal(t, uint32(len(before.Addresses)), next.Index)
+			require.NoError(t, address.Validate(coinType, next.Address))
+
+			after := getWallet(coinType)
+			require.Equal(t, append(before.Addresses, next), after.Addresses)
+
+			// deposit addresses of BTC and ETH derive from the xpub alone
+			if coinType == api.CoinTypeBTC || coinType == api.CoinTypeETH {
+				require.NotEmpty(t, after.XPub)
+				derived, err := hdwallet.AddressFromXPub(coinType, after.XPub, next.Index)
+				require.NoError(t, err)
+				require.Equal(t, next.Address, derived)
+			} else {
+				require.Empty(t, after.XPub)
+			}
+		}
+
+		response := r.Get("/api/hdwallet?cointype=LTC")
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+		response = r.Get("/api/getnewaddress?cointype=BTC")
+		require.Equal(t, http.StatusMethodNotAllowed, response.StatusCode)
+	})
+}
+
+func TestHDWalletSnapshot(t *testing.T) {
+	defer useFakeUpstream(t)()
+	defer model_server.DeleteSnapshot("wallets")
+
+	testflight.WithServer(api.InitRouting(), func(r *testflight.Requester) {
+		newAddress := func() hdwallet.Address {
+			response := r.Post("/api/getnewaddress?cointype=SKY", "", "")
+			require.Equal(t, http.StatusOK, response.StatusCode, response.Body)
+			var next hdwallet.Address
+			require.NoError(t, json.Unmarshal(response.RawBody, &next))
+			return next
+		}
+
+		newAddress()
+		response := r.Post("/api/admin/snapshot?name=wallets", "", "")
+		require.Equal(t, http.StatusOK, response.StatusCode, response.Body)
+		next := newAddress()
+		newAddress()
+
+		// the wallet derives again from where it stood in the snapshot
+		response = r.Post("/api/admin/restore?name=wallets", "", "")
+		require.Equal(t, http.StatusOK, response.StatusCode, response.Body)
+		require.Equal(t, next, newAddress())
+
+		// and from its hot wallet once its chain is reset
+		response = r.Post("/api/admin/reset?cointype=SKY", "", "")
+		require.Equal(t, http.StatusOK, response.StatusCode, response.Body)
+		require.Equal(t, uint32(1), newAddress().Index)
+	})
+}
@@ -0,0 +1,124 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/drewolson/testflight"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modeneis/coind/src/server/api"
+	"github.com/modeneis/coind/src/server/model_server"
+)
+
+func TestIdempotentDeposits(t *testing.T) {
+	defer useFakeUpstream(t)()
+	defer model_server.DefaultClock.Set(model_server.ClockReal, time.Now(), 0)
+	defer model_server.DefaultIdempotency.Reset()
+
+	start := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, model_server.DefaultClock.Set(model_server.ClockFixed, start, 0))
+
+	skyAddress := "24jt6Yyvs7Z5ARctzVgysq8mQPrqhrzFvxr"
+	wavesAddress := "3PFnbq8kQjYyPwHMaSnbyQ78t15uU6nbkqi"
+
+	testflight.WithServer(api.InitRouting(), func(r *testflight.Requester) {
+		submit := func(key string, deposits []model_server.Deposit) *testflight.Response {
+			raw, err := json.Marshal(deposits)
+			require.NoError(t, err)
+			request, err := http.NewRequest(http.MethodPost, "/api/nextdeposit", strings.NewReader(string(raw)))
+			require.NoError(t, err)
+			request.Header.Set("Content-Type", "application/json")
+			if key != "" {
+				request.Header.Set("Idempotency-Key", key)
+			}
+			return r.Do(request)
+		}
+		receipts := func(response *testflight.Response) []model_server.Receipt {
+			require.Equal(t, http.StatusOK, response.StatusCode, response.Body)
+			var receipts []model_server.Receipt
+			require.NoError(t, json.Unmarshal(response.RawBody, &receipts))
+			return receipts
+		}
+		blockCount := func(coinType string) string {
+			response := r.Get("/api/get_block_count?cointype=" + coinType)
+			require.Equal(t, http.StatusOK, response.StatusCode)
+			return response.Body
+		}
+
+		deposits := []model_server.Deposit{
+			{Address: skyAddress, Amount: "1", CoinType: api.CoinTypeSKY},
+			{Address: wavesAddress, Amount: "2", CoinType: api.CoinTypeWAVES},
+		}
+		first := receipts(submit("retry-1", deposits))
+		require.Len(t, first, 2)
+		skyCount, wavesCount := blockCount(api.CoinTypeSKY), blockCount(api.CoinTypeWAVES)
+
+		// a retry gets the same receipts and mints nothing
+		require.Equal(t, first, receipts(submit("retry-1", deposits)))
+		require.Equal(t, skyCount, blockCount(api.CoinTypeSKY))
+		require.Equal(t, wavesCount, blockCount(api.CoinTypeWAVES))
+
+		// other deposits under the same key are a conflict
+		response := submit("retry-1", deposits[:1])
+		require.Equal(t, http.StatusConflict, response.StatusCode, response.Body)
+
+		// without a key the deposits are minted again
+		again := receipts(submit("", deposits))
+		require.NotEqual(t, first[0].TxID, again[0].TxID)
+
+		// the block of a replayed deposit is still available
+		raw, err := json.Marshal(deposits)
+		require.NoError(t, err)
+		request, err := http.NewRequest(http.MethodPost, "/api/nextdeposit?block=true", strings.NewReader(string(raw)))
+		require.NoError(t, err)
+		request.Header.Set("Idempotency-Key", "retry-1")
+		response = r.Do(request)
+		require.Equal(t, http.StatusOK, response.StatusCode, response.Body)
+		require.Contains(t, response.Body, first[1].BlockHash)
+
+		// deposits with a ClientID are minted once, whatever the submission
+		withID := model_server.Deposit{Address: skyAddress, Amount: "3", CoinType: api.CoinTypeSKY, ClientID: "deposit-1"}
+		byID := receipts(submit("", []model_server.Deposit{withID}))
+		require.Equal(t, "deposit-1", byID[0].ClientID)
+		skyCount = blockCount(api.CoinTypeSKY)
+
+		replayed := receipts(submit("retry-2", []model_server.Deposit{withID, deposits[0]}))
+		require.Equal(t, byID[0], replayed[0])
+		require.NotEqual(t, first[0].TxID, replayed[1].TxID)
+
+		withID.Amount = "4"
+		response = submit("", []model_server.Deposit{withID})
+		require.Equal(t, http.StatusConflict, response.StatusCode, response.Body)
+
+		response = submit("", []model_server.Deposit{withID, withID})
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+		require.Contains(t, response.Body, "clientid")
+
+		// keys are forgotten once the window is over
+		require.NoError(t, model_server.DefaultClock.Set(model_server.ClockFixed, start.Add(model_server.DefaultIdempotencyWindow+time.Second), 0))
+		expired := receipts(submit("retry-1", deposits))
+		require.NotEqual(t, first[0].TxID, expired[0].TxID)
+		require.NotEqual(t, skyCount, blockCount(api.CoinTypeSKY))
+
+		// restoring a snapshot brings back the keys it was taken with
+		response = r.Post("/api/admin/snapshot?name=keys", "", "")
+		require.Equal(t, http.StatusOK, response.StatusCode, response.Body)
+		defer model_server.DeleteSnapshot("keys")
+		taken := receipts(submit("retry-3", deposits))
+		response = r.Post("/api/admin/restore?name=keys", "", "")
+		require.Equal(t, http.StatusOK, response.StatusCode, response.Body)
+		require.NotEqual(t, taken[0].TxID, receipts(submit("retry-3", deposits))[0].TxID)
+		require.Equal(t, expired, receipts(submit("retry-1", deposits)))
+
+		// resetting a chain forgets the keys of its deposits only
+		skyOnly := receipts(submit("retry-4", deposits[:1]))
+		response = r.Post("/api/admin/reset?cointype=WAVES", "", "")
+		require.Equal(t, http.StatusOK, response.StatusCode, response.Body)
+		require.Equal(t, skyOnly, receipts(submit("retry-4", deposits[:1])))
+		require.NotEqual(t, expired[0].TxID, receipts(submit("retry-1", deposits))[0].TxID)
+	})
+}
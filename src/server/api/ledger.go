@@ -0,0 +1,47 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/modeneis/coind/src/server/model_server"
+	"github.com/modeneis/coind/src/server/utils"
+)
+
+// Formats of the ledger
+const (
+	LedgerJSON  = "json"
+	LedgerJSONL = "jsonl"
+	LedgerCSV   = "csv"
+)
+
+// GetLedger writes the ledger entries selected by query, as a JSON array, as
+// JSON lines or as CSV
+func GetLedger(query model_server.LedgerQuery, format string, w http.ResponseWriter) (err error) {
+	entries := model_server.DefaultLedger.Entries(query)
+
+	switch format {
+	case "", LedgerJSON:
+		if err = utils.JSONResponse(w, entries); err != nil {
+			err = fmt.Errorf("GetLedger got Err when running JSONResponse %v", err)
+			return err
+		}
+	case LedgerJSONL:
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", `attachment; filename="ledger.jsonl"`)
+		if err = model_server.WriteLedgerJSONL(w, entries); err != nil {
+			err = fmt.Errorf("GetLedger got Err when writing JSON lines %v", err)
+			return err
+		}
+	case LedgerCSV:
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="ledger.csv"`)
+		if err = model_server.WriteLedgerCSV(w, entries); err != nil {
+			err = fmt.Errorf("GetLedger got Err when writing CSV %v", err)
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown ledger format %q", format)
+	}
+	return nil
+}
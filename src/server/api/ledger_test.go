@@ -0,0 +1,130 @@
+package api_test
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/drewolson/testflight"
+	"github.com/skycoin/skycoin/src/visor"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modeneis/coind/src/server/api"
+	"github.com/modeneis/coind/src/server/model_server"
+)
+
+func TestLedger(t *testing.T) {
+	defer useFakeUpstream(t)()
+	defaultLedger := model_server.DefaultLedger
+	model_server.DefaultLedger = model_server.NewLedger()
+	defer func() { model_server.DefaultLedger = defaultLedger }()
+	defer model_server.DefaultClock.Set(model_server.ClockReal, time.Now(), 0)
+
+	start := time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, model_server.DefaultClock.Set(model_server.ClockFixed, start, 0))
+
+	skyAddress := "24jt6Yyvs7Z5ARctzVgysq8mQPrqhrzFvxr"
+	wavesAddress := "3PFnbq8kQjYyPwHMaSnbyQ78t15uU6nbkqi"
+	window := "&from=2019-06-01T00:00:00Z&to=2019-06-02T00:00:00Z"
+
+	testflight.WithServer(api.InitRouting(), func(r *testflight.Requester) {
+		ledger := func(query string) []model_server.LedgerEntry {
+			response := r.Get("/api/ledger?" + query + window)
+			require.Equal(t, http.StatusOK, response.StatusCode, response.Body)
+			var entries []model_server.LedgerEntry
+			require.NoError(t, json.Unmarshal(response.RawBody, &entries))
+			return entries
+		}
+
+		raw, err := json.Marshal([]model_server.Deposit{
+			{Address: skyAddress, Amount: "1", CoinType: api.CoinTypeSKY},
+			{Address: skyAddress, Amount: "2", CoinType: api.CoinTypeSKY},
+			{Address: wavesAddress, Amount: "3", CoinType: api.CoinTypeWAVES},
+		})
+		require.NoError(t, err)
+		request, err := http.NewRequest(http.MethodPost, "/api/nextdeposit", strings.NewReader(string(raw)))
+		require.NoError(t, err)
+		request.Header.Set("X-Coind-Submitter", "teller")
+		response := r.Do(request)
+		require.Equal(t, http.StatusOK, response.StatusCode, response.Body)
+		var receipts []model_server.Receipt
+		require.NoError(t, json.Unmarshal(response.RawBody, &receipts))
+
+		entries := ledger("")
+		require.Len(t, entries, 3)
+		for i, entry := range entries {
+			require.Equal(t, receipts[i], entry.Receipt)
+			require.Equal(t, "teller", entry.Submitter)
+			require.Equal(t, start, entry.Time.UTC())
+			require.Equal(t, model_server.TxConfirmed, entry.Status)
+			require.Equal(t, receipts[i].BlockHash, entry.BlockHash)
+		}
+		require.True(t, entries[0].ID < entries[1].ID)
+
+		require.Len(t, ledger("cointype=WAVES"), 1)
+		require.Len(t, ledger("cointype=SKY&address="+skyAddress), 2)
+		require.Len(t, ledger("address="+wavesAddress+"&cointype=SKY"), 0)
+		response = r.Get("/api/ledger?from=2019-06-01T00:00:01Z&to=2019-06-02T00:00:00Z")
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.Equal(t, "[]", response.Body)
+
+		// disconnecting the block of the second deposit orphans it
+		raw, err = json.Marshal(model_server.Reorg{Depth: 1, Blocks: [][]model_server.Deposit{{}}})
+		require.NoError(t, err)
+		response = r.Post("/api/admin/reorg?cointype=SKY", "application/json", string(raw))
+		require.Equal(t, http.StatusOK, response.StatusCode, response.Body)
+
+		entries = ledger("cointype=SKY")
+		require.Equal(t, model_server.TxConfirmed, entries[0].Status)
+		require.Equal(t, model_server.TxOrphaned, entries[1].Status)
+		require.Equal(t, receipts[1].BlockHash, entries[1].BlockHash)
+
+		// double spending the first one orphans it too
+		var conflicting visor.ReadableTransaction
+		raw, err = json.Marshal(model_server.DoubleSpend{
+			TxID:    receipts[0].TxID,
+			Deposit: model_server.Deposit{Address: skyAddress, Value: 1},
+		})
+		require.NoError(t, err)
+		response = r.Post("/api/admin/double_spend?cointype=SKY", "application/json", string(raw))
+		require.Equal(t, http.StatusOK, response.StatusCode, response.Body)
+		require.NoError(t, json.Unmarshal(response.RawBody, &conflicting))
+		require.Equal(t, model_server.TxOrphaned, ledger("cointype=SKY")[0].Status)
+
+		response = r.Get("/api/ledger?format=jsonl" + window)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.Equal(t, "application/x-ndjson", response.Header.Get("Content-Type"))
+		lines := strings.Split(strings.TrimSpace(response.Body), "\n")
+		require.Len(t, lines, 3)
+		var entry model_server.LedgerEntry
+		require.NoError(t, json.Unmarshal([]byte(lines[2]), &entry))
+		require.Equal(t, receipts[2], entry.Receipt)
+
+		response = r.Get("/api/ledger?format=csv&cointype=WAVES" + window)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.Equal(t, "text/csv", response.Header.Get("Content-Type"))
+		records, err := csv.NewReader(strings.NewReader(response.Body)).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, 2)
+		require.Equal(t, model_server.LedgerColumns, records[0])
+		require.Equal(t, []string{"teller", "WAVES", receipts[2].TxID}, records[1][2:5])
+		require.Equal(t, "3", records[1][7])
+		require.Equal(t, model_server.TxConfirmed, records[1][10])
+
+		// resetting a chain removes the entries of its deposits
+		response = r.Post("/api/admin/reset?cointype=SKY", "", "")
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.Len(t, ledger(""), 1)
+		response = r.Post("/api/admin/reset", "", "")
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.Len(t, ledger(""), 0)
+
+		response = r.Get("/api/ledger?format=xml")
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+		response = r.Get("/api/ledger?from=yesterday")
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+	})
+}
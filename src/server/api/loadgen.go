@@ -0,0 +1,50 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/modeneis/coind/src/server/loadgen"
+	"github.com/modeneis/coind/src/server/utils"
+)
+
+// StartLoad parses a YAML, or JSON, load configuration, starts generating the
+// deposits and writes the report
+func StartLoad(data []byte, w http.ResponseWriter) (err error) {
+	config, err := loadgen.Parse(data)
+	if err != nil {
+		return err
+	}
+	if err = loadgen.Start(*config); err != nil {
+		return err
+	}
+	return LoadStatus(w)
+}
+
+// StopLoad stops the running load and writes its report
+func StopLoad(w http.ResponseWriter) (err error) {
+	report, err := loadgen.Stop()
+	if err != nil {
+		return err
+	}
+
+	if err = utils.JSONResponse(w, report); err != nil {
+		err = fmt.Errorf("StopLoad got Err when running JSONResponse %v", err)
+		return err
+	}
+	return nil
+}
+
+// LoadStatus writes the report of the running, or last, load
+func LoadStatus(w http.ResponseWriter) (err error) {
+	report, err := loadgen.Status()
+	if err != nil {
+		return err
+	}
+
+	if err = utils.JSONResponse(w, report); err != nil {
+		err = fmt.Errorf("LoadStatus got Err when running JSONResponse %v", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/drewolson/testflight"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modeneis/coind/src/server/api"
+	"github.com/modeneis/coind/src/server/loadgen"
+)
+
+func TestLoadgen(t *testing.T) {
+	defer useFakeUpstream(t)()
+
+	testflight.WithServer(api.InitRouting(), func(r *testflight.Requester) {
+		report := func(response *testflight.Response) loadgen.Report {
+			require.Equal(t, http.StatusOK, response.StatusCode, response.Body)
+			var report loadgen.Report
+			require.NoError(t, json.Unmarshal(response.RawBody, &report))
+			return report
+		}
+
+		response := r.Post("/api/admin/loadgen", "application/json", `{"rate": 0}`)
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+
+		load := "rate: 1000\ndeposits: 20\ncoins: {SKY: 1, WAVES: 1}\naddresses: 2\n"
+		require.Equal(t, loadgen.StatusRunning, report(r.Post("/api/admin/loadgen", "application/yaml", load)).Status)
+
+		var last loadgen.Report
+		for i := 0; i < 200; i++ {
+			if last = report(r.Get("/api/admin/loadgen")); last.Status != loadgen.StatusRunning {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		require.Equal(t, loadgen.StatusFinished, last.Status)
+		require.Equal(t, int64(20), last.Deposits)
+		require.Equal(t, int64(20), last.Confirmed)
+		require.True(t, last.Addresses <= 4)
+
+		require.Equal(t, loadgen.StatusRunning, report(r.Post("/api/admin/loadgen", "application/json", `{"rate": 1, "duration": "1h"}`)).Status)
+		require.Equal(t, loadgen.StatusStopped, report(r.Delete("/api/admin/loadgen", "", "")).Status)
+	})
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/modeneis/coind/src/server/model_server"
+	"github.com/modeneis/coind/src/server/utils"
+)
+
+// AddToMempool adds an unconfirmed transaction for each deposit and writes
+// the transactions
+func AddToMempool(deposits []model_server.Deposit, w http.ResponseWriter) (err error) {
+	if err = model_server.ValidateDeposits("", deposits); err != nil {
+		return err
+	}
+
+	txs := make([]interface{}, 0, len(deposits))
+	for _, deposit := range deposits {
+		provider, err := model_server.GetProvider(deposit.CoinType)
+		if err != nil {
+			err = fmt.Errorf("CoinType (%s) not supported for deposit %v", deposit.CoinType, deposit)
+			return err
+		}
+
+		tx, err := provider.AddToMempool(deposit)
+		if err != nil {
+			return err
+		}
+		txs = append(txs, tx)
+	}
+
+	if err = utils.JSONResponse(w, txs); err != nil {
+		err = fmt.Errorf("AddToMempool got Err when running JSONResponse %v", err)
+		return err
+	}
+	return nil
+}
+
+// MineBlocks mines n blocks of coinType right away and writes the last one
+func MineBlocks(coinType string, n int, w http.ResponseWriter) (err error) {
+	blocks, err := model_server.MineBlocks(coinType, n)
+	if err != nil {
+		return err
+	}
+
+	if err = utils.JSONResponse(w, blocks); err != nil {
+		err = fmt.Errorf("MineBlocks got Err when running JSONResponse %v", err)
+		return err
+	}
+	return nil
+}
+
+// SetMining pauses, resumes or reconfigures the block production of coinType,
+// or of every coin if coinType is empty, and writes the mining status
+func SetMining(coinType, action string, config *model_server.MinerConfig, w http.ResponseWriter) (err error) {
+	switch {
+	case config != nil:
+		if coinType == "" {
+			return fmt.Errorf("cointype is required to configure mining")
+		}
+		config.Paused = action == "pause"
+		err = model_server.StartMining(coinType, *config)
+	case action == "pause":
+		err = model_server.PauseMining(coinType)
+	case action == "resume":
+		err = model_server.ResumeMining(coinType)
+	default:
+		err = fmt.Errorf("unknown mining action %q", action)
+	}
+	if err != nil {
+		return err
+	}
+
+	return MiningStatus(w)
+}
+
+// MiningStatus writes the block production status of every coin
+func MiningStatus(w http.ResponseWriter) (err error) {
+	if err = utils.JSONResponse(w, model_server.MiningStatus()); err != nil {
+		err = fmt.Errorf("MiningStatus got Err when running JSONResponse %v", err)
+		return err
+	}
+	return nil
+}
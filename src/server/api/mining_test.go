@@ -0,0 +1,133 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/drewolson/testflight"
+	"github.com/skycoin/skycoin/src/visor"
+	"github.com/skycoin/skycoin/src/wallet"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modeneis/coind/src/providers/sky"
+	"github.com/modeneis/coind/src/server/api"
+	"github.com/modeneis/coind/src/server/model_server"
+)
+
+func TestMining(t *testing.T) {
+	defer useFakeUpstream(t)()
+	defer model_server.StopMining()
+
+	address := "2GgFvqoyk9RjwVzj8tqfcXVXB4orBwoc9qv"
+
+	testflight.WithServer(api.InitRouting(), func(r *testflight.Requester) {
+		balance := func() wallet.BalancePair {
+			response := r.Get("/api/balance?cointype=SKY&address=" + address)
+			require.Equal(t, http.StatusOK, response.StatusCode)
+			var bal wallet.BalancePair
+			require.NoError(t, json.Unmarshal(response.RawBody, &bal))
+			return bal
+		}
+
+		raw, err := json.Marshal([]model_server.Deposit{{Address: address, Value: 3, CoinType: api.CoinTypeSKY}})
+		require.NoError(t, err)
+		response := r.Post("/api/mempool", "application/json", string(raw))
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		var txs []visor.ReadableTransaction
+		require.NoError(t, json.Unmarshal(response.RawBody, &txs))
+		require.Len(t, txs, 1)
+
+		bal := balance()
+		require.Equal(t, uint64(0), bal.Confirmed.Coins)
+		require.Equal(t, uint64(3000000), bal.Predicted.Coins)
+
+		transaction := func() sky.Transaction {
+			response := r.Get("/api/transaction?cointype=SKY&txid=" + txs[0].Hash)
+			require.Equal(t, http.StatusOK, response.StatusCode)
+			var tx sky.Transaction
+			require.NoError(t, json.Unmarshal(response.RawBody, &tx))
+			return tx
+		}
+		require.True(t, transaction().Status.Unconfirmed)
+		require.Empty(t, transaction().BlockHash)
+
+		response = r.Post("/api/admin/mine?cointype=SKY", "", "")
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		var blocks visor.ReadableBlocks
+		require.NoError(t, json.Unmarshal(response.RawBody, &blocks))
+		require.Len(t, blocks.Blocks, 1)
+		require.Len(t, blocks.Blocks[0].Body.Transactions, 1)
+		require.Equal(t, txs[0].Hash, blocks.Blocks[0].Body.Transactions[0].Hash)
+		require.Equal(t, uint64(3000000), balance().Confirmed.Coins)
+
+		response = r.Post("/api/admin/mine?cointype=SKY&blocks=2", "", "")
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.NoError(t, json.Unmarshal(response.RawBody, &blocks))
+		require.Empty(t, blocks.Blocks[0].Body.Transactions)
+		require.Equal(t, blocks.Blocks[0].Head.BkSeq, uint64(3))
+
+		tx := transaction()
+		require.True(t, tx.Status.Confirmed)
+		require.Equal(t, uint64(3), tx.Status.Height)
+		require.Equal(t, uint64(1), tx.Status.BlockSeq)
+
+		response = r.Post("/api/admin/mine?cointype=DOGE", "", "")
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+
+		response = r.Post("/api/admin/mining?cointype=SKY&action=pause&block_time=1h", "", "")
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		var status []model_server.MinerStatus
+		require.NoError(t, json.Unmarshal(response.RawBody, &status))
+		require.Len(t, status, 1)
+		require.Equal(t, "SKY", status[0].CoinType)
+		require.Equal(t, "1h0m0s", status[0].BlockTime)
+		require.True(t, status[0].Paused)
+
+		response = r.Post("/api/admin/mining?cointype=SKY&action=resume", "", "")
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		response = r.Get("/api/admin/mining")
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.NoError(t, json.Unmarshal(response.RawBody, &status))
+		require.False(t, status[0].Paused)
+
+		response = r.Post("/api/admin/mining?cointype=SKY&block_time=-1s", "", "")
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+		response = r.Post("/api/admin/mining?cointype=SKY&action=explode", "", "")
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+	})
+}
+
+// TestMiningReads reads the chains while the miners extend them, for go test -race
+func TestMiningReads(t *testing.T) {
+	defer useFakeUpstream(t)()
+	defer model_server.StopMining()
+
+	for _, coinType := range []string{api.CoinTypeSKY, api.CoinTypeWAVES} {
+		require.NoError(t, model_server.StartMining(coinType, model_server.MinerConfig{BlockTime: time.Millisecond}))
+	}
+
+	testflight.WithServer(api.InitRouting(), func(r *testflight.Requester) {
+		for i := 0; i < 50; i++ {
+			for _, coinType := range []string{api.CoinTypeSKY, api.CoinTypeWAVES} {
+				response := r.Get("/api/get_last_blocks?cointype=" + coinType)
+				require.Equal(t, http.StatusOK, response.StatusCode)
+
+				var best btcjson.GetBestBlockResult
+				if json.Unmarshal(response.RawBody, &best) != nil || best.Hash == "" {
+					continue
+				}
+				response = r.Get("/api/get_blocks?cointype=" + coinType + "&hash=" + best.Hash)
+				require.Equal(t, http.StatusOK, response.StatusCode)
+				response = r.Get("/api/get_block_count?cointype=" + coinType)
+				require.Equal(t, http.StatusOK, response.StatusCode)
+			}
+		}
+	})
+}
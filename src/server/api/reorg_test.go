@@ -0,0 +1,199 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/drewolson/testflight"
+	"github.com/skycoin/skycoin/src/visor"
+	"github.com/skycoin/skycoin/src/wallet"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modeneis/coind/src/providers/sky"
+	"github.com/modeneis/coind/src/providers/waves"
+	"github.com/modeneis/coind/src/server/api"
+	"github.com/modeneis/coind/src/server/model_server"
+)
+
+func TestReorg(t *testing.T) {
+	defer useFakeUpstream(t)()
+
+	a := "2GgFvqoyk9RjwVzj8tqfcXVXB4orBwoc9qv"
+	b := "2evvxxvqnu2SYXN3RtzjUC3nu9NFGgv1LgF"
+	c := "cBnu9sUvv12dovBmjQKTtfE4rbjMmf3fzW"
+
+	testflight.WithServer(api.InitRouting(), func(r *testflight.Requester) {
+		skyDeposit := func(address string, value int64) model_server.Receipt {
+			raw, err := json.Marshal([]model_server.Deposit{{Address: address, Value: value, CoinType: api.CoinTypeSKY}})
+			require.NoError(t, err)
+			response := r.Post("/api/nextdeposit", "application/json", string(raw))
+			require.Equal(t, http.StatusOK, response.StatusCode)
+
+			var receipts []model_server.Receipt
+			require.NoError(t, json.Unmarshal(response.RawBody, &receipts))
+			return receipts[0]
+		}
+
+		skyBlock := func(seq int) visor.ReadableBlockHeader {
+			response := r.Get("/api/get_blocks_by_seq?cointype=SKY&seq=" + strconv.Itoa(seq))
+			require.Equal(t, http.StatusOK, response.StatusCode)
+			var best btcjson.GetBestBlockResult
+			require.NoError(t, json.Unmarshal(response.RawBody, &best))
+
+			response = r.Get("/api/get_blocks?cointype=SKY&hash=" + best.Hash)
+			require.Equal(t, http.StatusOK, response.StatusCode)
+			var blocks visor.ReadableBlocks
+			require.NoError(t, json.Unmarshal(response.RawBody, &blocks))
+			return blocks.Blocks[0].Head
+		}
+
+		balance := func(address string) uint64 {
+			response := r.Get("/api/balance?cointype=SKY&address=" + address)
+			require.Equal(t, http.StatusOK, response.StatusCode)
+			var bal wallet.BalancePair
+			require.NoError(t, json.Unmarshal(response.RawBody, &bal))
+			return bal.Confirmed.Coins
+		}
+
+		skyDeposit(a, 1)
+		txB := skyDeposit(b, 2)
+		txC := skyDeposit(c, 3)
+		old := skyBlock(3)
+
+		// B moves up a block with a new amount, C is dropped
+		reorg := model_server.Reorg{
+			Depth: 2,
+			Blocks: [][]model_server.Deposit{
+				{},
+				{{Address: b, Value: 5, Tx: txB.TxID}},
+				{},
+			},
+		}
+		raw, err := json.Marshal(reorg)
+		require.NoError(t, err)
+		response := r.Post("/api/admin/reorg?cointype=SKY", "application/json", string(raw))
+		require.Equal(t, http.StatusOK, response.StatusCode, response.Body)
+
+		var tip btcjson.GetBestBlockResult
+		require.NoError(t, json.Unmarshal(response.RawBody, &tip))
+		require.Equal(t, int32(4), tip.Height)
+
+		response = r.Get("/api/get_block_count?cointype=SKY")
+		require.Equal(t, "4", response.Body)
+
+		for seq := 2; seq <= 4; seq++ {
+			head := skyBlock(seq)
+			require.Equal(t, uint64(seq), head.BkSeq)
+			require.Equal(t, skyBlock(seq-1).BlockHash, head.PreviousBlockHash)
+		}
+		require.NotEqual(t, old.BlockHash, skyBlock(3).BlockHash)
+
+		// the disconnected block can still be fetched by hash
+		response = r.Get("/api/get_blocks?cointype=SKY&hash=" + old.BlockHash)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		response = r.Get("/api/get_transaction?cointype=SKY&tx=" + txC.TxID)
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+		response = r.Get("/api/get_transaction?cointype=SKY&tx=" + txB.TxID)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		var blocks visor.ReadableBlocks
+		require.NoError(t, json.Unmarshal(response.RawBody, &blocks))
+		require.Equal(t, uint64(3), blocks.Blocks[0].Head.BkSeq)
+
+		var tx sky.Transaction
+		response = r.Get("/api/transaction?cointype=SKY&txid=" + txB.TxID)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.NoError(t, json.Unmarshal(response.RawBody, &tx))
+		require.True(t, tx.Status.Confirmed)
+		require.Equal(t, uint64(2), tx.Status.Height)
+		require.Equal(t, uint64(3), tx.Status.BlockSeq)
+		require.Equal(t, skyBlock(3).BlockHash, tx.BlockHash)
+
+		tx = sky.Transaction{}
+		response = r.Get("/api/transaction?cointype=SKY&txid=" + txC.TxID)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.NoError(t, json.Unmarshal(response.RawBody, &tx))
+		require.False(t, tx.Status.Confirmed)
+		require.True(t, tx.Status.Unknown)
+		require.True(t, tx.Orphaned)
+		require.Equal(t, uint64(3), tx.Status.BlockSeq)
+		require.Equal(t, old.BlockHash, tx.BlockHash)
+		require.Equal(t, txC.TxID, tx.Transaction.Hash)
+
+		response = r.Get("/api/transaction?cointype=SKY&txid=missing")
+		require.Equal(t, http.StatusNotFound, response.StatusCode)
+
+		require.Equal(t, uint64(1e6), balance(a))
+		require.Equal(t, uint64(5e6), balance(b))
+		require.Equal(t, uint64(0), balance(c))
+
+		response = r.Post("/api/admin/reorg?cointype=SKY", "application/json", `{"depth": 5}`)
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+
+		// a branch that can not be funded leaves the chain untouched
+		tipHash := skyBlock(4).BlockHash
+		raw, err = json.Marshal(model_server.Reorg{
+			Depth: 1,
+			Blocks: [][]model_server.Deposit{
+				{{Address: c, Value: 1}},
+				{{Address: c, Amount: "9223372036854.775807", N: 1}},
+			},
+		})
+		require.NoError(t, err)
+		response = r.Post("/api/admin/reorg?cointype=SKY", "application/json", string(raw))
+		require.Equal(t, http.StatusBadRequest, response.StatusCode, response.Body)
+		response = r.Get("/api/get_block_count?cointype=SKY")
+		require.Equal(t, "4", response.Body)
+		require.Equal(t, tipHash, skyBlock(4).BlockHash)
+		response = r.Get("/api/get_transaction?cointype=SKY&tx=" + txB.TxID)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.Equal(t, uint64(5e6), balance(b))
+	})
+
+	t.Run("waves", func(t *testing.T) {
+		testflight.WithServer(api.InitRouting(), func(r *testflight.Requester) {
+			var txs []model_server.Receipt
+			for i := 0; i < 2; i++ {
+				raw, err := json.Marshal([]model_server.Deposit{{Address: "3PFnbq8kQjYyPwHMaSnbyQ78t15uU6nbkqi", Value: 10, CoinType: api.CoinTypeWAVES}})
+				require.NoError(t, err)
+				response := r.Post("/api/nextdeposit", "application/json", string(raw))
+				require.Equal(t, http.StatusOK, response.StatusCode)
+
+				var receipts []model_server.Receipt
+				require.NoError(t, json.Unmarshal(response.RawBody, &receipts))
+				require.Equal(t, int64(i+1), receipts[0].Height)
+				txs = append(txs, receipts[0])
+			}
+			require.NotEqual(t, txs[0].TxID, txs[1].TxID)
+
+			response := r.Post("/api/admin/reorg?cointype=WAVES", "application/json", `{"depth": 1}`)
+			require.Equal(t, http.StatusOK, response.StatusCode)
+			response = r.Get("/api/get_block_count?cointype=WAVES")
+			require.Equal(t, "1", response.Body)
+
+			response = r.Get("/api/get_transaction?cointype=WAVES&tx=" + txs[1].TxID)
+			require.Equal(t, http.StatusBadRequest, response.StatusCode)
+			response = r.Get("/api/get_transaction?cointype=WAVES&tx=" + txs[0].TxID)
+			require.Equal(t, http.StatusOK, response.StatusCode)
+
+			var info waves.TransactionInfo
+			response = r.Get("/api/transaction?cointype=WAVES&txid=" + txs[0].TxID)
+			require.Equal(t, http.StatusOK, response.StatusCode)
+			require.NoError(t, json.Unmarshal(response.RawBody, &info))
+			require.Equal(t, model_server.TxConfirmed, info.Status)
+			require.Equal(t, int64(1), info.Confirmations)
+			require.Equal(t, int64(1), info.Height)
+
+			info = waves.TransactionInfo{}
+			response = r.Get("/api/transaction?cointype=WAVES&txid=" + txs[1].TxID)
+			require.Equal(t, http.StatusOK, response.StatusCode)
+			require.NoError(t, json.Unmarshal(response.RawBody, &info))
+			require.Equal(t, model_server.TxOrphaned, info.Status)
+			require.Equal(t, int64(0), info.Confirmations)
+			require.Equal(t, int64(2), info.Height)
+		})
+	})
+}
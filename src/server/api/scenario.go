@@ -0,0 +1,44 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/modeneis/coind/src/server/scenario"
+	"github.com/modeneis/coind/src/server/utils"
+)
+
+// StartScenario parses a YAML scenario, starts running it and writes its progress
+func StartScenario(data []byte, w http.ResponseWriter) (err error) {
+	s, err := scenario.Parse(data)
+	if err != nil {
+		return err
+	}
+
+	run, err := scenario.GetRun(scenario.Start(s))
+	if err != nil {
+		return err
+	}
+
+	if err = utils.JSONResponse(w, run); err != nil {
+		err = fmt.Errorf("StartScenario got Err when running JSONResponse %v", err)
+		return err
+	}
+	return nil
+}
+
+// GetScenario writes the progress of a scenario run, or of every run if id is empty
+func GetScenario(id string, w http.ResponseWriter) (err error) {
+	var result interface{}
+	if id == "" {
+		result = scenario.ListRuns()
+	} else if result, err = scenario.GetRun(id); err != nil {
+		return err
+	}
+
+	if err = utils.JSONResponse(w, result); err != nil {
+		err = fmt.Errorf("GetScenario got Err when running JSONResponse %v", err)
+		return err
+	}
+	return nil
+}
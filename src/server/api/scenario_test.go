@@ -0,0 +1,101 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/drewolson/testflight"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modeneis/coind/src/server/api"
+	"github.com/modeneis/coind/src/server/scenario"
+)
+
+func TestScenarios(t *testing.T) {
+	defer useFakeUpstream(t)()
+
+	passing := `
+name: sky deposits
+steps:
+  - action: deposit
+    deposits:
+      - {cointype: SKY, address: 2evvxxvqnu2SYXN3RtzjUC3nu9NFGgv1LgF, value: 4}
+      - {cointype: SKY, address: 2evvxxvqnu2SYXN3RtzjUC3nu9NFGgv1LgF, value: 6}
+  - action: assert
+    cointype: SKY
+    query: balance
+    address: 2evvxxvqnu2SYXN3RtzjUC3nu9NFGgv1LgF
+    path: confirmed.coins
+    equals: 10000000
+  - action: assert
+    cointype: SKY
+    query: outputs
+    address: 2evvxxvqnu2SYXN3RtzjUC3nu9NFGgv1LgF
+    path: head_outputs.#
+    equals: 2
+  - action: deposit
+    mempool: true
+    deposits:
+      - {cointype: SKY, address: 2GgFvqoyk9RjwVzj8tqfcXVXB4orBwoc9qv, value: 1, tx: scenariotx}
+  - action: mine
+    cointype: SKY
+    blocks: 2
+  - action: assert
+    cointype: SKY
+    query: transaction
+    txid: scenariotx
+    path: status.height
+    equals: 2
+`
+	failing := `
+name: wrong balance
+steps:
+  - action: assert
+    cointype: SKY
+    query: balance
+    address: 2evvxxvqnu2SYXN3RtzjUC3nu9NFGgv1LgF
+    path: confirmed.coins
+    equals: 1
+  - action: wait
+    duration: 1s
+`
+
+	testflight.WithServer(api.InitRouting(), func(r *testflight.Requester) {
+		wait := func(id string) scenario.Run {
+			var run scenario.Run
+			for i := 0; i < 100; i++ {
+				response := r.Get("/api/scenarios?id=" + id)
+				require.Equal(t, http.StatusOK, response.StatusCode)
+				require.NoError(t, json.Unmarshal(response.RawBody, &run))
+				if run.Status == scenario.StatusPassed || run.Status == scenario.StatusFailed {
+					break
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			return run
+		}
+
+		var run scenario.Run
+		response := r.Post("/api/scenarios", "application/x-yaml", passing)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.NoError(t, json.Unmarshal(response.RawBody, &run))
+
+		run = wait(run.ID)
+		require.Equal(t, scenario.StatusPassed, run.Status, run.Error)
+		require.Len(t, run.Steps, 6)
+
+		response = r.Post("/api/scenarios", "application/x-yaml", failing)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.NoError(t, json.Unmarshal(response.RawBody, &run))
+
+		run = wait(run.ID)
+		require.Equal(t, scenario.StatusFailed, run.Status)
+		require.Contains(t, run.Error, "got 10000000, want 1")
+		require.Equal(t, scenario.StatusSkipped, run.Steps[1].Status)
+
+		response = r.Post("/api/scenarios", "application/x-yaml", "steps: [{action: reorg, depth: 2}]")
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+	})
+}
@@ -0,0 +1,67 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/drewolson/testflight"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modeneis/coind/src/providers/sky"
+	"github.com/modeneis/coind/src/providers/waves"
+	"github.com/modeneis/coind/src/server/api"
+	"github.com/modeneis/coind/src/server/model_server"
+)
+
+func TestSeededChains(t *testing.T) {
+	defer model_server.UseProviders(sky.New(), waves.New())
+	defer model_server.DefaultClock.Set(model_server.ClockReal, time.Now(), 0)
+	defer model_server.Unseed()
+
+	deposits := []model_server.Deposit{
+		{Address: "2GgFvqoyk9RjwVzj8tqfcXVXB4orBwoc9qv", Value: 10, CoinType: api.CoinTypeSKY},
+		{Address: "3PFnbq8kQjYyPwHMaSnbyQ78t15uU6nbkqi", Value: 5, CoinType: api.CoinTypeWAVES},
+	}
+
+	// run builds both chains from scratch, without any upstream, and returns
+	// every response of the chains. With fault, a fault rule is added between
+	// the deposits: its id must not change the chains.
+	run := func(seed int64, fault bool) []string {
+		model_server.Seed(seed)
+		require.NoError(t, model_server.DefaultClock.Set(model_server.ClockFixed, model_server.SeedEpoch, 0))
+		model_server.UseProviders(sky.New(), waves.New())
+
+		var bodies []string
+		testflight.WithServer(api.InitRouting(), func(r *testflight.Requester) {
+			record := func(response *testflight.Response) {
+				require.Equal(t, http.StatusOK, response.StatusCode, response.Body)
+				bodies = append(bodies, response.Body)
+			}
+
+			raw, err := json.Marshal(deposits)
+			require.NoError(t, err)
+			record(r.Post("/api/nextdeposit", "application/json", string(raw)))
+			if fault {
+				response := r.Post("/api/admin/faults", "application/json", `{"route": "/api/none", "action": "error"}`)
+				require.Equal(t, http.StatusOK, response.StatusCode, response.Body)
+				var rule struct{ ID string }
+				require.NoError(t, json.Unmarshal(response.RawBody, &rule))
+				defer r.Delete("/api/admin/faults?id="+rule.ID, "", "")
+			}
+			record(r.Post("/api/mempool", "application/json", string(raw)))
+			record(r.Post("/api/admin/clock?advance=10s", "", ""))
+			record(r.Post("/api/admin/mine?cointype=SKY", "", ""))
+			record(r.Post("/api/admin/mine?cointype=WAVES", "", ""))
+			record(r.Get("/api/address_transactions?cointype=SKY&address=" + deposits[0].Address))
+			record(r.Get("/api/address_transactions?cointype=WAVES&address=" + deposits[1].Address))
+		})
+		return bodies
+	}
+
+	first := run(42, false)
+	require.Equal(t, first, run(42, false))
+	require.Equal(t, first, run(42, true))
+	require.NotEqual(t, first, run(43, false))
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/modeneis/coind/src/server/fault"
 	"github.com/modeneis/coind/src/server/model_server"
 )
 
@@ -20,11 +21,20 @@ func NewHTTPAPIServer(address string) *HttpAPIServer {
 	}
 }
 
+// faultsRoute manages the injected faults, it is never faulted itself
+const faultsRoute = "/api/admin/faults"
+
+// Handler returns the routes of the API behind the fault injection middleware
+func Handler() http.Handler {
+	return fault.DefaultInjector.Middleware(InitRouting(), faultsRoute)
+}
+
 func InitRouting() *http.ServeMux {
 	mux := http.NewServeMux()
 
 	/*** BEGIN ROUTES ***/
 	mux.HandleFunc("/api/nextdeposit", HttpHandleNextDeposit)
+	mux.HandleFunc("/api/mempool", HttpHandleMempool)
 
 	mux.HandleFunc("/api/get_blocks", HttpHandleGetBlocks)
 
@@ -33,6 +43,46 @@ func InitRouting() *http.ServeMux {
 
 	mux.HandleFunc("/api/get_block_count", HttpHandleGetBlockCount)
 	mux.HandleFunc("/api/get_transaction", HttpHandleGetBlockHash)
+	mux.HandleFunc("/api/transaction", HttpHandleGetTransaction)
+
+	mux.HandleFunc("/api/balance", HttpHandleGetBalance)
+	mux.HandleFunc("/api/outputs", HttpHandleGetOutputs)
+	mux.HandleFunc("/api/address_transactions", HttpHandleGetAddressTransactions)
+
+	mux.HandleFunc("/api/assets/details", HttpHandleGetAsset)
+	mux.HandleFunc("/api/assets/balance", HttpHandleGetAssetBalances)
+
+	mux.HandleFunc("/api/sendtoaddress", HttpHandleSendToAddress)
+	mux.HandleFunc("/api/sendrawtransaction", HttpHandleSendRawTransaction)
+	mux.HandleFunc("/api/getnewaddress", HttpHandleGetNewAddress)
+	mux.HandleFunc("/api/hdwallet", HttpHandleGetHDWallet)
+
+	mux.HandleFunc("/api/wallet/create", HttpHandleSkyWalletCreate)
+	mux.HandleFunc("/api/wallet/newAddress", HttpHandleSkyWalletNewAddress)
+	mux.HandleFunc("/api/wallet/balance", HttpHandleSkyWalletBalance)
+	mux.HandleFunc("/api/wallet/spend", HttpHandleSkyWalletSpend)
+	mux.HandleFunc("/api/wallet/transactions", HttpHandleSkyWalletTransactions)
+
+	mux.HandleFunc("/api/admin/reset", HttpHandleReset)
+	mux.HandleFunc("/api/admin/snapshot", HttpHandleSnapshot)
+	mux.HandleFunc("/api/admin/restore", HttpHandleRestore)
+	mux.HandleFunc("/api/admin/reorg", HttpHandleReorg)
+	mux.HandleFunc("/api/admin/double_spend", HttpHandleDoubleSpend)
+	mux.HandleFunc("/api/admin/mine", HttpHandleMine)
+	mux.HandleFunc("/api/admin/mining", HttpHandleMining)
+	mux.HandleFunc("/api/admin/clock", HttpHandleClock)
+	mux.HandleFunc("/api/admin/loadgen", HttpHandleLoadgen)
+	mux.HandleFunc("/api/admin/assets", HttpHandleIssueAsset)
+	mux.HandleFunc(faultsRoute, HttpHandleFaults)
+
+	mux.HandleFunc("/api/ledger", HttpHandleLedger)
+
+	mux.HandleFunc("/api/events", HttpHandleEvents)
+
+	mux.HandleFunc("/api/webhooks", HttpHandleWebhooks)
+	mux.HandleFunc("/api/webhooks/deliveries", HttpHandleWebhookDeliveries)
+
+	mux.HandleFunc("/api/scenarios", HttpHandleScenarios)
 
 	/*** END ROUTES ***/
 
@@ -41,11 +91,9 @@ func InitRouting() *http.ServeMux {
 
 func (server *HttpAPIServer) Start() error {
 
-	mux := InitRouting()
-
 	server.listen = &http.Server{
 		Addr:         server.address,
-		Handler:      mux,
+		Handler:      Handler(),
 		ReadTimeout:  model_server.ServerReadTimeout,
 		WriteTimeout: model_server.ServerWriteTimeout,
 		IdleTimeout:  model_server.ServerIdleTimeout,
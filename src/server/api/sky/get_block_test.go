@@ -41,10 +41,10 @@ func TestGetBlock(t *testing.T) {
 				{
 					method:     "POST",
 					expectCode: http.StatusOK,
-					endpoint:   "/api/nextdeposit",
+					endpoint:   "/api/nextdeposit?block=true",
 					Deposits: []model_server.Deposit{
 						{
-							Address:  "1FeDtFhARLxjKUPPkQqEBL78tisenc9znS",
+							Address:  "24jt6Yyvs7Z5ARctzVgysq8mQPrqhrzFvxr",
 							Value:    10000,
 							Hours:    3455,
 							CoinType: api.CoinTypeSKY,
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/skycoin/skycoin/src/gui"
+	"github.com/skycoin/skycoin/src/wallet"
+
+	"github.com/modeneis/coind/src/server/model_server"
+	"github.com/modeneis/coind/src/server/utils"
+)
+
+// skyWallets is the wallet API of a skycoin node, emulated by the SKY provider
+type skyWallets interface {
+	CreateWallet(seed, label string, scanN uint64) (*wallet.ReadableWallet, error)
+	NewWalletAddresses(id string, num uint64) ([]string, error)
+	WalletBalance(id string) (wallet.BalancePair, error)
+	WalletSpend(id, dst string, coins uint64) (*gui.SpendResult, error)
+	WalletTransactions(id string) (*gui.UnconfirmedTxnsResponse, error)
+}
+
+// getSkyWallets returns the wallets of the SKY provider
+func getSkyWallets() (skyWallets, error) {
+	provider, err := model_server.GetProvider(CoinTypeSKY)
+	if err != nil {
+		return nil, fmt.Errorf("CoinType (%s) not supported", CoinTypeSKY)
+	}
+	wallets, ok := provider.(skyWallets)
+	if !ok {
+		return nil, fmt.Errorf("%s provider has no wallets", CoinTypeSKY)
+	}
+	return wallets, nil
+}
+
+// walletErrorCode is the status a skycoin node answers err with
+func walletErrorCode(err error) int {
+	if err == wallet.ErrWalletNotExist {
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
+
+// CreateSkyWallet creates a SKY wallet out of seed and writes it
+func CreateSkyWallet(seed, label string, scanN uint64, w http.ResponseWriter) (err error) {
+	wallets, err := getSkyWallets()
+	if err != nil {
+		return err
+	}
+
+	result, err := wallets.CreateWallet(seed, label, scanN)
+	if err != nil {
+		return err
+	}
+
+	if err = utils.JSONResponse(w, result); err != nil {
+		err = fmt.Errorf("CreateSkyWallet got Err when running JSONResponse %v", err)
+		return err
+	}
+	return nil
+}
+
+// NewSkyWalletAddresses derives num addresses of the SKY wallet id and writes them
+func NewSkyWalletAddresses(id string, num uint64, w http.ResponseWriter) (err error) {
+	wallets, err := getSkyWallets()
+	if err != nil {
+		return err
+	}
+
+	addresses, err := wallets.NewWalletAddresses(id, num)
+	if err != nil {
+		return err
+	}
+
+	result := struct {
+		Addresses []string `json:"addresses"`
+	}{addresses}
+	if err = utils.JSONResponse(w, result); err != nil {
+		err = fmt.Errorf("NewSkyWalletAddresses got Err when running JSONResponse %v", err)
+		return err
+	}
+	return nil
+}
+
+// GetSkyWalletBalance writes the balance of the SKY wallet id
+func GetSkyWalletBalance(id string, w http.ResponseWriter) (err error) {
+	wallets, err := getSkyWallets()
+	if err != nil {
+		return err
+	}
+
+	result, err := wallets.WalletBalance(id)
+	if err != nil {
+		return err
+	}
+
+	if err = utils.JSONResponse(w, result); err != nil {
+		err = fmt.Errorf("GetSkyWalletBalance got Err when running JSONResponse %v", err)
+		return err
+	}
+	return nil
+}
+
+// SkyWalletSpend sends coins droplets from the SKY wallet id to dst and writes
+// the pending transaction with the new balance of the wallet
+func SkyWalletSpend(id, dst string, coins uint64, w http.ResponseWriter) (err error) {
+	wallets, err := getSkyWallets()
+	if err != nil {
+		return err
+	}
+
+	result, err := wallets.WalletSpend(id, dst, coins)
+	if err != nil {
+		return err
+	}
+
+	if err = utils.JSONResponse(w, result); err != nil {
+		err = fmt.Errorf("SkyWalletSpend got Err when running JSONResponse %v", err)
+		return err
+	}
+	return nil
+}
+
+// GetSkyWalletTransactions writes the unconfirmed transactions paying the SKY
+// wallet id
+func GetSkyWalletTransactions(id string, w http.ResponseWriter) (err error) {
+	wallets, err := getSkyWallets()
+	if err != nil {
+		return err
+	}
+
+	result, err := wallets.WalletTransactions(id)
+	if err != nil {
+		return err
+	}
+
+	if err = utils.JSONResponse(w, result); err != nil {
+		err = fmt.Errorf("GetSkyWalletTransactions got Err when running JSONResponse %v", err)
+		return err
+	}
+	return nil
+}
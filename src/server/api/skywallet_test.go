@@ -0,0 +1,123 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/gui"
+	"github.com/skycoin/skycoin/src/wallet"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modeneis/coind/src/providers/sky"
+	"github.com/modeneis/coind/src/server/api"
+	"github.com/modeneis/coind/src/server/model_server"
+)
+
+func TestSkyWallets(t *testing.T) {
+	defer useFakeUpstream(t)()
+	defaultWallets := sky.DefaultWallets
+	sky.DefaultWallets = sky.NewWallets()
+	defer func() { sky.DefaultWallets = defaultWallets }()
+	defer model_server.DefaultClock.Set(model_server.ClockReal, time.Now(), 0)
+
+	const recipient = "24jt6Yyvs7Z5ARctzVgysq8mQPrqhrzFvxr"
+	start := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, model_server.DefaultClock.Set(model_server.ClockFixed, start, 0))
+
+	server := httptest.NewServer(api.InitRouting())
+	defer server.Close()
+	// the routes answer the client of a skycoin node
+	client := gui.NewClient(server.URL + "/api/")
+
+	deposit := func(address string, value int64) {
+		raw, err := json.Marshal([]model_server.Deposit{{CoinType: api.CoinTypeSKY, Address: address, Value: value}})
+		require.NoError(t, err)
+		response, err := http.Post(server.URL+"/api/nextdeposit", "application/json", bytes.NewReader(raw))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.NoError(t, response.Body.Close())
+	}
+	mine := func() {
+		response, err := http.Post(server.URL+"/api/admin/mine?cointype=SKY", "", nil)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.NoError(t, response.Body.Close())
+	}
+	statusCode := func(err error) int {
+		apiErr, ok := err.(gui.APIError)
+		require.True(t, ok, "%v", err)
+		return apiErr.StatusCode
+	}
+
+	created, err := client.CreateWallet("sky wallets test", "hot", 0)
+	require.NoError(t, err)
+	require.Len(t, created.Entries, 1)
+	id := created.Meta["filename"]
+	_, keys := cipher.GenerateDeterministicKeyPairsSeed([]byte("sky wallets test"), 3)
+	require.Equal(t, cipher.AddressFromSecKey(keys[0]).String(), created.Entries[0].Address)
+
+	addresses, err := client.NewWalletAddress(id, 2)
+	require.NoError(t, err)
+	require.Equal(t, []string{cipher.AddressFromSecKey(keys[1]).String(), cipher.AddressFromSecKey(keys[2]).String()}, addresses)
+
+	// a seed is used by a single wallet
+	_, err = client.CreateWallet("sky wallets test", "again", 0)
+	require.Equal(t, http.StatusBadRequest, statusCode(err))
+
+	deposit(addresses[1], 10)
+	require.NoError(t, model_server.DefaultClock.Advance(time.Hour))
+	mine()
+	bal, err := client.WalletBalance(id)
+	require.NoError(t, err)
+	require.Equal(t, uint64(10000000), bal.Confirmed.Coins)
+
+	spent, err := client.Spend(id, recipient, 3000000)
+	require.NoError(t, err)
+	require.Equal(t, uint64(10000000), spent.Balance.Confirmed.Coins)
+	require.Equal(t, uint64(7000000), spent.Balance.Predicted.Coins)
+
+	// the change comes back to the wallet
+	txns, err := client.WalletTransactions(id)
+	require.NoError(t, err)
+	require.Len(t, txns.Transactions, 1)
+	require.Equal(t, spent.Transaction.Hash, txns.Transactions[0].Txn.Hash)
+
+	_, err = client.Spend(id, recipient, 1000000)
+	require.Equal(t, http.StatusBadRequest, statusCode(err))
+	require.Contains(t, err.Error(), wallet.ErrSpendingUnconfirmed.Error())
+
+	// the spend settles with the next block
+	mine()
+	txns, err = client.WalletTransactions(id)
+	require.NoError(t, err)
+	require.Empty(t, txns.Transactions)
+	bal, err = client.WalletBalance(id)
+	require.NoError(t, err)
+	require.Equal(t, uint64(7000000), bal.Confirmed.Coins)
+	response, err := http.Get(server.URL + "/api/balance?cointype=SKY&address=" + recipient)
+	require.NoError(t, err)
+	defer response.Body.Close()
+	require.NoError(t, json.NewDecoder(response.Body).Decode(&bal))
+	require.Equal(t, uint64(3000000), bal.Confirmed.Coins)
+
+	_, err = client.Spend(id, recipient, 8000000)
+	require.Equal(t, http.StatusBadRequest, statusCode(err))
+	require.Contains(t, err.Error(), wallet.ErrInsufficientBalance.Error())
+
+	// restoring a wallet scans its addresses ahead for coins
+	_, restoredKeys := cipher.GenerateDeterministicKeyPairsSeed([]byte("sky wallets restore"), 2)
+	deposit(cipher.AddressFromSecKey(restoredKeys[1]).String(), 1)
+	restored, err := client.CreateWallet("sky wallets restore", "restored", 5)
+	require.NoError(t, err)
+	require.Len(t, restored.Entries, 2)
+
+	_, err = client.WalletBalance("missing.wlt")
+	require.Equal(t, http.StatusNotFound, statusCode(err))
+	_, err = client.NewWalletAddress("missing.wlt", 1)
+	require.Equal(t, http.StatusNotFound, statusCode(err))
+}
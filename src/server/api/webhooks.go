@@ -0,0 +1,58 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/modeneis/coind/src/server/utils"
+	"github.com/modeneis/coind/src/server/webhook"
+)
+
+// RegisterWebhook registers a callback URL for deposit notifications and
+// writes it back with its ID
+func RegisterWebhook(hook webhook.Webhook, w http.ResponseWriter) (err error) {
+	webhook.DefaultManager.Start()
+
+	hook, err = webhook.DefaultManager.Register(hook)
+	if err != nil {
+		return err
+	}
+
+	if err = utils.JSONResponse(w, hook); err != nil {
+		err = fmt.Errorf("RegisterWebhook got Err when running JSONResponse %v", err)
+		return err
+	}
+	return nil
+}
+
+// RemoveWebhook removes a registered webhook
+func RemoveWebhook(id string, w http.ResponseWriter) (err error) {
+	if err = webhook.DefaultManager.Remove(id); err != nil {
+		return err
+	}
+
+	if err = utils.JSONResponse(w, id); err != nil {
+		err = fmt.Errorf("RemoveWebhook got Err when running JSONResponse %v", err)
+		return err
+	}
+	return nil
+}
+
+// ListWebhooks writes every registered webhook, without their secrets
+func ListWebhooks(w http.ResponseWriter) (err error) {
+	if err = utils.JSONResponse(w, webhook.DefaultManager.Webhooks()); err != nil {
+		err = fmt.Errorf("ListWebhooks got Err when running JSONResponse %v", err)
+		return err
+	}
+	return nil
+}
+
+// ListWebhookDeliveries writes the recent deliveries of a webhook, or of every
+// webhook if id is empty
+func ListWebhookDeliveries(id string, w http.ResponseWriter) (err error) {
+	if err = utils.JSONResponse(w, webhook.DefaultManager.Deliveries(id)); err != nil {
+		err = fmt.Errorf("ListWebhookDeliveries got Err when running JSONResponse %v", err)
+		return err
+	}
+	return nil
+}
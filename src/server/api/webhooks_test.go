@@ -0,0 +1,85 @@
+package api_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/drewolson/testflight"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modeneis/coind/src/server/api"
+	"github.com/modeneis/coind/src/server/model_server"
+	"github.com/modeneis/coind/src/server/webhook"
+)
+
+func TestWebhooks(t *testing.T) {
+	defer useFakeUpstream(t)()
+
+	var mu sync.Mutex
+	var notifications []webhook.Notification
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.Equal(t, webhook.Sign("secret", body), r.Header.Get(webhook.HeaderSignature))
+
+		var n webhook.Notification
+		require.NoError(t, json.Unmarshal(body, &n))
+		mu.Lock()
+		notifications = append(notifications, n)
+		mu.Unlock()
+	}))
+	defer receiver.Close()
+
+	skyAddress := "cBnu9sUvv12dovBmjQKTtfE4rbjMmf3fzW"
+
+	testflight.WithServer(api.InitRouting(), func(r *testflight.Requester) {
+		raw, err := json.Marshal(webhook.Webhook{URL: receiver.URL, Secret: "secret", Address: skyAddress})
+		require.NoError(t, err)
+		response := r.Post("/api/webhooks", "application/json", string(raw))
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		var hook webhook.Webhook
+		require.NoError(t, json.Unmarshal(response.RawBody, &hook))
+		require.NotEmpty(t, hook.ID)
+		require.Equal(t, int64(1), hook.Confirmations)
+
+		raw, err = json.Marshal([]model_server.Deposit{{Address: skyAddress, Value: 7, CoinType: api.CoinTypeSKY}})
+		require.NoError(t, err)
+		response = r.Post("/api/nextdeposit", "application/json", string(raw))
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		for i := 0; i < 100; i++ {
+			mu.Lock()
+			n := len(notifications)
+			mu.Unlock()
+			if n == 2 {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		mu.Lock()
+		require.Len(t, notifications, 2)
+		require.Equal(t, webhook.EventDepositSeen, notifications[0].Event)
+		require.Equal(t, webhook.EventDepositConfirmed, notifications[1].Event)
+		require.Equal(t, int64(7e6), notifications[1].Amount)
+		require.Equal(t, int64(1), notifications[1].Confirmations)
+		mu.Unlock()
+
+		response = r.Get("/api/webhooks/deliveries?id=" + hook.ID)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		var deliveries []webhook.Delivery
+		require.NoError(t, json.Unmarshal(response.RawBody, &deliveries))
+		require.Len(t, deliveries, 2)
+
+		response = r.Delete("/api/webhooks?id="+hook.ID, "", "")
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		response = r.Delete("/api/webhooks?id="+hook.ID, "", "")
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+	})
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/modeneis/coind/src/server/model_server"
+	"github.com/modeneis/coind/src/server/utils"
+)
+
+// SendToAddress sends a withdrawal from the hot wallet of coinType and writes
+// the pending transaction
+func SendToAddress(coinType string, withdrawal model_server.Withdrawal, w http.ResponseWriter) (err error) {
+	provider, err := model_server.GetProvider(coinType)
+	if err != nil {
+		err = fmt.Errorf("CoinType (%s) not supported", coinType)
+		return err
+	}
+
+	tx, err := provider.SendToAddress(withdrawal)
+	if err != nil {
+		return err
+	}
+
+	if err = utils.JSONResponse(w, tx); err != nil {
+		err = fmt.Errorf("SendToAddress got Err when running JSONResponse %v", err)
+		return err
+	}
+	return nil
+}
+
+// SendRawTransaction adds a signed transaction of coinType to the mempool and
+// writes what the node of the coin answers
+func SendRawTransaction(coinType, rawTx string, w http.ResponseWriter) (err error) {
+	provider, err := model_server.GetProvider(coinType)
+	if err != nil {
+		err = fmt.Errorf("CoinType (%s) not supported", coinType)
+		return err
+	}
+
+	tx, err := provider.SendRawTransaction(rawTx)
+	if err != nil {
+		return err
+	}
+
+	if err = utils.JSONResponse(w, tx); err != nil {
+		err = fmt.Errorf("SendRawTransaction got Err when running JSONResponse %v", err)
+		return err
+	}
+	return nil
+}
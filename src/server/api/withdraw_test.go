@@ -0,0 +1,284 @@
+package api_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/drewolson/testflight"
+	"github.com/modeneis/waves-go-client/model"
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/cipher/base58"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/gui"
+	"github.com/skycoin/skycoin/src/visor"
+	"github.com/skycoin/skycoin/src/wallet"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modeneis/coind/src/providers/sky"
+	"github.com/modeneis/coind/src/providers/waves"
+	"github.com/modeneis/coind/src/server/address"
+	"github.com/modeneis/coind/src/server/api"
+	"github.com/modeneis/coind/src/server/model_server"
+)
+
+func TestSkyWithdrawals(t *testing.T) {
+	defer useFakeUpstream(t)()
+	defer model_server.DefaultClock.Set(model_server.ClockReal, time.Now(), 0)
+
+	const recipient = "24jt6Yyvs7Z5ARctzVgysq8mQPrqhrzFvxr"
+	start := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, model_server.DefaultClock.Set(model_server.ClockFixed, start, 0))
+
+	testflight.WithServer(api.InitRouting(), func(r *testflight.Requester) {
+		deposit := func(deposit model_server.Deposit) {
+			deposit.CoinType = api.CoinTypeSKY
+			raw, err := json.Marshal([]model_server.Deposit{deposit})
+			require.NoError(t, err)
+			response := r.Post("/api/nextdeposit", "application/json", string(raw))
+			require.Equal(t, http.StatusOK, response.StatusCode, response.Body)
+		}
+		send := func(amount string) *testflight.Response {
+			raw, err := json.Marshal(model_server.Withdrawal{Address: recipient, Amount: amount})
+			require.NoError(t, err)
+			return r.Post("/api/sendtoaddress?cointype=SKY", "application/json", string(raw))
+		}
+		inject := func(txn coin.Transaction) *testflight.Response {
+			raw, err := json.Marshal(map[string]string{"rawtx": hex.EncodeToString(txn.Serialize())})
+			require.NoError(t, err)
+			return r.Post("/api/sendrawtransaction?cointype=SKY", "application/json", string(raw))
+		}
+		balance := func(address string) wallet.BalancePair {
+			response := r.Get("/api/balance?cointype=SKY&address=" + address)
+			require.Equal(t, http.StatusOK, response.StatusCode)
+			var bal wallet.BalancePair
+			require.NoError(t, json.Unmarshal(response.RawBody, &bal))
+			return bal
+		}
+		status := func(txid string) visor.TransactionStatus {
+			response := r.Get("/api/transaction?cointype=SKY&txid=" + txid)
+			require.Equal(t, http.StatusOK, response.StatusCode, response.Body)
+			var tx sky.Transaction
+			require.NoError(t, json.Unmarshal(response.RawBody, &tx))
+			return tx.Status
+		}
+		mine := func() {
+			response := r.Post("/api/admin/mine?cointype=SKY", "", "")
+			require.Equal(t, http.StatusOK, response.StatusCode)
+		}
+
+		hot := hotWallet(t, r, api.CoinTypeSKY)
+
+		// the hot wallet only spends what was deposited to it
+		response := send("1")
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+		require.Contains(t, response.Body, wallet.ErrInsufficientBalance.Error())
+
+		// outputs without coin hours cannot pay the fee
+		deposit(model_server.Deposit{Address: hot, Value: 10})
+		response = send("1")
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+		require.Contains(t, response.Body, "fee")
+
+		require.NoError(t, model_server.DefaultClock.Advance(time.Hour))
+		mine()
+		response = send("1.0001")
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+		require.Contains(t, response.Body, "decimal places")
+
+		response = send("3")
+		require.Equal(t, http.StatusOK, response.StatusCode, response.Body)
+		var spent gui.SpendResult
+		require.NoError(t, json.Unmarshal(response.RawBody, &spent))
+		require.Equal(t, uint64(7000000), spent.Balance.Predicted.Coins)
+		require.Equal(t, uint64(10000000), spent.Balance.Confirmed.Coins)
+		require.True(t, status(spent.Transaction.Hash).Unconfirmed)
+		require.Equal(t, uint64(3000000), balance(recipient).Predicted.Coins)
+
+		// the wallet waits for its pending spend to be confirmed
+		response = send("1")
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+		require.Contains(t, response.Body, wallet.ErrSpendingUnconfirmed.Error())
+
+		// the next block confirms the withdrawal, even when it is not mined
+		// out of the mempool
+		deposit(model_server.Deposit{Address: recipient, Value: 1})
+		require.True(t, status(spent.Transaction.Hash).Confirmed)
+		require.Equal(t, uint64(7000000), balance(hot).Confirmed.Coins)
+		require.Equal(t, uint64(4000000), balance(recipient).Confirmed.Coins)
+
+		response = send("8")
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+		require.Contains(t, response.Body, wallet.ErrInsufficientBalance.Error())
+
+		// transactions signed outside of coind spend confirmed outputs
+		pubKey, secKey := cipher.GenerateDeterministicKeyPair([]byte("withdraw test"))
+		owner := cipher.AddressFromPubKey(pubKey)
+		deposit(model_server.Deposit{Address: owner.String(), Value: 2, Hours: 10})
+
+		response = r.Get("/api/outputs?cointype=SKY&address=" + owner.String())
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		var set visor.ReadableOutputSet
+		require.NoError(t, json.Unmarshal(response.RawBody, &set))
+		require.Len(t, set.HeadOutputs, 1)
+		uxID, err := cipher.SHA256FromHex(set.HeadOutputs[0].Hash)
+		require.NoError(t, err)
+
+		dest, err := cipher.DecodeBase58Address(recipient)
+		require.NoError(t, err)
+		newTxn := func(coins, hours uint64, key cipher.SecKey) coin.Transaction {
+			var txn coin.Transaction
+			txn.PushInput(uxID)
+			txn.PushOutput(dest, coins, hours)
+			txn.SignInputs([]cipher.SecKey{key})
+			txn.UpdateHeader()
+			return txn
+		}
+
+		response = inject(newTxn(2000000, 10, secKey))
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+		require.Contains(t, response.Body, "fee")
+
+		response = inject(newTxn(3000000, 5, secKey))
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+		require.Contains(t, response.Body, "Insufficient coins")
+
+		_, otherKey := cipher.GenerateDeterministicKeyPair([]byte("someone else"))
+		response = inject(newTxn(2000000, 5, otherKey))
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+		require.Contains(t, response.Body, "Signature not valid")
+
+		txn := newTxn(2000000, 5, secKey)
+		response = inject(txn)
+		require.Equal(t, http.StatusOK, response.StatusCode, response.Body)
+		var txid string
+		require.NoError(t, json.Unmarshal(response.RawBody, &txid))
+		require.Equal(t, txn.Hash().Hex(), txid)
+		require.Equal(t, uint64(0), balance(owner.String()).Predicted.Coins)
+
+		response = inject(txn)
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+		require.Contains(t, response.Body, "already pending")
+
+		mine()
+		require.True(t, status(txid).Confirmed)
+		require.Equal(t, uint64(6000000), balance(recipient).Confirmed.Coins)
+	})
+}
+
+func TestWavesWithdrawals(t *testing.T) {
+	defer useFakeUpstream(t)()
+
+	recipient := address.NewWavesFromPublicKey(make([]byte, 32))
+	senderKey := sha256.Sum256([]byte("withdraw test"))
+	sender := address.NewWavesFromPublicKey(senderKey[:])
+
+	testflight.WithServer(api.InitRouting(), func(r *testflight.Requester) {
+		deposit := func(deposit model_server.Deposit) {
+			deposit.CoinType = api.CoinTypeWAVES
+			raw, err := json.Marshal([]model_server.Deposit{deposit})
+			require.NoError(t, err)
+			response := r.Post("/api/nextdeposit", "application/json", string(raw))
+			require.Equal(t, http.StatusOK, response.StatusCode, response.Body)
+		}
+		send := func(amount string) *testflight.Response {
+			raw, err := json.Marshal(model_server.Withdrawal{Address: recipient, Amount: amount})
+			require.NoError(t, err)
+			return r.Post("/api/sendtoaddress?cointype=WAVES", "application/json", string(raw))
+		}
+		broadcast := func(transfer model.Transactions) *testflight.Response {
+			raw, err := json.Marshal(map[string]model.Transactions{"rawtx": transfer})
+			require.NoError(t, err)
+			return r.Post("/api/sendrawtransaction?cointype=WAVES", "application/json", string(raw))
+		}
+		balance := func(address string) waves.AddressBalance {
+			response := r.Get("/api/balance?cointype=WAVES&address=" + address)
+			require.Equal(t, http.StatusOK, response.StatusCode)
+			var bal waves.AddressBalance
+			require.NoError(t, json.Unmarshal(response.RawBody, &bal))
+			return bal
+		}
+		status := func(txid string) string {
+			response := r.Get("/api/transaction?cointype=WAVES&txid=" + txid)
+			require.Equal(t, http.StatusOK, response.StatusCode, response.Body)
+			var tx waves.TransactionInfo
+			require.NoError(t, json.Unmarshal(response.RawBody, &tx))
+			return tx.Status
+		}
+
+		hot := hotWallet(t, r, api.CoinTypeWAVES)
+		deposit(model_server.Deposit{Address: hot, Amount: "1"})
+
+		// the amount and the fee are both spent from the balance
+		response := send("1")
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+		require.Contains(t, response.Body, "insufficient funds")
+
+		response = send("0.5")
+		require.Equal(t, http.StatusOK, response.StatusCode, response.Body)
+		var sent model.Transactions
+		require.NoError(t, json.Unmarshal(response.RawBody, &sent))
+		require.Equal(t, hot, sent.Sender)
+		require.Equal(t, int64(waves.TransferFee), sent.Fee)
+		require.Equal(t, model_server.TxPending, status(sent.ID))
+
+		bal := balance(hot)
+		require.Equal(t, int64(100000000), bal.Balance)
+		require.Equal(t, int64(50000000-waves.TransferFee), bal.Unconfirmed)
+
+		response = r.Post("/api/admin/mine?cointype=WAVES", "", "")
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.Equal(t, model_server.TxConfirmed, status(sent.ID))
+		require.Equal(t, int64(50000000-waves.TransferFee), balance(hot).Balance)
+		require.Equal(t, int64(50000000), balance(recipient).Balance)
+
+		// signed transfers are debited from the address of their public key
+		deposit(model_server.Deposit{Address: sender, Amount: "1"})
+		transfer := model.Transactions{
+			ID:              "7Uq5Mjh1nkVD5qtjXhJ2wqtEFdp9WmYnaKPQkVk1W5H",
+			SenderPublicKey: base58.Hex2Base58String(senderKey[:]),
+			Recipient:       recipient,
+			Amount:          100000000,
+			Fee:             waves.TransferFee,
+			Signature:       "signature",
+		}
+		response = broadcast(transfer)
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+		require.Contains(t, response.Body, "insufficient funds")
+
+		transfer.Amount = 40000000
+		transfer.Fee = 1000
+		response = broadcast(transfer)
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+		require.Contains(t, response.Body, "fee")
+
+		transfer.Fee = waves.TransferFee
+		response = broadcast(transfer)
+		require.Equal(t, http.StatusOK, response.StatusCode, response.Body)
+		require.Equal(t, model_server.TxPending, status(transfer.ID))
+
+		// the next block confirms the transfer, even when it is not mined out
+		// of the mempool
+		deposit(model_server.Deposit{Address: recipient, Amount: "0.1"})
+		require.Equal(t, model_server.TxConfirmed, status(transfer.ID))
+		require.Equal(t, int64(60000000-waves.TransferFee), balance(sender).Balance)
+		require.Equal(t, int64(100000000), balance(recipient).Balance)
+
+		response = r.Get("/api/address_transactions?cointype=WAVES&address=" + sender)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		var page struct {
+			Total        int                  `json:"total"`
+			Transactions []model.Transactions `json:"transactions"`
+		}
+		require.NoError(t, json.Unmarshal(response.RawBody, &page))
+		require.Equal(t, 2, page.Total)
+		require.Equal(t, transfer.ID, page.Transactions[0].ID)
+		require.Equal(t, sender, page.Transactions[0].Sender)
+		require.Equal(t, recipient, page.Transactions[0].Recipient)
+		require.Equal(t, transfer.Amount, page.Transactions[0].Amount)
+		require.Equal(t, sender, page.Transactions[1].Recipient)
+	})
+}
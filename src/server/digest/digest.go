@@ -0,0 +1,4 @@
+// Package digest implements the hashes of the WAVES and ETH addresses that the
+// standard library lacks: legacy Keccak-256 and BLAKE2b-256. They only hash
+// a few short inputs per address, so they favor being short over being fast.
+package digest
@@ -0,0 +1,34 @@
+package digest
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDigest(t *testing.T) {
+	// inputs of n letters a, around the block sizes of both hashes
+	tt := []struct {
+		n         int
+		blake2b   string
+		keccak256 string
+	}{
+		{0, "0e5751c026e543b2e8ab2eb06099daa1d1e5df47778f7787faab45cdf12fe3a8", "c5d2460186f7233c927e7db2dcc703c0e500b653ca82273b7bfad8045d85a470"},
+		{3, "c8ed3cbe4acb99aeb94515ad89a6228f3f5d8f82dec429df135adafcea639416", "b9a5dc0048db9a7d13548781df3cd4b2334606391f75f40c14225a92f4cb3537"},
+		{127, "59e2f1aba240f20aa591016f5ef429990bc9c2131dcd0d30f0ffd75ed18f317d", "2985b12ded0bf77d3a81e1b504266c2ef82a7abc05aab74dfd3fcd9bf9d25ff2"},
+		{128, "ae2aa48507885c4c950fb809b2076f959cde9f8ea6da260d9a3587df33dac450", "81555b8e18b3c117311c16373b1aa78c0a84aad7b8f7f4c753d0021fd9a6700e"},
+		{129, "2f64744a6de0d2c0b56e64cf6e29a5aaa255010d415d51c75ccc82f73dccd865", "64aa8a28a772ee76c84d6ebde75064fc5282e22a8cf617af1d7e5e08ef9a3248"},
+		{135, "b5612841c46eda21e2b861ab2ae1731540ff19c1529768db77e9238c10d0717b", "34367dc248bbd832f4e3e69dfaac2f92638bd0bbd18f2912ba4ef454919cf446"},
+		{136, "998802defa23a4df8f053d4fa9fd2a97a085a4ffb15d5494be644a8f05b4eb4e", "a6c4d403279fe3e0af03729caada8374b5ca54d8065329a3ebcaeb4b60aa386e"},
+		{137, "b9184415945cf6935cba82c6e4e22971e5cd659bdafddaf085c3de0123b5cfe3", "d869f639c7046b4929fc92a4d988a8b22c55fbadb802c0c66ebcd484f1915f39"},
+		{256, "eae4d3a7627549b383179dc18049964f91a6fed14c9f3fb26705eda3eeda5558", "1daa7034adab66d9ec9e03e2c89201b83a7497e85dc5b971aa9dae2ccbb7a208"},
+		{1000, "e00b0ddbf1e2cdaf5c898e1a5e8826ea3a2c339bcf2a478da2e5fca9ff126672", "b6a4ac1f51884d71f30fa397a5e155de3099e11fc0edef5d08b646e621e19de9"},
+	}
+	for _, tc := range tt {
+		b := []byte(strings.Repeat("a", tc.n))
+		require.Equal(t, tc.blake2b, hex.EncodeToString(Blake2b256(b)), "blake2b-256 of %d bytes", tc.n)
+		require.Equal(t, tc.keccak256, hex.EncodeToString(Keccak256(b)), "keccak-256 of %d bytes", tc.n)
+	}
+}
@@ -0,0 +1,87 @@
+package digest
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// keccakRate is the bytes absorbed per permutation by Keccak-256
+const keccakRate = 136
+
+// keccakRounds holds the round constants of Keccak-f[1600]
+var keccakRounds = [24]uint64{
+	0x0000000000000001, 0x0000000000008082, 0x800000000000808a, 0x8000000080008000,
+	0x000000000000808b, 0x0000000080000001, 0x8000000080008081, 0x8000000000008009,
+	0x000000000000008a, 0x0000000000000088, 0x0000000080008009, 0x000000008000000a,
+	0x000000008000808b, 0x800000000000008b, 0x8000000000008089, 0x8000000000008003,
+	0x8000000000008002, 0x8000000000000080, 0x000000000000800a, 0x800000008000000a,
+	0x8000000080008081, 0x8000000000008080, 0x0000000080000001, 0x8000000080008008,
+}
+
+// keccakRotations holds the rotation of the lane x+5y in the rho step
+var keccakRotations = [25]int{
+	0, 1, 62, 28, 27,
+	36, 44, 6, 55, 20,
+	3, 10, 43, 25, 39,
+	41, 45, 15, 21, 8,
+	18, 2, 61, 56, 14,
+}
+
+// Keccak256 returns the Keccak-256 of b, with the original Keccak padding that
+// Ethereum and WAVES use rather than the one of SHA3-256
+func Keccak256(b []byte) []byte {
+	padded := make([]byte, (len(b)/keccakRate+1)*keccakRate)
+	copy(padded, b)
+	padded[len(b)] ^= 0x01
+	padded[len(padded)-1] |= 0x80
+
+	var state [25]uint64
+	for ; len(padded) > 0; padded = padded[keccakRate:] {
+		for i := 0; i < keccakRate/8; i++ {
+			state[i] ^= binary.LittleEndian.Uint64(padded[i*8:])
+		}
+		keccakF(&state)
+	}
+
+	sum := make([]byte, 32)
+	for i := 0; i < 4; i++ {
+		binary.LittleEndian.PutUint64(sum[i*8:], state[i])
+	}
+	return sum
+}
+
+// keccakF applies the Keccak-f[1600] permutation to the lanes a, lane x+5y
+// being the one of column x and row y
+func keccakF(a *[25]uint64) {
+	var c [5]uint64
+	var b [25]uint64
+	for _, rc := range keccakRounds {
+		// theta
+		for x := 0; x < 5; x++ {
+			c[x] = a[x] ^ a[x+5] ^ a[x+10] ^ a[x+15] ^ a[x+20]
+		}
+		for x := 0; x < 5; x++ {
+			d := c[(x+4)%5] ^ bits.RotateLeft64(c[(x+1)%5], 1)
+			for y := 0; y < 25; y += 5 {
+				a[x+y] ^= d
+			}
+		}
+
+		// rho and pi
+		for x := 0; x < 5; x++ {
+			for y := 0; y < 5; y++ {
+				b[y+5*((2*x+3*y)%5)] = bits.RotateLeft64(a[x+5*y], keccakRotations[x+5*y])
+			}
+		}
+
+		// chi
+		for y := 0; y < 25; y += 5 {
+			for x := 0; x < 5; x++ {
+				a[x+y] = b[x+y] ^ (^b[(x+1)%5+y] & b[(x+2)%5+y])
+			}
+		}
+
+		// iota
+		a[0] ^= rc
+	}
+}
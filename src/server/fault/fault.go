@@ -0,0 +1,358 @@
+// Package fault injects failures into the HTTP API and the RPC server, so that
+// clients can exercise their timeouts, retries and error handling
+package fault
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	mrand "math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/modeneis/coind/src/server/model_server"
+)
+
+// Fault actions. A rule without an action only adds latency.
+const (
+	ActionError     = "error"     // answer with an HTTP status code or an RPC error
+	ActionDrop      = "drop"      // close the connection without answering
+	ActionStall     = "stall"     // hold the connection open, then close it
+	ActionTruncate  = "truncate"  // send the first half of the body only
+	ActionMalformed = "malformed" // send a body that is not valid JSON
+)
+
+// DefaultRPCCode is the RPC error code of error rules that do not set one
+const DefaultRPCCode = -32603
+
+// Duration is a time.Duration written as a string such as "250ms" in JSON and
+// YAML
+type Duration time.Duration
+
+// MarshalJSON writes the duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON reads a duration string, or a number of nanoseconds
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		var ns int64
+		if err := json.Unmarshal(data, &ns); err != nil {
+			return fmt.Errorf("invalid duration %s", data)
+		}
+		*d = Duration(ns)
+		return nil
+	}
+	return d.parse(s)
+}
+
+// UnmarshalYAML reads a duration string
+func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	return d.parse(s)
+}
+
+func (d *Duration) parse(s string) error {
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Rule describes a fault and the requests it applies to. Route matches the
+// path of HTTP requests and Method the method of RPC requests; a rule with
+// neither applies to both. CoinType restricts the rule to HTTP requests for
+// that coin. A matching request is faulted with the given Probability, 1 if
+// unset, after waiting Latency. A rule with Times set is removed once it has
+// been applied that many times.
+type Rule struct {
+	ID          string   `json:"id" yaml:"id"`
+	Route       string   `json:"route,omitempty" yaml:"route"`
+	Method      string   `json:"method,omitempty" yaml:"method"`
+	CoinType    string   `json:"cointype,omitempty" yaml:"cointype"`
+	Action      string   `json:"action,omitempty" yaml:"action"`
+	Latency     Duration `json:"latency,omitempty" yaml:"latency"`
+	Probability float64  `json:"probability,omitempty" yaml:"probability"`
+	StatusCode  int      `json:"status_code,omitempty" yaml:"status_code"`
+	RPCCode     int      `json:"rpc_code,omitempty" yaml:"rpc_code"`
+	Stall       Duration `json:"stall,omitempty" yaml:"stall"`
+	Times       int      `json:"times,omitempty" yaml:"times"`
+	Hits        int      `json:"hits" yaml:"-"`
+}
+
+// Validate checks the rule and fills in its defaults
+func (r *Rule) Validate() error {
+	switch r.Action {
+	case "", ActionError, ActionDrop, ActionStall, ActionTruncate, ActionMalformed:
+	default:
+		return fmt.Errorf("unknown fault action %q", r.Action)
+	}
+	if r.Route != "" && r.Method != "" {
+		return fmt.Errorf("a fault applies to a route or to an RPC method, not both")
+	}
+	if r.Action == "" && r.Latency <= 0 {
+		return fmt.Errorf("a fault needs an action or a latency")
+	}
+	if r.Latency < 0 || r.Stall < 0 {
+		return fmt.Errorf("latency and stall must not be negative")
+	}
+	if r.Probability < 0 || r.Probability > 1 {
+		return fmt.Errorf("probability must be between 0 and 1")
+	}
+	if r.Times < 0 {
+		return fmt.Errorf("times must not be negative")
+	}
+	if r.StatusCode != 0 && (r.StatusCode < 400 || r.StatusCode > 599) {
+		return fmt.Errorf("status code %d is not an error", r.StatusCode)
+	}
+
+	if r.Probability == 0 {
+		r.Probability = 1
+	}
+	if r.Action == ActionError && r.StatusCode == 0 {
+		r.StatusCode = http.StatusInternalServerError
+	}
+	if r.Action == ActionError && r.RPCCode == 0 {
+		r.RPCCode = DefaultRPCCode
+	}
+	return nil
+}
+
+func (r *Rule) matches(route, method, coinType string) bool {
+	if route != "" && (r.Method != "" || (r.Route != "" && r.Route != route)) {
+		return false
+	}
+	if method != "" && (r.Route != "" || (r.Method != "" && r.Method != method)) {
+		return false
+	}
+	return r.CoinType == "" || r.CoinType == coinType
+}
+
+// Injector holds the fault rules, checked in the order they were added
+type Injector struct {
+	sync.Mutex
+	rules []*Rule
+	rand  *mrand.Rand // seeded from model_server on first use, see random
+}
+
+// DefaultInjector is the injector of the API and RPC servers
+var DefaultInjector = NewInjector()
+
+// NewInjector creates an Injector without rules
+func NewInjector() *Injector {
+	return &Injector{}
+}
+
+// Add validates a rule and adds it, returning it with its ID
+func (i *Injector) Add(rule Rule) (Rule, error) {
+	if err := rule.Validate(); err != nil {
+		return Rule{}, err
+	}
+	rule.ID = newID()
+	rule.Hits = 0
+
+	i.Lock()
+	defer i.Unlock()
+
+	i.rules = append(i.rules, &rule)
+	return rule, nil
+}
+
+// Remove removes the rule with the given ID
+func (i *Injector) Remove(id string) error {
+	i.Lock()
+	defer i.Unlock()
+
+	for n, rule := range i.rules {
+		if rule.ID == id {
+			i.rules = append(i.rules[:n], i.rules[n+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("fault %s not found", id)
+}
+
+// Clear removes every rule
+func (i *Injector) Clear() {
+	i.Lock()
+	defer i.Unlock()
+
+	i.rules = nil
+}
+
+// Rules returns the rules in the order they are checked
+func (i *Injector) Rules() []Rule {
+	i.Lock()
+	defer i.Unlock()
+
+	rules := make([]Rule, 0, len(i.rules))
+	for _, rule := range i.rules {
+		rules = append(rules, *rule)
+	}
+	return rules
+}
+
+// MatchRPC returns the rule to apply to a call of an RPC method, if any
+func (i *Injector) MatchRPC(method string) (Rule, bool) {
+	return i.match("", method, "")
+}
+
+func (i *Injector) match(route, method, coinType string) (Rule, bool) {
+	i.Lock()
+	defer i.Unlock()
+
+	for n, rule := range i.rules {
+		if !rule.matches(route, method, coinType) || i.random().Float64() >= rule.Probability {
+			continue
+		}
+
+		rule.Hits++
+		if rule.Times > 0 {
+			rule.Times--
+			if rule.Times == 0 {
+				i.rules = append(i.rules[:n], i.rules[n+1:]...)
+			}
+		}
+		return *rule, true
+	}
+	return Rule{}, false
+}
+
+// random returns the source the probabilities of the rules are drawn from. It
+// is seeded on first use rather than when the injector is created, so that the
+// DefaultInjector follows -seed. Must be called with the injector locked.
+func (i *Injector) random() *mrand.Rand {
+	if i.rand == nil {
+		i.rand = mrand.New(mrand.NewSource(model_server.RandomIDInt63()))
+	}
+	return i.rand
+}
+
+// Middleware applies the rules matching each HTTP request to next. Requests
+// whose path starts with one of exempt are never faulted, so that the rules
+// can always be changed.
+func (i *Injector) Middleware(next http.Handler, exempt ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, prefix := range exempt {
+			if strings.HasPrefix(r.URL.Path, prefix) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		rule, ok := i.match(r.URL.Path, "", r.URL.Query().Get("cointype"))
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		done := r.Context().Done()
+		if !Sleep(time.Duration(rule.Latency), done) {
+			return
+		}
+
+		switch rule.Action {
+		case ActionError:
+			http.Error(w, fmt.Sprintf("%d injected fault %s", rule.StatusCode, rule.ID), rule.StatusCode)
+		case ActionDrop:
+			panic(http.ErrAbortHandler)
+		case ActionStall:
+			Stall(time.Duration(rule.Stall), done)
+			panic(http.ErrAbortHandler)
+		case ActionTruncate, ActionMalformed:
+			rec := &recorder{header: make(http.Header), code: http.StatusOK}
+			next.ServeHTTP(rec, r)
+
+			for key, values := range rec.header {
+				w.Header()[key] = values
+			}
+			body := rec.body.Bytes()
+			if rule.Action == ActionTruncate {
+				// announce the whole body, the connection is closed after half of it
+				w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			} else {
+				w.Header().Del("Content-Length")
+			}
+			w.WriteHeader(rec.code)
+			w.Write(Corrupt(rule.Action, body))
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// Sleep waits for d, or until done is closed, and reports whether d elapsed
+func Sleep(d time.Duration, done <-chan struct{}) bool {
+	if d <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-done:
+		return false
+	}
+}
+
+// Stall waits for d, or until done is closed if d is 0
+func Stall(d time.Duration, done <-chan struct{}) {
+	if d <= 0 {
+		<-done
+		return
+	}
+	Sleep(d, done)
+}
+
+// Corrupt returns the body sent by a truncate or malformed fault
+func Corrupt(action string, body []byte) []byte {
+	switch action {
+	case ActionTruncate:
+		return body[:len(body)/2]
+	case ActionMalformed:
+		if !bytes.Contains(body, []byte(`"`)) {
+			return append([]byte("'"), body...)
+		}
+		return bytes.Replace(body, []byte(`"`), []byte("'"), -1)
+	default:
+		return body
+	}
+}
+
+// recorder buffers the response of a handler so that it can be corrupted
+type recorder struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func (r *recorder) Header() http.Header {
+	return r.header
+}
+
+func (r *recorder) WriteHeader(code int) {
+	r.code = code
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	return r.body.Write(b)
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	model_server.RandomIDBytes(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,115 @@
+package fault
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDuration(t *testing.T) {
+	var rule Rule
+	require.NoError(t, json.Unmarshal([]byte(`{"latency": "150ms", "stall": 1000}`), &rule))
+	require.Equal(t, 150*time.Millisecond, time.Duration(rule.Latency))
+	require.Equal(t, time.Microsecond, time.Duration(rule.Stall))
+
+	raw, err := json.Marshal(rule)
+	require.NoError(t, err)
+	require.Contains(t, string(raw), `"latency":"150ms"`)
+
+	require.Error(t, json.Unmarshal([]byte(`{"latency": "soon"}`), &rule))
+}
+
+func TestMiddleware(t *testing.T) {
+	i := NewInjector()
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"coins": "10", "hours": 2}`))
+	})
+	server := httptest.NewServer(i.Middleware(handler, "/admin"))
+	defer server.Close()
+
+	get := func(path string) (*http.Response, []byte, error) {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		return resp, body, err
+	}
+
+	_, err := i.Add(Rule{Action: "explode"})
+	require.Error(t, err)
+	_, err = i.Add(Rule{Route: "/balance", Method: "getblock", Action: ActionDrop})
+	require.Error(t, err)
+	_, err = i.Add(Rule{Route: "/balance"})
+	require.Error(t, err)
+
+	rule, err := i.Add(Rule{Route: "/balance", CoinType: "SKY", Action: ActionError, StatusCode: 503, Times: 2})
+	require.NoError(t, err)
+	require.NotEmpty(t, rule.ID)
+	require.Equal(t, 1.0, rule.Probability)
+
+	for n := 0; n < 2; n++ {
+		resp, _, err := get("/balance?cointype=SKY")
+		require.NoError(t, err)
+		require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	}
+	resp, body, err := get("/balance?cointype=WAVES")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.JSONEq(t, `{"coins": "10", "hours": 2}`, string(body))
+	resp, _, err = get("/balance?cointype=SKY")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode, "expired after two faults")
+	require.Empty(t, i.Rules())
+
+	rule, err = i.Add(Rule{Route: "/balance", Action: ActionTruncate})
+	require.NoError(t, err)
+	_, _, err = get("/balance")
+	require.Error(t, err, "unexpected EOF")
+	require.NoError(t, i.Remove(rule.ID))
+
+	rule, err = i.Add(Rule{Route: "/balance", Action: ActionMalformed})
+	require.NoError(t, err)
+	resp, body, err = get("/balance")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var v interface{}
+	require.Error(t, json.Unmarshal(body, &v))
+	require.NoError(t, i.Remove(rule.ID))
+
+	_, err = i.Add(Rule{Action: ActionDrop})
+	require.NoError(t, err)
+	_, _, err = get("/balance")
+	require.Error(t, err)
+	resp, _, err = get("/admin/faults")
+	require.NoError(t, err, "exempt routes are never faulted")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NotZero(t, i.Rules()[0].Hits)
+	i.Clear()
+
+	_, err = i.Add(Rule{Action: ActionStall, Stall: Duration(50 * time.Millisecond), Latency: Duration(50 * time.Millisecond)})
+	require.NoError(t, err)
+	start := time.Now()
+	_, _, err = get("/balance")
+	require.Error(t, err)
+	require.True(t, time.Since(start) >= 100*time.Millisecond)
+	i.Clear()
+
+	_, err = i.Add(Rule{Method: "getblockcount", Action: ActionError})
+	require.NoError(t, err)
+	resp, _, err = get("/balance")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode, "rpc rules do not apply to routes")
+	rule, ok := i.MatchRPC("getblockcount")
+	require.True(t, ok)
+	require.Equal(t, DefaultRPCCode, rule.RPCCode)
+	_, ok = i.MatchRPC("getblock")
+	require.False(t, ok)
+}
@@ -0,0 +1,243 @@
+// Package hdwallet derives the simulated wallets of coind out of a seed: the
+// deterministic key chain of skycoin wallets for SKY, BIP32/BIP44 for BTC and
+// ETH, and the seed phrase nonces of Waves for WAVES. Private keys are kept in
+// the wallets, only their addresses and xpubs are exposed.
+package hdwallet
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/skycoin/skycoin/src/cipher/base58"
+	"github.com/skycoin/skycoin/src/cipher/ripemd160"
+	"github.com/skycoin/skycoin/src/cipher/secp256k1-go"
+	secp "github.com/skycoin/skycoin/src/cipher/secp256k1-go/secp256k1-go2"
+)
+
+// HardenedKeyStart is the index of the first hardened child
+const HardenedKeyStart uint32 = 0x80000000
+
+// Version bytes of the serialized mainnet extended keys
+var (
+	xpubVersion = []byte{0x04, 0x88, 0xb2, 0x1e}
+	xprvVersion = []byte{0x04, 0x88, 0xad, 0xe4}
+)
+
+// Errors of the BIP32 derivation
+var (
+	ErrDeriveHardenedFromPublic = errors.New("cannot derive a hardened child from a public key")
+	ErrInvalidChild             = errors.New("invalid child, derive the next index")
+	ErrInvalidExtendedKey       = errors.New("invalid extended key")
+)
+
+// ExtendedKey is a BIP32 extended private or public key
+type ExtendedKey struct {
+	key         []byte // 32 bytes private key, or 33 bytes compressed public key
+	chainCode   []byte
+	depth       byte
+	parentFP    []byte
+	childNumber uint32
+	private     bool
+}
+
+// NewMaster derives the master private key of a BIP32 seed
+func NewMaster(seed []byte) (*ExtendedKey, error) {
+	if len(seed) < 16 || len(seed) > 64 {
+		return nil, fmt.Errorf("seed length must be between 16 and 64 bytes, not %d", len(seed))
+	}
+	mac := hmac.New(sha512.New, []byte("Bitcoin seed"))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+	if secp.SeckeyIsValid(sum[:32]) != 1 {
+		return nil, ErrInvalidChild
+	}
+	return &ExtendedKey{
+		key:       sum[:32],
+		chainCode: sum[32:],
+		parentFP:  []byte{0, 0, 0, 0},
+		private:   true,
+	}, nil
+}
+
+// IsPrivate tells whether k holds a private key
+func (k *ExtendedKey) IsPrivate() bool {
+	return k.private
+}
+
+// PublicKey returns the compressed public key of k
+func (k *ExtendedKey) PublicKey() []byte {
+	if k.private {
+		return secp256k1.PubkeyFromSeckey(k.key)
+	}
+	return k.key
+}
+
+// PrivateKey returns the private key of k
+func (k *ExtendedKey) PrivateKey() ([]byte, error) {
+	if !k.private {
+		return nil, errors.New("extended key is public")
+	}
+	return k.key, nil
+}
+
+// Child derives the child i of k. Indexes from HardenedKeyStart on are
+// hardened, and need a private key.
+func (k *ExtendedKey) Child(i uint32) (*ExtendedKey, error) {
+	var data []byte
+	if i >= HardenedKeyStart {
+		if !k.private {
+			return nil, ErrDeriveHardenedFromPublic
+		}
+		data = append([]byte{0x00}, k.key...)
+	} else {
+		data = append([]byte{}, k.PublicKey()...)
+	}
+	data = append(data, uint32Bytes(i)...)
+
+	mac := hmac.New(sha512.New, k.chainCode)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+	il, chainCode := sum[:32], sum[32:]
+	if secp.SeckeyIsValid(il) != 1 {
+		return nil, ErrInvalidChild
+	}
+
+	var key []byte
+	if k.private {
+		n := new(big.Int).SetBytes(il)
+		n.Add(n, new(big.Int).SetBytes(k.key))
+		n.Mod(n, &secp.TheCurve.Order.Int)
+		if n.Sign() == 0 {
+			return nil, ErrInvalidChild
+		}
+		key = leftPad(n.Bytes(), 32)
+	} else {
+		if key = secp.BaseMultiplyAdd(k.key, il); key == nil {
+			return nil, ErrInvalidChild
+		}
+	}
+
+	return &ExtendedKey{
+		key:         key,
+		chainCode:   chainCode,
+		depth:       k.depth + 1,
+		parentFP:    hash160(k.PublicKey())[:4],
+		childNumber: i,
+		private:     k.private,
+	}, nil
+}
+
+// Derive follows path, a list of child indexes, down from k
+func (k *ExtendedKey) Derive(path ...uint32) (*ExtendedKey, error) {
+	var err error
+	for _, i := range path {
+		if k, err = k.Child(i); err != nil {
+			return nil, err
+		}
+	}
+	return k, nil
+}
+
+// Neuter returns the public extended key of k
+func (k *ExtendedKey) Neuter() *ExtendedKey {
+	if !k.private {
+		return k
+	}
+	return &ExtendedKey{
+		key:         k.PublicKey(),
+		chainCode:   k.chainCode,
+		depth:       k.depth,
+		parentFP:    k.parentFP,
+		childNumber: k.childNumber,
+	}
+}
+
+// String serializes k, as an xprv or an xpub
+func (k *ExtendedKey) String() string {
+	b := make([]byte, 0, 82)
+	if k.private {
+		b = append(b, xprvVersion...)
+	} else {
+		b = append(b, xpubVersion...)
+	}
+	b = append(b, k.depth)
+	b = append(b, k.parentFP...)
+	b = append(b, uint32Bytes(k.childNumber)...)
+	b = append(b, k.chainCode...)
+	if k.private {
+		b = append(b, 0x00)
+	}
+	b = append(b, k.key...)
+	return base58.Hex2Base58String(append(b, checksum(b)...))
+}
+
+// ParseExtendedKey parses a serialized xprv or xpub
+func ParseExtendedKey(s string) (*ExtendedKey, error) {
+	b, err := base58.Base582Hex(s)
+	if err != nil || len(b) != 82 {
+		return nil, ErrInvalidExtendedKey
+	}
+	payload := b[:78]
+	if !bytes.Equal(checksum(payload), b[78:]) {
+		return nil, fmt.Errorf("%v: invalid checksum", ErrInvalidExtendedKey)
+	}
+
+	k := &ExtendedKey{
+		depth:       payload[4],
+		parentFP:    payload[5:9],
+		childNumber: binary.BigEndian.Uint32(payload[9:13]),
+		chainCode:   payload[13:45],
+	}
+	switch {
+	case bytes.Equal(payload[:4], xprvVersion):
+		if payload[45] != 0x00 || secp.SeckeyIsValid(payload[46:]) != 1 {
+			return nil, fmt.Errorf("%v: invalid private key", ErrInvalidExtendedKey)
+		}
+		k.key = payload[46:]
+		k.private = true
+	case bytes.Equal(payload[:4], xpubVersion):
+		if secp256k1.VerifyPubkey(payload[45:]) != 1 {
+			return nil, fmt.Errorf("%v: invalid public key", ErrInvalidExtendedKey)
+		}
+		k.key = payload[45:]
+	default:
+		return nil, fmt.Errorf("%v: unknown version %x", ErrInvalidExtendedKey, payload[:4])
+	}
+	return k, nil
+}
+
+// uint32Bytes returns i in big endian
+func uint32Bytes(i uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, i)
+	return b
+}
+
+// leftPad prepends zeros to b up to size bytes
+func leftPad(b []byte, size int) []byte {
+	if len(b) >= size {
+		return b
+	}
+	return append(make([]byte, size-len(b)), b...)
+}
+
+// checksum is the first 4 bytes of the double sha256 of b
+func checksum(b []byte) []byte {
+	first := sha256.Sum256(b)
+	second := sha256.Sum256(first[:])
+	return second[:4]
+}
+
+// hash160 is ripemd160(sha256(b)), the hash of the bitcoin public keys
+func hash160(b []byte) []byte {
+	sum := sha256.Sum256(b)
+	h := ripemd160.New()
+	h.Write(sum[:])
+	return h.Sum(nil)
+}
@@ -0,0 +1,66 @@
+package hdwallet
+
+import (
+	"math/big"
+)
+
+// curve25519 field prime 2^255-19, and (A-2)/4 of the curve
+var (
+	curveP   = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(19))
+	curveA24 = big.NewInt(121665)
+)
+
+// x25519Base returns the X25519 public key of secret, the u-coordinate of
+// the clamped secret times the base point, as in RFC 7748. It is slow but
+// only runs once per derived WAVES address.
+func x25519Base(secret [32]byte) []byte {
+	secret[0] &= 248
+	secret[31] &= 127
+	secret[31] |= 64
+
+	// the scalar is little endian
+	var reversed [32]byte
+	for i, b := range secret {
+		reversed[31-i] = b
+	}
+	k := new(big.Int).SetBytes(reversed[:])
+
+	mod := func(n *big.Int) *big.Int { return n.Mod(n, curveP) }
+	add := func(a, b *big.Int) *big.Int { return mod(new(big.Int).Add(a, b)) }
+	sub := func(a, b *big.Int) *big.Int { return mod(new(big.Int).Sub(a, b)) }
+	mul := func(a, b *big.Int) *big.Int { return mod(new(big.Int).Mul(a, b)) }
+
+	x1 := big.NewInt(9)
+	x2, z2 := big.NewInt(1), big.NewInt(0)
+	x3, z3 := big.NewInt(9), big.NewInt(1)
+	swap := uint(0)
+	for t := 254; t >= 0; t-- {
+		bit := k.Bit(t)
+		if swap^bit == 1 {
+			x2, x3 = x3, x2
+			z2, z3 = z3, z2
+		}
+		swap = bit
+
+		a, b := add(x2, z2), sub(x2, z2)
+		aa, bb := mul(a, a), mul(b, b)
+		e := sub(aa, bb)
+		c, d := add(x3, z3), sub(x3, z3)
+		da, cb := mul(d, a), mul(c, b)
+
+		x3 = mul(add(da, cb), add(da, cb))
+		z3 = mul(x1, mul(sub(da, cb), sub(da, cb)))
+		x2 = mul(aa, bb)
+		z2 = mul(e, add(aa, mul(curveA24, e)))
+	}
+	if swap == 1 {
+		x2, z2 = x3, z3
+	}
+
+	u := mul(x2, new(big.Int).Exp(z2, new(big.Int).Sub(curveP, big.NewInt(2)), curveP))
+	out := leftPad(u.Bytes(), 32)
+	for i, j := 0, 31; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out
+}
@@ -0,0 +1,205 @@
+package hdwallet
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/cipher/go-bip39"
+	"github.com/skycoin/skycoin/src/cipher/secp256k1-go"
+
+	"github.com/modeneis/coind/src/server/address"
+	"github.com/modeneis/coind/src/server/digest"
+)
+
+// bip44CoinTypes are the registered BIP44 coin types of the coins derived
+// along m/44'/coin'/0'/0/i
+var bip44CoinTypes = map[string]uint32{
+	"BTC": 0,
+	"ETH": 60,
+}
+
+// Address is an address of a wallet, the index-th derived from its seed
+type Address struct {
+	Address string `json:"address"`
+	Index   uint32 `json:"index"`
+	Path    string `json:"path,omitempty"`
+}
+
+// key is the key pair behind an address
+type key struct {
+	public []byte
+	secret []byte
+}
+
+// Wallet is the deterministic wallet of a coin. Its addresses are derived in
+// order out of a seed, and their keys stay in the wallet.
+type Wallet struct {
+	sync.Mutex
+	coinType  string
+	seed      string
+	chain     *ExtendedKey // external chain of the BIP44 account, for BTC and ETH
+	xpub      string
+	next      []byte // next seed of the skycoin key chain, for SKY
+	addresses []Address
+	keys      map[string]key
+}
+
+// New creates the wallet of coinType out of seed, with its first address
+// derived. The seed is a skycoin wallet seed for SKY and a seed phrase for
+// WAVES. BTC and ETH take it as the words of a BIP39 mnemonic, with an empty
+// passphrase: any phrase is accepted, and a valid mnemonic derives the same
+// addresses as other BIP39 wallets.
+func New(coinType, seed string) (*Wallet, error) {
+	coinType = strings.ToUpper(coinType)
+	if seed == "" {
+		return nil, fmt.Errorf("seed is required")
+	}
+
+	w := &Wallet{
+		coinType: coinType,
+		seed:     seed,
+		keys:     map[string]key{},
+	}
+	switch coinType {
+	case "SKY":
+		w.next = []byte(seed)
+	case "WAVES":
+	default:
+		coin, ok := bip44CoinTypes[coinType]
+		if !ok {
+			return nil, fmt.Errorf("no wallet for %s", coinType)
+		}
+		master, err := NewMaster(bip39.NewSeed(seed, ""))
+		if err != nil {
+			return nil, err
+		}
+		account, err := master.Derive(44+HardenedKeyStart, coin+HardenedKeyStart, HardenedKeyStart)
+		if err != nil {
+			return nil, err
+		}
+		if w.chain, err = account.Child(0); err != nil {
+			return nil, err
+		}
+		w.xpub = account.Neuter().String()
+	}
+
+	if _, err := w.NewAddress(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// CoinType returns the coin of the wallet
+func (w *Wallet) CoinType() string {
+	return w.coinType
+}
+
+// XPub returns the extended public key of the BIP44 account of a BTC or ETH
+// wallet, whose external chain derives its addresses. SKY and WAVES wallets
+// have none.
+func (w *Wallet) XPub() string {
+	return w.xpub
+}
+
+// NewAddress derives the next address of the wallet
+func (w *Wallet) NewAddress() (Address, error) {
+	w.Lock()
+	defer w.Unlock()
+
+	index := uint32(len(w.addresses))
+	a := Address{Index: index}
+	var k key
+	switch w.coinType {
+	case "SKY":
+		var pubKey cipher.PubKey
+		var secKey cipher.SecKey
+		w.next, pubKey, secKey = cipher.DeterministicKeyPairIterator(w.next)
+		a.Address = cipher.AddressFromPubKey(pubKey).String()
+		k = key{public: pubKey[:], secret: secKey[:]}
+	case "WAVES":
+		secret := sha256.Sum256(digest.Keccak256(digest.Blake2b256(append(uint32Bytes(index), w.seed...))))
+		k = key{public: x25519Base(secret), secret: secret[:]}
+		a.Address = address.NewWavesFromPublicKey(k.public)
+	default:
+		child, err := w.chain.Child(index)
+		if err != nil {
+			return Address{}, err
+		}
+		k.secret, _ = child.PrivateKey()
+		k.public = child.PublicKey()
+		a.Address = addressOf(w.coinType, k.public)
+		a.Path = fmt.Sprintf("m/44'/%d'/0'/0/%d", bip44CoinTypes[w.coinType], index)
+	}
+
+	w.addresses = append(w.addresses, a)
+	w.keys[a.Address] = k
+	return a, nil
+}
+
+// Addresses returns the addresses derived so far, in order
+func (w *Wallet) Addresses() []Address {
+	w.Lock()
+	defer w.Unlock()
+	return append([]Address{}, w.addresses...)
+}
+
+// Owns tells whether address was derived by the wallet
+func (w *Wallet) Owns(address string) bool {
+	w.Lock()
+	defer w.Unlock()
+	_, ok := w.keys[address]
+	return ok
+}
+
+// PublicKey returns the public key of address: compressed secp256k1 for SKY,
+// BTC and ETH, curve25519 for WAVES
+func (w *Wallet) PublicKey(address string) ([]byte, error) {
+	w.Lock()
+	defer w.Unlock()
+	k, ok := w.keys[address]
+	if !ok {
+		return nil, fmt.Errorf("address %s is not in the %s wallet", address, w.coinType)
+	}
+	return k.public, nil
+}
+
+// SecretKey returns the private key of address, to sign what it spends
+func (w *Wallet) SecretKey(address string) ([]byte, error) {
+	w.Lock()
+	defer w.Unlock()
+	k, ok := w.keys[address]
+	if !ok {
+		return nil, fmt.Errorf("address %s is not in the %s wallet", address, w.coinType)
+	}
+	return k.secret, nil
+}
+
+// AddressFromXPub derives the index-th address of the external chain of a
+// BIP44 account xpub of coinType, the way a watch-only wallet would
+func AddressFromXPub(coinType, xpub string, index uint32) (string, error) {
+	coinType = strings.ToUpper(coinType)
+	if _, ok := bip44CoinTypes[coinType]; !ok {
+		return "", fmt.Errorf("%s addresses do not derive from an xpub", coinType)
+	}
+	account, err := ParseExtendedKey(xpub)
+	if err != nil {
+		return "", err
+	}
+	child, err := account.Neuter().Derive(0, index)
+	if err != nil {
+		return "", err
+	}
+	return addressOf(coinType, child.PublicKey()), nil
+}
+
+// addressOf returns the address of a compressed secp256k1 public key: P2PKH
+// for BTC, the last 20 bytes of the keccak256 of the uncompressed key for ETH
+func addressOf(coinType string, pubKey []byte) string {
+	if coinType == "ETH" {
+		return address.NewEthereum(digest.Keccak256(secp256k1.UncompressPubkey(pubKey)[1:])[12:])
+	}
+	return address.NewBitcoin(hash160(pubKey))
+}
@@ -0,0 +1,115 @@
+package hdwallet
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modeneis/coind/src/server/address"
+)
+
+const mnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+func TestBIP32(t *testing.T) {
+	// test vector 1 of BIP32
+	seed, err := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	require.NoError(t, err)
+	master, err := NewMaster(seed)
+	require.NoError(t, err)
+	require.Equal(t, "xprv9s21ZrQH143K3QTDL4LXw2F7HEK3wJUD2nW2nRk4stbPy6cq3jPPqjiChkVvvNKmPGJxWUtg6LnF5kejMRNNU3TGtRBeJgk33yuGBxrMPHi", master.String())
+	require.Equal(t, "xpub661MyMwAqRbcFtXgS5sYJABqqG9YLmC4Q1Rdap9gSE8NqtwybGhePY2gZ29ESFjqJoCu1Rupje8YtGqsefD265TMg7usUDFdp6W1EGMcet8", master.Neuter().String())
+
+	child, err := master.Derive(HardenedKeyStart, 1)
+	require.NoError(t, err)
+	require.Equal(t, "xprv9wTYmMFdV23N2TdNG573QoEsfRrWKQgWeibmLntzniatZvR9BmLnvSxqu53Kw1UmYPxLgboyZQaXwTCg8MSY3H2EU4pWcQDnRnrVA1xe8fs", child.String())
+	require.Equal(t, "xpub6ASuArnXKPbfEwhqN6e3mwBcDTgzisQN1wXN9BJcM47sSikHjJf3UFHKkNAWbWMiGj7Wf5uMash7SyYq527Hqck2AxYysAA7xmALppuCkwQ", child.Neuter().String())
+
+	// public derivation matches the private one
+	hardened, err := master.Child(HardenedKeyStart)
+	require.NoError(t, err)
+	public, err := hardened.Neuter().Child(1)
+	require.NoError(t, err)
+	require.Equal(t, child.Neuter().String(), public.String())
+
+	_, err = master.Neuter().Child(HardenedKeyStart)
+	require.Equal(t, ErrDeriveHardenedFromPublic, err)
+
+	parsed, err := ParseExtendedKey(child.String())
+	require.NoError(t, err)
+	require.True(t, parsed.IsPrivate())
+	require.Equal(t, child.String(), parsed.String())
+	parsed, err = ParseExtendedKey(child.Neuter().String())
+	require.NoError(t, err)
+	require.False(t, parsed.IsPrivate())
+
+	_, err = ParseExtendedKey("xpub6ASuArnXKPbfEwhqN6e3mwBcDTgzisQN1wXN9BJcM47sSikHjJf3UFHKkNAWbWMiGj7Wf5uMash7SyYq527Hqck2AxYysAA7xmALppuCkwR")
+	require.Error(t, err)
+}
+
+func TestWallet(t *testing.T) {
+	tt := []struct {
+		coinType string
+		first    string
+		path     string
+		xpub     string
+	}{
+		{"BTC", "1LqBGSKuX5yYUonjxT5qGfpUsXKYYWeabA", "m/44'/0'/0'/0/0", "xpub6BosfCnifzxcFwrSzQiqu2DBVTshkCXacvNsWGYJVVhhawA7d4R5WSWGFNbi8Aw6ZRc1brxMyWMzG3DSSSSoekkudhUd9yLb6qx39T9nMdj"},
+		{"ETH", "0x9858EfFD232B4033E47d90003D41EC34EcaEda94", "m/44'/60'/0'/0/0", "xpub6DCoCpSuQZB2jawqnGMEPS63ePKWkwWPH4TU45Q7LPXWuNd8TMtVxRrgjtEshuqpK3mdhaWHPFsBngh5GFZaM6si3yZdUsT8ddYM3PwnATt"},
+		{"SKY", "2EFSW8YqFDG3x6mwfbDjBk6M9eMc6WUoFHZ", "", ""},
+		{"WAVES", "3PNzrAc2WQVmFWH8zGQSLGKi8FzQAXgARYk", "", ""},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.coinType, func(t *testing.T) {
+			w, err := New(tc.coinType, mnemonic)
+			require.NoError(t, err)
+			require.Equal(t, tc.xpub, w.XPub())
+			require.Equal(t, []Address{{Address: tc.first, Path: tc.path}}, w.Addresses())
+
+			next, err := w.NewAddress()
+			require.NoError(t, err)
+			require.Equal(t, uint32(1), next.Index)
+			require.NoError(t, address.Validate(tc.coinType, next.Address))
+			require.True(t, w.Owns(next.Address))
+			require.Len(t, w.Addresses(), 2)
+
+			if tc.xpub != "" {
+				derived, err := AddressFromXPub(tc.coinType, tc.xpub, 1)
+				require.NoError(t, err)
+				require.Equal(t, next.Address, derived)
+			}
+
+			_, err = w.SecretKey("unknown")
+			require.Error(t, err)
+		})
+	}
+
+	_, err := New("LTC", mnemonic)
+	require.Error(t, err)
+}
+
+func TestSkyWalletMatchesSkycoin(t *testing.T) {
+	w, err := New("SKY", "coind")
+	require.NoError(t, err)
+	_, err = w.NewAddress()
+	require.NoError(t, err)
+
+	_, keys := cipher.GenerateDeterministicKeyPairsSeed([]byte("coind"), 2)
+	for i, a := range w.Addresses() {
+		secret, err := w.SecretKey(a.Address)
+		require.NoError(t, err)
+		require.Equal(t, keys[i][:], secret)
+		require.Equal(t, cipher.AddressFromSecKey(keys[i]).String(), a.Address)
+	}
+}
+
+func TestX25519Base(t *testing.T) {
+	// Alice's key pair of RFC 7748, section 6.1
+	secret, err := hex.DecodeString("77076d0a7318a57d3c16c17251b26645df4c2f87ebc0992ab177fba51db92c2a")
+	require.NoError(t, err)
+	var s [32]byte
+	copy(s[:], secret)
+	require.Equal(t, "8520f0098930a754748b7ddcb43ef75a0dbf3a0d26381af4eba4a98eaa9b4e6a", hex.EncodeToString(x25519Base(s)))
+}
@@ -0,0 +1,149 @@
+package loadgen
+
+import (
+	"fmt"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/modeneis/coind/src/server/model_server"
+)
+
+// Amount distributions
+const (
+	// AmountFixed makes every deposit Mean
+	AmountFixed = "fixed"
+	// AmountUniform spreads the deposits evenly between Min and Max
+	AmountUniform = "uniform"
+	// AmountExponential makes many small deposits and a few large ones,
+	// averaging Mean and never below Min
+	AmountExponential = "exponential"
+)
+
+// Config describes a load, for example a minute of 200 deposits per second,
+// mostly SKY, to 500 addresses that are each paid several times:
+//
+//	rate: 200
+//	duration: 1m
+//	workers: 4
+//	coins: {SKY: 3, WAVES: 1}
+//	amount: {distribution: uniform, min: 1, max: 100}
+//	addresses: 500
+type Config struct {
+	// Rate is the number of deposits per second, across every coin
+	Rate float64 `yaml:"rate" json:"rate"`
+	// Duration stops the load after a while, Deposits after a number of
+	// deposits, whichever comes first
+	Duration time.Duration `yaml:"duration" json:"duration,omitempty"`
+	Deposits int           `yaml:"deposits" json:"deposits,omitempty"`
+	// Workers is how many deposits may be processed at the same time
+	Workers int `yaml:"workers" json:"workers,omitempty"`
+	// Coins weighs the share of each coin, every provider gets the same share
+	// if it is empty
+	Coins  map[string]float64 `yaml:"coins" json:"coins,omitempty"`
+	Amount Amount             `yaml:"amount" json:"amount"`
+	// Addresses is the size of the address pool of each coin, a fresh address
+	// is used for every deposit if it is zero
+	Addresses int `yaml:"addresses" json:"addresses,omitempty"`
+	// Mempool sends the deposits to the mempool instead of a block each. They
+	// are then confirmed every BlockInterval, or by the miners if it is zero.
+	Mempool       bool          `yaml:"mempool" json:"mempool,omitempty"`
+	BlockInterval time.Duration `yaml:"block_interval" json:"block_interval,omitempty"`
+}
+
+// Amount is the distribution of the deposit values
+type Amount struct {
+	Distribution string `yaml:"distribution" json:"distribution,omitempty"`
+	Min          int64  `yaml:"min" json:"min,omitempty"`
+	Max          int64  `yaml:"max" json:"max,omitempty"`
+	Mean         int64  `yaml:"mean" json:"mean,omitempty"`
+}
+
+// Parse reads a YAML, or JSON, load configuration and validates it
+func Parse(data []byte) (*Config, error) {
+	var c Config
+	if err := yaml.UnmarshalStrict(data, &c); err != nil {
+		return nil, fmt.Errorf("invalid load: %v", err)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Validate checks the configuration and fills in the defaults
+func (c *Config) Validate() error {
+	if c.Rate <= 0 {
+		return fmt.Errorf("rate must be positive")
+	}
+	if c.Duration < 0 || c.Deposits < 0 {
+		return fmt.Errorf("duration and deposits must not be negative")
+	}
+	if c.Duration == 0 && c.Deposits == 0 {
+		return fmt.Errorf("duration or deposits is required")
+	}
+	if c.Workers < 0 {
+		return fmt.Errorf("workers must not be negative")
+	}
+	if c.Workers == 0 {
+		c.Workers = 1
+	}
+	if c.Addresses < 0 {
+		return fmt.Errorf("addresses must not be negative")
+	}
+	if c.BlockInterval < 0 {
+		return fmt.Errorf("block interval must not be negative")
+	}
+	if c.BlockInterval > 0 && !c.Mempool {
+		return fmt.Errorf("block interval requires mempool")
+	}
+
+	if len(c.Coins) == 0 {
+		c.Coins = make(map[string]float64)
+		for coinType := range model_server.GetProviders() {
+			c.Coins[coinType] = 1
+		}
+	}
+	var total float64
+	for coinType, weight := range c.Coins {
+		if _, err := model_server.GetProvider(coinType); err != nil {
+			return err
+		}
+		if weight < 0 {
+			return fmt.Errorf("weight of %s must not be negative", coinType)
+		}
+		total += weight
+	}
+	if total == 0 {
+		return fmt.Errorf("coins have no weight")
+	}
+
+	return c.Amount.validate()
+}
+
+func (a *Amount) validate() error {
+	switch a.Distribution {
+	case "", AmountFixed:
+		a.Distribution = AmountFixed
+		if a.Mean == 0 {
+			a.Mean = 1
+		}
+		if a.Mean < 0 {
+			return fmt.Errorf("mean amount must be positive")
+		}
+	case AmountUniform:
+		if a.Min <= 0 || a.Max < a.Min {
+			return fmt.Errorf("uniform amounts need 0 < min <= max")
+		}
+	case AmountExponential:
+		if a.Min == 0 {
+			a.Min = 1
+		}
+		if a.Min < 0 || a.Mean < a.Min {
+			return fmt.Errorf("exponential amounts need 0 < min <= mean")
+		}
+	default:
+		return fmt.Errorf("unknown amount distribution %q", a.Distribution)
+	}
+	return nil
+}
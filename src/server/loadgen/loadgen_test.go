@@ -0,0 +1,128 @@
+package loadgen
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/modeneis/coind/src/providers/sky"
+	"github.com/modeneis/coind/src/providers/waves"
+	"github.com/modeneis/coind/src/server/address"
+	"github.com/modeneis/coind/src/server/model_server"
+)
+
+func init() {
+	// seeded providers build their blocks out of built-in templates
+	model_server.Seed(1)
+	model_server.UseProviders(sky.New(), waves.New())
+}
+
+func TestParse(t *testing.T) {
+	c, err := Parse([]byte(`
+rate: 100
+duration: 2s
+coins: {SKY: 3, WAVES: 1}
+amount: {distribution: uniform, min: 1, max: 5}
+addresses: 10
+`))
+	require.NoError(t, err)
+	require.Equal(t, 2*time.Second, c.Duration)
+	require.Equal(t, 1, c.Workers)
+
+	c, err = Parse([]byte(`{"rate": 10, "deposits": 5}`))
+	require.NoError(t, err)
+	require.Len(t, c.Coins, 2)
+	require.Equal(t, AmountFixed, c.Amount.Distribution)
+	require.Equal(t, int64(1), c.Amount.Mean)
+
+	for _, data := range []string{
+		`rate: 0`,
+		`{rate: 10}`,
+		`{rate: 10, deposits: 1, coins: {BTC: 1}}`,
+		`{rate: 10, deposits: 1, coins: {SKY: 0}}`,
+		`{rate: 10, deposits: 1, amount: {distribution: uniform, min: 5, max: 1}}`,
+		`{rate: 10, deposits: 1, amount: {distribution: pareto}}`,
+		`{rate: 10, deposits: 1, block_interval: 1s}`,
+		`{rate: 10, deposits: 1, speed: 3}`,
+	} {
+		_, err := Parse([]byte(data))
+		require.Error(t, err, data)
+	}
+}
+
+func TestRun(t *testing.T) {
+	report, err := Run(Config{
+		Rate:      1000,
+		Deposits:  40,
+		Workers:   4,
+		Coins:     map[string]float64{"SKY": 1, "WAVES": 1},
+		Amount:    Amount{Distribution: AmountExponential, Min: 1, Mean: 10},
+		Addresses: 3,
+	})
+	require.NoError(t, err)
+	require.Equal(t, StatusFinished, report.Status)
+	require.Equal(t, int64(40), report.Deposits)
+	require.Zero(t, report.Errors)
+	require.Equal(t, report.Deposits, report.Coins["SKY"]+report.Coins["WAVES"])
+	require.True(t, report.Addresses <= 6)
+	require.Equal(t, int64(40), report.Confirmed)
+	require.Equal(t, int64(40), report.Blocks)
+	require.Equal(t, 40, report.DepositLatency.Count)
+	require.Equal(t, 40, report.ConfirmationLatency.Count)
+	require.Zero(t, report.MineLatency.Count)
+	require.True(t, report.Throughput > 0)
+
+	report, err = Run(Config{
+		Rate:          500,
+		Duration:      100 * time.Millisecond,
+		Coins:         map[string]float64{"SKY": 1},
+		Amount:        Amount{Distribution: AmountFixed, Mean: 2},
+		Mempool:       true,
+		BlockInterval: 20 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	require.NotZero(t, report.Deposits)
+	require.Equal(t, report.Deposits, report.Confirmed)
+	require.Equal(t, int(report.Deposits), report.Addresses)
+	require.NotZero(t, report.MineLatency.Count)
+	require.True(t, report.Blocks < report.Deposits)
+}
+
+func TestStop(t *testing.T) {
+	require.NoError(t, Start(Config{Rate: 10, Duration: time.Hour, Coins: map[string]float64{"SKY": 1}}))
+	require.Error(t, Start(Config{Rate: 10, Duration: time.Hour}))
+
+	report, err := Status()
+	require.NoError(t, err)
+	require.Equal(t, StatusRunning, report.Status)
+
+	report, err = Stop()
+	require.NoError(t, err)
+	require.Equal(t, StatusStopped, report.Status)
+	require.False(t, report.Finished.IsZero())
+}
+
+func TestNewAddress(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, coinType := range []string{"SKY", "WAVES", "ETH"} {
+		require.NoError(t, address.Validate(coinType, newAddress(coinType, r)), coinType)
+	}
+}
+
+func TestLatency(t *testing.T) {
+	require.Equal(t, Latency{}, latency(nil))
+
+	var samples []time.Duration
+	for i := 100; i > 0; i-- {
+		samples = append(samples, time.Duration(i)*time.Millisecond)
+	}
+	l := latency(samples)
+	require.Equal(t, 100, l.Count)
+	require.Equal(t, "1ms", l.Min)
+	require.Equal(t, "50ms", l.P50)
+	require.Equal(t, "95ms", l.P95)
+	require.Equal(t, "100ms", l.Max)
+	require.Equal(t, "50.5ms", l.Mean)
+}
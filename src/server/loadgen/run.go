@@ -0,0 +1,544 @@
+package loadgen
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/cipher/base58"
+
+	"github.com/modeneis/coind/src/server/address"
+	"github.com/modeneis/coind/src/server/model_server"
+)
+
+// Load statuses
+const (
+	StatusRunning  = "running"
+	StatusFinished = "finished"
+	StatusStopped  = "stopped"
+)
+
+// drainTimeout is how long a load waits for its last deposits to confirm
+const drainTimeout = 10 * time.Second
+
+// Latency summarizes the durations of an operation
+type Latency struct {
+	Count int    `json:"count"`
+	Min   string `json:"min,omitempty"`
+	Mean  string `json:"mean,omitempty"`
+	P50   string `json:"p50,omitempty"`
+	P95   string `json:"p95,omitempty"`
+	P99   string `json:"p99,omitempty"`
+	Max   string `json:"max,omitempty"`
+}
+
+// Report is the progress of a load. DepositLatency is the time taken to
+// process a deposit, ConfirmationLatency the time from sending a deposit to
+// its confirmation and MineLatency the time taken to mine a block.
+type Report struct {
+	Status     string           `json:"status"`
+	Error      string           `json:"error,omitempty"`
+	Started    time.Time        `json:"started"`
+	Finished   time.Time        `json:"finished,omitempty"`
+	Elapsed    string           `json:"elapsed"`
+	Deposits   int64            `json:"deposits"`
+	Errors     int64            `json:"errors"`
+	Throughput float64          `json:"throughput"`
+	Coins      map[string]int64 `json:"coins"`
+	Addresses  int              `json:"addresses"`
+	Confirmed  int64            `json:"confirmed"`
+	Blocks     int64            `json:"blocks"`
+	BlockRate  float64          `json:"block_rate"`
+
+	DepositLatency      Latency `json:"deposit_latency"`
+	ConfirmationLatency Latency `json:"confirmation_latency"`
+	MineLatency         Latency `json:"mine_latency"`
+}
+
+// load generates the deposits of a Config and measures them
+type load struct {
+	sync.Mutex
+	config  Config
+	rand    *rand.Rand
+	coins   []string
+	weights []float64 // cumulative weight of coins
+	pools   map[string][]string
+	paid    map[string]bool
+	pending map[string]time.Time // send time of the unconfirmed deposits by txid
+	issued  int64                // deposits scheduled so far, updated atomically
+	until   time.Time            // when the last deposit was sent
+
+	report        Report
+	deposits      []time.Duration
+	confirmations []time.Duration
+	mines         []time.Duration
+
+	quit chan struct{}
+	done chan struct{}
+}
+
+var current = struct {
+	sync.Mutex
+	load *load
+}{}
+
+// Start runs a load in the background, only one load may run at a time
+func Start(config Config) error {
+	_, err := start(config)
+	return err
+}
+
+// Run runs a load to completion and returns its report
+func Run(config Config) (Report, error) {
+	l, err := start(config)
+	if err != nil {
+		return Report{}, err
+	}
+	<-l.done
+	return l.status(), nil
+}
+
+// Stop stops the running load and returns its report
+func Stop() (Report, error) {
+	current.Lock()
+	l := current.load
+	current.Unlock()
+
+	if l == nil {
+		return Report{}, fmt.Errorf("no load was started")
+	}
+	select {
+	case <-l.quit:
+	default:
+		close(l.quit)
+	}
+	<-l.done
+	return l.status(), nil
+}
+
+// Status returns the report of the running, or last, load
+func Status() (Report, error) {
+	current.Lock()
+	l := current.load
+	current.Unlock()
+
+	if l == nil {
+		return Report{}, fmt.Errorf("no load was started")
+	}
+	return l.status(), nil
+}
+
+func start(config Config) (*load, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	current.Lock()
+	defer current.Unlock()
+
+	if current.load != nil {
+		select {
+		case <-current.load.done:
+		default:
+			return nil, fmt.Errorf("a load is already running")
+		}
+	}
+
+	l := newLoad(config)
+	current.load = l
+	go l.run()
+	return l, nil
+}
+
+func newLoad(config Config) *load {
+	l := &load{
+		config:  config,
+		rand:    rand.New(rand.NewSource(model_server.RandomInt63())),
+		pools:   make(map[string][]string),
+		paid:    make(map[string]bool),
+		pending: make(map[string]time.Time),
+		report: Report{
+			Status:  StatusRunning,
+			Started: time.Now().UTC(),
+			Coins:   make(map[string]int64),
+		},
+		quit: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	for coinType := range config.Coins {
+		l.coins = append(l.coins, coinType)
+	}
+	sort.Strings(l.coins)
+
+	var total float64
+	for _, coinType := range l.coins {
+		total += config.Coins[coinType]
+		l.weights = append(l.weights, total)
+		for i := 0; i < config.Addresses; i++ {
+			l.pools[coinType] = append(l.pools[coinType], newAddress(coinType, l.rand))
+		}
+	}
+	return l
+}
+
+func (l *load) run() {
+	_, sub := model_server.SubscribeEvents(math.MaxInt64)
+	stopWatch := make(chan struct{})
+	watched := make(chan struct{})
+	go func() {
+		l.watch(sub, stopWatch)
+		close(watched)
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < l.config.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.work()
+		}()
+	}
+
+	sent := make(chan struct{})
+	mined := make(chan struct{})
+	go func() {
+		if l.config.BlockInterval > 0 {
+			l.mineEvery(l.config.BlockInterval, sent)
+		}
+		close(mined)
+	}()
+
+	wg.Wait()
+	l.Lock()
+	l.until = time.Now()
+	l.Unlock()
+	close(sent)
+	<-mined
+
+	l.drain()
+	close(stopWatch)
+	<-watched
+
+	l.Lock()
+	defer l.Unlock()
+	l.report.Finished = time.Now().UTC()
+	l.report.Status = StatusFinished
+	select {
+	case <-l.quit:
+		l.report.Status = StatusStopped
+	default:
+	}
+	close(l.done)
+}
+
+// work sends the deposits of the schedule shared by every worker, until the
+// load is over
+func (l *load) work() {
+	started := l.report.Started
+	for {
+		n := atomic.AddInt64(&l.issued, 1) - 1
+		if l.config.Deposits > 0 && n >= int64(l.config.Deposits) {
+			return
+		}
+		due := time.Duration(float64(n) / l.config.Rate * float64(time.Second))
+		if l.config.Duration > 0 && due >= l.config.Duration {
+			return
+		}
+
+		timer := time.NewTimer(time.Until(started.Add(due)))
+		select {
+		case <-l.quit:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		l.send(l.next())
+	}
+}
+
+// send processes a deposit the way ProcessDeposits, or the mempool endpoint,
+// does
+func (l *load) send(deposit model_server.Deposit) {
+	provider, err := model_server.GetProvider(deposit.CoinType)
+	if err != nil {
+		l.fail(err)
+		return
+	}
+
+	sent := time.Now()
+	l.Lock()
+	l.pending[deposit.Tx] = sent
+	l.Unlock()
+
+	if l.config.Mempool {
+		_, err = provider.AddToMempool(deposit)
+	} else {
+		var receipt model_server.Receipt
+		if receipt, _, err = provider.CreateFakeBlock(deposit); err == nil {
+			model_server.DefaultLedger.Record("loadgen", receipt)
+		}
+	}
+	took := time.Since(sent)
+
+	l.Lock()
+	defer l.Unlock()
+	if err != nil {
+		delete(l.pending, deposit.Tx)
+		l.report.Errors++
+		l.report.Error = err.Error()
+		return
+	}
+	l.report.Deposits++
+	l.report.Coins[deposit.CoinType]++
+	l.paid[deposit.CoinType+":"+deposit.Address] = true
+	l.deposits = append(l.deposits, took)
+}
+
+// mineEvery mines a block of every coin at each interval, and a last one once
+// every deposit was sent
+func (l *load) mineEvery(interval time.Duration, sent <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.quit:
+			return
+		case <-sent:
+			l.mine()
+			return
+		case <-ticker.C:
+			l.mine()
+		}
+	}
+}
+
+func (l *load) mine() {
+	for _, coinType := range l.coins {
+		started := time.Now()
+		_, err := model_server.MineBlocks(coinType, 1)
+		took := time.Since(started)
+
+		l.Lock()
+		if err != nil {
+			l.report.Errors++
+			l.report.Error = err.Error()
+		} else {
+			l.mines = append(l.mines, took)
+		}
+		l.Unlock()
+	}
+}
+
+// watch counts the blocks and the confirmations of the deposits until stop
+// is closed, resubscribing when it falls behind
+func (l *load) watch(sub *model_server.Subscription, stop <-chan struct{}) {
+	var lastID int64
+	for {
+		select {
+		case <-stop:
+			sub.Cancel()
+			return
+		case e, ok := <-sub.C:
+			if ok {
+				lastID = e.ID
+				l.observe(e)
+				continue
+			}
+
+			var backlog []model_server.Event
+			backlog, sub = model_server.SubscribeEvents(lastID)
+			for _, e := range backlog {
+				l.observe(e)
+			}
+		}
+	}
+}
+
+func (l *load) observe(e model_server.Event) {
+	l.Lock()
+	defer l.Unlock()
+
+	if _, ok := l.config.Coins[e.CoinType]; !ok {
+		return
+	}
+	switch e.Type {
+	case model_server.EventBlockConnected:
+		l.report.Blocks++
+	case model_server.EventDepositConfirmed:
+		if sent, ok := l.pending[e.TxID]; ok {
+			delete(l.pending, e.TxID)
+			l.report.Confirmed++
+			l.confirmations = append(l.confirmations, time.Since(sent))
+		}
+	}
+}
+
+// drain waits for the sent deposits to confirm
+func (l *load) drain() {
+	deadline := time.Now().Add(drainTimeout)
+	for time.Now().Before(deadline) {
+		l.Lock()
+		pending := len(l.pending)
+		l.Unlock()
+		if pending == 0 {
+			return
+		}
+
+		select {
+		case <-l.quit:
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func (l *load) fail(err error) {
+	l.Lock()
+	defer l.Unlock()
+	l.report.Errors++
+	l.report.Error = err.Error()
+}
+
+// next generates a deposit of the configured coin mix and amounts
+func (l *load) next() model_server.Deposit {
+	l.Lock()
+	defer l.Unlock()
+
+	coinType := l.coins[len(l.coins)-1]
+	pick := l.rand.Float64() * l.weights[len(l.weights)-1]
+	for i, weight := range l.weights {
+		if pick < weight {
+			coinType = l.coins[i]
+			break
+		}
+	}
+
+	var address string
+	if pool := l.pools[coinType]; len(pool) > 0 {
+		address = pool[l.rand.Intn(len(pool))]
+	} else {
+		address = newAddress(coinType, l.rand)
+	}
+
+	return model_server.Deposit{
+		Address:  address,
+		Value:    l.amount(),
+		Tx:       newTxID(coinType, l.rand),
+		CoinType: coinType,
+	}
+}
+
+func (l *load) amount() int64 {
+	a := l.config.Amount
+	switch a.Distribution {
+	case AmountUniform:
+		return a.Min + l.rand.Int63n(a.Max-a.Min+1)
+	case AmountExponential:
+		return a.Min + int64(l.rand.ExpFloat64()*float64(a.Mean-a.Min))
+	default:
+		return a.Mean
+	}
+}
+
+func (l *load) status() Report {
+	l.Lock()
+	defer l.Unlock()
+
+	r := l.report
+	r.Coins = make(map[string]int64, len(l.report.Coins))
+	for coinType, n := range l.report.Coins {
+		r.Coins[coinType] = n
+	}
+	r.Addresses = len(l.paid)
+
+	end := time.Now()
+	if !r.Finished.IsZero() {
+		end = r.Finished
+	}
+	r.Elapsed = end.Sub(r.Started).String()
+
+	sending := end
+	if !l.until.IsZero() {
+		sending = l.until
+	}
+	if seconds := sending.Sub(r.Started).Seconds(); seconds > 0 {
+		r.Throughput = float64(r.Deposits) / seconds
+	}
+	if seconds := end.Sub(r.Started).Seconds(); seconds > 0 {
+		r.BlockRate = float64(r.Blocks) / seconds
+	}
+
+	r.DepositLatency = latency(l.deposits)
+	r.ConfirmationLatency = latency(l.confirmations)
+	r.MineLatency = latency(l.mines)
+	return r
+}
+
+// latency summarizes samples
+func latency(samples []time.Duration) Latency {
+	if len(samples) == 0 {
+		return Latency{}
+	}
+
+	sorted := append([]time.Duration(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+	percentile := func(p float64) string {
+		return sorted[int(p*float64(len(sorted)-1))].String()
+	}
+
+	return Latency{
+		Count: len(sorted),
+		Min:   sorted[0].String(),
+		Mean:  (total / time.Duration(len(sorted))).String(),
+		P50:   percentile(0.50),
+		P95:   percentile(0.95),
+		P99:   percentile(0.99),
+		Max:   sorted[len(sorted)-1].String(),
+	}
+}
+
+// newAddress generates a valid address of coinType, or a random hex string
+// for the coins without a known address format
+func newAddress(coinType string, r *rand.Rand) string {
+	seed := make([]byte, 32)
+	r.Read(seed)
+	switch coinType {
+	case "SKY":
+		return address.NewSkycoin(seed)
+	case "WAVES":
+		return address.NewWaves(seed)
+	case "ETH":
+		return address.NewEthereum(seed)
+	default:
+		return hex.EncodeToString(seed[:20])
+	}
+}
+
+// newTxID generates a transaction id of coinType, so that the confirmation of
+// a deposit can be told from the others
+func newTxID(coinType string, r *rand.Rand) string {
+	b := make([]byte, 32)
+	r.Read(b)
+	switch coinType {
+	case "SKY":
+		return cipher.SumSHA256(b).Hex()
+	case "WAVES":
+		return base58.Hex2Base58String(b)
+	default:
+		return hex.EncodeToString(b)
+	}
+}
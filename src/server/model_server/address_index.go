@@ -0,0 +1,351 @@
+package model_server
+
+import (
+	"fmt"
+)
+
+// UxOut is an output credited to an address, as tracked by an AddressIndex.
+// Amount is always expressed in the smallest unit of the coin (droplets for
+// SKY, wavelets for WAVES, satoshis for BTC).
+type UxOut struct {
+	Hash        string // unique output id, the uxid for SKY
+	TxID        string // the transaction that created the output
+	N           uint32 // the index of the output in the tx
+	Address     string
+	Amount      int64
+	Asset       string // the asset held by the output, the coin itself if empty
+	Hours       uint64
+	Height      int64 // 0 while the output is unconfirmed
+	BlockHash   string
+	Time        int64
+	SpentTxID   string // the transaction that spent the output, if any
+	SpentHeight int64  // 0 while the spend is unconfirmed
+}
+
+// Spent reports whether a transaction spending the output was seen.
+func (o UxOut) Spent() bool {
+	return o.SpentTxID != ""
+}
+
+// AddressTx is an entry in the transaction history of an address
+type AddressTx struct {
+	TxID      string
+	Height    int64
+	BlockHash string
+	Time      int64
+	Received  int64 // amount credited to the address by the tx
+	Sent      int64 // amount debited from the address by the tx
+}
+
+// Balance is the amount held by an address
+type Balance struct {
+	Coins int64
+	Hours uint64
+}
+
+// BalancePair records the confirmed balance and the balance once all
+// unconfirmed transactions are included
+type BalancePair struct {
+	Confirmed   Balance
+	Unconfirmed Balance
+}
+
+// AddressIndex indexes the outputs and transactions of every address seen in a
+// provider's fake chain. It is not safe for concurrent use, callers must hold
+// the lock of the block store that owns it.
+type AddressIndex struct {
+	outputs   map[string]*UxOut
+	addresses map[string][]string // address -> output hashes, oldest first
+	debits    map[string]*UxOut
+	debited   map[string][]string     // address -> debit hashes, oldest first
+	history   map[string][]*AddressTx // address -> transactions, oldest first
+}
+
+// NewAddressIndex creates an empty AddressIndex
+func NewAddressIndex() *AddressIndex {
+	return &AddressIndex{
+		outputs:   make(map[string]*UxOut),
+		addresses: make(map[string][]string),
+		debits:    make(map[string]*UxOut),
+		debited:   make(map[string][]string),
+		history:   make(map[string][]*AddressTx),
+	}
+}
+
+// AddOutput indexes a new output. If Hash is empty the output is keyed by
+// TxID:N. Adding an output that is already indexed updates it in place, which
+// is how an unconfirmed output gets confirmed.
+func (idx *AddressIndex) AddOutput(out UxOut) {
+	if out.Hash == "" {
+		out.Hash = fmt.Sprintf("%s:%d", out.TxID, out.N)
+	}
+
+	received := out.Amount
+	if prev, ok := idx.outputs[out.Hash]; ok {
+		received -= prev.Amount
+		if !out.Spent() {
+			out.SpentTxID = prev.SpentTxID
+			out.SpentHeight = prev.SpentHeight
+		}
+	} else {
+		idx.addresses[out.Address] = append(idx.addresses[out.Address], out.Hash)
+	}
+	idx.outputs[out.Hash] = &out
+
+	tx := idx.addressTx(out.Address, out.TxID)
+	tx.Height = out.Height
+	tx.BlockHash = out.BlockHash
+	tx.Time = out.Time
+	tx.Received += received
+}
+
+// Debit records the amount of out as debited from the account of its address,
+// the way account based coins spend. It is a spend of the address, not an
+// output: it shows in the history as sent and lowers the balance. If Hash is
+// empty the debit is keyed by TxID:N. Recording a debit again updates it in
+// place, which is how an unconfirmed debit gets confirmed.
+func (idx *AddressIndex) Debit(out UxOut) {
+	if out.Hash == "" {
+		out.Hash = fmt.Sprintf("%s:%d", out.TxID, out.N)
+	}
+
+	sent := out.Amount
+	if prev, ok := idx.debits[out.Hash]; ok {
+		sent -= prev.Amount
+	} else {
+		idx.debited[out.Address] = append(idx.debited[out.Address], out.Hash)
+	}
+	idx.debits[out.Hash] = &out
+
+	tx := idx.addressTx(out.Address, out.TxID)
+	tx.Height = out.Height
+	tx.BlockHash = out.BlockHash
+	tx.Time = out.Time
+	tx.Sent += sent
+}
+
+// SpendOutput marks an indexed output as spent by txid. Spends of outputs that
+// were never indexed are reported as an error.
+func (idx *AddressIndex) SpendOutput(hash, txid string, height int64, blockHash string, time int64) error {
+	out, ok := idx.outputs[hash]
+	if !ok {
+		return fmt.Errorf("output %s not found", hash)
+	}
+	if out.Spent() && out.SpentTxID != txid {
+		return fmt.Errorf("output %s already spent by %s", hash, out.SpentTxID)
+	}
+	seen := out.Spent()
+
+	out.SpentTxID = txid
+	out.SpentHeight = height
+
+	tx := idx.addressTx(out.Address, txid)
+	tx.Height = height
+	tx.BlockHash = blockHash
+	tx.Time = time
+	if !seen {
+		tx.Sent += out.Amount
+	}
+	return nil
+}
+
+// RemoveTransaction forgets txid, as when its block is disconnected: the
+// outputs it created and the debits it made are dropped and the outputs it
+// spent become unspent.
+func (idx *AddressIndex) RemoveTransaction(txid string) {
+	for hash, out := range idx.outputs {
+		if out.TxID == txid {
+			delete(idx.outputs, hash)
+			idx.addresses[out.Address] = removeString(idx.addresses[out.Address], hash)
+			if len(idx.addresses[out.Address]) == 0 {
+				delete(idx.addresses, out.Address)
+			}
+		}
+		if out.SpentTxID == txid {
+			out.SpentTxID = ""
+			out.SpentHeight = 0
+		}
+	}
+	for hash, debit := range idx.debits {
+		if debit.TxID == txid {
+			delete(idx.debits, hash)
+			idx.debited[debit.Address] = removeString(idx.debited[debit.Address], hash)
+			if len(idx.debited[debit.Address]) == 0 {
+				delete(idx.debited, debit.Address)
+			}
+		}
+	}
+
+	for address, history := range idx.history {
+		kept := history[:0]
+		for _, tx := range history {
+			if tx.TxID != txid {
+				kept = append(kept, tx)
+			}
+		}
+		if len(kept) == 0 {
+			delete(idx.history, address)
+		} else {
+			idx.history[address] = kept
+		}
+	}
+}
+
+func removeString(list []string, s string) []string {
+	for i := range list {
+		if list[i] == s {
+			return append(list[:i], list[i+1:]...)
+		}
+	}
+	return list
+}
+
+// Output returns a single output by hash
+func (idx *AddressIndex) Output(hash string) (UxOut, bool) {
+	out, ok := idx.outputs[hash]
+	if !ok {
+		return UxOut{}, false
+	}
+	return *out, true
+}
+
+// Outputs returns every output ever credited to address, oldest first,
+// including the spent ones
+func (idx *AddressIndex) Outputs(address string) []UxOut {
+	hashes := idx.addresses[address]
+	outs := make([]UxOut, 0, len(hashes))
+	for _, hash := range hashes {
+		outs = append(outs, *idx.outputs[hash])
+	}
+	return outs
+}
+
+// Balance sums the unspent outputs of address, in the coin itself. Outputs
+// spent by an unconfirmed transaction still count towards the confirmed
+// balance.
+func (idx *AddressIndex) Balance(address string) BalancePair {
+	return idx.AssetBalances(address)[""]
+}
+
+// AssetBalances sums the unspent outputs of address by asset, less its
+// debits, the coin itself being the empty asset
+func (idx *AddressIndex) AssetBalances(address string) map[string]BalancePair {
+	balances := make(map[string]BalancePair)
+	for _, hash := range idx.addresses[address] {
+		out := idx.outputs[hash]
+		bal := balances[out.Asset]
+
+		if out.Height > 0 && (!out.Spent() || out.SpentHeight == 0) {
+			bal.Confirmed.Coins += out.Amount
+			bal.Confirmed.Hours += out.Hours
+		}
+		if !out.Spent() {
+			bal.Unconfirmed.Coins += out.Amount
+			bal.Unconfirmed.Hours += out.Hours
+		}
+		balances[out.Asset] = bal
+	}
+	for _, hash := range idx.debited[address] {
+		debit := idx.debits[hash]
+		bal := balances[debit.Asset]
+
+		if debit.Height > 0 {
+			bal.Confirmed.Coins -= debit.Amount
+		}
+		bal.Unconfirmed.Coins -= debit.Amount
+		balances[debit.Asset] = bal
+	}
+	return balances
+}
+
+// AssetSupply sums the amounts of asset held by every address, whether they
+// are confirmed or not
+func (idx *AddressIndex) AssetSupply(asset string) int64 {
+	var supply int64
+	for _, out := range idx.outputs {
+		if out.Asset == asset && !out.Spent() {
+			supply += out.Amount
+		}
+	}
+	for _, debit := range idx.debits {
+		if debit.Asset == asset {
+			supply -= debit.Amount
+		}
+	}
+	return supply
+}
+
+// Transactions returns a page of the transaction history of address, newest
+// first, along with the total number of transactions. A limit of 0 returns
+// everything after offset.
+func (idx *AddressIndex) Transactions(address string, offset, limit int) ([]AddressTx, int) {
+	history := idx.history[address]
+	total := len(history)
+
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > total {
+		offset = total
+	}
+	end := total
+	if limit > 0 && offset+limit < total {
+		end = offset + limit
+	}
+
+	txs := make([]AddressTx, 0, end-offset)
+	for i := offset; i < end; i++ {
+		txs = append(txs, *history[total-1-i])
+	}
+	return txs, total
+}
+
+// addressTx returns the history entry of txid for address, creating it if needed
+func (idx *AddressIndex) addressTx(address, txid string) *AddressTx {
+	for _, tx := range idx.history[address] {
+		if tx.TxID == txid {
+			return tx
+		}
+	}
+
+	tx := &AddressTx{TxID: txid}
+	idx.history[address] = append(idx.history[address], tx)
+	return tx
+}
+
+// TransactionPage is a page of the transaction history of an address
+type TransactionPage struct {
+	Address      string      `json:"address"`
+	Total        int         `json:"total"`
+	Offset       int         `json:"offset"`
+	Limit        int         `json:"limit"`
+	Transactions interface{} `json:"transactions"`
+}
+
+// Clone returns a deep copy of the index
+func (idx *AddressIndex) Clone() *AddressIndex {
+	clone := NewAddressIndex()
+	for hash, out := range idx.outputs {
+		o := *out
+		clone.outputs[hash] = &o
+	}
+	for address, hashes := range idx.addresses {
+		clone.addresses[address] = append([]string(nil), hashes...)
+	}
+	for hash, debit := range idx.debits {
+		d := *debit
+		clone.debits[hash] = &d
+	}
+	for address, hashes := range idx.debited {
+		clone.debited[address] = append([]string(nil), hashes...)
+	}
+	for address, txs := range idx.history {
+		history := make([]*AddressTx, 0, len(txs))
+		for _, tx := range txs {
+			t := *tx
+			history = append(history, &t)
+		}
+		clone.history[address] = history
+	}
+	return clone
+}
@@ -0,0 +1,105 @@
+package model_server_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/modeneis/coind/src/server/model_server"
+)
+
+func TestAddressIndex(t *testing.T) {
+	idx := model_server.NewAddressIndex()
+
+	idx.AddOutput(model_server.UxOut{Hash: "a", TxID: "tx1", Address: "addr1", Amount: 100, Hours: 10, Height: 1, BlockHash: "b1"})
+	idx.AddOutput(model_server.UxOut{Hash: "b", TxID: "tx2", Address: "addr1", Amount: 50, Height: 2, BlockHash: "b2"})
+	idx.AddOutput(model_server.UxOut{TxID: "tx3", N: 1, Address: "addr1", Amount: 7})
+
+	bal := idx.Balance("addr1")
+	require.Equal(t, int64(150), bal.Confirmed.Coins)
+	require.Equal(t, uint64(10), bal.Confirmed.Hours)
+	require.Equal(t, int64(157), bal.Unconfirmed.Coins)
+
+	out, ok := idx.Output("tx3:1")
+	require.True(t, ok)
+	require.Equal(t, int64(7), out.Amount)
+
+	// confirming an output does not count it twice
+	idx.AddOutput(model_server.UxOut{TxID: "tx3", N: 1, Address: "addr1", Amount: 7, Height: 3, BlockHash: "b3"})
+	idx.AddOutput(model_server.UxOut{TxID: "tx3", N: 1, Address: "addr1", Amount: 7})
+	require.Len(t, idx.Outputs("addr1"), 3)
+
+	// unconfirmed spend still counts towards the confirmed balance
+	require.NoError(t, idx.SpendOutput("a", "tx4", 0, "", 0))
+	bal = idx.Balance("addr1")
+	require.Equal(t, int64(150), bal.Confirmed.Coins)
+	require.Equal(t, int64(57), bal.Unconfirmed.Coins)
+
+	require.NoError(t, idx.SpendOutput("a", "tx4", 3, "b4", 0))
+	bal = idx.Balance("addr1")
+	require.Equal(t, int64(50), bal.Confirmed.Coins)
+
+	require.Error(t, idx.SpendOutput("a", "tx5", 4, "b4", 0))
+	require.Error(t, idx.SpendOutput("unknown", "tx5", 4, "b4", 0))
+
+	require.Len(t, idx.Outputs("addr1"), 3)
+	require.Empty(t, idx.Outputs("addr2"))
+
+	txs, total := idx.Transactions("addr1", 0, 2)
+	require.Equal(t, 4, total)
+	require.Len(t, txs, 2)
+	require.Equal(t, "tx4", txs[0].TxID)
+	require.Equal(t, int64(100), txs[0].Sent)
+	require.Equal(t, "tx3", txs[1].TxID)
+
+	txs, total = idx.Transactions("addr1", 3, 2)
+	require.Equal(t, 4, total)
+	require.Len(t, txs, 1)
+	require.Equal(t, "tx1", txs[0].TxID)
+	require.Equal(t, int64(100), txs[0].Received)
+
+	txs, _ = idx.Transactions("addr1", 10, 0)
+	require.Empty(t, txs)
+
+	// removing the spending tx restores the output, removing the funding tx drops it
+	idx.RemoveTransaction("tx4")
+	require.Equal(t, int64(150), idx.Balance("addr1").Confirmed.Coins)
+	idx.RemoveTransaction("tx1")
+	require.Equal(t, int64(50), idx.Balance("addr1").Confirmed.Coins)
+	require.Len(t, idx.Outputs("addr1"), 2)
+	_, total = idx.Transactions("addr1", 0, 0)
+	require.Equal(t, 2, total)
+}
+
+func TestAddressIndexDebits(t *testing.T) {
+	idx := model_server.NewAddressIndex()
+
+	idx.AddOutput(model_server.UxOut{TxID: "tx1", Address: "addr1", Amount: 100, Height: 1, BlockHash: "b1"})
+	idx.AddOutput(model_server.UxOut{TxID: "tx2", Address: "addr2", Amount: 30})
+	idx.Debit(model_server.UxOut{TxID: "tx2", N: 1, Address: "addr1", Amount: 30})
+	idx.Debit(model_server.UxOut{TxID: "tx2", N: 2, Address: "addr1", Amount: 1})
+
+	bal := idx.Balance("addr1")
+	require.Equal(t, int64(100), bal.Confirmed.Coins)
+	require.Equal(t, int64(69), bal.Unconfirmed.Coins)
+
+	// debits are spends of the account, not outputs
+	require.Len(t, idx.Outputs("addr1"), 1)
+	txs, total := idx.Transactions("addr1", 0, 0)
+	require.Equal(t, 2, total)
+	require.Equal(t, model_server.AddressTx{TxID: "tx2", Sent: 31}, txs[0])
+
+	// confirming a debit does not count it twice
+	idx.Debit(model_server.UxOut{TxID: "tx2", N: 1, Address: "addr1", Amount: 30, Height: 2, BlockHash: "b2"})
+	idx.Debit(model_server.UxOut{TxID: "tx2", N: 2, Address: "addr1", Amount: 1, Height: 2, BlockHash: "b2"})
+	require.Equal(t, int64(69), idx.Balance("addr1").Confirmed.Coins)
+	txs, _ = idx.Transactions("addr1", 0, 1)
+	require.Equal(t, model_server.AddressTx{TxID: "tx2", Height: 2, BlockHash: "b2", Sent: 31}, txs[0])
+
+	clone := idx.Clone()
+	idx.RemoveTransaction("tx2")
+	require.Equal(t, int64(100), idx.Balance("addr1").Unconfirmed.Coins)
+	_, total = idx.Transactions("addr1", 0, 0)
+	require.Equal(t, 1, total)
+	require.Equal(t, int64(69), clone.Balance("addr1").Unconfirmed.Coins)
+}
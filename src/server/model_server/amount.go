@@ -0,0 +1,107 @@
+package model_server
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/shopspring/decimal"
+)
+
+// Decimals is the number of decimal places of the base unit of each coin:
+// droplets for SKY, wavelets for WAVES, satoshis for BTC and wei for ETH
+var Decimals = map[string]int32{
+	"SKY":   6,
+	"WAVES": 8,
+	"BTC":   8,
+	"ETH":   18,
+}
+
+var maxUnits = decimal.New(math.MaxInt64, 0)
+
+// maxAmountLength bounds the length and the exponent of the amounts parsed.
+// Amounts beyond it do not fit in an int64 anyway, and an exponent such as
+// 1e1000000000 would take ages to convert exactly.
+const maxAmountLength = 64
+
+// ParseAmount converts a decimal amount of coins, such as "1.5", to base units
+// of coinType. It rejects amounts that are not positive, that have more
+// decimals than the coin or that do not fit in an int64, as does ParseUnits.
+func ParseAmount(coinType, amount string) (int64, error) {
+	decimals, ok := Decimals[coinType]
+	if !ok {
+		return 0, fmt.Errorf("unknown precision for %s", coinType)
+	}
+	return ParseUnits(decimals, amount)
+}
+
+// ParseUnits converts a decimal amount, such as "1.5", to base units of a coin
+// or an asset with the given number of decimals
+func ParseUnits(decimals int32, amount string) (int64, error) {
+	if len(amount) > maxAmountLength {
+		return 0, fmt.Errorf("amount must not be above %d characters", maxAmountLength)
+	}
+	d, err := decimal.NewFromString(amount)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", amount)
+	}
+	if d.Sign() <= 0 {
+		return 0, fmt.Errorf("amount must be positive")
+	}
+	if d.Exponent() > maxAmountLength {
+		return 0, fmt.Errorf("amount %s is too large", amount)
+	}
+	if d.Exponent() < -maxAmountLength {
+		return 0, fmt.Errorf("amount %s has more than %d decimals", amount, decimals)
+	}
+
+	units := d.Mul(decimal.New(1, decimals))
+	if !units.Equal(units.Truncate(0)) {
+		return 0, fmt.Errorf("amount %s has more than %d decimals", amount, decimals)
+	}
+	if units.GreaterThan(maxUnits) {
+		return 0, fmt.Errorf("amount %s is too large", amount)
+	}
+	return units.IntPart(), nil
+}
+
+// FormatAmount converts base units of coinType to a decimal amount of coins
+func FormatAmount(coinType string, units int64) string {
+	return FormatUnits(Decimals[coinType], units)
+}
+
+// FormatUnits converts base units of a coin or an asset with the given number
+// of decimals to a decimal amount
+func FormatUnits(decimals int32, units int64) string {
+	return decimal.New(units, -decimals).String()
+}
+
+// Units returns the amount of the deposit in base units of coinType. Amount
+// is used when it is set, Value otherwise, which is in whole coins for SKY and
+// in base units for the other coins.
+func (d Deposit) Units(coinType string) (int64, error) {
+	if d.Amount != "" {
+		return ParseAmount(coinType, d.Amount)
+	}
+	if d.Value <= 0 {
+		return 0, fmt.Errorf("value must be positive")
+	}
+	if coinType == "SKY" {
+		if d.Value > math.MaxInt64/1000000 {
+			return 0, fmt.Errorf("value %d is too large", d.Value)
+		}
+		return d.Value * 1000000, nil
+	}
+	return d.Value, nil
+}
+
+// AssetUnits returns the amount of an asset deposit in base units of an asset
+// with the given number of decimals. Value is in base units of the asset.
+func (d Deposit) AssetUnits(decimals int32) (int64, error) {
+	if d.Amount != "" {
+		return ParseUnits(decimals, d.Amount)
+	}
+	if d.Value <= 0 {
+		return 0, fmt.Errorf("value must be positive")
+	}
+	return d.Value, nil
+}
@@ -0,0 +1,72 @@
+package model_server
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAmount(t *testing.T) {
+	tt := []struct {
+		coinType string
+		amount   string
+		units    int64
+		err      string
+	}{
+		{"SKY", "1.5", 1500000, ""},
+		{"SKY", "0.000001", 1, ""},
+		{"SKY", "2.5000000", 2500000, ""},
+		{"SKY", "0.0000001", 0, "amount 0.0000001 has more than 6 decimals"},
+		{"WAVES", "5601", 560100000000, ""},
+		{"WAVES", "0.00000001", 1, ""},
+		{"BTC", "0.123456789", 0, "amount 0.123456789 has more than 8 decimals"},
+		{"BTC", "92233720368.54775807", 9223372036854775807, ""},
+		{"BTC", "92233720368.54775808", 0, "amount 92233720368.54775808 is too large"},
+		{"ETH", "1.000000000000000001", 1000000000000000001, ""},
+		{"ETH", "10", 0, "amount 10 is too large"},
+		{"SKY", "0", 0, "amount must be positive"},
+		{"SKY", "-1", 0, "amount must be positive"},
+		{"SKY", "1,5", 0, `invalid amount "1,5"`},
+		{"SKY", "1e1000000000", 0, "amount 1e1000000000 is too large"},
+		{"SKY", "1e-1000000000", 0, "amount 1e-1000000000 has more than 6 decimals"},
+		{"SKY", "1." + strings.Repeat("0", 63), 0, "amount must not be above 64 characters"},
+		{"SKY", "1.5e-1", 150000, ""},
+		{"XRP", "1", 0, "unknown precision for XRP"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.coinType+" "+tc.amount, func(t *testing.T) {
+			units, err := ParseAmount(tc.coinType, tc.amount)
+			if tc.err != "" {
+				require.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.units, units)
+		})
+	}
+}
+
+func TestFormatAmount(t *testing.T) {
+	require.Equal(t, "1.5", FormatAmount("SKY", 1500000))
+	require.Equal(t, "0.00000001", FormatAmount("BTC", 1))
+	require.Equal(t, "5601", FormatAmount("WAVES", 560100000000))
+}
+
+func TestDepositUnits(t *testing.T) {
+	units, err := Deposit{Value: 10}.Units("SKY")
+	require.NoError(t, err)
+	require.Equal(t, int64(10000000), units)
+
+	units, err = Deposit{Value: 10}.Units("WAVES")
+	require.NoError(t, err)
+	require.Equal(t, int64(10), units)
+
+	units, err = Deposit{Amount: "0.1"}.Units("WAVES")
+	require.NoError(t, err)
+	require.Equal(t, int64(10000000), units)
+
+	_, err = Deposit{Value: 1 << 62}.Units("SKY")
+	require.EqualError(t, err, "value 4611686018427387904 is too large")
+}
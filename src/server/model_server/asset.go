@@ -0,0 +1,11 @@
+package model_server
+
+// Asset describes a token to issue on a chain with assets
+type Asset struct {
+	AssetID     string `json:"assetid" yaml:"assetid"` // a new id is generated if empty
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description" yaml:"description"`
+	Decimals    int32  `json:"decimals" yaml:"decimals"`
+	Quantity    string `json:"quantity" yaml:"quantity"` // decimal amount of the asset issued
+	Reissuable  bool   `json:"reissuable" yaml:"reissuable"`
+}
@@ -0,0 +1,283 @@
+package model_server
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Clock modes
+const (
+	// ClockReal follows the wall clock
+	ClockReal = "real"
+	// ClockFixed stands still until it is advanced
+	ClockFixed = "fixed"
+	// ClockScaled runs Scale times faster than the wall clock
+	ClockScaled = "scaled"
+)
+
+// ClockStatus describes a clock
+type ClockStatus struct {
+	Mode  string    `json:"mode"`
+	Now   time.Time `json:"now"`
+	Scale float64   `json:"scale"`
+}
+
+// Clock is the virtual time of the fake chains. Block timestamps and the
+// mining cadence follow it, so tests can fix it or run it faster than the wall
+// clock, and advance it by hand.
+type Clock struct {
+	sync.Mutex
+	mode   string
+	scale  float64
+	base   time.Time // virtual time at anchor
+	anchor time.Time // wall time at which base was set
+	timers map[*Timer]struct{}
+
+	advancing sync.Mutex // serializes Advance, which unlocks the clock to call timer functions
+}
+
+// Timer sends the virtual time on C once the clock has reached its deadline,
+// or calls its function if it was made by AfterFunc
+type Timer struct {
+	C        <-chan time.Time
+	c        chan time.Time
+	f        func()
+	clock    *Clock
+	deadline time.Time
+	wall     *time.Timer
+}
+
+// DefaultClock is the clock used by every provider
+var DefaultClock = NewClock()
+
+// NewClock returns a clock that follows the wall clock
+func NewClock() *Clock {
+	now := time.Now()
+	return &Clock{
+		mode:   ClockReal,
+		scale:  1,
+		base:   now,
+		anchor: now,
+		timers: make(map[*Timer]struct{}),
+	}
+}
+
+// Now returns the current time of the DefaultClock
+func Now() time.Time {
+	return DefaultClock.Now()
+}
+
+// Now returns the current virtual time
+func (c *Clock) Now() time.Time {
+	c.Lock()
+	defer c.Unlock()
+	return c.now()
+}
+
+// Status describes the clock
+func (c *Clock) Status() ClockStatus {
+	c.Lock()
+	defer c.Unlock()
+	return ClockStatus{
+		Mode:  c.mode,
+		Now:   c.now(),
+		Scale: c.rate(),
+	}
+}
+
+// Set switches the clock to mode, starting from start, or from the current
+// virtual time if start is zero. scale is only used by ClockScaled.
+func (c *Clock) Set(mode string, start time.Time, scale float64) error {
+	switch mode {
+	case ClockReal, ClockFixed:
+		scale = 1
+	case ClockScaled:
+		if scale <= 0 {
+			return fmt.Errorf("scale must be positive")
+		}
+	default:
+		return fmt.Errorf("unknown clock mode %q", mode)
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	if start.IsZero() {
+		start = c.now()
+	}
+	c.mode = mode
+	c.scale = scale
+	c.base = start.UTC()
+	c.anchor = time.Now()
+	c.reschedule()
+	return nil
+}
+
+// Advance moves the clock forward by d, firing the timers it passes in the
+// order of their deadlines, each with the clock at its deadline. The functions
+// of the timers made by AfterFunc run before Advance returns, so that a timer
+// they make within d fires too: a miner mines a block for every block interval
+// passed, 360 blocks for an hour of 10s blocks.
+func (c *Clock) Advance(d time.Duration) error {
+	if d < 0 {
+		return fmt.Errorf("the clock can not go backwards")
+	}
+
+	c.advancing.Lock()
+	defer c.advancing.Unlock()
+
+	c.Lock()
+	defer c.Unlock()
+
+	target := c.now().Add(d)
+	for {
+		t := c.next(target)
+		if t == nil {
+			break
+		}
+		c.base = t.deadline
+		c.anchor = time.Now()
+		if f := c.expire(t); f != nil {
+			c.Unlock()
+			f()
+			c.Lock()
+		}
+	}
+
+	if c.now().Before(target) {
+		c.base = target
+		c.anchor = time.Now()
+	}
+	c.reschedule()
+	return nil
+}
+
+// NewTimer returns a timer that fires once d of virtual time has passed. A
+// timer on a fixed clock only fires when the clock is advanced.
+func (c *Clock) NewTimer(d time.Duration) *Timer {
+	ch := make(chan time.Time, 1)
+	return c.start(&Timer{C: ch, c: ch}, d)
+}
+
+// AfterFunc returns a timer that calls f in its own goroutine once d of
+// virtual time has passed, or before Advance returns if Advance passes it
+func (c *Clock) AfterFunc(d time.Duration, f func()) *Timer {
+	return c.start(&Timer{f: f}, d)
+}
+
+func (c *Clock) start(t *Timer, d time.Duration) *Timer {
+	t.clock = c
+
+	c.Lock()
+	defer c.Unlock()
+
+	t.deadline = c.now().Add(d)
+	c.timers[t] = struct{}{}
+	if f := c.schedule(t); f != nil {
+		go f()
+	}
+	return t
+}
+
+// Stop prevents the timer from firing, it returns false if it already fired
+func (t *Timer) Stop() bool {
+	c := t.clock
+	c.Lock()
+	defer c.Unlock()
+
+	if _, ok := c.timers[t]; !ok {
+		return false
+	}
+	delete(c.timers, t)
+	if t.wall != nil {
+		t.wall.Stop()
+	}
+	return true
+}
+
+func (c *Clock) rate() float64 {
+	switch c.mode {
+	case ClockFixed:
+		return 0
+	case ClockScaled:
+		return c.scale
+	default:
+		return 1
+	}
+}
+
+func (c *Clock) now() time.Time {
+	elapsed := float64(time.Since(c.anchor)) * c.rate()
+	return c.base.Add(time.Duration(elapsed)).UTC()
+}
+
+// next returns the timer with the earliest deadline not after target, if any
+func (c *Clock) next(target time.Time) *Timer {
+	var next *Timer
+	for t := range c.timers {
+		if !t.deadline.After(target) && (next == nil || t.deadline.Before(next.deadline)) {
+			next = t
+		}
+	}
+	return next
+}
+
+// expire removes t and sends the time on its channel. It returns the function
+// of a timer made by AfterFunc, to call once the clock is unlocked.
+func (c *Clock) expire(t *Timer) func() {
+	delete(c.timers, t)
+	if t.wall != nil {
+		t.wall.Stop()
+		t.wall = nil
+	}
+	if t.f != nil {
+		return t.f
+	}
+	select {
+	case t.c <- c.now():
+	default:
+	}
+	return nil
+}
+
+// schedule expires t if its deadline has passed, or arms a wall clock timer
+// for when it will. It returns the function to call for an expired timer, see
+// expire.
+func (c *Clock) schedule(t *Timer) func() {
+	if t.wall != nil {
+		t.wall.Stop()
+		t.wall = nil
+	}
+
+	now := c.now()
+	if !now.Before(t.deadline) {
+		return c.expire(t)
+	}
+
+	rate := c.rate()
+	if rate == 0 {
+		return nil
+	}
+	wait := time.Duration(float64(t.deadline.Sub(now)) / rate)
+	t.wall = time.AfterFunc(wait, func() {
+		c.Lock()
+		var f func()
+		if _, ok := c.timers[t]; ok {
+			f = c.schedule(t)
+		}
+		c.Unlock()
+		if f != nil {
+			f()
+		}
+	})
+	return nil
+}
+
+func (c *Clock) reschedule() {
+	for t := range c.timers {
+		if f := c.schedule(t); f != nil {
+			go f()
+		}
+	}
+}
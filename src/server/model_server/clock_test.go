@@ -0,0 +1,82 @@
+package model_server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClock(t *testing.T) {
+	c := NewClock()
+	require.Equal(t, ClockReal, c.Status().Mode)
+
+	start := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, c.Set(ClockFixed, start, 0))
+	time.Sleep(5 * time.Millisecond)
+	require.Equal(t, start, c.Now())
+
+	timer := c.NewTimer(time.Minute)
+	require.NoError(t, c.Advance(30*time.Second))
+	select {
+	case <-timer.C:
+		t.Fatal("timer fired before its deadline")
+	default:
+	}
+	require.NoError(t, c.Advance(30*time.Second))
+	require.Equal(t, start.Add(time.Minute), <-timer.C)
+	require.False(t, timer.Stop())
+	require.Error(t, c.Advance(-time.Second))
+
+	require.Error(t, c.Set(ClockScaled, start, 0))
+	require.Error(t, c.Set("lunar", start, 1))
+	require.NoError(t, c.Set(ClockScaled, start, 1000))
+	timer = c.NewTimer(time.Minute)
+	select {
+	case now := <-timer.C:
+		require.False(t, now.Before(start.Add(time.Minute)))
+	case <-time.After(time.Second):
+		t.Fatal("scaled timer did not fire")
+	}
+
+	require.NoError(t, c.Set(ClockFixed, time.Time{}, 0))
+	timer = c.NewTimer(time.Minute)
+	require.True(t, timer.Stop())
+	require.NoError(t, c.Advance(time.Hour))
+	select {
+	case <-timer.C:
+		t.Fatal("stopped timer fired")
+	default:
+	}
+}
+
+func TestMinerFollowsClock(t *testing.T) {
+	start := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, DefaultClock.Set(ClockFixed, start, 0))
+	defer DefaultClock.Set(ClockReal, time.Now(), 0)
+
+	p := &countingProvider{}
+	UseProviders(p)
+	defer delete(providers.byType, p.GetType())
+	defer StopMining()
+
+	require.NoError(t, StartMining("TEST", MinerConfig{BlockTime: time.Minute}))
+	time.Sleep(20 * time.Millisecond)
+	require.Equal(t, 0, p.count())
+
+	require.NoError(t, DefaultClock.Advance(time.Minute))
+	for i := 0; i < 100 && p.count() < 1; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	require.Equal(t, 1, p.count())
+	require.Equal(t, start.Add(time.Minute), MiningStatus()[0].LastBlock)
+
+	// every block interval an advance passes is mined, at its own time
+	require.NoError(t, DefaultClock.Advance(time.Hour))
+	require.Equal(t, 61, p.count())
+	require.Equal(t, start.Add(61*time.Minute), MiningStatus()[0].LastBlock)
+
+	require.NoError(t, PauseMining("TEST"))
+	require.NoError(t, DefaultClock.Advance(time.Hour))
+	require.Equal(t, 61, p.count())
+}
@@ -0,0 +1,31 @@
+package model_server
+
+// Transaction statuses reported by transaction lookups. An orphaned
+// transaction was mined in a block that a reorg later disconnected.
+const (
+	TxPending   = "pending"
+	TxConfirmed = "confirmed"
+	TxOrphaned  = "orphaned"
+)
+
+// Confirmations returns how many blocks deep a transaction mined at height is,
+// with the chain tip at tip. It is 0 for a transaction that is not mined.
+func Confirmations(tip, height int64) int64 {
+	if height <= 0 || height > tip {
+		return 0
+	}
+	return tip - height + 1
+}
+
+// TxStatus is where a transaction stands, in a form common to every coin.
+// Height and BlockHash are those of the block holding the transaction, or of
+// the block it was dropped from when it is orphaned. Time is the unix time of
+// the transaction as the coin reports it.
+type TxStatus struct {
+	TxID          string
+	Status        string
+	Confirmations int64
+	Height        int64
+	BlockHash     string
+	Time          int64
+}
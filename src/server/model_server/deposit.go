@@ -1,12 +1,218 @@
 package model_server
 
+import (
+	"fmt"
+	"strings"
+
+	"github.com/modeneis/coind/src/server/address"
+)
+
 // Deposit records information about a POST deposit
 type Deposit struct {
 	Address  string // deposit address
-	Value    int64  // deposit amount. For BTC, measured in satoshis.
+	Value    int64  // deposit amount. For SKY in coins, otherwise in base units.
+	Amount   string // decimal deposit amount in coins, such as "1.5". Takes precedence over Value.
 	Hours    uint64 // hours amount.
-	Height   int64  // the block height
-	Tx       string // the transaction id
-	N        uint32 // the index of vout in the tx [BTC]
+	Height   int64  // the height of the block to confirm the deposit in, the next block if 0
+	Tx       string // the transaction id, a random one if empty
+	N        uint32 // the index of the deposit output in the tx, for coins with outputs
 	CoinType string
+
+	AssetID    string // the asset paid by the deposit, the coin itself if empty [WAVES]
+	FeeAssetID string // the asset the fee is paid in, the coin itself if empty [WAVES]
+	Attachment string // a note attached to the transfer [WAVES]
+
+	ClientID string // identifies the deposit to the client, it is minted once per ClientID
+}
+
+// MaxAttachmentSize is the largest attachment of a deposit, in bytes
+const MaxAttachmentSize = 140
+
+// MaxOutputIndex is the largest N of a deposit, fake transactions hold at most
+// MaxOutputIndex+1 outputs
+const MaxOutputIndex = 255
+
+// accountCoins are the coins whose transactions pay a single recipient instead
+// of a list of outputs, so their deposits have no N
+var accountCoins = map[string]bool{
+	"WAVES": true,
+	"ETH":   true,
+}
+
+// assetCoins are the coins whose transfers may pay an asset and carry an
+// attachment
+var assetCoins = map[string]bool{
+	"WAVES": true,
+}
+
+// FieldError is an invalid field of the deposit at Index of a request
+type FieldError struct {
+	Index   int    `json:"index"`
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every invalid field of the deposits of a request
+type ValidationError struct {
+	Errors []FieldError `json:"errors"`
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, f := range e.Errors {
+		msgs = append(msgs, fmt.Sprintf("deposit %d %s: %s", f.Index, f.Field, f.Message))
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// ValidateDeposits checks the coin type, the address, the amount, the height,
+// the output index, the asset and the attachment of every deposit, before any
+// block is created. The amount of an asset deposit is checked against the
+// precision of the coin, providers check it against the asset's. coinType is
+// used for the deposits without one. It returns a *ValidationError.
+func ValidateDeposits(coinType string, deposits []Deposit) error {
+	var errs []FieldError
+	clientIDs := make(map[string]bool)
+	for i, d := range deposits {
+		invalid := func(field string, err error) {
+			errs = append(errs, FieldError{Index: i, Field: field, Message: err.Error()})
+		}
+
+		ct := d.CoinType
+		if ct == "" {
+			ct = coinType
+		}
+		if ct == "" {
+			invalid("cointype", fmt.Errorf("cointype is required"))
+		} else if _, err := GetProvider(ct); err != nil {
+			invalid("cointype", err)
+		}
+
+		if err := address.Validate(ct, d.Address); err != nil {
+			invalid("address", err)
+		}
+		switch {
+		case d.Amount != "" && d.Value != 0:
+			invalid("amount", fmt.Errorf("amount and value are exclusive"))
+		case d.Amount != "":
+			if _, err := d.Units(ct); err != nil {
+				invalid("amount", err)
+			}
+		default:
+			if _, err := d.Units(ct); err != nil {
+				invalid("value", err)
+			}
+		}
+
+		if d.Height < 0 {
+			invalid("height", fmt.Errorf("height must not be negative"))
+		}
+		if d.N != 0 && accountCoins[ct] {
+			invalid("n", fmt.Errorf("%s transactions have no outputs", ct))
+		} else if d.N > MaxOutputIndex {
+			invalid("n", fmt.Errorf("n must not be above %d", MaxOutputIndex))
+		}
+
+		if d.AssetID != "" && !assetCoins[ct] {
+			invalid("assetid", fmt.Errorf("%s has no assets", ct))
+		}
+		if d.FeeAssetID != "" && !assetCoins[ct] {
+			invalid("feeassetid", fmt.Errorf("%s has no assets", ct))
+		}
+		if d.Attachment != "" && !assetCoins[ct] {
+			invalid("attachment", fmt.Errorf("%s transactions have no attachment", ct))
+		} else if len(d.Attachment) > MaxAttachmentSize {
+			invalid("attachment", fmt.Errorf("attachment must not be above %d bytes", MaxAttachmentSize))
+		}
+
+		if d.ClientID != "" && clientIDs[d.ClientID] {
+			invalid("clientid", fmt.Errorf("clientid %s is used by another deposit", d.ClientID))
+		}
+		clientIDs[d.ClientID] = true
+	}
+
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+// ProcessDeposits validates deposits, then creates a block for each of them
+// and returns their receipts, along with the block of the last one. The
+// deposits of a submission with a key are minted once: a later submission of
+// the same deposits with the same key gets the receipts of the deposits
+// already minted back, and only mints the rest. A deposit with a ClientID is
+// likewise minted once. The block is nil when the last deposit is replayed.
+// The deposits minted are recorded in the DefaultLedger as submitted by
+// submitter.
+func ProcessDeposits(submitter, key string, deposits []Deposit) (receipts []Receipt, blocks interface{}, err error) {
+	if err = ValidateDeposits("", deposits); err != nil {
+		return nil, nil, err
+	}
+
+	var minted []Receipt
+	if key != "" {
+		var claimed bool
+		if minted, claimed, err = DefaultIdempotency.begin(IdempotencyKey, key, fingerprint(deposits)); err != nil {
+			return nil, nil, err
+		}
+		if claimed {
+			defer func() {
+				DefaultIdempotency.finish(IdempotencyKey, key, receipts)
+			}()
+		}
+	}
+
+	receipts = make([]Receipt, 0, len(deposits))
+	for i, deposit := range deposits {
+		if i < len(minted) {
+			receipts = append(receipts, minted[i])
+			blocks = nil
+			continue
+		}
+
+		var receipt Receipt
+		if receipt, blocks, err = processDeposit(submitter, deposit); err != nil {
+			return receipts, nil, err
+		}
+		receipts = append(receipts, receipt)
+	}
+	return receipts, blocks, nil
+}
+
+// processDeposit creates the block of deposit and records it, unless a deposit
+// with the same ClientID was minted already, whose receipt is returned instead
+func processDeposit(submitter string, deposit Deposit) (receipt Receipt, blocks interface{}, err error) {
+	provider, err := GetProvider(deposit.CoinType)
+	if err != nil {
+		err = fmt.Errorf("CoinType (%s) not supported for deposit %v", deposit.CoinType, deposit)
+		return receipt, nil, err
+	}
+	if deposit.ClientID == "" {
+		if receipt, blocks, err = provider.CreateFakeBlock(deposit); err == nil {
+			DefaultLedger.Record(submitter, receipt)
+		}
+		return receipt, blocks, err
+	}
+
+	minted, claimed, err := DefaultIdempotency.begin(ClientIDKey, deposit.ClientID, fingerprint([]Deposit{deposit}))
+	if err != nil {
+		return receipt, nil, err
+	}
+	if len(minted) > 0 {
+		DefaultIdempotency.finish(ClientIDKey, deposit.ClientID, minted)
+		return minted[0], nil, nil
+	}
+
+	receipt, blocks, err = provider.CreateFakeBlock(deposit)
+	var receipts []Receipt
+	if err == nil {
+		receipt.ClientID = deposit.ClientID
+		receipts = append(receipts, receipt)
+		DefaultLedger.Record(submitter, receipt)
+	}
+	if claimed {
+		DefaultIdempotency.finish(ClientIDKey, deposit.ClientID, receipts)
+	}
+	return receipt, blocks, err
 }
@@ -0,0 +1,32 @@
+package model_server
+
+import (
+	"fmt"
+)
+
+// DoubleSpend describes a transaction conflicting with TxID: it spends the same
+// inputs and pays Deposit instead. A pending TxID is evicted from the mempool,
+// and the conflicting transaction takes its place, or is mined right away if
+// Mine is set. A confirmed TxID is dropped by a reorg that replaces its block,
+// and every block after it, with a longer chain confirming the conflicting
+// transaction in its place.
+type DoubleSpend struct {
+	TxID    string  `json:"txid" yaml:"txid"`
+	Deposit Deposit `json:"deposit" yaml:"deposit"`
+	Mine    bool    `json:"mine" yaml:"mine"`
+}
+
+// Validate checks the double spend, and its deposit of coinType, before it is
+// looked up on a chain
+func (d DoubleSpend) Validate(coinType string) error {
+	if d.TxID == "" {
+		return fmt.Errorf("txid of the transaction to double spend is required")
+	}
+	if d.Deposit.Tx == d.TxID {
+		return fmt.Errorf("conflicting transaction must have a new txid")
+	}
+	if d.Deposit.Height != 0 {
+		return fmt.Errorf("the conflicting transaction takes the place of the original, it has no height")
+	}
+	return ValidateDeposits(coinType, []Deposit{d.Deposit})
+}
@@ -0,0 +1,145 @@
+package model_server
+
+import (
+	"sync"
+	"time"
+)
+
+// Event types published on the event bus
+const (
+	EventBlockConnected     = "block_connected"
+	EventBlockDisconnected  = "block_disconnected"
+	EventDepositAccepted    = "deposit_accepted"
+	EventDepositConfirmed   = "deposit_confirmed"
+	EventDepositDoubleSpent = "deposit_double_spent"
+	EventReset              = "reset"
+)
+
+// DefaultEventHistory is how many events are kept for clients resuming a stream
+const DefaultEventHistory = 1000
+
+// subscriberBuffer is how many events a subscriber may lag behind before it
+// gets dropped
+const subscriberBuffer = 256
+
+// Event describes something that happened on one of the fake chains
+type Event struct {
+	ID         int64     `json:"id"`
+	Type       string    `json:"type"`
+	CoinType   string    `json:"cointype"`
+	Time       time.Time `json:"time"`
+	Height     int64     `json:"height,omitempty"`
+	BlockHash  string    `json:"block_hash,omitempty"`
+	TxID       string    `json:"txid,omitempty"`
+	N          uint32    `json:"n,omitempty"`
+	Address    string    `json:"address,omitempty"`
+	Amount     int64     `json:"amount,omitempty"`
+	AssetID    string    `json:"asset_id,omitempty"`
+	ReplacedBy string    `json:"replaced_by,omitempty"`
+	Message    string    `json:"message,omitempty"`
+}
+
+// EventBus fans events out to subscribers and keeps a bounded history so
+// that subscribers can resume from the last event they saw
+type EventBus struct {
+	sync.Mutex
+	lastID      int64
+	history     []Event
+	maxHistory  int
+	subscribers map[*Subscription]struct{}
+}
+
+// Subscription receives the events published after it was created. C is
+// closed when the subscription is cancelled, or when the subscriber falls too
+// far behind, in which case it should resubscribe from its last event ID.
+type Subscription struct {
+	C   <-chan Event
+	c   chan Event
+	bus *EventBus
+}
+
+// NewEventBus creates an EventBus that remembers up to maxHistory events
+func NewEventBus(maxHistory int) *EventBus {
+	return &EventBus{
+		maxHistory:  maxHistory,
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish assigns an ID and a time to e and sends it to every subscriber
+func (b *EventBus) Publish(e Event) Event {
+	b.Lock()
+	defer b.Unlock()
+
+	b.lastID++
+	e.ID = b.lastID
+	if e.Time.IsZero() {
+		e.Time = Now()
+	}
+
+	b.history = append(b.history, e)
+	if len(b.history) > b.maxHistory {
+		b.history = b.history[len(b.history)-b.maxHistory:]
+	}
+
+	for sub := range b.subscribers {
+		select {
+		case sub.c <- e:
+		default:
+			b.drop(sub)
+		}
+	}
+
+	return e
+}
+
+// Subscribe returns the events published after lastID that are still in the
+// history, and a subscription to every event published from now on
+func (b *EventBus) Subscribe(lastID int64) ([]Event, *Subscription) {
+	b.Lock()
+	defer b.Unlock()
+
+	var backlog []Event
+	for _, e := range b.history {
+		if e.ID > lastID {
+			backlog = append(backlog, e)
+		}
+	}
+
+	c := make(chan Event, subscriberBuffer)
+	sub := &Subscription{C: c, c: c, bus: b}
+	b.subscribers[sub] = struct{}{}
+
+	return backlog, sub
+}
+
+// Cancel stops the subscription and closes its channel
+func (s *Subscription) Cancel() {
+	s.bus.Lock()
+	defer s.bus.Unlock()
+
+	s.bus.drop(s)
+}
+
+// drop removes a subscriber. Must be called with the bus locked.
+func (b *EventBus) drop(sub *Subscription) {
+	if _, ok := b.subscribers[sub]; ok {
+		delete(b.subscribers, sub)
+		close(sub.c)
+	}
+}
+
+var events = NewEventBus(DefaultEventHistory)
+
+// PublishEvent publishes e on the default event bus, and updates the default
+// ledger with it
+func PublishEvent(e Event) Event {
+	e = events.Publish(e)
+	DefaultLedger.apply(e)
+	return e
+}
+
+// SubscribeEvents subscribes to the default event bus
+func SubscribeEvents(lastID int64) ([]Event, *Subscription) {
+	return events.Subscribe(lastID)
+}
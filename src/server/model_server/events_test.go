@@ -0,0 +1,47 @@
+package model_server_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/modeneis/coind/src/server/model_server"
+)
+
+func TestEventBus(t *testing.T) {
+	bus := model_server.NewEventBus(3)
+
+	for i := 0; i < 5; i++ {
+		bus.Publish(model_server.Event{Type: model_server.EventBlockConnected, CoinType: "SKY", Height: int64(i)})
+	}
+
+	// only the last 3 events are kept
+	backlog, sub := bus.Subscribe(0)
+	require.Len(t, backlog, 3)
+	require.Equal(t, int64(3), backlog[0].ID)
+	require.False(t, backlog[0].Time.IsZero())
+
+	backlog, resumed := bus.Subscribe(4)
+	require.Len(t, backlog, 1)
+	require.Equal(t, int64(5), backlog[0].ID)
+
+	e := bus.Publish(model_server.Event{Type: model_server.EventReset, CoinType: "SKY"})
+	require.Equal(t, int64(6), e.ID)
+	require.Equal(t, e, <-sub.C)
+	require.Equal(t, e, <-resumed.C)
+
+	sub.Cancel()
+	_, ok := <-sub.C
+	require.False(t, ok)
+
+	// a subscriber that does not keep up gets dropped
+	for i := 0; i < 300; i++ {
+		bus.Publish(model_server.Event{Type: model_server.EventBlockConnected, CoinType: "SKY"})
+	}
+	received := 0
+	for range resumed.C {
+		received++
+	}
+	require.True(t, received < 300)
+	resumed.Cancel()
+}
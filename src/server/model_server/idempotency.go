@@ -0,0 +1,198 @@
+package model_server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultIdempotencyWindow is how long the receipts of a deposit submission
+// are kept for its replays
+const DefaultIdempotencyWindow = 24 * time.Hour
+
+// Kinds of the keys of an IdempotencyCache
+const (
+	IdempotencyKey = "idempotency key"
+	ClientIDKey    = "client id"
+)
+
+// IdempotencyError is returned when a key is reused for other deposits, or
+// while the submission that first used it is still in progress
+type IdempotencyError struct {
+	Kind   string
+	Key    string
+	Reason string
+}
+
+func (e *IdempotencyError) Error() string {
+	return fmt.Sprintf("%s %s %s", e.Kind, e.Key, e.Reason)
+}
+
+// idempotencyEntry holds the receipts of the deposits of a submission minted
+// so far
+type idempotencyEntry struct {
+	fingerprint string
+	receipts    []Receipt
+	expires     time.Time
+	inFlight    bool
+}
+
+// IdempotencyCache remembers the receipts of deposit submissions by key, so
+// that a retried submission gets the original receipts back instead of minting
+// the deposits again. Keys expire a window after their last submission, by the
+// DefaultClock.
+type IdempotencyCache struct {
+	sync.Mutex
+	window  time.Duration
+	entries map[string]*idempotencyEntry
+}
+
+// DefaultIdempotency is the cache of the deposit submissions of the API and
+// of the RPC server
+var DefaultIdempotency = NewIdempotencyCache(DefaultIdempotencyWindow)
+
+// NewIdempotencyCache creates a cache keeping keys for window, a window of 0
+// disables it
+func NewIdempotencyCache(window time.Duration) *IdempotencyCache {
+	return &IdempotencyCache{
+		window:  window,
+		entries: make(map[string]*idempotencyEntry),
+	}
+}
+
+// SetWindow changes how long keys are kept, from their next submission on
+func (c *IdempotencyCache) SetWindow(window time.Duration) error {
+	if window < 0 {
+		return fmt.Errorf("idempotency window must not be negative")
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	c.window = window
+	return nil
+}
+
+// Window returns how long keys are kept
+func (c *IdempotencyCache) Window() time.Duration {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.window
+}
+
+// Reset forgets every key
+func (c *IdempotencyCache) Reset() {
+	c.Lock()
+	defer c.Unlock()
+
+	c.entries = make(map[string]*idempotencyEntry)
+}
+
+// ResetCoin forgets the keys of the submissions that minted deposits of
+// coinType, which are gone with its chain
+func (c *IdempotencyCache) ResetCoin(coinType string) {
+	c.Lock()
+	defer c.Unlock()
+
+	for k, entry := range c.entries {
+		for _, receipt := range entry.receipts {
+			if receipt.CoinType == coinType {
+				delete(c.entries, k)
+				break
+			}
+		}
+	}
+}
+
+// snapshot returns a copy of the keys of the finished submissions
+func (c *IdempotencyCache) snapshot() map[string]idempotencyEntry {
+	c.Lock()
+	defer c.Unlock()
+
+	entries := make(map[string]idempotencyEntry, len(c.entries))
+	for k, entry := range c.entries {
+		if !entry.inFlight {
+			entries[k] = *entry
+		}
+	}
+	return entries
+}
+
+// restore replaces the keys with the ones of a snapshot
+func (c *IdempotencyCache) restore(entries map[string]idempotencyEntry) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.entries = make(map[string]*idempotencyEntry, len(entries))
+	for k, entry := range entries {
+		entry := entry
+		c.entries[k] = &entry
+	}
+}
+
+// begin claims the key of the given kind for a submission of the deposits
+// with the given fingerprint, and returns the receipts of the deposits a
+// previous submission already minted. It fails if the key was used for other
+// deposits or is claimed by a submission in progress. A disabled cache claims
+// nothing.
+func (c *IdempotencyCache) begin(kind, key, fingerprint string) ([]Receipt, bool, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.window == 0 {
+		return nil, false, nil
+	}
+
+	now := Now()
+	for k, entry := range c.entries {
+		if !entry.inFlight && now.After(entry.expires) {
+			delete(c.entries, k)
+		}
+	}
+
+	entry, ok := c.entries[kind+"/"+key]
+	if !ok {
+		c.entries[kind+"/"+key] = &idempotencyEntry{fingerprint: fingerprint, inFlight: true}
+		return nil, true, nil
+	}
+	if entry.fingerprint != fingerprint {
+		return nil, false, &IdempotencyError{Kind: kind, Key: key, Reason: "was used for other deposits"}
+	}
+	if entry.inFlight {
+		return nil, false, &IdempotencyError{Kind: kind, Key: key, Reason: "is in use by a submission in progress"}
+	}
+
+	entry.inFlight = true
+	return append([]Receipt(nil), entry.receipts...), true, nil
+}
+
+// finish releases the key claimed by begin, recording the receipts of the
+// deposits minted so far. A key with no receipts is forgotten.
+func (c *IdempotencyCache) finish(kind, key string, receipts []Receipt) {
+	c.Lock()
+	defer c.Unlock()
+
+	entry, ok := c.entries[kind+"/"+key]
+	if !ok {
+		return
+	}
+	if len(receipts) == 0 {
+		delete(c.entries, kind+"/"+key)
+		return
+	}
+
+	entry.receipts = append([]Receipt(nil), receipts...)
+	entry.expires = Now().Add(c.window)
+	entry.inFlight = false
+}
+
+// fingerprint identifies a submission of deposits
+func fingerprint(deposits []Deposit) string {
+	raw, _ := json.Marshal(deposits)
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,415 @@
+package model_server
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LedgerColumns are the columns of the CSV export of the ledger
+var LedgerColumns = []string{
+	"id", "time", "submitter", "coin_type", "txid", "n", "address", "amount",
+	"asset_id", "client_id", "status", "height", "block_hash", "updated",
+}
+
+// LedgerEntry records a deposit minted by coind: when and by whom it was
+// submitted, its receipt, and its status on the chain, which follows the
+// blocks connected and disconnected afterwards. A deposit is orphaned when
+// its block is disconnected and when it is double spent. Resetting its chain
+// removes it from the ledger, restoring a snapshot brings back the ledger as
+// it was when the snapshot was taken.
+type LedgerEntry struct {
+	ID        int64     `json:"id"`
+	Time      time.Time `json:"time"`
+	Submitter string    `json:"submitter"`
+	Receipt   Receipt   `json:"receipt"`
+	Status    string    `json:"status"`
+	Height    int64     `json:"height"`
+	BlockHash string    `json:"block_hash,omitempty"`
+	Updated   time.Time `json:"updated"`
+}
+
+// LedgerQuery selects the entries of a ledger by the coin and the address of
+// their deposit and by the time they were recorded, From included and To
+// excluded. Empty fields select every entry.
+type LedgerQuery struct {
+	CoinType string
+	Address  string
+	From     time.Time
+	To       time.Time
+}
+
+// depositState is where a deposit stands on its chain
+type depositState struct {
+	status    string
+	height    int64
+	blockHash string
+}
+
+// Ledger records the deposits minted by coind, see LedgerEntry. Entries are
+// kept in memory and, once the ledger is opened, appended to a file of JSON
+// lines, a line per change of an entry, which the next Open reloads.
+type Ledger struct {
+	sync.Mutex
+	entries []*LedgerEntry
+	byTx    map[string]*LedgerEntry  // coin type/txid -> entry recorded by this process
+	chain   map[string]*depositState // coin type/txid -> state of every deposit seen
+	file    *os.File
+}
+
+// DefaultLedger records the deposits of nextdeposit, of the load generator and
+// of the scenarios. It follows the default event bus.
+var DefaultLedger = NewLedger()
+
+// NewLedger creates an empty Ledger, kept in memory only until it is opened
+func NewLedger() *Ledger {
+	return &Ledger{
+		byTx:  make(map[string]*LedgerEntry),
+		chain: make(map[string]*depositState),
+	}
+}
+
+// Open loads the entries of the ledger file at path, replacing the entries in
+// memory, then appends every change to it. The file is created if need be.
+// Loaded entries keep their last status, the chains they were on are gone.
+func (l *Ledger) Open(path string) error {
+	l.Lock()
+	defer l.Unlock()
+
+	entries, err := readLedger(path)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+
+	if l.file != nil {
+		l.file.Close()
+	}
+	l.file = f
+	l.entries = entries
+	l.byTx = make(map[string]*LedgerEntry)
+	return nil
+}
+
+// Close closes the ledger file, the ledger is kept in memory only afterwards
+func (l *Ledger) Close() error {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// readLedger returns the entries of the ledger file at path, in the order
+// they were recorded, or nothing if there is no such file
+func readLedger(path string) ([]*LedgerEntry, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []*LedgerEntry
+	byID := make(map[int64]*LedgerEntry)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry LedgerEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("invalid ledger entry at %s:%d: %v", path, line, err)
+		}
+		if e, ok := byID[entry.ID]; ok {
+			*e = entry
+			continue
+		}
+		byID[entry.ID] = &entry
+		entries = append(entries, &entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Record adds the deposit of receipt, submitted by submitter, to the ledger.
+// Its status is the latest one of the deposit on its chain.
+func (l *Ledger) Record(submitter string, receipt Receipt) LedgerEntry {
+	l.Lock()
+	defer l.Unlock()
+
+	now := Now()
+	entry := &LedgerEntry{
+		Time:      now,
+		Submitter: submitter,
+		Receipt:   receipt,
+		Status:    receipt.Status,
+		Height:    receipt.Height,
+		BlockHash: receipt.BlockHash,
+		Updated:   now,
+	}
+	if len(l.entries) > 0 {
+		entry.ID = l.entries[len(l.entries)-1].ID
+	}
+	entry.ID++
+
+	key := receipt.CoinType + "/" + receipt.TxID
+	if state, ok := l.chain[key]; ok {
+		entry.Status = state.status
+		entry.Height = state.height
+		entry.BlockHash = state.blockHash
+	}
+	l.entries = append(l.entries, entry)
+	l.byTx[key] = entry
+	l.write(entry)
+	return *entry
+}
+
+// Reset removes the entries of the deposits of coinType, or every entry if
+// coinType is empty, and rewrites the ledger file with the remaining ones
+func (l *Ledger) Reset(coinType string) {
+	l.Lock()
+	defer l.Unlock()
+
+	entries := l.entries[:0]
+	for _, entry := range l.entries {
+		if coinType != "" && entry.Receipt.CoinType != coinType {
+			entries = append(entries, entry)
+		}
+	}
+	l.entries = entries
+	for key := range l.chain {
+		if coinType == "" || strings.HasPrefix(key, coinType+"/") {
+			delete(l.chain, key)
+			delete(l.byTx, key)
+		}
+	}
+	l.rewrite()
+}
+
+// ledgerSnapshot is a copy of the entries of a ledger and of the state of the
+// deposits on their chains
+type ledgerSnapshot struct {
+	entries []LedgerEntry
+	byTx    map[string]int64 // coin type/txid -> id of the entry
+	chain   map[string]depositState
+}
+
+// snapshot returns a copy of the ledger
+func (l *Ledger) snapshot() ledgerSnapshot {
+	l.Lock()
+	defer l.Unlock()
+
+	s := ledgerSnapshot{
+		entries: make([]LedgerEntry, len(l.entries)),
+		byTx:    make(map[string]int64, len(l.byTx)),
+		chain:   make(map[string]depositState, len(l.chain)),
+	}
+	for i, entry := range l.entries {
+		s.entries[i] = *entry
+	}
+	for key, entry := range l.byTx {
+		s.byTx[key] = entry.ID
+	}
+	for key, state := range l.chain {
+		s.chain[key] = *state
+	}
+	return s
+}
+
+// restore replaces the ledger with a snapshot and rewrites the ledger file
+func (l *Ledger) restore(s ledgerSnapshot) {
+	l.Lock()
+	defer l.Unlock()
+
+	l.entries = make([]*LedgerEntry, len(s.entries))
+	byID := make(map[int64]*LedgerEntry, len(s.entries))
+	for i := range s.entries {
+		entry := s.entries[i]
+		l.entries[i] = &entry
+		byID[entry.ID] = &entry
+	}
+	l.byTx = make(map[string]*LedgerEntry, len(s.byTx))
+	for key, id := range s.byTx {
+		l.byTx[key] = byID[id]
+	}
+	l.chain = make(map[string]*depositState, len(s.chain))
+	for key, state := range s.chain {
+		state := state
+		l.chain[key] = &state
+	}
+	l.rewrite()
+}
+
+// Entries returns the entries selected by q, in the order they were recorded
+func (l *Ledger) Entries(q LedgerQuery) []LedgerEntry {
+	l.Lock()
+	defer l.Unlock()
+
+	entries := []LedgerEntry{}
+	for _, entry := range l.entries {
+		switch {
+		case q.CoinType != "" && entry.Receipt.CoinType != q.CoinType,
+			q.Address != "" && entry.Receipt.Address != q.Address,
+			!q.From.IsZero() && entry.Time.Before(q.From),
+			!q.To.IsZero() && !entry.Time.Before(q.To):
+			continue
+		}
+		entries = append(entries, *entry)
+	}
+	return entries
+}
+
+// apply updates the state of the deposits with a chain event
+func (l *Ledger) apply(e Event) {
+	l.Lock()
+	defer l.Unlock()
+
+	key := e.CoinType + "/" + e.TxID
+	switch e.Type {
+	case EventDepositAccepted:
+		l.update(key, depositState{status: TxPending})
+
+	case EventDepositConfirmed:
+		l.update(key, depositState{status: TxConfirmed, height: e.Height, blockHash: e.BlockHash})
+
+	case EventDepositDoubleSpent:
+		if state, ok := l.chain[key]; ok {
+			l.update(key, depositState{status: TxOrphaned, height: state.height, blockHash: state.blockHash})
+		}
+
+	case EventBlockDisconnected:
+		for key, state := range l.chain {
+			if state.status == TxConfirmed && state.height >= e.Height && strings.HasPrefix(key, e.CoinType+"/") {
+				l.update(key, depositState{status: TxOrphaned, height: state.height, blockHash: state.blockHash})
+			}
+		}
+
+	case EventReset:
+		for key, state := range l.chain {
+			if !strings.HasPrefix(key, e.CoinType+"/") {
+				continue
+			}
+			if state.status != TxOrphaned {
+				l.update(key, depositState{status: TxOrphaned, height: state.height, blockHash: state.blockHash})
+			}
+			delete(l.chain, key)
+			delete(l.byTx, key)
+		}
+	}
+}
+
+// update sets the state of the deposit of key, and the status of its entry.
+// Must be called with the ledger locked.
+func (l *Ledger) update(key string, state depositState) {
+	s, ok := l.chain[key]
+	if !ok {
+		s = &depositState{}
+		l.chain[key] = s
+	}
+	*s = state
+
+	entry, ok := l.byTx[key]
+	if !ok || (entry.Status == state.status && entry.Height == state.height && entry.BlockHash == state.blockHash) {
+		return
+	}
+	entry.Status = state.status
+	entry.Height = state.height
+	entry.BlockHash = state.blockHash
+	entry.Updated = Now()
+	l.write(entry)
+}
+
+// write appends entry to the ledger file, if it is open. Must be called with
+// the ledger locked.
+func (l *Ledger) write(entry *LedgerEntry) {
+	if l.file == nil {
+		return
+	}
+	line, err := json.Marshal(entry)
+	if err == nil {
+		_, err = l.file.Write(append(line, '\n'))
+	}
+	if err != nil {
+		log.Printf("failed to append ledger entry %d: %v", entry.ID, err)
+	}
+}
+
+// rewrite replaces the content of the ledger file, if it is open, with the
+// entries in memory. Must be called with the ledger locked.
+func (l *Ledger) rewrite() {
+	if l.file == nil {
+		return
+	}
+	if err := l.file.Truncate(0); err != nil {
+		log.Printf("failed to truncate the ledger file: %v", err)
+		return
+	}
+	for _, entry := range l.entries {
+		l.write(entry)
+	}
+}
+
+// WriteLedgerJSONL writes entries as JSON lines, an entry per line
+func WriteLedgerJSONL(w io.Writer, entries []LedgerEntry) error {
+	encoder := json.NewEncoder(w)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteLedgerCSV writes entries as CSV with a header of LedgerColumns, times
+// being in RFC3339
+func WriteLedgerCSV(w io.Writer, entries []LedgerEntry) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(LedgerColumns); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		r := entry.Receipt
+		record := []string{
+			strconv.FormatInt(entry.ID, 10),
+			entry.Time.UTC().Format(time.RFC3339Nano),
+			entry.Submitter,
+			r.CoinType,
+			r.TxID,
+			strconv.FormatUint(uint64(r.N), 10),
+			r.Address,
+			r.Amount,
+			r.AssetID,
+			r.ClientID,
+			entry.Status,
+			strconv.FormatInt(entry.Height, 10),
+			entry.BlockHash,
+			entry.Updated.UTC().Format(time.RFC3339Nano),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
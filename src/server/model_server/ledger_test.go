@@ -0,0 +1,58 @@
+package model_server_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/modeneis/coind/src/server/model_server"
+)
+
+func TestLedgerPersistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ledger")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "ledger.jsonl")
+
+	ledger := model_server.NewLedger()
+	require.NoError(t, ledger.Open(path))
+	first := ledger.Record("teller", model_server.Receipt{CoinType: "SKY", TxID: "a", Address: "x", Amount: "1", Status: model_server.TxConfirmed, Height: 1})
+	second := ledger.Record("loadgen", model_server.Receipt{CoinType: "WAVES", TxID: "b", Address: "y", Amount: "2", Status: model_server.TxPending})
+	require.Equal(t, int64(1), first.ID)
+	require.Equal(t, int64(2), second.ID)
+	require.NoError(t, ledger.Close())
+
+	reopened := model_server.NewLedger()
+	require.NoError(t, reopened.Open(path))
+	defer reopened.Close()
+	entries := reopened.Entries(model_server.LedgerQuery{})
+	require.Len(t, entries, 2)
+	require.Equal(t, first.Receipt, entries[0].Receipt)
+	require.Equal(t, "loadgen", entries[1].Submitter)
+	require.True(t, second.Time.Equal(entries[1].Time))
+
+	// new entries follow the reloaded ones
+	third := reopened.Record("teller", model_server.Receipt{CoinType: "SKY", TxID: "c", Address: "x", Amount: "3"})
+	require.Equal(t, int64(3), third.ID)
+
+	require.Len(t, reopened.Entries(model_server.LedgerQuery{CoinType: "SKY"}), 2)
+	require.Len(t, reopened.Entries(model_server.LedgerQuery{Address: "y"}), 1)
+	require.Len(t, reopened.Entries(model_server.LedgerQuery{To: first.Time}), 0)
+	require.Len(t, reopened.Entries(model_server.LedgerQuery{From: first.Time, To: first.Time.Add(time.Hour)}), 3)
+
+	var buf bytes.Buffer
+	require.NoError(t, model_server.WriteLedgerCSV(&buf, entries[:1]))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	require.Equal(t, strings.Join(model_server.LedgerColumns, ","), lines[0])
+	require.True(t, strings.HasPrefix(lines[1], "1,"))
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("{not json\n"), 0644))
+	require.Error(t, model_server.NewLedger().Open(path))
+}
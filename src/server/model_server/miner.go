@@ -0,0 +1,280 @@
+package model_server
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultBlockTimes is the block interval of each coin, close to the real
+// chains
+var DefaultBlockTimes = map[string]time.Duration{
+	"BTC":   600 * time.Second,
+	"ETH":   15 * time.Second,
+	"SKY":   10 * time.Second,
+	"WAVES": 60 * time.Second,
+}
+
+// DefaultBlockJitter is how much a block interval may vary, as a fraction of
+// the block time
+const DefaultBlockJitter = 0.2
+
+// MinerConfig configures the block production of a coin. The wait between two
+// blocks is BlockTime divided by Scale, plus or minus Jitter of it, measured
+// on the DefaultClock.
+type MinerConfig struct {
+	BlockTime time.Duration
+	Jitter    float64
+	Scale     float64
+	Paused    bool
+}
+
+// MinerStatus describes the block production of a coin
+type MinerStatus struct {
+	CoinType  string    `json:"cointype"`
+	BlockTime string    `json:"block_time"`
+	Jitter    float64   `json:"jitter"`
+	Scale     float64   `json:"scale"`
+	Paused    bool      `json:"paused"`
+	Blocks    int64     `json:"blocks"`
+	LastBlock time.Time `json:"last_block,omitempty"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// miner mines a block on a provider at every block interval, with a timer of
+// the DefaultClock armed for the next one
+type miner struct {
+	sync.Mutex
+	coinType  string
+	config    MinerConfig
+	blocks    int64
+	lastBlock time.Time
+	lastError string
+	rand      *rand.Rand
+	timer     *Timer
+	armed     int64 // counts the timers armed, a timer that is not the last one is stale
+	stopped   bool
+}
+
+var miners = struct {
+	sync.Mutex
+	byCoin map[string]*miner
+}{
+	byCoin: make(map[string]*miner),
+}
+
+// DefaultMinerConfig returns the configuration of a coin that was not given one
+func DefaultMinerConfig(coinType string) MinerConfig {
+	blockTime, ok := DefaultBlockTimes[coinType]
+	if !ok {
+		blockTime = time.Minute
+	}
+	return MinerConfig{
+		BlockTime: blockTime,
+		Jitter:    DefaultBlockJitter,
+		Scale:     1,
+	}
+}
+
+// StartMining starts, or reconfigures, the block production of coinType
+func StartMining(coinType string, config MinerConfig) error {
+	if _, err := GetProvider(coinType); err != nil {
+		return err
+	}
+	if config.BlockTime <= 0 {
+		return fmt.Errorf("block time must be positive")
+	}
+	if config.Jitter < 0 || config.Jitter >= 1 {
+		return fmt.Errorf("jitter must be between 0 and 1")
+	}
+	if config.Scale == 0 {
+		config.Scale = 1
+	}
+	if config.Scale < 0 {
+		return fmt.Errorf("scale must be positive")
+	}
+
+	miners.Lock()
+	defer miners.Unlock()
+
+	m, ok := miners.byCoin[coinType]
+	if !ok {
+		m = &miner{
+			coinType: coinType,
+			rand:     rand.New(rand.NewSource(RandomInt63())),
+		}
+		miners.byCoin[coinType] = m
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	m.config = config
+	m.arm()
+	return nil
+}
+
+// PauseMining stops the block production of coinType, or of every coin if
+// coinType is empty
+func PauseMining(coinType string) error {
+	return setPaused(coinType, true)
+}
+
+// ResumeMining restarts the block production of coinType, or of every coin if
+// coinType is empty. A coin that was never started is started with its
+// default configuration.
+func ResumeMining(coinType string) error {
+	if coinType != "" {
+		miners.Lock()
+		_, ok := miners.byCoin[coinType]
+		miners.Unlock()
+		if !ok {
+			return StartMining(coinType, DefaultMinerConfig(coinType))
+		}
+	}
+	return setPaused(coinType, false)
+}
+
+func setPaused(coinType string, paused bool) error {
+	miners.Lock()
+	defer miners.Unlock()
+
+	for coin, m := range miners.byCoin {
+		if coinType != "" && coin != coinType {
+			continue
+		}
+		m.Lock()
+		m.config.Paused = paused
+		m.arm()
+		m.Unlock()
+	}
+
+	if _, ok := miners.byCoin[coinType]; coinType != "" && !ok {
+		return fmt.Errorf("no miner for %s", coinType)
+	}
+	return nil
+}
+
+// StopMining stops every miner
+func StopMining() {
+	miners.Lock()
+	defer miners.Unlock()
+
+	for coinType, m := range miners.byCoin {
+		m.Lock()
+		m.stopped = true
+		m.arm()
+		m.Unlock()
+		delete(miners.byCoin, coinType)
+	}
+}
+
+// MiningStatus describes the block production of every coin, sorted by coin type
+func MiningStatus() []MinerStatus {
+	miners.Lock()
+	defer miners.Unlock()
+
+	status := make([]MinerStatus, 0, len(miners.byCoin))
+	for _, m := range miners.byCoin {
+		status = append(status, m.status())
+	}
+	sort.Slice(status, func(i, j int) bool {
+		return status[i].CoinType < status[j].CoinType
+	})
+	return status
+}
+
+// MineBlocks mines n blocks on coinType right away, each confirming the
+// pending mempool transactions, and returns the last one
+func MineBlocks(coinType string, n int) (interface{}, error) {
+	provider, err := GetProvider(coinType)
+	if err != nil {
+		return nil, err
+	}
+	if n <= 0 {
+		return nil, fmt.Errorf("number of blocks must be positive")
+	}
+
+	var blocks interface{}
+	for i := 0; i < n; i++ {
+		if blocks, err = provider.MineBlock(); err != nil {
+			return nil, err
+		}
+	}
+	return blocks, nil
+}
+
+func (m *miner) status() MinerStatus {
+	m.Lock()
+	defer m.Unlock()
+
+	return MinerStatus{
+		CoinType:  m.coinType,
+		BlockTime: m.config.BlockTime.String(),
+		Jitter:    m.config.Jitter,
+		Scale:     m.config.Scale,
+		Paused:    m.config.Paused,
+		Blocks:    m.blocks,
+		LastBlock: m.lastBlock,
+		LastError: m.lastError,
+	}
+}
+
+// arm replaces the timer of the miner with one for the next block, unless it
+// is paused or stopped. Must be called with the miner locked.
+func (m *miner) arm() {
+	if m.timer != nil {
+		m.timer.Stop()
+		m.timer = nil
+	}
+	m.armed++
+	if m.stopped || m.config.Paused {
+		return
+	}
+
+	wait := float64(m.config.BlockTime) / m.config.Scale
+	wait += wait * m.config.Jitter * (2*m.rand.Float64() - 1)
+	armed := m.armed
+	m.timer = DefaultClock.AfterFunc(time.Duration(wait), func() {
+		m.tick(armed)
+	})
+}
+
+// tick mines the block of the timer armed, then arms the next one. The timer
+// of an earlier configuration may fire as it is replaced, it mines nothing.
+func (m *miner) tick(armed int64) {
+	m.Lock()
+	stale := armed != m.armed
+	m.Unlock()
+	if stale {
+		return
+	}
+
+	m.mine()
+
+	m.Lock()
+	defer m.Unlock()
+	if armed == m.armed {
+		m.arm()
+	}
+}
+
+func (m *miner) mine() {
+	provider, err := GetProvider(m.coinType)
+	if err == nil {
+		_, err = provider.MineBlock()
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	if err != nil {
+		m.lastError = err.Error()
+		return
+	}
+	m.blocks++
+	m.lastBlock = Now()
+	m.lastError = ""
+}
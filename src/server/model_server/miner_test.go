@@ -0,0 +1,67 @@
+package model_server
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type countingProvider struct {
+	Provider
+	sync.Mutex
+	mined int
+}
+
+func (p *countingProvider) GetType() string { return "TEST" }
+
+func (p *countingProvider) MineBlock() (interface{}, error) {
+	p.Lock()
+	defer p.Unlock()
+	p.mined++
+	return p.mined, nil
+}
+
+func (p *countingProvider) count() int {
+	p.Lock()
+	defer p.Unlock()
+	return p.mined
+}
+
+func TestMiner(t *testing.T) {
+	p := &countingProvider{}
+	UseProviders(p)
+	defer delete(providers.byType, p.GetType())
+	defer StopMining()
+
+	require.Error(t, StartMining("TEST", MinerConfig{}))
+	require.Error(t, StartMining("TEST", MinerConfig{BlockTime: time.Second, Jitter: 1}))
+	require.Error(t, StartMining("NONE", DefaultMinerConfig("NONE")))
+
+	require.NoError(t, StartMining("TEST", MinerConfig{BlockTime: time.Second, Scale: 100}))
+	for i := 0; i < 100 && p.count() < 3; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.True(t, p.count() >= 3)
+
+	require.NoError(t, PauseMining("TEST"))
+	time.Sleep(20 * time.Millisecond)
+	paused := p.count()
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, paused, p.count())
+
+	status := MiningStatus()
+	require.Len(t, status, 1)
+	require.True(t, status[0].Paused)
+	require.Equal(t, int64(paused), status[0].Blocks)
+
+	last, err := MineBlocks("TEST", 2)
+	require.NoError(t, err)
+	require.Equal(t, paused+2, last)
+	_, err = MineBlocks("TEST", 0)
+	require.Error(t, err)
+
+	require.NoError(t, ResumeMining(""))
+	require.Error(t, PauseMining("NONE"))
+}
@@ -2,39 +2,74 @@ package model_server
 
 import (
 	"fmt"
+	"sync"
 )
 
 // Provider needs to be implemented for each 3rd party provider
 type Provider interface {
 	Name() string
 	GetType() string
-	CreateFakeBlock(deposit Deposit) (blocks interface{}, err error)
+	CreateFakeBlock(deposit Deposit) (receipt Receipt, blocks interface{}, err error)
+	AddToMempool(deposit Deposit) (tx interface{}, err error)
+	MineBlock() (blocks interface{}, err error)
+	Reorg(reorg Reorg) (tip interface{}, err error)
+	DoubleSpend(doubleSpend DoubleSpend) (tx interface{}, err error)
 	GetBlock(hash string) (block interface{}, err error)
 	GetBestBlock(seq int64) (block interface{}, err error)
 	GetGetBlockHash(tx string) (block interface{}, err error)
+	GetTransaction(txid string) (tx interface{}, err error)
+	GetTransactionStatus(txid string) (status TxStatus, err error)
 	GetBlockCount() (count int32)
+	GetBalance(address string) (balance interface{}, err error)
+	GetOutputs(address string) (outputs interface{}, err error)
+	GetAddressTransactions(address string, offset, limit int) (txs interface{}, err error)
+	IssueAsset(asset Asset) (details interface{}, err error)
+	GetAsset(assetID string) (details interface{}, err error)
+	GetAssetBalances(address, assetID string) (balances interface{}, err error)
+	SendToAddress(withdrawal Withdrawal) (tx interface{}, err error)
+	SendRawTransaction(rawTx string) (tx interface{}, err error)
+	Reset()
+	Snapshot() (snapshot interface{})
+	Restore(snapshot interface{}) error
 }
 
 // Providers is list of known/available providers.
 type Providers map[string]Provider
 
-var providers = Providers{}
+var providers = struct {
+	sync.RWMutex
+	byType Providers
+}{
+	byType: Providers{},
+}
 
 // UseProviders sets a list of available providers
 func UseProviders(p ...Provider) {
+	providers.Lock()
+	defer providers.Unlock()
+
 	for _, provider := range p {
-		providers[provider.GetType()] = provider
+		providers.byType[provider.GetType()] = provider
 	}
 }
 
 // GetProviders returns a list of all the providers currently in use.
 func GetProviders() Providers {
-	return providers
+	providers.RLock()
+	defer providers.RUnlock()
+
+	inUse := make(Providers, len(providers.byType))
+	for name, provider := range providers.byType {
+		inUse[name] = provider
+	}
+	return inUse
 }
 
 // GetProvider returns a previously created provider.
 func GetProvider(name string) (Provider, error) {
-	provider := providers[name]
+	providers.RLock()
+	provider := providers.byType[name]
+	providers.RUnlock()
 	if provider == nil {
 		return nil, fmt.Errorf("no provider for %s exists", name)
 	}
@@ -43,5 +78,8 @@ func GetProvider(name string) (Provider, error) {
 
 // ClearProviders will remove all providers currently in use.
 func ClearProviders() {
-	providers = Providers{}
+	providers.Lock()
+	defer providers.Unlock()
+
+	providers.byType = Providers{}
 }
@@ -0,0 +1,19 @@
+package model_server
+
+// Receipt describes where a deposit landed: the transaction paying it, the
+// index of its output and the block confirming it, if any. Amount is the
+// canonical decimal amount paid, in coins or in units of AssetID. Timestamp is
+// the unix time of the block, or of the transaction while it is pending.
+type Receipt struct {
+	CoinType  string `json:"coin_type"`
+	TxID      string `json:"txid"`
+	N         uint32 `json:"n"`
+	Address   string `json:"address"`
+	Amount    string `json:"amount"`
+	AssetID   string `json:"asset_id,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Status    string `json:"status"`
+	BlockHash string `json:"block_hash,omitempty"`
+	Height    int64  `json:"height"`
+	Timestamp int64  `json:"timestamp"`
+}
@@ -0,0 +1,39 @@
+package model_server
+
+import (
+	"fmt"
+)
+
+// Reorg describes a chain reorganization: the last Depth blocks are
+// disconnected and replaced by Blocks, each holding the deposits it confirms.
+// A replacement deposit that reuses the Tx of a disconnected one moves, or
+// alters, that deposit. Disconnected deposits left out of Blocks are dropped.
+type Reorg struct {
+	Depth  int         `json:"depth" yaml:"depth"`
+	Blocks [][]Deposit `json:"blocks" yaml:"blocks"`
+}
+
+// Validate checks the reorg, and its deposits of coinType, against a chain
+// whose tip is at height
+func (r Reorg) Validate(coinType string, height int64) error {
+	if r.Depth < 0 {
+		return fmt.Errorf("reorg depth must not be negative")
+	}
+	if int64(r.Depth) > height {
+		return fmt.Errorf("reorg depth %d is deeper than the chain height %d", r.Depth, height)
+	}
+	if r.Depth == 0 && len(r.Blocks) == 0 {
+		return fmt.Errorf("reorg changes nothing")
+	}
+	for _, block := range r.Blocks {
+		if err := ValidateDeposits(coinType, block); err != nil {
+			return err
+		}
+		for _, d := range block {
+			if d.Height != 0 {
+				return fmt.Errorf("the height of a reorg deposit is the height of its block")
+			}
+		}
+	}
+	return nil
+}
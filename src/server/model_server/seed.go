@@ -0,0 +1,107 @@
+package model_server
+
+import (
+	"crypto/rand"
+	mrand "math/rand"
+	"sync"
+	"time"
+)
+
+// SeedEpoch is where the clock starts in seeded mode, unless told otherwise
+var SeedEpoch = time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// random holds the sources of the generated data. The chain data, hashes,
+// transaction ids and signatures, is drawn from chain and the auxiliary ids,
+// those of webhooks, deliveries, faults, scenario runs and wallets, from ids,
+// so that creating a webhook does not change the chains that follow. Both read
+// crypto/rand until Seed is called.
+var random = struct {
+	sync.Mutex
+	seeded bool
+	chain  *mrand.Rand
+	ids    *mrand.Rand
+}{}
+
+// Seed makes every generated hash, id and signature derive from seed, so the
+// same sequence of calls yields byte-identical chains across runs
+func Seed(seed int64) {
+	random.Lock()
+	defer random.Unlock()
+
+	random.seeded = true
+	random.chain = mrand.New(mrand.NewSource(seed))
+	random.ids = mrand.New(mrand.NewSource(random.chain.Int63()))
+}
+
+// Seeded tells whether Seed was called. Providers then build their fake blocks
+// out of fixed templates instead of the live upstream ones.
+func Seeded() bool {
+	random.Lock()
+	defer random.Unlock()
+	return random.seeded
+}
+
+// RandomBytes fills b with chain data from the seeded source, or from
+// crypto/rand when not seeded
+func RandomBytes(b []byte) {
+	random.Lock()
+	defer random.Unlock()
+
+	read(random.chain, b)
+}
+
+// RandomInt63 returns a number from the seeded source of the chain data, or
+// from crypto/rand when not seeded, to seed the per coin sources such as the
+// miners'
+func RandomInt63() int64 {
+	b := make([]byte, 8)
+	RandomBytes(b)
+	return int63(b)
+}
+
+// RandomIDBytes fills b from the seeded source of the auxiliary ids, or from
+// crypto/rand when not seeded
+func RandomIDBytes(b []byte) {
+	random.Lock()
+	defer random.Unlock()
+
+	read(random.ids, b)
+}
+
+// RandomIDInt63 returns a number from the seeded source of the auxiliary ids,
+// or from crypto/rand when not seeded
+func RandomIDInt63() int64 {
+	b := make([]byte, 8)
+	RandomIDBytes(b)
+	return int63(b)
+}
+
+// read fills b from source, or from crypto/rand if source is nil. Must be
+// called with random locked.
+func read(source *mrand.Rand, b []byte) {
+	if source == nil {
+		if _, err := rand.Read(b); err != nil {
+			panic(err)
+		}
+		return
+	}
+	source.Read(b)
+}
+
+func int63(b []byte) int64 {
+	var n int64
+	for _, c := range b {
+		n = n<<8 | int64(c)
+	}
+	return n & (1<<63 - 1)
+}
+
+// Unseed goes back to generating from crypto/rand
+func Unseed() {
+	random.Lock()
+	defer random.Unlock()
+
+	random.seeded = false
+	random.chain = nil
+	random.ids = nil
+}
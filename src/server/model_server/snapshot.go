@@ -0,0 +1,165 @@
+package model_server
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Snapshot is a named copy of the block stores of every provider, along with
+// the idempotency keys, the ledger and the wallets that go with the chains
+type Snapshot struct {
+	Name        string
+	Created     time.Time
+	stores      map[string]interface{}
+	idempotency map[string]idempotencyEntry
+	ledger      ledgerSnapshot
+	wallets     walletSnapshot
+}
+
+// SnapshotInfo describes a snapshot without its content
+type SnapshotInfo struct {
+	Name      string    `json:"name"`
+	Created   time.Time `json:"created"`
+	CoinTypes []string  `json:"cointypes"`
+}
+
+var snapshots = struct {
+	sync.Mutex
+	byName map[string]*Snapshot
+}{
+	byName: make(map[string]*Snapshot),
+}
+
+// ResetChains resets the chain of coinType to genesis, or every chain if
+// coinType is empty. The idempotency keys and the ledger entries of the
+// deposits on the chains are forgotten too, and so are the addresses their
+// wallets derived.
+func ResetChains(coinType string) error {
+	if coinType == "" {
+		for _, provider := range GetProviders() {
+			resetProvider(provider, "reset to genesis")
+		}
+		DefaultIdempotency.Reset()
+		DefaultLedger.Reset("")
+		resetWallets("")
+		return nil
+	}
+
+	provider, err := GetProvider(coinType)
+	if err != nil {
+		return err
+	}
+	resetProvider(provider, "reset to genesis")
+	DefaultIdempotency.ResetCoin(coinType)
+	DefaultLedger.Reset(coinType)
+	resetWallets(coinType)
+	return nil
+}
+
+func resetProvider(provider Provider, message string) {
+	provider.Reset()
+	PublishEvent(Event{
+		Type:     EventReset,
+		CoinType: provider.GetType(),
+		Message:  message,
+	})
+}
+
+// TakeSnapshot stores a copy of all stores under name, replacing any
+// previous snapshot with the same name.
+func TakeSnapshot(name string) (SnapshotInfo, error) {
+	if name == "" {
+		return SnapshotInfo{}, fmt.Errorf("snapshot name is required")
+	}
+
+	inUse := GetProviders()
+	snapshot := &Snapshot{
+		Name:        name,
+		Created:     time.Now(),
+		stores:      make(map[string]interface{}, len(inUse)),
+		idempotency: DefaultIdempotency.snapshot(),
+		ledger:      DefaultLedger.snapshot(),
+		wallets:     snapshotWallets(),
+	}
+	for coinType, provider := range inUse {
+		snapshot.stores[coinType] = provider.Snapshot()
+	}
+
+	snapshots.Lock()
+	snapshots.byName[name] = snapshot
+	snapshots.Unlock()
+
+	return snapshot.info(), nil
+}
+
+// RestoreSnapshot restores the stores of every provider found in snapshot
+// name, then the idempotency keys, the ledger and the wallets
+func RestoreSnapshot(name string) error {
+	snapshots.Lock()
+	snapshot, ok := snapshots.byName[name]
+	snapshots.Unlock()
+	if !ok {
+		return fmt.Errorf("snapshot %s not found", name)
+	}
+
+	for coinType, store := range snapshot.stores {
+		provider, err := GetProvider(coinType)
+		if err != nil {
+			return err
+		}
+		if err := provider.Restore(store); err != nil {
+			return fmt.Errorf("restoring %s failed: %v", coinType, err)
+		}
+		PublishEvent(Event{
+			Type:     EventReset,
+			CoinType: coinType,
+			Message:  "restored snapshot " + name,
+		})
+	}
+	DefaultIdempotency.restore(snapshot.idempotency)
+	DefaultLedger.restore(snapshot.ledger)
+	return restoreWallets(snapshot.wallets)
+}
+
+// DeleteSnapshot removes snapshot name
+func DeleteSnapshot(name string) error {
+	snapshots.Lock()
+	defer snapshots.Unlock()
+
+	if _, ok := snapshots.byName[name]; !ok {
+		return fmt.Errorf("snapshot %s not found", name)
+	}
+	delete(snapshots.byName, name)
+	return nil
+}
+
+// ListSnapshots returns every snapshot, sorted by name
+func ListSnapshots() []SnapshotInfo {
+	snapshots.Lock()
+	defer snapshots.Unlock()
+
+	infos := make([]SnapshotInfo, 0, len(snapshots.byName))
+	for _, snapshot := range snapshots.byName {
+		infos = append(infos, snapshot.info())
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	return infos
+}
+
+func (s *Snapshot) info() SnapshotInfo {
+	coinTypes := make([]string, 0, len(s.stores))
+	for coinType := range s.stores {
+		coinTypes = append(coinTypes, coinType)
+	}
+	sort.Strings(coinTypes)
+
+	return SnapshotInfo{
+		Name:      s.Name,
+		Created:   s.Created,
+		CoinTypes: coinTypes,
+	}
+}
@@ -0,0 +1,108 @@
+package model_server
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/modeneis/coind/src/server/hdwallet"
+)
+
+// DefaultWalletSeed is the seed of the wallets unless SetWalletSeed is called.
+// It is not a valid BIP39 mnemonic, see hdwallet.New.
+const DefaultWalletSeed = "coind simulated wallet seed"
+
+// wallets holds the wallet of each coin, created out of the seed on first use
+var wallets = struct {
+	sync.Mutex
+	seed   string
+	byCoin map[string]*hdwallet.Wallet
+}{
+	seed:   DefaultWalletSeed,
+	byCoin: map[string]*hdwallet.Wallet{},
+}
+
+// SetWalletSeed makes the wallets derive from seed, dropping the addresses
+// derived so far
+func SetWalletSeed(seed string) {
+	wallets.Lock()
+	defer wallets.Unlock()
+
+	wallets.seed = seed
+	wallets.byCoin = map[string]*hdwallet.Wallet{}
+}
+
+// GetWallet returns the wallet of coinType. Its first address is the hot
+// wallet withdrawals are sent from.
+func GetWallet(coinType string) (*hdwallet.Wallet, error) {
+	coinType = strings.ToUpper(coinType)
+
+	wallets.Lock()
+	defer wallets.Unlock()
+
+	if w, ok := wallets.byCoin[coinType]; ok {
+		return w, nil
+	}
+	w, err := hdwallet.New(coinType, wallets.seed)
+	if err != nil {
+		return nil, err
+	}
+	wallets.byCoin[coinType] = w
+	return w, nil
+}
+
+// walletSnapshot is the seed of the wallets and how many addresses each one
+// derived, enough to derive them again
+type walletSnapshot struct {
+	seed    string
+	derived map[string]int
+}
+
+// snapshotWallets returns the state of the wallets for a snapshot
+func snapshotWallets() walletSnapshot {
+	wallets.Lock()
+	defer wallets.Unlock()
+
+	s := walletSnapshot{seed: wallets.seed, derived: map[string]int{}}
+	for coinType, w := range wallets.byCoin {
+		s.derived[coinType] = len(w.Addresses())
+	}
+	return s
+}
+
+// restoreWallets derives the wallets of a snapshot again, so that their next
+// address is the one they would have derived when it was taken
+func restoreWallets(s walletSnapshot) error {
+	byCoin := map[string]*hdwallet.Wallet{}
+	for coinType, derived := range s.derived {
+		w, err := hdwallet.New(coinType, s.seed)
+		if err != nil {
+			return err
+		}
+		for len(w.Addresses()) < derived {
+			if _, err := w.NewAddress(); err != nil {
+				return err
+			}
+		}
+		byCoin[coinType] = w
+	}
+
+	wallets.Lock()
+	defer wallets.Unlock()
+
+	wallets.seed = s.seed
+	wallets.byCoin = byCoin
+	return nil
+}
+
+// resetWallets drops the addresses derived by the wallet of coinType, or by
+// every wallet if coinType is empty
+func resetWallets(coinType string) {
+	wallets.Lock()
+	defer wallets.Unlock()
+
+	if coinType == "" {
+		wallets.byCoin = map[string]*hdwallet.Wallet{}
+		return
+	}
+	delete(wallets.byCoin, strings.ToUpper(coinType))
+}
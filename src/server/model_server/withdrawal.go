@@ -0,0 +1,37 @@
+package model_server
+
+import (
+	"fmt"
+
+	"github.com/modeneis/coind/src/server/address"
+)
+
+// Withdrawal is an outgoing payment sent by the hot wallet of a provider, the
+// simulated wallet of the exchange. It pays Amount, a decimal amount of the
+// coin, or of the asset AssetID if it is set, to Address. The transaction is
+// pending until the next block confirms it. Only SKY and WAVES have hot
+// wallets, coind does not simulate BTC withdrawals.
+type Withdrawal struct {
+	Address string `json:"address" yaml:"address"`
+	Amount  string `json:"amount" yaml:"amount"`
+	AssetID string `json:"assetid" yaml:"assetid"`
+}
+
+// Validate checks the withdrawal of coinType before it is spent by a hot
+// wallet. The amount of an asset is checked by the provider, which knows its
+// decimals.
+func (w Withdrawal) Validate(coinType string) error {
+	if err := address.Validate(coinType, w.Address); err != nil {
+		return err
+	}
+	if w.AssetID != "" {
+		if !assetCoins[coinType] {
+			return fmt.Errorf("%s has no assets", coinType)
+		}
+		return nil
+	}
+	if _, err := ParseAmount(coinType, w.Amount); err != nil {
+		return fmt.Errorf("invalid amount: %v", err)
+	}
+	return nil
+}
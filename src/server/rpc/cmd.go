@@ -19,6 +19,13 @@ type parsedRPCCmd struct {
 	err    *btcjson.RPCError
 }
 
+// nextDepositCmd is the nextdeposit command: the deposits, then an optional
+// idempotency key, see model_server.ProcessDeposits
+type nextDepositCmd struct {
+	Deposits []model_server.Deposit
+	Key      string
+}
+
 // parseCmd parses a JSON-RPC request object into known concrete command.  The
 // err field of the returned parsedRPCCmd struct will contain an RPC error that
 // is suitable for use in replies if the command is invalid in some way such as
@@ -32,15 +39,21 @@ func parseCmd(request *btcjson.Request) *parsedRPCCmd {
 
 	// Handle new commands except btcd cmds
 	if request.Method == "nextdeposit" {
-		if len(request.Params) == 1 {
-			var deposit []model_server.Deposit
-			err := json.Unmarshal(request.Params[0], &deposit)
+		if len(request.Params) == 1 || len(request.Params) == 2 {
+			var c nextDepositCmd
+			err := json.Unmarshal(request.Params[0], &c.Deposits)
 			if err != nil {
 				parsedCmd.err = btcjson.ErrRPCMethodNotFound
 				return &parsedCmd
 			}
-			fmt.Printf("%v\n", deposit)
-			parsedCmd.cmd = deposit
+			if len(request.Params) == 2 {
+				if err := json.Unmarshal(request.Params[1], &c.Key); err != nil {
+					parsedCmd.err = btcjson.NewRPCError(
+						btcjson.ErrRPCInvalidParams.Code, fmt.Sprintf("invalid idempotency key: %v", err))
+					return &parsedCmd
+				}
+			}
+			parsedCmd.cmd = &c
 		}
 		return &parsedCmd
 	}
@@ -2,8 +2,11 @@ package rpc
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/btcsuite/btcd/btcjson"
+
+	"github.com/modeneis/coind/src/server/model_server"
 )
 
 // RPCErrorCode represents an error code to be used as a part of an RPCError
@@ -71,21 +74,134 @@ func handleGetBlockCount(s *RpcServer, cmd interface{}, closeChan <-chan struct{
 	return 0, nil
 }
 
-//
-//
+// handleNextDeposit creates a block per deposit and returns their receipts.
+// Deposits submitted again under the same idempotency key, or with the same
+// ClientID, get their original receipts back.
 func handleNextDeposit(s *RpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
-	//	var deposits []model_server.Deposit
-	//	if cmd != nil {
-	//		deposits = cmd.([]model_server.Deposit)
-	//		fmt.Printf("Got %v\n", deposits)
-	//	}
-	//
-	//	newBlock, err := model_server.ProcessDeposits(deposits)
-	//
-	//	if err != nil {
-	//		fmt.Printf("processDeposits %v\n", err)
-	//		return nil,  fmt.Errorf("Block not found")
-	//	}
-	//
-	return nil, nil
+	c, ok := cmd.(*nextDepositCmd)
+	if !ok {
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCInvalidParams.Code, "deposits are required")
+	}
+
+	receipts, _, err := model_server.ProcessDeposits("rpc", c.Key, c.Deposits)
+	if err != nil {
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCInvalidParams.Code, err.Error())
+	}
+	return receipts, nil
+}
+
+// getTransactionResult is the gettransaction result, extended with the status
+// of the transaction: pending, confirmed or orphaned. An orphaned transaction
+// has no confirmations and the hash of the block it was dropped from.
+type getTransactionResult struct {
+	btcjson.GetTransactionResult
+	Status string `json:"status"`
+}
+
+// txRawResult is the verbose getrawtransaction result, extended like
+// getTransactionResult
+type txRawResult struct {
+	btcjson.TxRawResult
+	Status string `json:"status"`
+}
+
+// handleGetTransaction looks a transaction up on every chain and reports its
+// confirmations, counted from the tip of its chain
+func handleGetTransaction(s *RpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c, ok := cmd.(*btcjson.GetTransactionCmd)
+	if !ok {
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCInvalidParams.Code, "txid is required")
+	}
+
+	status, err := transactionStatus(c.Txid)
+	if err != nil {
+		return nil, err
+	}
+	return &getTransactionResult{
+		GetTransactionResult: btcjson.GetTransactionResult{
+			Confirmations:   status.Confirmations,
+			BlockHash:       status.BlockHash,
+			BlockTime:       status.Time,
+			TxID:            status.TxID,
+			WalletConflicts: []string{},
+			Time:            status.Time,
+			TimeReceived:    status.Time,
+			Details:         []btcjson.GetTransactionDetailsResult{},
+		},
+		Status: status.Status,
+	}, nil
+}
+
+// handleGetRawTransaction is handleGetTransaction in the getrawtransaction
+// format. The fake transactions have no raw encoding, so only the verbose form
+// is supported.
+func handleGetRawTransaction(s *RpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c, ok := cmd.(*btcjson.GetRawTransactionCmd)
+	if !ok {
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCInvalidParams.Code, "txid is required")
+	}
+	if c.Verbose == nil || *c.Verbose == 0 {
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCInvalidParams.Code, "only verbose getrawtransaction is supported")
+	}
+
+	status, err := transactionStatus(c.Txid)
+	if err != nil {
+		return nil, err
+	}
+	return &txRawResult{
+		TxRawResult: btcjson.TxRawResult{
+			Txid:          status.TxID,
+			Hash:          status.TxID,
+			Vin:           []btcjson.Vin{},
+			Vout:          []btcjson.Vout{},
+			BlockHash:     status.BlockHash,
+			Confirmations: uint64(status.Confirmations),
+			Time:          status.Time,
+			Blocktime:     status.Time,
+		},
+		Status: status.Status,
+	}, nil
+}
+
+// transactionStatus looks txid up in every provider, in the order of their
+// coin types
+func transactionStatus(txid string) (model_server.TxStatus, error) {
+	var coinTypes []string
+	for coinType := range model_server.GetProviders() {
+		coinTypes = append(coinTypes, coinType)
+	}
+	sort.Strings(coinTypes)
+
+	for _, coinType := range coinTypes {
+		provider, err := model_server.GetProvider(coinType)
+		if err != nil {
+			continue
+		}
+		if status, err := provider.GetTransactionStatus(txid); err == nil && status.TxID != "" {
+			return status, nil
+		}
+	}
+	return model_server.TxStatus{}, btcjson.NewRPCError(btcjson.ErrRPCNoTxInfo, fmt.Sprintf("transaction %s not found", txid))
+}
+
+// handleGetNewAddress derives the next address of the BTC wallet, along
+// m/44'/0'/0'/0/i. Accounts are not supported.
+func handleGetNewAddress(s *RpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c, ok := cmd.(*btcjson.GetNewAddressCmd)
+	if !ok {
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCInvalidParams.Code, "invalid getnewaddress parameters")
+	}
+	if c.Account != nil && *c.Account != "" && *c.Account != "default" {
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCWalletInvalidAccountName, "accounts are not supported")
+	}
+
+	wallet, err := model_server.GetWallet("BTC")
+	if err != nil {
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCNoWallet, err.Error())
+	}
+	address, err := wallet.NewAddress()
+	if err != nil {
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCWallet, err.Error())
+	}
+	return address.Address, nil
 }
@@ -12,9 +12,11 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/btcsuite/btcd/btcjson"
 
+	"github.com/modeneis/coind/src/server/fault"
 	"github.com/modeneis/coind/src/server/model_server"
 	"github.com/modeneis/coind/src/server/utils"
 )
@@ -36,7 +38,10 @@ var rpcHandlers = map[string]commandHandler{
 	"get_blocks_by_seq": handleGetBestBlock,
 	"get_lastblocks":    handleGetBlockHash,
 	"getblockcount":     handleGetBlockCount,
+	"getrawtransaction": handleGetRawTransaction,
+	"gettransaction":    handleGetTransaction,
 	"nextdeposit":       handleNextDeposit, // for triggering a fake deposit
+	"getnewaddress":     handleGetNewAddress,
 }
 
 type commandHandler func(*RpcServer, interface{}, <-chan struct{}) (interface{}, error)
@@ -138,6 +143,7 @@ func (s *RpcServer) jsonRPCRead(w http.ResponseWriter, r *http.Request) {
 	var responseID interface{}
 	var jsonErr error
 	var result interface{}
+	var faultAction string
 	var request btcjson.Request
 	if err := json.Unmarshal(body, &request); err != nil {
 		jsonErr = &btcjson.RPCError{
@@ -182,6 +188,26 @@ func (s *RpcServer) jsonRPCRead(w http.ResponseWriter, r *http.Request) {
 			}
 		}()
 
+		// Apply the fault injected into this method, if any.
+		if rule, ok := fault.DefaultInjector.MatchRPC(request.Method); ok {
+			if !fault.Sleep(time.Duration(rule.Latency), closeChan) {
+				return
+			}
+			switch rule.Action {
+			case fault.ActionError:
+				jsonErr = &btcjson.RPCError{
+					Code:    btcjson.RPCErrorCode(rule.RPCCode),
+					Message: "injected fault " + rule.ID,
+				}
+			case fault.ActionDrop:
+				return
+			case fault.ActionStall:
+				fault.Stall(time.Duration(rule.Stall), closeChan)
+				return
+			}
+			faultAction = rule.Action
+		}
+
 		if jsonErr == nil {
 			// Attempt to parse the JSON-RPC request into a known concrete
 			// command.
@@ -202,6 +228,8 @@ func (s *RpcServer) jsonRPCRead(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	msg = fault.Corrupt(faultAction, msg)
+
 	// Write the response.
 	err = s.writeHTTPResponseHeaders(r, w.Header(), buf)
 	if err != nil {
@@ -0,0 +1,313 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/skycoin/skycoin/src/gui"
+	"github.com/skycoin/skycoin/src/visor"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modeneis/coind/src/providers/sky"
+	"github.com/modeneis/coind/src/server/address"
+	"github.com/modeneis/coind/src/server/fault"
+	"github.com/modeneis/coind/src/server/hdwallet"
+	"github.com/modeneis/coind/src/server/model_server"
+	"github.com/modeneis/coind/src/server/utils"
+)
+
+// useFakeSky registers a SKY provider that reads its template blocks from a
+// local server instead of the public explorer
+func useFakeSky(t *testing.T) (*sky.Provider, func()) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/blockchain/metadata", func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, utils.JSONResponse(w, visor.BlockchainMetadata{Head: visor.ReadableBlockHeader{BkSeq: 1}}))
+	})
+	mux.HandleFunc("/api/blocks", func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write([]byte(sky.SkyBlockString))
+		require.NoError(t, err)
+	})
+	upstream := httptest.NewServer(mux)
+
+	provider := &sky.Provider{SkyRESTClinet: &gui.Client{Addr: upstream.URL + "/api/"}}
+	provider.Start()
+	model_server.UseProviders(provider)
+
+	return provider, func() {
+		upstream.Close()
+		model_server.ClearProviders()
+	}
+}
+
+// newTestServer serves the RPC server over HTTP the way Start does
+func newTestServer() *httptest.Server {
+	s := &RpcServer{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Connection", "close")
+		w.Header().Set("Content-Type", "application/json")
+		r.Close = true
+		s.jsonRPCRead(w, r)
+	}))
+}
+
+// post sends a JSON-RPC request to the server at url and returns the body of
+// the response
+func post(t *testing.T, url, method string, params ...interface{}) ([]byte, error) {
+	request, err := btcjson.NewRequest(1, method, params)
+	require.NoError(t, err)
+	raw, err := json.Marshal(request)
+	require.NoError(t, err)
+
+	response, err := http.Post(url, "application/json", bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	return ioutil.ReadAll(response.Body)
+}
+
+// call is post for a well-formed response
+func call(t *testing.T, url, method string, params ...interface{}) btcjson.Response {
+	body, err := post(t, url, method, params...)
+	require.NoError(t, err)
+
+	var reply btcjson.Response
+	require.NoError(t, json.Unmarshal(body, &reply))
+	return reply
+}
+
+// txCase is a transaction lookup and the result or the RPC error it expects
+type txCase struct {
+	method        string
+	params        []interface{}
+	confirmations int64
+	status        string
+	code          btcjson.RPCErrorCode
+}
+
+func TestGetTransaction(t *testing.T) {
+	provider, done := useFakeSky(t)
+	defer done()
+	server := newTestServer()
+	defer server.Close()
+
+	var txs []string
+	for i := 0; i < 2; i++ {
+		receipt, _, err := provider.CreateFakeBlock(model_server.Deposit{Address: "2GgFvqoyk9RjwVzj8tqfcXVXB4orBwoc9qv", Value: 1, CoinType: "SKY"})
+		require.NoError(t, err)
+		txs = append(txs, receipt.TxID)
+	}
+
+	type result struct {
+		TxID          string `json:"txid"`
+		Confirmations int64  `json:"confirmations"`
+		BlockHash     string `json:"blockhash"`
+		Status        string `json:"status"`
+	}
+	check := func(name string, cases []txCase) {
+		for _, tc := range cases {
+			t.Run(name+"/"+tc.method, func(t *testing.T) {
+				reply := call(t, server.URL, tc.method, tc.params...)
+				if tc.code != 0 {
+					require.NotNil(t, reply.Error)
+					require.Equal(t, tc.code, reply.Error.Code)
+					return
+				}
+				require.Nil(t, reply.Error)
+
+				var r result
+				require.NoError(t, json.Unmarshal(reply.Result, &r))
+				require.Equal(t, tc.params[0], r.TxID)
+				require.Equal(t, tc.confirmations, r.Confirmations)
+				require.Equal(t, tc.status, r.Status)
+				require.NotEmpty(t, r.BlockHash)
+			})
+		}
+	}
+
+	check("mined", []txCase{
+		{method: "gettransaction", params: []interface{}{txs[0]}, confirmations: 2, status: model_server.TxConfirmed},
+		{method: "gettransaction", params: []interface{}{txs[1]}, confirmations: 1, status: model_server.TxConfirmed},
+		{method: "getrawtransaction", params: []interface{}{txs[0], 1}, confirmations: 2, status: model_server.TxConfirmed},
+		{method: "getrawtransaction", params: []interface{}{txs[0]}, code: btcjson.ErrRPCInvalidParams.Code},
+		{method: "gettransaction", params: []interface{}{"missing"}, code: btcjson.ErrRPCNoTxInfo},
+		{method: "gettransaction", code: btcjson.ErrRPCInvalidParams.Code},
+	})
+
+	// replace the block of the second deposit by an empty one
+	_, err := provider.Reorg(model_server.Reorg{Depth: 1, Blocks: [][]model_server.Deposit{{}}})
+	require.NoError(t, err)
+
+	check("reorged", []txCase{
+		{method: "gettransaction", params: []interface{}{txs[0]}, confirmations: 2, status: model_server.TxConfirmed},
+		{method: "gettransaction", params: []interface{}{txs[1]}, confirmations: 0, status: model_server.TxOrphaned},
+		{method: "getrawtransaction", params: []interface{}{txs[1], 1}, confirmations: 0, status: model_server.TxOrphaned},
+	})
+}
+
+func TestFaultInjection(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+	defer fault.DefaultInjector.Clear()
+
+	cases := []struct {
+		rule      fault.Rule
+		code      btcjson.RPCErrorCode
+		dropped   bool
+		malformed bool
+	}{
+		{rule: fault.Rule{Action: fault.ActionError}, code: fault.DefaultRPCCode},
+		{rule: fault.Rule{Action: fault.ActionError, RPCCode: int(btcjson.ErrRPCClientNotConnected)}, code: btcjson.ErrRPCClientNotConnected},
+		{rule: fault.Rule{Action: fault.ActionDrop}, dropped: true},
+		{rule: fault.Rule{Action: fault.ActionTruncate}, malformed: true},
+		{rule: fault.Rule{Action: fault.ActionMalformed}, malformed: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.rule.Action, func(t *testing.T) {
+			tc.rule.Method = "getblockcount"
+			tc.rule.Times = 1
+			rule, err := fault.DefaultInjector.Add(tc.rule)
+			require.NoError(t, err)
+
+			// a fault on a method leaves the others alone
+			reply := call(t, server.URL, "gettransaction")
+			require.NotNil(t, reply.Error)
+			require.Equal(t, btcjson.ErrRPCInvalidParams.Code, reply.Error.Code)
+
+			body, err := post(t, server.URL, "getblockcount")
+			switch {
+			case tc.dropped:
+				require.Error(t, err)
+			case tc.malformed:
+				require.NoError(t, err)
+				require.Error(t, json.Unmarshal(body, &btcjson.Response{}))
+			default:
+				require.NoError(t, err)
+				var faulted btcjson.Response
+				require.NoError(t, json.Unmarshal(body, &faulted))
+				require.NotNil(t, faulted.Error)
+				require.Equal(t, tc.code, faulted.Error.Code)
+				require.Equal(t, "injected fault "+rule.ID, faulted.Error.Message)
+			}
+
+			// the fault applied once only
+			reply = call(t, server.URL, "getblockcount")
+			require.Nil(t, reply.Error)
+			require.Equal(t, "0", string(reply.Result))
+		})
+	}
+}
+
+func TestNextDepositIdempotencyKey(t *testing.T) {
+	_, done := useFakeSky(t)
+	defer done()
+	server := newTestServer()
+	defer server.Close()
+	model_server.DefaultIdempotency.Reset()
+	defer model_server.DefaultIdempotency.Reset()
+
+	deposits := func(value int64) []model_server.Deposit {
+		return []model_server.Deposit{{Address: "2GgFvqoyk9RjwVzj8tqfcXVXB4orBwoc9qv", Value: value, CoinType: "SKY"}}
+	}
+
+	// replays is the case whose transaction a case gets back, -1 for a new one
+	cases := []struct {
+		name    string
+		params  []interface{}
+		replays int
+		err     string
+	}{
+		{name: "no key", params: []interface{}{deposits(1)}, replays: -1},
+		{name: "key", params: []interface{}{deposits(1), "retry-1"}, replays: -1},
+		{name: "same key", params: []interface{}{deposits(1), "retry-1"}, replays: 1},
+		{name: "other key", params: []interface{}{deposits(1), "retry-2"}, replays: -1},
+		{name: "empty key", params: []interface{}{deposits(1), ""}, replays: -1},
+		{name: "key of other deposits", params: []interface{}{deposits(2), "retry-1"}, err: "idempotency key retry-1 was used for other deposits"},
+		{name: "number key", params: []interface{}{deposits(1), 5}, err: "invalid idempotency key"},
+		{name: "object key", params: []interface{}{deposits(1), map[string]string{"key": "retry-1"}}, err: "invalid idempotency key"},
+	}
+
+	txs := make([]string, len(cases))
+	for n, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			reply := call(t, server.URL, "nextdeposit", tc.params...)
+			if tc.err != "" {
+				require.NotNil(t, reply.Error)
+				require.Equal(t, btcjson.ErrRPCInvalidParams.Code, reply.Error.Code)
+				require.Contains(t, reply.Error.Message, tc.err)
+				return
+			}
+			require.Nil(t, reply.Error)
+
+			var receipts []model_server.Receipt
+			require.NoError(t, json.Unmarshal(reply.Result, &receipts))
+			require.Len(t, receipts, 1)
+			txs[n] = receipts[0].TxID
+
+			if tc.replays >= 0 {
+				require.Equal(t, txs[tc.replays], txs[n])
+				return
+			}
+			for _, tx := range txs[:n] {
+				require.NotEqual(t, tx, txs[n])
+			}
+		})
+	}
+}
+
+func TestGetNewAddress(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+	model_server.SetWalletSeed(model_server.DefaultWalletSeed)
+	defer model_server.SetWalletSeed(model_server.DefaultWalletSeed)
+
+	cases := []struct {
+		name   string
+		params []interface{}
+		code   btcjson.RPCErrorCode
+	}{
+		{name: "no account"},
+		{name: "empty account", params: []interface{}{""}},
+		{name: "default account", params: []interface{}{"default"}},
+		{name: "other account", params: []interface{}{"savings"}, code: btcjson.ErrRPCWalletInvalidAccountName},
+		{name: "number account", params: []interface{}{1}, code: btcjson.ErrRPCInvalidParams.Code},
+	}
+
+	// the first address is the hot wallet, getnewaddress derives from the second on
+	index := uint32(1)
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			reply := call(t, server.URL, "getnewaddress", tc.params...)
+			if tc.code != 0 {
+				require.NotNil(t, reply.Error)
+				require.Equal(t, tc.code, reply.Error.Code)
+				return
+			}
+			require.Nil(t, reply.Error)
+
+			var next string
+			require.NoError(t, json.Unmarshal(reply.Result, &next))
+			require.NoError(t, address.Validate("BTC", next))
+
+			wallet, err := model_server.GetWallet("BTC")
+			require.NoError(t, err)
+			derived, err := hdwallet.AddressFromXPub("BTC", wallet.XPub(), index)
+			require.NoError(t, err)
+			require.Equal(t, derived, next)
+
+			addresses := wallet.Addresses()
+			require.Len(t, addresses, int(index)+1)
+			require.Equal(t, next, addresses[index].Address)
+			require.Equal(t, fmt.Sprintf("m/44'/0'/0'/0/%d", index), addresses[index].Path)
+			index++
+		})
+	}
+	require.Equal(t, uint32(4), index)
+}
@@ -0,0 +1,343 @@
+package scenario
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/modeneis/coind/src/server/fault"
+	"github.com/modeneis/coind/src/server/model_server"
+)
+
+// Run and step statuses
+const (
+	StatusPending = "pending"
+	StatusRunning = "running"
+	StatusPassed  = "passed"
+	StatusFailed  = "failed"
+	StatusSkipped = "skipped"
+)
+
+// StepResult is the progress of a single step
+type StepResult struct {
+	Index    int       `json:"index"`
+	Name     string    `json:"name,omitempty"`
+	Action   string    `json:"action"`
+	Status   string    `json:"status"`
+	Error    string    `json:"error,omitempty"`
+	Started  time.Time `json:"started,omitempty"`
+	Finished time.Time `json:"finished,omitempty"`
+}
+
+// Run is the progress of a scenario. Steps after a failed one are skipped.
+type Run struct {
+	ID       string       `json:"id"`
+	Name     string       `json:"name"`
+	Status   string       `json:"status"`
+	Current  int          `json:"current"`
+	Steps    []StepResult `json:"steps"`
+	Error    string       `json:"error,omitempty"`
+	Started  time.Time    `json:"started"`
+	Finished time.Time    `json:"finished,omitempty"`
+}
+
+var runs = struct {
+	sync.Mutex
+	byID map[string]*Run
+}{
+	byID: make(map[string]*Run),
+}
+
+// Start runs s in the background and returns its ID. Steps of a scenario run
+// one at a time in order, but separate scenarios may run concurrently.
+func Start(s *Scenario) string {
+	run := newRun(s)
+
+	runs.Lock()
+	runs.byID[run.ID] = run
+	runs.Unlock()
+
+	go execute(s, run)
+	return run.ID
+}
+
+// Execute runs s to completion and returns its final progress
+func Execute(s *Scenario) Run {
+	run := newRun(s)
+
+	runs.Lock()
+	runs.byID[run.ID] = run
+	runs.Unlock()
+
+	execute(s, run)
+	r, _ := GetRun(run.ID)
+	return r
+}
+
+// GetRun returns the progress of a scenario
+func GetRun(id string) (Run, error) {
+	runs.Lock()
+	defer runs.Unlock()
+
+	run, ok := runs.byID[id]
+	if !ok {
+		return Run{}, fmt.Errorf("scenario run %s not found", id)
+	}
+	return run.copy(), nil
+}
+
+// ListRuns returns the progress of every scenario, oldest first
+func ListRuns() []Run {
+	runs.Lock()
+	defer runs.Unlock()
+
+	list := make([]Run, 0, len(runs.byID))
+	for _, run := range runs.byID {
+		list = append(list, run.copy())
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Started.Before(list[j].Started)
+	})
+	return list
+}
+
+func newRun(s *Scenario) *Run {
+	b := make([]byte, 8)
+	model_server.RandomIDBytes(b)
+
+	run := &Run{
+		ID:      hex.EncodeToString(b),
+		Name:    s.Name,
+		Status:  StatusPending,
+		Steps:   make([]StepResult, len(s.Steps)),
+		Started: model_server.Now(),
+	}
+	for i, step := range s.Steps {
+		run.Steps[i] = StepResult{
+			Index:  i + 1,
+			Name:   step.Name,
+			Action: step.Action,
+			Status: StatusPending,
+		}
+	}
+	return run
+}
+
+func (r *Run) copy() Run {
+	c := *r
+	c.Steps = append([]StepResult(nil), r.Steps...)
+	return c
+}
+
+// update changes a run with the runs locked
+func update(run *Run, fn func(r *Run)) {
+	runs.Lock()
+	defer runs.Unlock()
+	fn(run)
+}
+
+func execute(s *Scenario, run *Run) {
+	update(run, func(r *Run) { r.Status = StatusRunning })
+
+	for i, step := range s.Steps {
+		update(run, func(r *Run) {
+			r.Current = i + 1
+			r.Steps[i].Status = StatusRunning
+			r.Steps[i].Started = model_server.Now()
+		})
+
+		sleep(step.After)
+		err := runStep(step)
+
+		update(run, func(r *Run) {
+			r.Steps[i].Finished = model_server.Now()
+			if err != nil {
+				r.Steps[i].Status = StatusFailed
+				r.Steps[i].Error = err.Error()
+			} else {
+				r.Steps[i].Status = StatusPassed
+			}
+		})
+
+		if err != nil {
+			update(run, func(r *Run) {
+				for j := i + 1; j < len(r.Steps); j++ {
+					r.Steps[j].Status = StatusSkipped
+				}
+				r.Status = StatusFailed
+				r.Error = fmt.Sprintf("step %d (%s): %v", i+1, step.Action, err)
+				r.Finished = model_server.Now()
+			})
+			return
+		}
+	}
+
+	update(run, func(r *Run) {
+		r.Status = StatusPassed
+		r.Finished = model_server.Now()
+	})
+}
+
+// sleep waits for d of virtual time, so that scenarios follow the block
+// timestamps. On a fixed clock it only returns once the clock is advanced.
+func sleep(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	<-model_server.DefaultClock.NewTimer(d).C
+}
+
+func runStep(step Step) error {
+	switch step.Action {
+	case ActionDeposit:
+		for _, deposit := range step.Deposits {
+			if deposit.CoinType == "" {
+				deposit.CoinType = step.CoinType
+			}
+			provider, err := model_server.GetProvider(deposit.CoinType)
+			if err != nil {
+				return err
+			}
+			if step.Mempool {
+				_, err = provider.AddToMempool(deposit)
+			} else {
+				var receipt model_server.Receipt
+				if receipt, _, err = provider.CreateFakeBlock(deposit); err == nil {
+					model_server.DefaultLedger.Record("scenario", receipt)
+				}
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case ActionMine:
+		blocks := step.Blocks
+		if blocks == 0 {
+			blocks = 1
+		}
+		_, err := model_server.MineBlocks(step.CoinType, blocks)
+		return err
+	case ActionWait:
+		sleep(step.Duration)
+		return nil
+	case ActionAdvance:
+		return model_server.DefaultClock.Advance(step.Duration)
+	case ActionReorg:
+		provider, err := model_server.GetProvider(step.CoinType)
+		if err != nil {
+			return err
+		}
+		_, err = provider.Reorg(model_server.Reorg{Depth: step.Depth, Blocks: step.Branch})
+		return err
+	case ActionFault:
+		rule, err := fault.DefaultInjector.Add(*step.Fault)
+		if err != nil {
+			return err
+		}
+		if step.Duration > 0 {
+			go func() {
+				sleep(step.Duration)
+				fault.DefaultInjector.Remove(rule.ID)
+			}()
+		}
+		return nil
+	case ActionAssert:
+		return step.Assertion.check(step.CoinType)
+	default:
+		return fmt.Errorf("unknown action %q", step.Action)
+	}
+}
+
+// check runs the query of the assertion and compares the value at its path
+func (a Assertion) check(coinType string) error {
+	provider, err := model_server.GetProvider(coinType)
+	if err != nil {
+		return err
+	}
+
+	var result interface{}
+	switch a.Query {
+	case QueryBalance:
+		result, err = provider.GetBalance(a.Address)
+	case QueryOutputs:
+		result, err = provider.GetOutputs(a.Address)
+	case QueryTransactions:
+		result, err = provider.GetAddressTransactions(a.Address, 0, 0)
+	case QueryTransaction:
+		result, err = provider.GetTransaction(a.TxID)
+	case QueryBlockCount:
+		result = provider.GetBlockCount()
+	case QueryBestBlock:
+		result, err = provider.GetBestBlock(0)
+	default:
+		err = fmt.Errorf("unknown query %q", a.Query)
+	}
+	if err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return err
+	}
+
+	value, err = lookup(value, a.Path)
+	if err != nil {
+		return err
+	}
+
+	if got, want := fmt.Sprint(value), fmt.Sprint(a.Equals); got != want {
+		return fmt.Errorf("%s %s: got %s, want %s", a.Query, a.Path, got, want)
+	}
+	return nil
+}
+
+// lookup follows a dotted path into a decoded JSON value
+func lookup(value interface{}, path string) (interface{}, error) {
+	if path == "" {
+		return value, nil
+	}
+
+	for _, key := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			next, ok := v[key]
+			if !ok {
+				return nil, fmt.Errorf("path %s: no field %q", path, key)
+			}
+			value = next
+		case []interface{}:
+			if key == "#" {
+				value = len(v)
+				continue
+			}
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, fmt.Errorf("path %s: invalid index %q of %d items", path, key, len(v))
+			}
+			value = v[i]
+		case nil:
+			if key == "#" {
+				value = 0
+				continue
+			}
+			return nil, fmt.Errorf("path %s: no field %q", path, key)
+		default:
+			return nil, fmt.Errorf("path %s: cannot look up %q in %v", path, key, value)
+		}
+	}
+	return value, nil
+}
@@ -0,0 +1,171 @@
+// Package scenario runs declarative scripts of deposits, blocks and
+// assertions against the fake chains
+package scenario
+
+import (
+	"fmt"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/modeneis/coind/src/server/fault"
+	"github.com/modeneis/coind/src/server/model_server"
+)
+
+// Step actions
+const (
+	ActionDeposit = "deposit"
+	ActionMine    = "mine"
+	ActionWait    = "wait"
+	ActionAdvance = "advance"
+	ActionReorg   = "reorg"
+	ActionFault   = "fault"
+	ActionAssert  = "assert"
+)
+
+// Assertion queries
+const (
+	QueryBalance      = "balance"
+	QueryOutputs      = "outputs"
+	QueryTransactions = "transactions"
+	QueryTransaction  = "transaction"
+	QueryBlockCount   = "block_count"
+	QueryBestBlock    = "best_block"
+)
+
+// Scenario is a list of steps run one after the other, for example:
+//
+//	name: two sky deposits
+//	steps:
+//	  - action: deposit
+//	    deposits:
+//	      - {cointype: SKY, address: 2GgFvqoyk9RjwVzj8tqfcXVXB4orBwoc9qv, value: 10}
+//	  - action: wait
+//	    duration: 2s
+//	  - action: assert
+//	    cointype: SKY
+//	    query: balance
+//	    address: 2GgFvqoyk9RjwVzj8tqfcXVXB4orBwoc9qv
+//	    path: confirmed.coins
+//	    equals: 10000000
+type Scenario struct {
+	Name  string `yaml:"name" json:"name"`
+	Steps []Step `yaml:"steps" json:"steps"`
+}
+
+// Step is a single action of a scenario. After delays the step, Duration is
+// how long a wait step lasts, both in the virtual time of the clock. A deposit
+// step with Mempool set leaves its deposits unconfirmed until a mine step mines
+// Blocks blocks, one by default.
+// A reorg step replaces the last Depth blocks with a block for each entry of
+// Branch. A fault step injects Fault into the API and RPC servers, for
+// Duration if it is set or until the rule expires otherwise.
+type Step struct {
+	Name      string                   `yaml:"name" json:"name,omitempty"`
+	Action    string                   `yaml:"action" json:"action"`
+	After     time.Duration            `yaml:"after" json:"after,omitempty"`
+	CoinType  string                   `yaml:"cointype" json:"cointype,omitempty"`
+	Deposits  []model_server.Deposit   `yaml:"deposits" json:"deposits,omitempty"`
+	Mempool   bool                     `yaml:"mempool" json:"mempool,omitempty"`
+	Blocks    int                      `yaml:"blocks" json:"blocks,omitempty"`
+	Depth     int                      `yaml:"depth" json:"depth,omitempty"`
+	Branch    [][]model_server.Deposit `yaml:"branch" json:"branch,omitempty"`
+	Duration  time.Duration            `yaml:"duration" json:"duration,omitempty"`
+	Fault     *fault.Rule              `yaml:"fault" json:"fault,omitempty"`
+	Assertion `yaml:",inline"`
+}
+
+// Assertion checks a value of the JSON answer of a query. Path is a dotted
+// path into the answer, where a number indexes an array and "#" is the length
+// of an array, for example "transactions.0.txid" or "head_outputs.#".
+type Assertion struct {
+	Query   string      `yaml:"query" json:"query,omitempty"`
+	Address string      `yaml:"address" json:"address,omitempty"`
+	TxID    string      `yaml:"txid" json:"txid,omitempty"`
+	Path    string      `yaml:"path" json:"path,omitempty"`
+	Equals  interface{} `yaml:"equals" json:"equals,omitempty"`
+}
+
+// Parse reads a YAML, or JSON, scenario and validates its steps
+func Parse(data []byte) (*Scenario, error) {
+	var s Scenario
+	if err := yaml.UnmarshalStrict(data, &s); err != nil {
+		return nil, fmt.Errorf("invalid scenario: %v", err)
+	}
+
+	if len(s.Steps) == 0 {
+		return nil, fmt.Errorf("scenario has no steps")
+	}
+	for i, step := range s.Steps {
+		if err := step.validate(); err != nil {
+			return nil, fmt.Errorf("step %d (%s): %v", i+1, step.Action, err)
+		}
+	}
+	return &s, nil
+}
+
+func (s Step) validate() error {
+	if s.After < 0 {
+		return fmt.Errorf("after must not be negative")
+	}
+
+	switch s.Action {
+	case ActionDeposit:
+		if len(s.Deposits) == 0 {
+			return fmt.Errorf("deposits are required")
+		}
+		return model_server.ValidateDeposits(s.CoinType, s.Deposits)
+	case ActionWait, ActionAdvance:
+		if s.Duration <= 0 {
+			return fmt.Errorf("duration is required")
+		}
+	case ActionAssert:
+		if s.CoinType == "" {
+			return fmt.Errorf("cointype is required")
+		}
+		switch s.Query {
+		case QueryBalance, QueryOutputs, QueryTransactions:
+			if s.Address == "" {
+				return fmt.Errorf("address is required for %s", s.Query)
+			}
+		case QueryTransaction:
+			if s.TxID == "" {
+				return fmt.Errorf("txid is required for %s", s.Query)
+			}
+		case QueryBlockCount, QueryBestBlock:
+		default:
+			return fmt.Errorf("unknown query %q", s.Query)
+		}
+	case ActionReorg:
+		if s.CoinType == "" {
+			return fmt.Errorf("cointype is required")
+		}
+		if s.Depth <= 0 && len(s.Branch) == 0 {
+			return fmt.Errorf("depth or branch is required")
+		}
+		for _, block := range s.Branch {
+			if err := model_server.ValidateDeposits(s.CoinType, block); err != nil {
+				return err
+			}
+		}
+	case ActionMine:
+		if s.CoinType == "" {
+			return fmt.Errorf("cointype is required")
+		}
+		if s.Blocks < 0 {
+			return fmt.Errorf("blocks must not be negative")
+		}
+	case ActionFault:
+		if s.Fault == nil {
+			return fmt.Errorf("fault is required")
+		}
+		if s.Duration < 0 {
+			return fmt.Errorf("duration must not be negative")
+		}
+		rule := *s.Fault
+		return rule.Validate()
+	default:
+		return fmt.Errorf("unknown action %q", s.Action)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package scenario
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/modeneis/coind/src/providers/sky"
+	"github.com/modeneis/coind/src/providers/waves"
+	"github.com/modeneis/coind/src/server/model_server"
+)
+
+func init() {
+	model_server.UseProviders(sky.New(), waves.New())
+}
+
+func TestParse(t *testing.T) {
+	s, err := Parse([]byte(`
+name: deposits
+steps:
+  - action: deposit
+    after: 1s
+    cointype: SKY
+    deposits:
+      - {address: 2GgFvqoyk9RjwVzj8tqfcXVXB4orBwoc9qv, value: 10, hours: 2}
+  - action: wait
+    duration: 500ms
+  - action: assert
+    cointype: SKY
+    query: outputs
+    address: 2GgFvqoyk9RjwVzj8tqfcXVXB4orBwoc9qv
+    path: head_outputs.#
+    equals: 1
+`))
+	require.NoError(t, err)
+	require.Equal(t, "deposits", s.Name)
+	require.Len(t, s.Steps, 3)
+	require.Equal(t, time.Second, s.Steps[0].After)
+	require.Equal(t, int64(10), s.Steps[0].Deposits[0].Value)
+	require.Equal(t, uint64(2), s.Steps[0].Deposits[0].Hours)
+	require.Equal(t, 500*time.Millisecond, s.Steps[1].Duration)
+	require.Equal(t, "head_outputs.#", s.Steps[2].Path)
+
+	for _, invalid := range []string{
+		`steps: []`,
+		`steps: [{action: explode}]`,
+		`steps: [{action: deposit}]`,
+		`steps: [{action: deposit, deposits: [{address: x}]}]`,
+		`steps: [{action: deposit, cointype: SKY, deposits: [{address: 1FeDtFhARLxjKUPPkQqEBL78tisenc9znS, value: 1}]}]`,
+		`steps: [{action: deposit, cointype: SKY, deposits: [{address: 2GgFvqoyk9RjwVzj8tqfcXVXB4orBwoc9qv}]}]`,
+		`steps: [{action: reorg, cointype: SKY, branch: [[{address: x, value: 1}]]}]`,
+		`steps: [{action: wait}]`,
+		`steps: [{action: mine}]`,
+		`steps: [{action: fault}]`,
+		`steps: [{action: fault, fault: {action: explode}}]`,
+		`steps: [{action: fault, fault: {route: /api/balance}}]`,
+		`steps: [{action: assert, cointype: SKY, query: balance}]`,
+		`steps: [{action: assert, cointype: SKY, query: weather}]`,
+		`steps: [{action: assert, cointype: SKY, query: transaction}]`,
+		`steps: [{action: wait, duration: 1s, typo: 1}]`,
+	} {
+		_, err := Parse([]byte(invalid))
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestParseFault(t *testing.T) {
+	s, err := Parse([]byte(`
+steps:
+  - action: fault
+    duration: 5s
+    fault:
+      route: /api/balance
+      cointype: SKY
+      action: error
+      status_code: 503
+      latency: 250ms
+      times: 2
+`))
+	require.NoError(t, err)
+	rule := s.Steps[0].Fault
+	require.NotNil(t, rule)
+	require.Equal(t, "/api/balance", rule.Route)
+	require.Equal(t, 503, rule.StatusCode)
+	require.Equal(t, 250*time.Millisecond, time.Duration(rule.Latency))
+	require.Equal(t, 2, rule.Times)
+	require.Equal(t, 5*time.Second, s.Steps[0].Duration)
+}
+
+func TestLookup(t *testing.T) {
+	value := map[string]interface{}{
+		"confirmed": map[string]interface{}{"coins": 10},
+		"outputs":   []interface{}{"a", "b"},
+		"empty":     nil,
+	}
+
+	for path, want := range map[string]interface{}{
+		"confirmed.coins": 10,
+		"outputs.#":       2,
+		"outputs.1":       "b",
+		"empty.#":         0,
+	} {
+		got, err := lookup(value, path)
+		require.NoError(t, err, path)
+		require.Equal(t, want, got, path)
+	}
+
+	for _, path := range []string{"missing", "outputs.2", "confirmed.coins.x"} {
+		_, err := lookup(value, path)
+		require.Error(t, err, path)
+	}
+}
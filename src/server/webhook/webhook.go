@@ -0,0 +1,482 @@
+// Package webhook notifies registered callback URLs when a deposit is first
+// seen, when it reaches a number of confirmations and when it is double spent
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff"
+
+	"github.com/modeneis/coind/src/server/model_server"
+)
+
+// Notification events
+const (
+	EventDepositSeen        = "deposit_seen"
+	EventDepositConfirmed   = "deposit_confirmed"
+	EventDepositDoubleSpent = "deposit_double_spent"
+)
+
+// Headers set on every delivery
+const (
+	HeaderEvent     = "X-Coind-Event"
+	HeaderDelivery  = "X-Coind-Delivery"
+	HeaderSignature = "X-Coind-Signature"
+)
+
+// maxDeliveries is how many deliveries are kept for inspection
+const maxDeliveries = 500
+
+// Webhook is a callback URL registered for deposit notifications. CoinType
+// and Address optionally restrict the deposits it is notified about.
+type Webhook struct {
+	ID            string `json:"id"`
+	URL           string `json:"url"`
+	Secret        string `json:"secret,omitempty"`
+	CoinType      string `json:"cointype,omitempty"`
+	Address       string `json:"address,omitempty"`
+	Confirmations int64  `json:"confirmations"`
+}
+
+// Notification is the JSON body POSTed to a webhook
+type Notification struct {
+	Event         string    `json:"event"`
+	WebhookID     string    `json:"webhook_id"`
+	CoinType      string    `json:"cointype"`
+	Address       string    `json:"address"`
+	TxID          string    `json:"txid"`
+	N             uint32    `json:"n"`
+	Amount        int64     `json:"amount"`
+	Height        int64     `json:"height,omitempty"`
+	BlockHash     string    `json:"block_hash,omitempty"`
+	Confirmations int64     `json:"confirmations"`
+	ReplacedBy    string    `json:"replaced_by,omitempty"`
+	Time          time.Time `json:"time"`
+}
+
+// Delivery records the outcome of a notification
+type Delivery struct {
+	ID         string    `json:"id"`
+	WebhookID  string    `json:"webhook_id"`
+	Event      string    `json:"event"`
+	TxID       string    `json:"txid"`
+	Attempts   int       `json:"attempts"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Delivered  bool      `json:"delivered"`
+	Time       time.Time `json:"time"`
+}
+
+// tracked is a deposit waiting for enough confirmations
+type tracked struct {
+	event  model_server.Event
+	height int64
+}
+
+// Manager keeps the registered webhooks and delivers their notifications
+type Manager struct {
+	sync.Mutex
+
+	// Client is used for deliveries
+	Client *http.Client
+	// NewBackOff returns the retry policy of a single delivery
+	NewBackOff func() backoff.BackOff
+
+	hooks      map[string]*Webhook
+	pending    map[string]map[string]*tracked // webhook id -> deposit -> confirmations pending
+	tips       map[string]int64               // coin type -> best height seen
+	deliveries []*Delivery
+	last       map[string]chan struct{} // webhook id -> done when its last delivery finishes
+	sub        *model_server.Subscription
+	quit       chan struct{}
+	wg         sync.WaitGroup
+}
+
+// NewManager creates a Manager that retries each delivery for up to a minute
+func NewManager() *Manager {
+	return &Manager{
+		Client: &http.Client{Timeout: 10 * time.Second},
+		NewBackOff: func() backoff.BackOff {
+			b := backoff.NewExponentialBackOff()
+			b.InitialInterval = 200 * time.Millisecond
+			b.MaxElapsedTime = time.Minute
+			return b
+		},
+		hooks:   make(map[string]*Webhook),
+		pending: make(map[string]map[string]*tracked),
+		tips:    make(map[string]int64),
+		last:    make(map[string]chan struct{}),
+	}
+}
+
+// Start listens to chain events. Only the events published after Start are
+// handled.
+func (m *Manager) Start() {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.quit != nil {
+		return
+	}
+	m.quit = make(chan struct{})
+
+	// skip the history, only deposits seen from now on are notified
+	backlog, sub := model_server.SubscribeEvents(0)
+	lastID := int64(0)
+	if len(backlog) > 0 {
+		lastID = backlog[len(backlog)-1].ID
+	}
+	m.sub = sub
+	go m.run(m.quit, lastID, sub)
+}
+
+// Stop stops listening to chain events and waits for pending deliveries
+func (m *Manager) Stop() {
+	m.Lock()
+	if m.quit == nil {
+		m.Unlock()
+		return
+	}
+	close(m.quit)
+	m.sub.Cancel()
+	m.quit = nil
+	m.Unlock()
+
+	m.wg.Wait()
+}
+
+func (m *Manager) run(quit chan struct{}, lastID int64, sub *model_server.Subscription) {
+	for {
+		e, ok := <-sub.C
+		if !ok {
+			select {
+			case <-quit:
+				return
+			default:
+			}
+
+			// fell behind, resume from the last handled event
+			var backlog []model_server.Event
+			backlog, sub = model_server.SubscribeEvents(lastID)
+			m.Lock()
+			if m.quit != quit {
+				m.Unlock()
+				sub.Cancel()
+				return
+			}
+			m.sub = sub
+			m.Unlock()
+			for _, e := range backlog {
+				m.handle(e)
+				lastID = e.ID
+			}
+			continue
+		}
+
+		m.handle(e)
+		lastID = e.ID
+	}
+}
+
+// Register validates and adds a webhook, returning it with its ID set
+func (m *Manager) Register(hook Webhook) (Webhook, error) {
+	u, err := url.Parse(hook.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Webhook{}, fmt.Errorf("invalid webhook url %q", hook.URL)
+	}
+	if hook.Confirmations < 0 {
+		return Webhook{}, fmt.Errorf("confirmations must not be negative")
+	}
+	if hook.Confirmations == 0 {
+		hook.Confirmations = 1
+	}
+	hook.ID = newID()
+
+	m.Lock()
+	defer m.Unlock()
+
+	m.hooks[hook.ID] = &hook
+	m.pending[hook.ID] = make(map[string]*tracked)
+	return hook, nil
+}
+
+// Remove deletes a webhook
+func (m *Manager) Remove(id string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if _, ok := m.hooks[id]; !ok {
+		return fmt.Errorf("webhook %s not found", id)
+	}
+	delete(m.hooks, id)
+	delete(m.pending, id)
+	delete(m.last, id)
+	return nil
+}
+
+// Webhooks returns every registered webhook, without secrets
+func (m *Manager) Webhooks() []Webhook {
+	m.Lock()
+	defer m.Unlock()
+
+	hooks := make([]Webhook, 0, len(m.hooks))
+	for _, hook := range m.hooks {
+		h := *hook
+		h.Secret = ""
+		hooks = append(hooks, h)
+	}
+	sort.Slice(hooks, func(i, j int) bool {
+		return hooks[i].ID < hooks[j].ID
+	})
+	return hooks
+}
+
+// Deliveries returns the recent deliveries of webhook id, or of every webhook
+// if id is empty, oldest first
+func (m *Manager) Deliveries(id string) []Delivery {
+	m.Lock()
+	defer m.Unlock()
+
+	deliveries := []Delivery{}
+	for _, d := range m.deliveries {
+		if id == "" || d.WebhookID == id {
+			deliveries = append(deliveries, *d)
+		}
+	}
+	return deliveries
+}
+
+// handle updates the tracked deposits with a chain event and sends the
+// notifications that are due
+func (m *Manager) handle(e model_server.Event) {
+	m.Lock()
+	defer m.Unlock()
+
+	switch e.Type {
+	case model_server.EventDepositAccepted:
+		for _, hook := range m.hooks {
+			if !hook.matches(e) {
+				continue
+			}
+			m.pending[hook.ID][depositKey(e)] = &tracked{event: e}
+			m.notify(hook, EventDepositSeen, e, 0)
+		}
+
+	case model_server.EventDepositConfirmed:
+		if e.Height > m.tips[e.CoinType] {
+			m.tips[e.CoinType] = e.Height
+		}
+		for _, hook := range m.hooks {
+			if t, ok := m.pending[hook.ID][depositKey(e)]; ok {
+				t.height = e.Height
+				t.event.Height = e.Height
+				t.event.BlockHash = e.BlockHash
+			}
+		}
+		m.checkConfirmations(e.CoinType)
+
+	case model_server.EventDepositDoubleSpent:
+		for _, hook := range m.hooks {
+			if !hook.matches(e) {
+				continue
+			}
+			delete(m.pending[hook.ID], depositKey(e))
+			m.notify(hook, EventDepositDoubleSpent, e, 0)
+		}
+
+	case model_server.EventBlockConnected:
+		m.tips[e.CoinType] = e.Height
+		m.checkConfirmations(e.CoinType)
+
+	case model_server.EventBlockDisconnected:
+		m.tips[e.CoinType] = e.Height - 1
+		for _, pending := range m.pending {
+			for _, t := range pending {
+				if t.event.CoinType == e.CoinType && t.height >= e.Height {
+					t.height = 0
+				}
+			}
+		}
+
+	case model_server.EventReset:
+		delete(m.tips, e.CoinType)
+		for _, pending := range m.pending {
+			for key, t := range pending {
+				if t.event.CoinType == e.CoinType {
+					delete(pending, key)
+				}
+			}
+		}
+	}
+}
+
+// checkConfirmations notifies the deposits of coinType that reached the
+// confirmations of their webhook. Must be called with the manager locked.
+func (m *Manager) checkConfirmations(coinType string) {
+	tip := m.tips[coinType]
+	for id, pending := range m.pending {
+		hook := m.hooks[id]
+		for key, t := range pending {
+			if t.event.CoinType != coinType || t.height == 0 {
+				continue
+			}
+
+			confirmations := tip - t.height + 1
+			if confirmations >= hook.Confirmations {
+				delete(pending, key)
+				m.notify(hook, EventDepositConfirmed, t.event, confirmations)
+			}
+		}
+	}
+}
+
+// notify queues a notification, deliveries of a webhook are made one at a
+// time in order. Must be called with the manager locked.
+func (m *Manager) notify(hook *Webhook, event string, e model_server.Event, confirmations int64) {
+	n := Notification{
+		Event:         event,
+		WebhookID:     hook.ID,
+		CoinType:      e.CoinType,
+		Address:       e.Address,
+		TxID:          e.TxID,
+		N:             e.N,
+		Amount:        e.Amount,
+		Height:        e.Height,
+		BlockHash:     e.BlockHash,
+		Confirmations: confirmations,
+		ReplacedBy:    e.ReplacedBy,
+		Time:          model_server.Now(),
+	}
+
+	d := &Delivery{
+		ID:        newID(),
+		WebhookID: hook.ID,
+		Event:     event,
+		TxID:      e.TxID,
+		Time:      n.Time,
+	}
+	m.deliveries = append(m.deliveries, d)
+	if len(m.deliveries) > maxDeliveries {
+		m.deliveries = m.deliveries[len(m.deliveries)-maxDeliveries:]
+	}
+
+	prev := m.last[hook.ID]
+	done := make(chan struct{})
+	m.last[hook.ID] = done
+
+	m.wg.Add(1)
+	go func(hook Webhook) {
+		defer m.wg.Done()
+		defer close(done)
+		if prev != nil {
+			<-prev
+		}
+		m.deliver(hook, d, n)
+	}(*hook)
+}
+
+// deliver POSTs a notification until it is accepted or the backoff gives up
+func (m *Manager) deliver(hook Webhook, d *Delivery, n Notification) {
+	body, err := json.Marshal(n)
+	if err != nil {
+		m.finish(d, 0, err)
+		return
+	}
+
+	var status int
+	attempt := func() error {
+		m.Lock()
+		d.Attempts++
+		m.Unlock()
+
+		status, err = m.post(hook, d.ID, n.Event, body)
+		if err != nil {
+			return err
+		}
+		switch {
+		case status >= 200 && status < 300:
+			return nil
+		case status >= 400 && status < 500 && status != http.StatusRequestTimeout && status != http.StatusTooManyRequests:
+			return backoff.Permanent(fmt.Errorf("webhook answered %d", status))
+		default:
+			return fmt.Errorf("webhook answered %d", status)
+		}
+	}
+
+	m.finish(d, status, backoff.Retry(attempt, m.NewBackOff()))
+}
+
+func (m *Manager) post(hook Webhook, deliveryID, event string, body []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, backoff.Permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, event)
+	req.Header.Set(HeaderDelivery, deliveryID)
+	if hook.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(hook.Secret, body))
+	}
+
+	resp, err := m.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(ioutil.Discard, resp.Body)
+	return resp.StatusCode, err
+}
+
+func (m *Manager) finish(d *Delivery, status int, err error) {
+	m.Lock()
+	defer m.Unlock()
+
+	d.StatusCode = status
+	d.Delivered = err == nil
+	if err != nil {
+		d.Error = err.Error()
+	}
+}
+
+// Sign returns the signature header value of body: "sha256=" followed by the
+// hex encoded HMAC-SHA256 of body keyed with secret
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (h *Webhook) matches(e model_server.Event) bool {
+	if h.CoinType != "" && h.CoinType != e.CoinType {
+		return false
+	}
+	if h.Address != "" && h.Address != e.Address {
+		return false
+	}
+	return true
+}
+
+func depositKey(e model_server.Event) string {
+	return fmt.Sprintf("%s:%s:%d:%s", e.CoinType, e.TxID, e.N, e.Address)
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	model_server.RandomIDBytes(b)
+	return hex.EncodeToString(b)
+}
+
+// DefaultManager delivers the webhooks registered through the API
+var DefaultManager = NewManager()
@@ -0,0 +1,118 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modeneis/coind/src/server/model_server"
+)
+
+type receiver struct {
+	sync.Mutex
+	t             *testing.T
+	secret        string
+	failures      int
+	notifications []Notification
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	require.NoError(rc.t, err)
+	require.Equal(rc.t, Sign(rc.secret, body), r.Header.Get(HeaderSignature))
+	require.NotEmpty(rc.t, r.Header.Get(HeaderDelivery))
+
+	rc.Lock()
+	defer rc.Unlock()
+
+	if rc.failures > 0 {
+		rc.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	var n Notification
+	require.NoError(rc.t, json.Unmarshal(body, &n))
+	require.Equal(rc.t, n.Event, r.Header.Get(HeaderEvent))
+	rc.notifications = append(rc.notifications, n)
+}
+
+func (rc *receiver) received() []Notification {
+	rc.Lock()
+	defer rc.Unlock()
+	return append([]Notification(nil), rc.notifications...)
+}
+
+func TestWebhooks(t *testing.T) {
+	rc := &receiver{t: t, secret: "s3cret", failures: 2}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	m := NewManager()
+	m.NewBackOff = func() backoff.BackOff {
+		return backoff.WithMaxRetries(backoff.NewConstantBackOff(time.Millisecond), 5)
+	}
+	m.Start()
+
+	_, err := m.Register(Webhook{URL: "ftp://example.com"})
+	require.Error(t, err)
+
+	hook, err := m.Register(Webhook{URL: server.URL, Secret: rc.secret, CoinType: "SKY", Confirmations: 2})
+	require.NoError(t, err)
+	require.Len(t, m.Webhooks(), 1)
+	require.Empty(t, m.Webhooks()[0].Secret)
+
+	deposit := model_server.Event{CoinType: "SKY", TxID: "tx1", Address: "addr", Amount: 10}
+	other := model_server.Event{CoinType: "WAVES", TxID: "tx2", Address: "addr", Amount: 10}
+
+	for _, e := range []model_server.Event{deposit, other} {
+		e.Type = model_server.EventDepositAccepted
+		model_server.PublishEvent(e)
+	}
+	confirmed := deposit
+	confirmed.Type = model_server.EventDepositConfirmed
+	confirmed.Height = 5
+	model_server.PublishEvent(model_server.Event{Type: model_server.EventBlockConnected, CoinType: "SKY", Height: 5})
+	model_server.PublishEvent(confirmed)
+
+	time.Sleep(100 * time.Millisecond)
+	require.Len(t, rc.received(), 1, "only seen until the second confirmation")
+
+	model_server.PublishEvent(model_server.Event{Type: model_server.EventBlockConnected, CoinType: "SKY", Height: 6})
+
+	spent := deposit
+	spent.Type = model_server.EventDepositDoubleSpent
+	spent.ReplacedBy = "tx3"
+	model_server.PublishEvent(spent)
+
+	for i := 0; i < 100 && len(rc.received()) < 3; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	m.Stop()
+
+	notifications := rc.received()
+	require.Len(t, notifications, 3)
+	require.Equal(t, EventDepositSeen, notifications[0].Event)
+	require.Equal(t, "tx1", notifications[0].TxID)
+	require.Equal(t, EventDepositConfirmed, notifications[1].Event)
+	require.Equal(t, int64(2), notifications[1].Confirmations)
+	require.Equal(t, int64(5), notifications[1].Height)
+	require.Equal(t, EventDepositDoubleSpent, notifications[2].Event)
+	require.Equal(t, "tx1", notifications[2].TxID)
+	require.Equal(t, "tx3", notifications[2].ReplacedBy)
+
+	deliveries := m.Deliveries(hook.ID)
+	require.Len(t, deliveries, 3)
+	require.True(t, deliveries[0].Delivered)
+	require.Equal(t, 3, deliveries[0].Attempts)
+
+	require.NoError(t, m.Remove(hook.ID))
+	require.Error(t, m.Remove(hook.ID))
+}